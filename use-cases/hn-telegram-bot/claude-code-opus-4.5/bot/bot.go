@@ -4,21 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"html"
-	"regexp"
+	"log/slog"
+	"math"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"hn-telegram-bot/config"
+	"hn-telegram-bot/util"
 )
 
 // Sentinel errors for dependency interfaces
 var (
-	ErrSettingNotFound  = errors.New("setting not found")
-	ErrArticleNotFound  = errors.New("article not found")
+	ErrSettingNotFound = errors.New("setting not found")
+	ErrArticleNotFound = errors.New("article not found")
+	ErrNoLikeToUndo    = errors.New("no like to undo")
 )
 
-// MessageSender sends messages to Telegram.
+// MessageSender sends messages to Telegram, with text already escaped for
+// the given parse mode.
 type MessageSender interface {
-	SendMessage(ctx context.Context, chatID int64, text string, html bool) (int64, error)
+	SendMessage(ctx context.Context, chatID int64, text string, mode ParseMode) (int64, error)
+}
+
+// PhotoSender sends a photo from a URL with an HTML caption.
+type PhotoSender interface {
+	SendPhoto(ctx context.Context, chatID int64, photoURL, caption string) (int64, error)
 }
 
 // SettingsStore manages persistent settings.
@@ -27,36 +41,171 @@ type SettingsStore interface {
 	SetSetting(ctx context.Context, key, value string) error
 }
 
-// ScheduleUpdater updates the digest schedule.
+// ScheduleUpdater updates the digest schedule. Reschedule must replace any
+// job scheduled before, so that exactly one digest job stays active.
 type ScheduleUpdater interface {
-	Schedule(timeStr string, fn func()) error
+	Reschedule(timeStr string, fn func()) error
 }
 
 // LikeTracker tracks article likes.
 type LikeTracker interface {
 	IsArticleLiked(ctx context.Context, articleID int64) (bool, error)
-	LikeArticle(ctx context.Context, articleID int64) error
+	LikeArticle(ctx context.Context, articleID, chatID int64) error
 	GetLikeCount(ctx context.Context) (int, error)
+	GetLikeCountSince(ctx context.Context, since time.Time) (int, error)
+}
+
+// DislikeTracker tracks article dislikes.
+type DislikeTracker interface {
+	IsArticleDisliked(ctx context.Context, articleID int64) (bool, error)
+	DislikeArticle(ctx context.Context, articleID int64) error
 }
 
 // TagBooster boosts tag weights.
 type TagBooster interface {
-	BoostTagWeight(ctx context.Context, tag string, boost float64) error
+	// BoostTagWeights boosts each tag by its amount, boosting either all
+	// of them or, on error, none.
+	BoostTagWeights(ctx context.Context, boosts map[string]float64) error
+}
+
+// TagCounter reports how many times a tag has been boosted.
+type TagCounter interface {
+	GetTagCount(ctx context.Context, tag string) (int, error)
+}
+
+// LikeBoostRecorder records a like together with the boosts it applies,
+// in one transaction, so that the like can be undone. An article that is
+// already liked is left as it is.
+type LikeBoostRecorder interface {
+	RecordLike(ctx context.Context, articleID, chatID int64, tagBoosts, domainBoosts map[string]float64) error
+}
+
+// LikeUndoer undoes a chat's last like, taking back its boosts without
+// lowering weights below minWeight. It returns the unliked article, or
+// ErrNoLikeToUndo if the chat has no like to undo, including when its last
+// like was already undone.
+type LikeUndoer interface {
+	UndoLastLike(ctx context.Context, chatID int64, minWeight float64) (*ArticleInfo, error)
+}
+
+// DomainBooster boosts domain weights.
+type DomainBooster interface {
+	BoostDomainWeight(ctx context.Context, domain string, boost float64) error
+}
+
+// DomainStatsProvider provides domain statistics.
+type DomainStatsProvider interface {
+	GetTopDomains(ctx context.Context, limit int) ([]DomainStat, error)
+}
+
+// SourceStatsProvider tallies sent articles by the story source they came from.
+type SourceStatsProvider interface {
+	GetSentCountsBySource(ctx context.Context, chatID int64, within time.Duration) ([]SourceStat, error)
+}
+
+// TagHistoryProvider provides a tag's recorded weights over time.
+type TagHistoryProvider interface {
+	GetTagWeightHistory(ctx context.Context, tag string, since time.Time) ([]TagWeightPoint, error)
+}
+
+// SentArticleLister lists the articles most recently sent to a chat.
+type SentArticleLister interface {
+	RecentSentArticles(ctx context.Context, chatID int64, limit int) ([]SentArticle, error)
 }
 
 // TagStatsProvider provides tag statistics.
 type TagStatsProvider interface {
-	GetTopTags(ctx context.Context, limit int) ([]TagStat, error)
+	GetTagWeightsPaged(ctx context.Context, limit, offset int) ([]TagStat, error)
 }
 
-// ArticleLookup finds articles by message ID.
+// ArticleLookup finds articles by the chat and message they were sent as.
 type ArticleLookup interface {
-	GetArticleByMessageID(ctx context.Context, msgID int64) (*ArticleInfo, error)
+	GetArticleByMessageID(ctx context.Context, chatID, msgID int64) (*ArticleInfo, error)
+}
+
+// ArticleMessageRecorder records further messages sent about an article,
+// so that lookups of either message find the article. An ArticleLookup
+// that implements it has the replies /expand sends recorded.
+type ArticleMessageRecorder interface {
+	AddArticleMessage(ctx context.Context, articleID, chatID, msgID int64) error
+}
+
+// ArticleExpander writes a longer summary of an article than the one it
+// was sent with.
+type ArticleExpander interface {
+	ExpandArticle(ctx context.Context, article *ArticleInfo) (string, error)
+}
+
+// URLTester runs a URL through the scrape and summarize steps of a digest,
+// without storing anything or learning from it.
+type URLTester interface {
+	TestURL(ctx context.Context, url string) (*ArticleForDisplay, error)
 }
 
-// DigestTrigger triggers a digest manually.
+// DigestTrigger starts digest runs, either on demand or from the schedule.
 type DigestTrigger interface {
 	TriggerDigest(ctx context.Context) error
+	TriggerScheduledDigest(ctx context.Context) error
+	// QueueDigest starts an on-demand digest run at the given time.
+	QueueDigest(ctx context.Context, at time.Time) error
+}
+
+// Previewer ranks the next digest's articles without sending them.
+type Previewer interface {
+	Preview(ctx context.Context) ([]PreviewItem, error)
+}
+
+// NextRunProvider reports when the next scheduled digest will run.
+type NextRunProvider interface {
+	NextRun() time.Time
+}
+
+// ArticleStatsProvider provides article delivery statistics.
+type ArticleStatsProvider interface {
+	GetSentArticleCount(ctx context.Context) (int, error)
+}
+
+// PreferenceResetter deletes all learned preferences.
+type PreferenceResetter interface {
+	ClearPreferences(ctx context.Context) (*ResetSummary, error)
+}
+
+// SubscriberLister lists the chats that receive digests.
+type SubscriberLister interface {
+	GetSubscribedChatIDs(ctx context.Context) ([]int64, error)
+}
+
+// SubscriptionStore records whether a chat receives scheduled digests.
+type SubscriptionStore interface {
+	UnsubscribeChat(ctx context.Context, chatID int64, reason string) error
+	ResubscribeChat(ctx context.Context, chatID int64) error
+	IsChatUnsubscribed(ctx context.Context, chatID int64) (bool, error)
+}
+
+// Article formats selectable with /settings format.
+const (
+	FormatText  = "text"
+	FormatPhoto = "photo"
+)
+
+// HandlerConfig holds configured values used when no stored setting overrides them.
+type HandlerConfig struct {
+	ChatID       int64
+	DigestTime   string
+	ArticleCount int
+	Timezone     string
+	Model        string
+	PinnedTags   []string
+	// QuietHoursStart and QuietHoursEnd (HH:MM, in Timezone) bound the
+	// daily period during which /fetch is queued instead of sent. Quiet
+	// hours are off unless both are set.
+	QuietHoursStart string
+	QuietHoursEnd   string
+	// MinTagWeight is the floor /undo won't lower weights below.
+	MinTagWeight float64
+	// AdminChatIDs are the chats allowed to use admin commands such as
+	// /broadcast.
+	AdminChatIDs []int64
 }
 
 // TagStat holds tag statistics.
@@ -65,32 +214,266 @@ type TagStat struct {
 	Weight float64
 }
 
+// TagWeightPoint holds a tag's weight at a point in time.
+type TagWeightPoint struct {
+	Weight     float64
+	RecordedAt time.Time
+}
+
+// DomainStat holds domain statistics.
+type DomainStat struct {
+	Domain string
+	Weight float64
+}
+
+// SourceStat holds the number of articles sent from a story source.
+type SourceStat struct {
+	Source string
+	Count  int
+}
+
+// SentArticle holds an article listed by /articles.
+type SentArticle struct {
+	Title  string
+	URL    string
+	SentAt time.Time
+}
+
+// ResetSummary counts what /reset cleared.
+type ResetSummary struct {
+	Tags     int
+	Domains  int
+	Likes    int
+	Dislikes int
+}
+
+// PreviewItem holds a ranked article shown by /preview.
+type PreviewItem struct {
+	Title   string
+	HNScore int
+	Score   float64
+	Tags    []string
+}
+
 // ArticleInfo holds article data needed for reaction handling.
 type ArticleInfo struct {
-	ID   int64
-	Tags []string
+	ID     int64
+	Title  string
+	URL    string
+	Tags   []string
+	Domain string
 }
 
 // ArticleForDisplay holds article data for message formatting.
 type ArticleForDisplay struct {
-	ID       int64
-	Title    string
-	Summary  string
-	HNScore  int
-	Comments int
-	URL      string
+	ID          int64
+	Title       string
+	Summary     string
+	HNScore     int
+	Comments    int
+	URL         string
+	Explanation string
+	Discussion  string    // One-line take on the HN comments, if any
+	WordCount   int       // Words in the article's page, 0 if unknown
+	PostedAt    time.Time // When the story was posted to HN, if known
+	Source      string    // HN list the story came from, such as "top"
+	By          string    // Username of the submitter, if known
+	// FirstScore is the score the story had when first fetched, at
+	// FirstSeenAt, to show how it has trended since. FirstSeenAt is zero if
+	// it hasn't been fetched before.
+	FirstScore  int
+	FirstSeenAt time.Time
+	// Footer lays out the score, comments and links. Nil means the
+	// default footer.
+	Footer *FooterTemplate
+	// MaxLength caps the formatted message's length in characters by
+	// shortening the summary. Zero means Telegram's limit.
+	MaxLength int
+	// ImageURL, if set, is sent as a photo with the message as its caption.
+	ImageURL string
 }
 
-var timeRegex = regexp.MustCompile(`^([01][0-9]|2[0-3]):([0-5][0-9])$`)
+const (
+	// sourceStatsWindow is how far back /stats sources counts sent articles.
+	sourceStatsWindow = 30 * 24 * time.Hour
+	// statsTagsPerPage is how many tags each page of /stats lists.
+	statsTagsPerPage = 10
+	// recentLikesWindow is the period /stats counts recent likes over.
+	recentLikesWindow = 7 * 24 * time.Hour
+	// tagHistoryWindow is how far back /history shows a tag's weights.
+	tagHistoryWindow = 30 * 24 * time.Hour
+	// defaultArticlesListed and maxArticlesListed bound /articles [n].
+	defaultArticlesListed = 10
+	maxArticlesListed     = 50
+	// maxMessageLength is Telegram's limit on a message's text.
+	maxMessageLength = 4096
+	// maxCaptionLength is Telegram's limit on a photo's caption.
+	maxCaptionLength = 1024
+	// broadcastInterval spaces /broadcast messages to stay well within
+	// Telegram's limit of about 30 messages per second.
+	broadcastInterval = 50 * time.Millisecond
+	// maxBroadcastFailuresListed caps the failed chats /broadcast reports.
+	maxBroadcastFailuresListed = 10
+)
 
 // CommandHandler handles bot commands.
 type CommandHandler struct {
-	sender         MessageSender
-	settings       SettingsStore
-	schedUpdater   ScheduleUpdater
-	likeTracker    LikeTracker
-	tagStats       TagStatsProvider
-	digestTrigger  DigestTrigger
+	sender        MessageSender
+	settings      SettingsStore
+	schedUpdater  ScheduleUpdater
+	likeTracker   LikeTracker
+	tagStats      TagStatsProvider
+	digestTrigger DigestTrigger
+	nextRun       NextRunProvider
+	articleStats  ArticleStatsProvider
+	domainStats   DomainStatsProvider
+	sourceStats   SourceStatsProvider
+	tagHistory    TagHistoryProvider
+	sentArticles  SentArticleLister
+	resetter      PreferenceResetter
+	subscriptions SubscriptionStore
+	subscribers   SubscriberLister
+	articleLookup ArticleLookup
+	expander      ArticleExpander
+	urlTester     URLTester
+	likeUndoer    LikeUndoer
+	previewer     Previewer
+	tagCounter    TagCounter
+	boostCurve    BoostCurve
+	boostAmount   float64
+	config        HandlerConfig
+	now           func() time.Time
+
+	broadcastInterval time.Duration
+}
+
+// HandlerOption configures a CommandHandler.
+type HandlerOption func(*CommandHandler)
+
+// WithDigestTrigger sets the trigger used by /fetch and the scheduled digest.
+func WithDigestTrigger(trigger DigestTrigger) HandlerOption {
+	return func(h *CommandHandler) {
+		h.digestTrigger = trigger
+	}
+}
+
+// WithLikeBoost sets the like boost /simulate applies: amount, scaled by
+// curve and counter as the reaction handler scales it.
+func WithLikeBoost(amount float64, curve BoostCurve, counter TagCounter) HandlerOption {
+	return func(h *CommandHandler) {
+		h.boostAmount = amount
+		h.boostCurve = curve
+		h.tagCounter = counter
+	}
+}
+
+// WithDomainStats sets the source of top domains shown by /stats.
+func WithDomainStats(domainStats DomainStatsProvider) HandlerOption {
+	return func(h *CommandHandler) {
+		h.domainStats = domainStats
+	}
+}
+
+// WithSourceStats sets the source of per-source counts shown by /stats sources.
+func WithSourceStats(sourceStats SourceStatsProvider) HandlerOption {
+	return func(h *CommandHandler) {
+		h.sourceStats = sourceStats
+	}
+}
+
+// WithTagHistory sets the source of tag weight history shown by /history.
+func WithTagHistory(tagHistory TagHistoryProvider) HandlerOption {
+	return func(h *CommandHandler) {
+		h.tagHistory = tagHistory
+	}
+}
+
+// WithSentArticles sets the source of the list shown by /articles.
+func WithSentArticles(sentArticles SentArticleLister) HandlerOption {
+	return func(h *CommandHandler) {
+		h.sentArticles = sentArticles
+	}
+}
+
+// WithPreferenceResetter enables /reset.
+func WithPreferenceResetter(resetter PreferenceResetter) HandlerOption {
+	return func(h *CommandHandler) {
+		h.resetter = resetter
+	}
+}
+
+// WithPreviewer sets the source of the ranked list shown by /preview.
+func WithPreviewer(previewer Previewer) HandlerOption {
+	return func(h *CommandHandler) {
+		h.previewer = previewer
+	}
+}
+
+// WithSubscriptions records subscription changes made with /subscribe and
+// /unsubscribe, so digests also resume after the user unblocks the bot.
+func WithSubscriptions(subscriptions SubscriptionStore) HandlerOption {
+	return func(h *CommandHandler) {
+		h.subscriptions = subscriptions
+	}
+}
+
+// WithSubscribers sets the source of the chats /broadcast sends to.
+func WithSubscribers(subscribers SubscriberLister) HandlerOption {
+	return func(h *CommandHandler) {
+		h.subscribers = subscribers
+	}
+}
+
+// WithExpander enables /expand, which finds the article a message replies
+// to with lookup and sends the longer summary written by expander.
+func WithExpander(lookup ArticleLookup, expander ArticleExpander) HandlerOption {
+	return func(h *CommandHandler) {
+		h.articleLookup = lookup
+		h.expander = expander
+	}
+}
+
+// WithURLTester enables the admin command /test <url>, which replies with
+// the article tester makes of any URL.
+func WithURLTester(tester URLTester) HandlerOption {
+	return func(h *CommandHandler) {
+		h.urlTester = tester
+	}
+}
+
+// WithLikeUndoer enables /undo, which takes back the chat's last like.
+func WithLikeUndoer(undoer LikeUndoer) HandlerOption {
+	return func(h *CommandHandler) {
+		h.likeUndoer = undoer
+	}
+}
+
+// WithStatusProviders sets the sources used by /status.
+func WithStatusProviders(nextRun NextRunProvider, articleStats ArticleStatsProvider) HandlerOption {
+	return func(h *CommandHandler) {
+		h.nextRun = nextRun
+		h.articleStats = articleStats
+	}
+}
+
+// WithClock sets the function used to tell the time, for testing.
+func WithClock(now func() time.Time) HandlerOption {
+	return func(h *CommandHandler) {
+		h.now = now
+	}
+}
+
+// WithConfig sets the configured defaults shown by /settings and /status.
+func WithConfig(cfg HandlerConfig) HandlerOption {
+	return func(h *CommandHandler) {
+		if cfg.DigestTime == "" {
+			cfg.DigestTime = h.config.DigestTime
+		}
+		if cfg.ArticleCount == 0 {
+			cfg.ArticleCount = h.config.ArticleCount
+		}
+		h.config = cfg
+	}
 }
 
 // NewCommandHandler creates a new command handler.
@@ -100,31 +483,87 @@ func NewCommandHandler(
 	schedUpdater ScheduleUpdater,
 	likeTracker LikeTracker,
 	tagStats TagStatsProvider,
+	opts ...HandlerOption,
 ) *CommandHandler {
-	return &CommandHandler{
+	h := &CommandHandler{
 		sender:       sender,
 		settings:     settings,
 		schedUpdater: schedUpdater,
 		likeTracker:  likeTracker,
 		tagStats:     tagStats,
+		config: HandlerConfig{
+			DigestTime:   "09:00",
+			ArticleCount: 30,
+		},
+		now:               time.Now,
+		broadcastInterval: broadcastInterval,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
-// HandleStart handles the /start command.
+// HandleStart handles the /start command. It only shows help; the chat
+// isn't subscribed to digests until /subscribe.
 func (h *CommandHandler) HandleStart(ctx context.Context, chatID int64) error {
-	// Save chat ID
-	if err := h.settings.SetSetting(ctx, "chat_id", strconv.FormatInt(chatID, 10)); err != nil {
-		return fmt.Errorf("save chat_id: %w", err)
-	}
-
 	msg := "Welcome to the HN Digest Bot! 🗞️\n\n" +
+		"Send /subscribe to get a daily digest of Hacker News stories.\n\n" +
 		"Commands:\n" +
+		"/subscribe - Receive the daily digest\n" +
+		"/unsubscribe - Stop the daily digest\n" +
 		"/fetch - Get your personalized digest now\n" +
+		"/preview - See how the next digest would be ranked\n" +
 		"/settings - View or update digest settings\n" +
-		"/stats - View your interests and stats\n\n" +
+		"/stats [page] - View your interests and stats\n" +
+		"/stats sources - See where sent articles came from\n" +
+		"/history <tag> - See how a tag's weight changed over time\n" +
+		"/articles [n] - List the last n articles sent\n" +
+		"/expand - Reply to an article with this for a longer summary\n" +
+		"/lists - Review or clear your pinned tags\n" +
+		"/undo - Take back your last 👍\n" +
+		"/reset - Clear learned preferences and start fresh\n" +
+		"/status - View bot status\n\n" +
 		"React with 👍 to articles you like to train your preferences!"
 
-	_, err := h.sender.SendMessage(ctx, chatID, msg, false)
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// HandleSubscribe handles the /subscribe command, making chatID the chat
+// that receives scheduled digests.
+func (h *CommandHandler) HandleSubscribe(ctx context.Context, chatID int64) error {
+	if err := h.settings.SetSetting(ctx, "chat_id", strconv.FormatInt(chatID, 10)); err != nil {
+		return fmt.Errorf("save chat_id: %w", err)
+	}
+
+	if h.subscriptions != nil {
+		if err := h.subscriptions.ResubscribeChat(ctx, chatID); err != nil {
+			return fmt.Errorf("resubscribe chat: %w", err)
+		}
+	}
+
+	msg := fmt.Sprintf("✅ Subscribed. Your digest arrives daily at %s.\n"+
+		"Send /unsubscribe to stop it.", h.currentDigestTime(ctx))
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// HandleUnsubscribe handles the /unsubscribe command. Scheduled digests stop,
+// but the chat's preferences are kept and every other command still works.
+func (h *CommandHandler) HandleUnsubscribe(ctx context.Context, chatID int64) error {
+	if h.subscriptions == nil {
+		_, err := h.sender.SendMessage(ctx, chatID, "Unsubscribing is not available.", ParseModeNone)
+		return err
+	}
+
+	if err := h.subscriptions.UnsubscribeChat(ctx, chatID, "unsubscribed with /unsubscribe"); err != nil {
+		return fmt.Errorf("unsubscribe chat: %w", err)
+	}
+
+	msg := "🔕 Unsubscribed. Scheduled digests have stopped; /fetch, /stats and your preferences still work.\n" +
+		"Send /subscribe to resume."
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 	return err
 }
 
@@ -150,38 +589,55 @@ func (h *CommandHandler) HandleSettings(ctx context.Context, chatID int64, args
 		return h.updateDigestTime(ctx, chatID, value)
 	case "count":
 		return h.updateArticleCount(ctx, chatID, value)
+	case "explain":
+		return h.updateExplain(ctx, chatID, value)
+	case "format":
+		return h.updateFormat(ctx, chatID, value)
+	case "pin":
+		return h.pinTag(ctx, chatID, value)
+	case "unpin":
+		return h.unpinTag(ctx, chatID, value)
 	default:
 		return h.sendSettingsUsage(ctx, chatID)
 	}
 }
 
 func (h *CommandHandler) displaySettings(ctx context.Context, chatID int64) error {
-	digestTime := "09:00"
-	if t, err := h.settings.GetSetting(ctx, "digest_time"); err == nil {
-		digestTime = t
+	digestTime := h.currentDigestTime(ctx)
+	articleCount := h.currentArticleCount(ctx)
+	explain := "off"
+	if h.explainEnabled(ctx) {
+		explain = "on"
 	}
-
-	articleCount := "30"
-	if c, err := h.settings.GetSetting(ctx, "article_count"); err == nil {
-		articleCount = c
+	pinned := "none"
+	if tags := append(slices.Clone(h.config.PinnedTags), h.storedPinnedTags(ctx)...); len(tags) > 0 {
+		pinned = strings.Join(tags, ", ")
 	}
 
 	msg := fmt.Sprintf("Current Settings:\n\n"+
 		"📅 Digest Time: %s\n"+
-		"📰 Articles per Digest: %s\n\n"+
+		"📰 Articles per Digest: %d\n"+
+		"🔎 Explain Rankings: %s\n"+
+		"🖼 Article Format: %s\n"+
+		"📌 Pinned Tags: %s\n\n"+
 		"Update with:\n"+
 		"/settings time HH:MM\n"+
-		"/settings count N", digestTime, articleCount)
+		"/settings count N\n"+
+		"/settings explain on|off\n"+
+		"/settings format text|photo\n"+
+		"/settings pin|unpin TAG", digestTime, articleCount, explain, h.currentFormat(ctx), pinned)
 
-	_, err := h.sender.SendMessage(ctx, chatID, msg, false)
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 	return err
 }
 
 func (h *CommandHandler) updateDigestTime(ctx context.Context, chatID int64, timeStr string) error {
-	if !timeRegex.MatchString(timeStr) {
-		_, err := h.sender.SendMessage(ctx, chatID, "Invalid time format. Use HH:MM (e.g., 09:00, 18:30)", false)
+	hour, minute, err := config.ParseDigestTime(timeStr)
+	if err != nil {
+		_, err := h.sender.SendMessage(ctx, chatID, "Invalid time format. Use HH:MM (e.g., 09:00, 18:30)", ParseModeNone)
 		return err
 	}
+	timeStr = config.FormatDigestTime(hour, minute)
 
 	if err := h.settings.SetSetting(ctx, "digest_time", timeStr); err != nil {
 		return fmt.Errorf("save digest_time: %w", err)
@@ -189,19 +645,20 @@ func (h *CommandHandler) updateDigestTime(ctx context.Context, chatID int64, tim
 
 	// Update scheduler if available
 	if h.schedUpdater != nil {
-		// Note: The actual digest function is set up in main
-		h.schedUpdater.Schedule(timeStr, func() {})
+		if err := h.schedUpdater.Reschedule(timeStr, h.runScheduledDigest); err != nil {
+			return fmt.Errorf("reschedule digest: %w", err)
+		}
 	}
 
 	msg := fmt.Sprintf("✅ Digest time updated to %s", timeStr)
-	_, err := h.sender.SendMessage(ctx, chatID, msg, false)
+	_, err = h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 	return err
 }
 
 func (h *CommandHandler) updateArticleCount(ctx context.Context, chatID int64, countStr string) error {
 	count, err := strconv.Atoi(countStr)
 	if err != nil || count < 1 || count > 100 {
-		_, err := h.sender.SendMessage(ctx, chatID, "Invalid count. Must be a number between 1 and 100.", false)
+		_, err := h.sender.SendMessage(ctx, chatID, "Invalid count. Must be a number between 1 and 100.", ParseModeNone)
 		return err
 	}
 
@@ -210,21 +667,164 @@ func (h *CommandHandler) updateArticleCount(ctx context.Context, chatID int64, c
 	}
 
 	msg := fmt.Sprintf("✅ Article count updated to %d", count)
-	_, err = h.sender.SendMessage(ctx, chatID, msg, false)
+	_, err = h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+func (h *CommandHandler) updateExplain(ctx context.Context, chatID int64, value string) error {
+	value = strings.ToLower(value)
+	if value != "on" && value != "off" {
+		_, err := h.sender.SendMessage(ctx, chatID, "Invalid value. Use /settings explain on or /settings explain off.", ParseModeNone)
+		return err
+	}
+
+	if err := h.settings.SetSetting(ctx, "explain", value); err != nil {
+		return fmt.Errorf("save explain: %w", err)
+	}
+
+	msg := fmt.Sprintf("✅ Ranking explanations turned %s", value)
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+func (h *CommandHandler) explainEnabled(ctx context.Context) bool {
+	v, err := h.settings.GetSetting(ctx, "explain")
+	return err == nil && v == "on"
+}
+
+func (h *CommandHandler) updateFormat(ctx context.Context, chatID int64, value string) error {
+	value = strings.ToLower(value)
+	if value != FormatText && value != FormatPhoto {
+		_, err := h.sender.SendMessage(ctx, chatID, "Invalid value. Use /settings format text or /settings format photo.", ParseModeNone)
+		return err
+	}
+
+	if err := h.settings.SetSetting(ctx, "format", value); err != nil {
+		return fmt.Errorf("save format: %w", err)
+	}
+
+	msg := fmt.Sprintf("✅ Articles will be sent as %s", value)
+	if value == FormatPhoto {
+		msg += " where the page has an image"
+	}
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 	return err
 }
 
+func (h *CommandHandler) pinTag(ctx context.Context, chatID int64, tag string) error {
+	tag = strings.ToLower(tag)
+	if tag == "" || strings.Contains(tag, ",") {
+		_, err := h.sender.SendMessage(ctx, chatID, "Invalid tag. Use /settings pin TAG, e.g. /settings pin security.", ParseModeNone)
+		return err
+	}
+
+	pinned := h.storedPinnedTags(ctx)
+	if !slices.Contains(pinned, tag) && !slices.Contains(h.config.PinnedTags, tag) {
+		pinned = append(pinned, tag)
+		if err := h.settings.SetSetting(ctx, "pinned_tags", strings.Join(pinned, ",")); err != nil {
+			return fmt.Errorf("save pinned_tags: %w", err)
+		}
+	}
+
+	msg := fmt.Sprintf("📌 Pinned %s: each digest will include an article tagged %s when there is one", tag, tag)
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+func (h *CommandHandler) unpinTag(ctx context.Context, chatID int64, tag string) error {
+	tag = strings.ToLower(tag)
+	pinned := h.storedPinnedTags(ctx)
+
+	var msg string
+	switch {
+	case slices.Contains(pinned, tag):
+		pinned = slices.DeleteFunc(pinned, func(t string) bool { return t == tag })
+		if err := h.settings.SetSetting(ctx, "pinned_tags", strings.Join(pinned, ",")); err != nil {
+			return fmt.Errorf("save pinned_tags: %w", err)
+		}
+		msg = fmt.Sprintf("✅ Unpinned %s", tag)
+	case slices.Contains(h.config.PinnedTags, tag):
+		msg = fmt.Sprintf("%s is pinned in the config file, so it can't be unpinned here.", tag)
+	default:
+		msg = fmt.Sprintf("%s isn't pinned.", tag)
+	}
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// storedPinnedTags returns the tags pinned with /settings pin.
+func (h *CommandHandler) storedPinnedTags(ctx context.Context) []string {
+	return h.storedList(ctx, pinnedList)
+}
+
+// currentFormat returns the stored article format, defaulting to text.
+func (h *CommandHandler) currentFormat(ctx context.Context) string {
+	if v, err := h.settings.GetSetting(ctx, "format"); err == nil && v == FormatPhoto {
+		return FormatPhoto
+	}
+	return FormatText
+}
+
 func (h *CommandHandler) sendSettingsUsage(ctx context.Context, chatID int64) error {
 	msg := "Usage:\n" +
 		"/settings - Show current settings\n" +
 		"/settings time HH:MM - Update digest time\n" +
-		"/settings count N - Update article count (1-100)"
-	_, err := h.sender.SendMessage(ctx, chatID, msg, false)
+		"/settings count N - Update article count (1-100)\n" +
+		"/settings explain on|off - Show why each article was picked\n" +
+		"/settings format text|photo - Send articles as text or as photos with captions\n" +
+		"/settings pin|unpin TAG - Always include an article with this tag"
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 	return err
 }
 
-// HandleStats handles the /stats command.
-func (h *CommandHandler) HandleStats(ctx context.Context, chatID int64) error {
+// currentDigestTime returns the stored digest time, or the configured default.
+func (h *CommandHandler) currentDigestTime(ctx context.Context) string {
+	if t, err := h.settings.GetSetting(ctx, "digest_time"); err == nil {
+		return t
+	}
+	return h.config.DigestTime
+}
+
+// currentArticleCount returns the stored article count, or the configured default.
+func (h *CommandHandler) currentArticleCount(ctx context.Context) int {
+	if c, err := h.settings.GetSetting(ctx, "article_count"); err == nil {
+		if n, err := strconv.Atoi(c); err == nil {
+			return n
+		}
+	}
+	return h.config.ArticleCount
+}
+
+// currentChatID returns the stored chat ID, or the configured default.
+func (h *CommandHandler) currentChatID(ctx context.Context) int64 {
+	if c, err := h.settings.GetSetting(ctx, "chat_id"); err == nil {
+		if id, err := strconv.ParseInt(c, 10, 64); err == nil {
+			return id
+		}
+	}
+	return h.config.ChatID
+}
+
+func (h *CommandHandler) runScheduledDigest() {
+	if h.digestTrigger != nil {
+		h.digestTrigger.TriggerScheduledDigest(context.Background())
+	}
+}
+
+// HandleStats handles the /stats [page] command. The first page shows the
+// top tags along with top domains and like counts; later pages list the
+// following tags.
+func (h *CommandHandler) HandleStats(ctx context.Context, chatID int64, args string) error {
+	page := 1
+	if arg := strings.TrimSpace(args); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			_, err := h.sender.SendMessage(ctx, chatID, "Usage: /stats [page]\nExample: /stats 2", ParseModeNone)
+			return err
+		}
+		page = n
+	}
+
 	likeCount, err := h.likeTracker.GetLikeCount(ctx)
 	if err != nil {
 		return fmt.Errorf("get like count: %w", err)
@@ -232,42 +832,604 @@ func (h *CommandHandler) HandleStats(ctx context.Context, chatID int64) error {
 
 	if likeCount == 0 {
 		msg := "No likes yet! React with 👍 to articles to train your preferences."
-		_, err := h.sender.SendMessage(ctx, chatID, msg, false)
+		_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
 		return err
 	}
 
-	topTags, err := h.tagStats.GetTopTags(ctx, 10)
+	// Fetch one tag past the page to tell whether there is a next one
+	offset := (page - 1) * statsTagsPerPage
+	tags, err := h.tagStats.GetTagWeightsPaged(ctx, statsTagsPerPage+1, offset)
 	if err != nil {
 		return fmt.Errorf("get top tags: %w", err)
 	}
+	hasNext := len(tags) > statsTagsPerPage
+	tags = tags[:min(len(tags), statsTagsPerPage)]
+
+	if page > 1 && len(tags) == 0 {
+		msg := fmt.Sprintf("No tags on page %d. Send /stats for the first page.", page)
+		_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+		return err
+	}
 
 	var sb strings.Builder
-	sb.WriteString("📊 Your Interests:\n\n")
+	if page == 1 {
+		sb.WriteString("📊 Your Interests:\n\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("📊 Your Interests (page %d):\n\n", page))
+	}
+
+	for i, tag := range tags {
+		sb.WriteString(fmt.Sprintf("%d. %s (%.2f)\n", offset+i+1, tag.Tag, tag.Weight))
+	}
+
+	var nav []string
+	if page > 1 {
+		nav = append(nav, fmt.Sprintf("◀️ /stats %d", page-1))
+	}
+	if hasNext {
+		nav = append(nav, fmt.Sprintf("/stats %d ▶️", page+1))
+	}
+	if len(nav) > 0 {
+		sb.WriteString("\n" + strings.Join(nav, " | ") + "\n")
+	}
+
+	if page > 1 {
+		_, err = h.sender.SendMessage(ctx, chatID, strings.TrimSuffix(sb.String(), "\n"), ParseModeNone)
+		return err
+	}
+
+	if h.domainStats != nil {
+		topDomains, err := h.domainStats.GetTopDomains(ctx, 5)
+		if err != nil {
+			return fmt.Errorf("get top domains: %w", err)
+		}
+		if len(topDomains) > 0 {
+			sb.WriteString("\n🌐 Top Domains:\n\n")
+			for i, d := range topDomains {
+				sb.WriteString(fmt.Sprintf("%d. %s (%.2f)\n", i+1, d.Domain, d.Weight))
+			}
+		}
+	}
 
-	for i, tag := range topTags {
-		sb.WriteString(fmt.Sprintf("%d. %s (%.2f)\n", i+1, tag.Tag, tag.Weight))
+	recentLikes, err := h.likeTracker.GetLikeCountSince(ctx, h.now().Add(-recentLikesWindow))
+	if err != nil {
+		return fmt.Errorf("get recent like count: %w", err)
 	}
 
 	sb.WriteString(fmt.Sprintf("\nTotal articles liked: %d", likeCount))
+	sb.WriteString(fmt.Sprintf("\nLikes this week: %d", recentLikes))
+
+	_, err = h.sender.SendMessage(ctx, chatID, sb.String(), ParseModeNone)
+	return err
+}
+
+// HandleSourceStats handles the /stats sources command, showing how many
+// articles were sent from each story source over the last sourceStatsWindow.
+func (h *CommandHandler) HandleSourceStats(ctx context.Context, chatID int64) error {
+	if h.sourceStats == nil {
+		return nil
+	}
+
+	counts, err := h.sourceStats.GetSentCountsBySource(ctx, chatID, sourceStatsWindow)
+	if err != nil {
+		return fmt.Errorf("get source counts: %w", err)
+	}
+
+	days := int(sourceStatsWindow.Hours() / 24)
+	if len(counts) == 0 {
+		msg := fmt.Sprintf("No articles sent in the last %d days.", days)
+		_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📰 Sources (last %d days):\n\n", days))
+
+	total := 0
+	for _, c := range counts {
+		sb.WriteString(fmt.Sprintf("%s: %d\n", c.Source, c.Count))
+		total += c.Count
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal articles sent: %d", total))
+
+	_, err = h.sender.SendMessage(ctx, chatID, sb.String(), ParseModeNone)
+	return err
+}
+
+// HandleHistory handles the /history command, listing the weights recorded
+// for a tag after each digest over the last tagHistoryWindow.
+func (h *CommandHandler) HandleHistory(ctx context.Context, chatID int64, args string) error {
+	if h.tagHistory == nil {
+		return nil
+	}
+
+	tag := strings.TrimSpace(args)
+	if tag == "" {
+		_, err := h.sender.SendMessage(ctx, chatID, "Usage: /history <tag>\nExample: /history go", ParseModeNone)
+		return err
+	}
+
+	points, err := h.tagHistory.GetTagWeightHistory(ctx, tag, h.now().Add(-tagHistoryWindow))
+	if err != nil {
+		return fmt.Errorf("get tag history: %w", err)
+	}
+
+	days := int(tagHistoryWindow.Hours() / 24)
+	if len(points) == 0 {
+		msg := fmt.Sprintf("No history for %q in the last %d days.", tag, days)
+		_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📈 %s (last %d days):\n\n", tag, days))
+	for _, p := range points {
+		sb.WriteString(fmt.Sprintf("%s  %.2f\n", p.RecordedAt.Format("2006-01-02 15:04"), p.Weight))
+	}
 
-	_, err = h.sender.SendMessage(ctx, chatID, sb.String(), false)
+	_, err = h.sender.SendMessage(ctx, chatID, strings.TrimSuffix(sb.String(), "\n"), ParseModeNone)
 	return err
 }
 
-// HandleFetch handles the /fetch command.
+// HandleArticles handles the /articles [n] command, listing the last n
+// articles sent to the chat with links. Entries that would push the message
+// past Telegram's length limit are left out.
+func (h *CommandHandler) HandleArticles(ctx context.Context, chatID int64, args string) error {
+	if h.sentArticles == nil {
+		return nil
+	}
+
+	limit := defaultArticlesListed
+	if arg := strings.TrimSpace(args); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			_, err := h.sender.SendMessage(ctx, chatID, "Usage: /articles [n]\nExample: /articles 5", ParseModeNone)
+			return err
+		}
+		limit = min(n, maxArticlesListed)
+	}
+
+	articles, err := h.sentArticles.RecentSentArticles(ctx, chatID, limit)
+	if err != nil {
+		return fmt.Errorf("get recent articles: %w", err)
+	}
+
+	if len(articles) == 0 {
+		_, err := h.sender.SendMessage(ctx, chatID, "No articles sent yet.", ParseModeNone)
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📚 Last %d articles:\n", len(articles)))
+	for i, a := range articles {
+		entry := fmt.Sprintf("\n%d. <a href=\"%s\">%s</a>\n   %s",
+			i+1, ParseModeHTML.Escape(a.URL), ParseModeHTML.Escape(a.Title), a.SentAt.Format("2006-01-02 15:04"))
+		more := fmt.Sprintf("\n\n…and %d more", len(articles)-i)
+		if utf8.RuneCountInString(sb.String()+entry+more) > maxMessageLength {
+			sb.WriteString(more)
+			break
+		}
+		sb.WriteString(entry)
+	}
+
+	_, err = h.sender.SendMessage(ctx, chatID, sb.String(), ParseModeHTML)
+	return err
+}
+
+// HandleReset handles the /reset command. A bare /reset only explains what
+// will be cleared; the reset happens on "/reset confirm".
+func (h *CommandHandler) HandleReset(ctx context.Context, chatID int64, args string) error {
+	if h.resetter == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(args) != "confirm" {
+		msg := "⚠️ This clears all learned preferences: tag and domain weights, likes and dislikes.\n" +
+			"Sent article history and settings are kept.\n\n" +
+			"Send /reset confirm to continue."
+		_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+		return err
+	}
+
+	cleared, err := h.resetter.ClearPreferences(ctx)
+	if err != nil {
+		return fmt.Errorf("clear preferences: %w", err)
+	}
+
+	msg := fmt.Sprintf("🧹 Preferences reset.\n\n"+
+		"Tags cleared: %d\n"+
+		"Domains cleared: %d\n"+
+		"Likes cleared: %d\n"+
+		"Dislikes cleared: %d",
+		cleared.Tags, cleared.Domains, cleared.Likes, cleared.Dislikes)
+	_, err = h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// HandleBroadcast handles the admin-only /broadcast command, sending text
+// to every subscribed chat. A chat that can't be reached doesn't stop the
+// broadcast; the admin is told which chats failed.
+func (h *CommandHandler) HandleBroadcast(ctx context.Context, chatID int64, args string) error {
+	if !slices.Contains(h.config.AdminChatIDs, chatID) {
+		_, err := h.sender.SendMessage(ctx, chatID, "⛔ You are not authorized to use /broadcast.", ParseModeNone)
+		return err
+	}
+	if h.subscribers == nil {
+		return nil
+	}
+
+	text := strings.TrimSpace(args)
+	if text == "" {
+		_, err := h.sender.SendMessage(ctx, chatID, "Usage: /broadcast <message>", ParseModeNone)
+		return err
+	}
+
+	chatIDs, err := h.subscribers.GetSubscribedChatIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("get subscribers: %w", err)
+	}
+
+	var failed []string
+	for i, id := range chatIDs {
+		if i > 0 {
+			if err := util.Sleep(ctx, h.broadcastInterval); err != nil {
+				return err
+			}
+		}
+		if _, err := h.sender.SendMessage(ctx, id, text, ParseModeNone); err != nil {
+			failed = append(failed, fmt.Sprintf("%d (%v)", id, err))
+		}
+	}
+
+	msg := fmt.Sprintf("📣 Broadcast sent to %d of %d chats.", len(chatIDs)-len(failed), len(chatIDs))
+	if len(failed) > maxBroadcastFailuresListed {
+		more := len(failed) - maxBroadcastFailuresListed
+		failed = append(failed[:maxBroadcastFailuresListed], fmt.Sprintf("…and %d more", more))
+	}
+	if len(failed) > 0 {
+		msg += "\nFailed: " + strings.Join(failed, ", ")
+	}
+	_, err = h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// HandleFetch handles the /fetch command. During quiet hours the digest
+// is queued until they end instead.
 func (h *CommandHandler) HandleFetch(ctx context.Context, chatID int64) error {
-	if h.digestTrigger != nil {
+	if h.digestTrigger == nil {
+		return nil
+	}
+
+	until, quiet := h.quietUntil(h.now())
+	if !quiet {
 		return h.digestTrigger.TriggerDigest(ctx)
 	}
+	if err := h.digestTrigger.QueueDigest(ctx, until); err != nil {
+		return fmt.Errorf("queue digest: %w", err)
+	}
+	msg := fmt.Sprintf("🌙 Quiet hours: digest queued until %s.", until.Format("15:04"))
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// quietUntil reports whether t falls within quiet hours and, if so, when
+// they end. Quiet hours that start later in the day than they end span
+// midnight.
+func (h *CommandHandler) quietUntil(t time.Time) (time.Time, bool) {
+	if h.config.QuietHoursStart == "" || h.config.QuietHoursEnd == "" {
+		return time.Time{}, false
+	}
+	startHour, startMinute, err := config.ParseDigestTime(h.config.QuietHoursStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	endHour, endMinute, err := config.ParseDigestTime(h.config.QuietHoursEnd)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(h.config.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	start := startHour*60 + startMinute
+	end := endHour*60 + endMinute
+
+	var quiet bool
+	if start <= end {
+		quiet = now >= start && now < end
+	} else {
+		quiet = now >= start || now < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(t.Year(), t.Month(), t.Day(), endHour, endMinute, 0, 0, loc)
+	if !until.After(t) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+// HandleUndo handles the /undo command, removing the chat's most recent
+// like and the boosts it gave. With nothing to undo, it says so.
+func (h *CommandHandler) HandleUndo(ctx context.Context, chatID int64) error {
+	if h.likeUndoer == nil {
+		return nil
+	}
+
+	article, err := h.likeUndoer.UndoLastLike(ctx, chatID, h.config.MinTagWeight)
+	if errors.Is(err, ErrNoLikeToUndo) {
+		_, err := h.sender.SendMessage(ctx, chatID, "Nothing to undo: your last 👍 was already taken back, or you have none.", ParseModeNone)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("undo last like: %w", err)
+	}
+
+	msg := fmt.Sprintf("↩️ Removed your like of \"%s\" and its boosts.", article.Title)
+	_, err = h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// HandleExpand handles the /expand command, sent as a reply to the article
+// message with ID replyToID. It replies with a longer summary of that
+// article, which reactions and /expand then treat like the article message.
+func (h *CommandHandler) HandleExpand(ctx context.Context, chatID, replyToID int64) error {
+	if h.expander == nil {
+		return nil
+	}
+	if replyToID == 0 {
+		_, err := h.sender.SendMessage(ctx, chatID, "Reply to an article with /expand to get a longer summary.", ParseModeNone)
+		return err
+	}
+
+	article, err := h.articleLookup.GetArticleByMessageID(ctx, chatID, replyToID)
+	if errors.Is(err, ErrArticleNotFound) {
+		_, err := h.sender.SendMessage(ctx, chatID, "That message isn't an article. Reply to an article from a digest with /expand.", ParseModeNone)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("lookup article: %w", err)
+	}
+
+	summary, err := h.expander.ExpandArticle(ctx, article)
+	if err != nil {
+		return fmt.Errorf("expand article %d: %w", article.ID, err)
+	}
+
+	head := fmt.Sprintf("📖 <b>%s</b>\n\n", ParseModeHTML.Escape(article.Title))
+	tail := fmt.Sprintf("\n\n<a href=\"%s\">Article</a>", ParseModeHTML.Escape(article.URL))
+	budget := maxMessageLength - utf8.RuneCountInString(head+tail)
+	msgID, err := h.sender.SendMessage(ctx, chatID, head+truncateEscaped(summary, budget)+tail, ParseModeHTML)
+	if err != nil {
+		return err
+	}
+	if recorder, ok := h.articleLookup.(ArticleMessageRecorder); ok {
+		if err := recorder.AddArticleMessage(ctx, article.ID, chatID, msgID); err != nil {
+			slog.Warn("failed to record expanded summary message", "id", article.ID, "chat_id", chatID, "error", err)
+		}
+	}
 	return nil
 }
 
+// HandleTest handles the admin command /test <url>, replying with the
+// article the digest would send for the URL. Failures are reported in the
+// reply, since the command exists to debug them.
+func (h *CommandHandler) HandleTest(ctx context.Context, chatID int64, args string) error {
+	if !slices.Contains(h.config.AdminChatIDs, chatID) {
+		_, err := h.sender.SendMessage(ctx, chatID, "⛔ You are not authorized to use /test.", ParseModeNone)
+		return err
+	}
+	if h.urlTester == nil {
+		return nil
+	}
+
+	rawURL := strings.TrimSpace(args)
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		_, err := h.sender.SendMessage(ctx, chatID, "Usage: /test <url>\nExample: /test https://example.com/post", ParseModeNone)
+		return err
+	}
+
+	article, err := h.urlTester.TestURL(ctx, rawURL)
+	if err != nil {
+		_, err := h.sender.SendMessage(ctx, chatID, fmt.Sprintf("❌ Test of %s failed: %v", rawURL, err), ParseModeNone)
+		return err
+	}
+
+	const head = "🧪 Test result:\n\n"
+	article.MaxLength = maxMessageLength - utf8.RuneCountInString(head)
+	_, err = h.sender.SendMessage(ctx, chatID, head+FormatArticleMessage(article), ParseModeHTML)
+	return err
+}
+
+// HandlePreview handles the /preview command. It replies with a single
+// ranked list instead of sending each article.
+func (h *CommandHandler) HandlePreview(ctx context.Context, chatID int64) error {
+	if h.previewer == nil {
+		return nil
+	}
+
+	items, err := h.previewer.Preview(ctx)
+	if err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+
+	if len(items) == 0 {
+		_, err := h.sender.SendMessage(ctx, chatID, "🔍 Preview: no new articles to rank right now.", ParseModeNone)
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔍 Preview (nothing sent yet)\n")
+	sb.WriteString("Ranked on HN score and tags from previously summarized articles.\n\n")
+
+	for i, item := range items {
+		sb.WriteString(fmt.Sprintf("%d. %s\n   %.2f · %d points", i+1, item.Title, item.Score, item.HNScore))
+		if len(item.Tags) > 0 {
+			sb.WriteString(" · " + strings.Join(item.Tags, ", "))
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err = h.sender.SendMessage(ctx, chatID, strings.TrimSuffix(sb.String(), "\n"), ParseModeNone)
+	return err
+}
+
+// HandleStatus handles the /status command.
+func (h *CommandHandler) HandleStatus(ctx context.Context, chatID int64) error {
+	var sb strings.Builder
+	sb.WriteString("🔧 Bot Status:\n\n")
+
+	configuredChat := h.currentChatID(ctx)
+	if configuredChat != 0 {
+		sb.WriteString(fmt.Sprintf("💬 Chat ID: %d\n", configuredChat))
+	} else {
+		sb.WriteString("💬 Chat ID: not set\n")
+	}
+
+	// Digests go to the configured chat, so its subscription is the one
+	// that matters
+	if h.subscriptions != nil && configuredChat != 0 {
+		unsubscribed, err := h.subscriptions.IsChatUnsubscribed(ctx, configuredChat)
+		if err != nil {
+			return fmt.Errorf("check subscription: %w", err)
+		}
+		if unsubscribed {
+			sb.WriteString("🔕 Scheduled Digests: paused (unsubscribed, /subscribe to resume)\n")
+		} else {
+			sb.WriteString("🔔 Scheduled Digests: active\n")
+		}
+	}
+
+	timezone := h.config.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	sb.WriteString(fmt.Sprintf("📅 Digest Time: %s (%s)\n", h.currentDigestTime(ctx), timezone))
+	sb.WriteString(fmt.Sprintf("📰 Articles per Digest: %d\n", h.currentArticleCount(ctx)))
+
+	if h.nextRun != nil {
+		if next := h.nextRun.NextRun(); !next.IsZero() {
+			sb.WriteString(fmt.Sprintf("⏰ Next Run: %s\n", next.Format("2006-01-02 15:04 MST")))
+		} else {
+			sb.WriteString("⏰ Next Run: not scheduled\n")
+		}
+	}
+
+	if h.articleStats != nil {
+		sent, err := h.articleStats.GetSentArticleCount(ctx)
+		if err != nil {
+			return fmt.Errorf("get sent article count: %w", err)
+		}
+		sb.WriteString(fmt.Sprintf("📤 Articles Sent: %d\n", sent))
+	}
+
+	if h.likeTracker != nil {
+		likes, err := h.likeTracker.GetLikeCount(ctx)
+		if err != nil {
+			return fmt.Errorf("get like count: %w", err)
+		}
+		sb.WriteString(fmt.Sprintf("👍 Articles Liked: %d\n", likes))
+	}
+
+	if h.config.Model != "" {
+		sb.WriteString(fmt.Sprintf("🤖 Summarizer Model: %s\n", h.config.Model))
+	}
+
+	_, err := h.sender.SendMessage(ctx, chatID, strings.TrimSuffix(sb.String(), "\n"), ParseModeNone)
+	return err
+}
+
 // ReactionHandler handles message reactions.
 type ReactionHandler struct {
-	articleLookup ArticleLookup
-	likeTracker   LikeTracker
-	tagBooster    TagBooster
-	boostAmount   float64
+	articleLookup  ArticleLookup
+	likeTracker    LikeTracker
+	tagBooster     TagBooster
+	boostAmount    float64
+	likeEmojis     map[string]bool
+	dislikeEmojis  map[string]bool
+	dislikeTracker DislikeTracker
+	domainBooster  DomainBooster
+	boostCurve     BoostCurve
+	tagCounter     TagCounter
+	likeRecorder   LikeBoostRecorder
+}
+
+// BoostCurve determines how a like's tag boost shrinks as the tag gathers
+// more likes.
+type BoostCurve string
+
+const (
+	// BoostLinear boosts by the same amount on every like.
+	BoostLinear BoostCurve = "linear"
+	// BoostLog divides the boost by 1+ln(1+n) after n earlier boosts.
+	BoostLog BoostCurve = "log"
+	// BoostSqrt divides the boost by sqrt(1+n) after n earlier boosts.
+	BoostSqrt BoostCurve = "sqrt"
+)
+
+// Scale returns the boost for a tag that has been boosted count times
+// before. Unknown curves behave as linear.
+func (c BoostCurve) Scale(boost float64, count int) float64 {
+	n := float64(max(count, 0))
+	switch c {
+	case BoostLog:
+		return boost / (1 + math.Log1p(n))
+	case BoostSqrt:
+		return boost / math.Sqrt(1+n)
+	default:
+		return boost
+	}
+}
+
+// ReactionOption configures a ReactionHandler.
+type ReactionOption func(*ReactionHandler)
+
+// WithLikeEmojis sets the emojis that count as a like.
+func WithLikeEmojis(emojis []string) ReactionOption {
+	return func(h *ReactionHandler) {
+		h.likeEmojis = emojiSet(emojis)
+	}
+}
+
+// WithDislikeEmojis sets the emojis that count as a dislike. A dislike
+// lowers the weights of the article's tags by the boost amount.
+func WithDislikeEmojis(tracker DislikeTracker, emojis []string) ReactionOption {
+	return func(h *ReactionHandler) {
+		h.dislikeTracker = tracker
+		h.dislikeEmojis = emojiSet(emojis)
+	}
+}
+
+// WithDomainBooster enables domain learning: a like also boosts the
+// weight of the article's domain.
+func WithDomainBooster(booster DomainBooster) ReactionOption {
+	return func(h *ReactionHandler) {
+		h.domainBooster = booster
+	}
+}
+
+// WithBoostCurve gives frequently liked tags diminishing boosts, so a few
+// tags can't come to dominate the ranking. The counter supplies each tag's
+// current boost count. Dislikes are not scaled.
+func WithBoostCurve(curve BoostCurve, counter TagCounter) ReactionOption {
+	return func(h *ReactionHandler) {
+		h.boostCurve = curve
+		h.tagCounter = counter
+	}
+}
+
+// WithLikeRecorder records each like and applies its boosts through the
+// recorder instead of the like tracker and boosters, so that /undo can take
+// them back.
+func WithLikeRecorder(recorder LikeBoostRecorder) ReactionOption {
+	return func(h *ReactionHandler) {
+		h.likeRecorder = recorder
+	}
 }
 
 // NewReactionHandler creates a new reaction handler.
@@ -276,29 +1438,39 @@ func NewReactionHandler(
 	likeTracker LikeTracker,
 	tagBooster TagBooster,
 	boostAmount float64,
+	opts ...ReactionOption,
 ) *ReactionHandler {
-	return &ReactionHandler{
+	h := &ReactionHandler{
 		articleLookup: articleLookup,
 		likeTracker:   likeTracker,
 		tagBooster:    tagBooster,
 		boostAmount:   boostAmount,
+		likeEmojis:    emojiSet([]string{"👍"}),
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // HandleReaction processes a reaction event.
-func (h *ReactionHandler) HandleReaction(ctx context.Context, messageID int64, emoji string) error {
-	// Only process thumbs-up
-	if emoji != "👍" {
+func (h *ReactionHandler) HandleReaction(ctx context.Context, chatID, messageID int64, emoji string) error {
+	emoji = normalizeEmoji(emoji)
+
+	switch {
+	case h.likeEmojis[emoji]:
+		return h.handleLike(ctx, chatID, messageID)
+	case h.dislikeEmojis[emoji] && h.dislikeTracker != nil:
+		return h.handleDislike(ctx, chatID, messageID)
+	default:
 		return nil
 	}
+}
 
-	// Look up article
-	article, err := h.articleLookup.GetArticleByMessageID(ctx, messageID)
-	if err != nil {
-		if errors.Is(err, ErrArticleNotFound) {
-			return nil // Silently ignore reactions to non-article messages
-		}
-		return fmt.Errorf("lookup article: %w", err)
+func (h *ReactionHandler) handleLike(ctx context.Context, chatID, messageID int64) error {
+	article, err := h.lookupArticle(ctx, chatID, messageID)
+	if err != nil || article == nil {
+		return err
 	}
 
 	// Check if already liked (idempotent)
@@ -310,32 +1482,195 @@ func (h *ReactionHandler) HandleReaction(ctx context.Context, messageID int64, e
 		return nil // Already liked, no-op
 	}
 
-	// Record the like
-	if err := h.likeTracker.LikeArticle(ctx, article.ID); err != nil {
+	tagBoosts, err := h.likedTagBoosts(ctx, article.Tags)
+	if err != nil {
+		return err
+	}
+	domainBoosts := make(map[string]float64)
+	if h.domainBooster != nil && article.Domain != "" {
+		domainBoosts[article.Domain] = h.boostAmount
+	}
+
+	// The recorder applies the like and its boosts all at once
+	if h.likeRecorder != nil {
+		if err := h.likeRecorder.RecordLike(ctx, article.ID, chatID, tagBoosts, domainBoosts); err != nil {
+			return fmt.Errorf("record like: %w", err)
+		}
+		return nil
+	}
+
+	if err := h.likeTracker.LikeArticle(ctx, article.ID, chatID); err != nil {
 		return fmt.Errorf("record like: %w", err)
 	}
+	if err := h.tagBooster.BoostTagWeights(ctx, tagBoosts); err != nil {
+		return fmt.Errorf("boost tags: %w", err)
+	}
+	for domain, boost := range domainBoosts {
+		if err := h.domainBooster.BoostDomainWeight(ctx, domain, boost); err != nil {
+			return fmt.Errorf("boost domain %s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+func (h *ReactionHandler) handleDislike(ctx context.Context, chatID, messageID int64) error {
+	article, err := h.lookupArticle(ctx, chatID, messageID)
+	if err != nil || article == nil {
+		return err
+	}
+
+	disliked, err := h.dislikeTracker.IsArticleDisliked(ctx, article.ID)
+	if err != nil {
+		return fmt.Errorf("check if disliked: %w", err)
+	}
+	if disliked {
+		return nil
+	}
+
+	if err := h.dislikeTracker.DislikeArticle(ctx, article.ID); err != nil {
+		return fmt.Errorf("record dislike: %w", err)
+	}
+
+	return h.boostTags(ctx, article.Tags, -h.boostAmount)
+}
 
-	// Boost tags
-	for _, tag := range article.Tags {
-		if err := h.tagBooster.BoostTagWeight(ctx, tag, h.boostAmount); err != nil {
-			return fmt.Errorf("boost tag %s: %w", tag, err)
+// lookupArticle returns nil without error for messages that aren't articles.
+func (h *ReactionHandler) lookupArticle(ctx context.Context, chatID, messageID int64) (*ArticleInfo, error) {
+	article, err := h.articleLookup.GetArticleByMessageID(ctx, chatID, messageID)
+	if err != nil {
+		if errors.Is(err, ErrArticleNotFound) {
+			return nil, nil // Silently ignore reactions to non-article messages
 		}
+		return nil, fmt.Errorf("lookup article: %w", err)
 	}
+	return article, nil
+}
 
+// likedTagBoosts returns the boost for each tag of a liked article: the
+// boost amount scaled to the tag's count on the configured curve.
+func (h *ReactionHandler) likedTagBoosts(ctx context.Context, tags []string) (map[string]float64, error) {
+	boosts := make(map[string]float64, len(tags))
+	for _, tag := range tags {
+		boost, err := likeBoost(ctx, h.boostAmount, h.boostCurve, h.tagCounter, tag)
+		if err != nil {
+			return nil, err
+		}
+		boosts[tag] = boost
+	}
+	return boosts, nil
+}
+
+// likeBoost returns the boost a like gives tag: amount, scaled down by
+// curve for how often the tag has been boosted when counter is set.
+func likeBoost(ctx context.Context, amount float64, curve BoostCurve, counter TagCounter, tag string) (float64, error) {
+	if counter == nil || curve == "" || curve == BoostLinear {
+		return amount, nil
+	}
+	count, err := counter.GetTagCount(ctx, tag)
+	if err != nil {
+		return 0, fmt.Errorf("get count of tag %s: %w", tag, err)
+	}
+	return curve.Scale(amount, count), nil
+}
+
+func (h *ReactionHandler) boostTags(ctx context.Context, tags []string, amount float64) error {
+	boosts := make(map[string]float64, len(tags))
+	for _, tag := range tags {
+		boosts[tag] = amount
+	}
+	if err := h.tagBooster.BoostTagWeights(ctx, boosts); err != nil {
+		return fmt.Errorf("boost tags: %w", err)
+	}
 	return nil
 }
 
+func emojiSet(emojis []string) map[string]bool {
+	set := make(map[string]bool, len(emojis))
+	for _, e := range emojis {
+		set[normalizeEmoji(e)] = true
+	}
+	return set
+}
+
+// normalizeEmoji strips variation selectors so that "❤️" in config
+// matches the "❤" Telegram sends in reaction updates.
+func normalizeEmoji(emoji string) string {
+	return strings.ReplaceAll(strings.TrimSpace(emoji), "\uFE0F", "")
+}
+
 // FormatArticleMessage formats an article for display in Telegram.
+// Text posts without an external URL link only to the HN discussion.
 func FormatArticleMessage(article *ArticleForDisplay) string {
-	title := html.EscapeString(article.Title)
-	summary := html.EscapeString(article.Summary)
+	msg := formatArticle(article, ParseModeHTML.Escape(article.Summary))
+
+	limit := article.MaxLength
+	if limit <= 0 {
+		limit = maxMessageLength
+	}
+	n := utf8.RuneCountInString(msg)
+	if n <= limit {
+		return msg
+	}
+
+	// Shorten the summary by the excess, keeping the title and links intact
+	summaryLen := utf8.RuneCountInString(ParseModeHTML.Escape(article.Summary))
+	return formatArticle(article, truncateEscaped(article.Summary, summaryLen-(n-limit)))
+}
+
+// formatArticle formats an article around an already escaped summary.
+func formatArticle(article *ArticleForDisplay, summary string) string {
+	title := ParseModeHTML.Escape(article.Title)
 	hnURL := fmt.Sprintf("https://news.ycombinator.com/item?id=%d", article.ID)
+	// The URL may come from a user, as with /test, so it can't be trusted
+	// to be free of quotes and tags
+	articleURL := ParseModeHTML.Escape(article.URL)
+
+	links := fmt.Sprintf("<a href=\"%s\">Article</a> | <a href=\"%s\">HN Discussion</a>", articleURL, hnURL)
+	switch {
+	case article.ID == 0:
+		// Not an HN story, such as a /test result
+		links = fmt.Sprintf("<a href=\"%s\">Article</a>", articleURL)
+	case article.URL == "" || article.URL == hnURL:
+		links = fmt.Sprintf("<a href=\"%s\">HN Discussion</a>", hnURL)
+	}
+
+	discussion := ""
+	if article.Discussion != "" {
+		discussion = "🗣 " + ParseModeHTML.Escape(article.Discussion) + "\n\n"
+	}
 
-	return fmt.Sprintf(
+	footer := article.Footer
+	if footer == nil {
+		footer = defaultFooter
+	}
+
+	msg := fmt.Sprintf(
 		"📰 <b>%s</b>\n\n"+
 			"<i>%s</i>\n\n"+
-			"⬆️ %d points | 💬 %d comments\n"+
-			"<a href=\"%s\">Article</a> | <a href=\"%s\">HN Discussion</a>",
-		title, summary, article.HNScore, article.Comments, article.URL, hnURL,
+			"%s"+
+			"%s",
+		title, summary, discussion, footer.render(article, links, time.Now()),
 	)
+	if article.Explanation != "" {
+		msg += "\n🔎 " + ParseModeHTML.Escape(article.Explanation)
+	}
+	return msg
+}
+
+// truncateEscaped HTML-escapes s, cut at a word boundary and marked with
+// "…" so that the result is at most limit characters long, as Telegram
+// counts them.
+func truncateEscaped(s string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	escaped := ParseModeHTML.Escape(s)
+	// Characters never outnumber bytes, so cut by the excess characters
+	// until the result fits
+	out, maxBytes := escaped, len(escaped)
+	for excess := utf8.RuneCountInString(out) - limit; excess > 0; excess = utf8.RuneCountInString(out) - limit {
+		maxBytes = min(maxBytes, len(out)) - excess
+		out = util.TruncateHTMLSafe(escaped, maxBytes)
+	}
+	return out
 }
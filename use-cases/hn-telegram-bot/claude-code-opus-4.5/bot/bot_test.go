@@ -2,24 +2,33 @@ package bot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 // Mock implementations for testing
 
 type mockMessageSender struct {
 	sentMessages []sentMessage
+	errs         map[int64]error // Errors to return for sends to a chat
 }
 
 type sentMessage struct {
 	chatID int64
 	text   string
-	html   bool
+	mode   ParseMode
 }
 
-func (m *mockMessageSender) SendMessage(ctx context.Context, chatID int64, text string, html bool) (int64, error) {
-	m.sentMessages = append(m.sentMessages, sentMessage{chatID, text, html})
+func (m *mockMessageSender) SendMessage(ctx context.Context, chatID int64, text string, mode ParseMode) (int64, error) {
+	if err := m.errs[chatID]; err != nil {
+		return 0, err
+	}
+	m.sentMessages = append(m.sentMessages, sentMessage{chatID, text, mode})
 	return int64(len(m.sentMessages)), nil
 }
 
@@ -44,14 +53,26 @@ func (m *mockSettingsStore) SetSetting(ctx context.Context, key, value string) e
 }
 
 type mockArticleLookup struct {
-	articles map[int64]*ArticleInfo
+	articles      map[int64]*ArticleInfo
+	lookedUpChats []int64
+}
+
+func (m *mockArticleLookup) AddArticleMessage(ctx context.Context, articleID, chatID, msgID int64) error {
+	for _, a := range m.articles {
+		if a.ID == articleID {
+			m.articles[msgID] = a
+			return nil
+		}
+	}
+	return ErrArticleNotFound
 }
 
 func newMockArticleLookup() *mockArticleLookup {
 	return &mockArticleLookup{articles: make(map[int64]*ArticleInfo)}
 }
 
-func (m *mockArticleLookup) GetArticleByMessageID(ctx context.Context, msgID int64) (*ArticleInfo, error) {
+func (m *mockArticleLookup) GetArticleByMessageID(ctx context.Context, chatID, msgID int64) (*ArticleInfo, error) {
+	m.lookedUpChats = append(m.lookedUpChats, chatID)
 	if a, ok := m.articles[msgID]; ok {
 		return a, nil
 	}
@@ -59,36 +80,85 @@ func (m *mockArticleLookup) GetArticleByMessageID(ctx context.Context, msgID int
 }
 
 type mockLikeTracker struct {
-	liked map[int64]bool
+	liked   map[int64]bool
+	likedAt map[int64]time.Time
 }
 
 func newMockLikeTracker() *mockLikeTracker {
-	return &mockLikeTracker{liked: make(map[int64]bool)}
+	return &mockLikeTracker{liked: make(map[int64]bool), likedAt: make(map[int64]time.Time)}
 }
 
 func (m *mockLikeTracker) IsArticleLiked(ctx context.Context, articleID int64) (bool, error) {
 	return m.liked[articleID], nil
 }
 
-func (m *mockLikeTracker) LikeArticle(ctx context.Context, articleID int64) error {
+func (m *mockLikeTracker) LikeArticle(ctx context.Context, articleID, chatID int64) error {
 	m.liked[articleID] = true
+	m.likedAt[articleID] = time.Now()
 	return nil
 }
 
+func (m *mockLikeTracker) GetLikeCountSince(ctx context.Context, since time.Time) (int, error) {
+	n := 0
+	for _, at := range m.likedAt {
+		if !at.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (m *mockLikeTracker) GetLikeCount(ctx context.Context) (int, error) {
 	return len(m.liked), nil
 }
 
 type mockTagBooster struct {
 	boosted map[string]float64
+	counts  map[string]int
 }
 
 func newMockTagBooster() *mockTagBooster {
-	return &mockTagBooster{boosted: make(map[string]float64)}
+	return &mockTagBooster{boosted: make(map[string]float64), counts: make(map[string]int)}
+}
+
+func (m *mockTagBooster) BoostTagWeights(ctx context.Context, boosts map[string]float64) error {
+	for tag, boost := range boosts {
+		m.boosted[tag] += boost
+		m.counts[tag]++
+	}
+	return nil
+}
+
+func (m *mockTagBooster) GetTagCount(ctx context.Context, tag string) (int, error) {
+	return m.counts[tag], nil
+}
+
+type mockDomainBooster struct {
+	boosted map[string]float64
+}
+
+func (m *mockDomainBooster) BoostDomainWeight(ctx context.Context, domain string, boost float64) error {
+	if m.boosted == nil {
+		m.boosted = make(map[string]float64)
+	}
+	m.boosted[domain] += boost
+	return nil
+}
+
+type mockDislikeTracker struct {
+	disliked map[int64]bool
 }
 
-func (m *mockTagBooster) BoostTagWeight(ctx context.Context, tag string, boost float64) error {
-	m.boosted[tag] += boost
+func newMockDislikeTracker() *mockDislikeTracker {
+	return &mockDislikeTracker{disliked: make(map[int64]bool)}
+}
+
+func (m *mockDislikeTracker) IsArticleDisliked(ctx context.Context, articleID int64) (bool, error) {
+	return m.disliked[articleID], nil
+}
+
+func (m *mockDislikeTracker) DislikeArticle(ctx context.Context, articleID int64) error {
+	m.disliked[articleID] = true
 	return nil
 }
 
@@ -96,24 +166,39 @@ type mockTagStats struct {
 	topTags []TagStat
 }
 
-func (m *mockTagStats) GetTopTags(ctx context.Context, limit int) ([]TagStat, error) {
-	if limit > len(m.topTags) {
-		return m.topTags, nil
+func (m *mockTagStats) GetTagWeightsPaged(ctx context.Context, limit, offset int) ([]TagStat, error) {
+	if offset >= len(m.topTags) {
+		return nil, nil
+	}
+	return m.topTags[offset:min(offset+limit, len(m.topTags))], nil
+}
+
+type mockDomainStats struct {
+	topDomains []DomainStat
+}
+
+func (m *mockDomainStats) GetTopDomains(ctx context.Context, limit int) ([]DomainStat, error) {
+	if limit > len(m.topDomains) {
+		return m.topDomains, nil
 	}
-	return m.topTags[:limit], nil
+	return m.topDomains[:limit], nil
 }
 
 type mockScheduleUpdater struct {
 	scheduledTime string
+	fn            func()
 }
 
-func (m *mockScheduleUpdater) Schedule(timeStr string, fn func()) error {
+func (m *mockScheduleUpdater) Reschedule(timeStr string, fn func()) error {
 	m.scheduledTime = timeStr
+	m.fn = fn
 	return nil
 }
 
 type mockDigestTrigger struct {
 	triggered bool
+	scheduled bool
+	queuedAt  time.Time
 }
 
 func (m *mockDigestTrigger) TriggerDigest(ctx context.Context) error {
@@ -121,6 +206,40 @@ func (m *mockDigestTrigger) TriggerDigest(ctx context.Context) error {
 	return nil
 }
 
+func (m *mockDigestTrigger) TriggerScheduledDigest(ctx context.Context) error {
+	m.scheduled = true
+	return nil
+}
+
+func (m *mockDigestTrigger) QueueDigest(ctx context.Context, at time.Time) error {
+	m.queuedAt = at
+	return nil
+}
+
+type mockPreviewer struct {
+	items []PreviewItem
+}
+
+func (m *mockPreviewer) Preview(ctx context.Context) ([]PreviewItem, error) {
+	return m.items, nil
+}
+
+type mockNextRun struct {
+	next time.Time
+}
+
+func (m *mockNextRun) NextRun() time.Time {
+	return m.next
+}
+
+type mockArticleStats struct {
+	sent int
+}
+
+func (m *mockArticleStats) GetSentArticleCount(ctx context.Context) (int, error) {
+	return m.sent, nil
+}
+
 // Tests
 
 func TestHandleStartCommand(t *testing.T) {
@@ -135,13 +254,9 @@ func TestHandleStartCommand(t *testing.T) {
 		t.Fatalf("HandleStart failed: %v", err)
 	}
 
-	// Should save chat_id
-	chatID, err := settings.GetSetting(ctx, "chat_id")
-	if err != nil {
-		t.Fatalf("chat_id not saved: %v", err)
-	}
-	if chatID != "12345" {
-		t.Errorf("chat_id = %q, want '12345'", chatID)
+	// Should not subscribe the chat
+	if _, err := settings.GetSetting(ctx, "chat_id"); err == nil {
+		t.Error("chat_id saved on /start, want it saved only on /subscribe")
 	}
 
 	// Should send welcome message
@@ -151,6 +266,105 @@ func TestHandleStartCommand(t *testing.T) {
 	if sender.sentMessages[0].chatID != 12345 {
 		t.Errorf("message sent to wrong chat: %d", sender.sentMessages[0].chatID)
 	}
+	if !strings.Contains(sender.sentMessages[0].text, "/subscribe") {
+		t.Errorf("welcome message should mention /subscribe: %q", sender.sentMessages[0].text)
+	}
+}
+
+type mockSubscriptions struct {
+	unsubscribed map[int64]string
+}
+
+func newMockSubscriptions() *mockSubscriptions {
+	return &mockSubscriptions{unsubscribed: make(map[int64]string)}
+}
+
+func (m *mockSubscriptions) UnsubscribeChat(ctx context.Context, chatID int64, reason string) error {
+	m.unsubscribed[chatID] = reason
+	return nil
+}
+
+func (m *mockSubscriptions) ResubscribeChat(ctx context.Context, chatID int64) error {
+	delete(m.unsubscribed, chatID)
+	return nil
+}
+
+func (m *mockSubscriptions) IsChatUnsubscribed(ctx context.Context, chatID int64) (bool, error) {
+	_, ok := m.unsubscribed[chatID]
+	return ok, nil
+}
+
+func TestHandleSubscribe(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	settings.settings["digest_time"] = "07:30"
+	subscriptions := newMockSubscriptions()
+	subscriptions.unsubscribed[12345] = "Forbidden: bot was blocked by the user"
+	handler := NewCommandHandler(sender, settings, nil, nil, nil, WithSubscriptions(subscriptions))
+	ctx := context.Background()
+
+	if err := handler.HandleSubscribe(ctx, 12345); err != nil {
+		t.Fatalf("HandleSubscribe failed: %v", err)
+	}
+
+	if chatID, err := settings.GetSetting(ctx, "chat_id"); err != nil || chatID != "12345" {
+		t.Errorf("chat_id = %q (err %v), want 12345", chatID, err)
+	}
+	if _, ok := subscriptions.unsubscribed[12345]; ok {
+		t.Error("chat still unsubscribed after /subscribe")
+	}
+	if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, "07:30") {
+		t.Errorf("confirmation should give the digest time: %+v", sender.sentMessages)
+	}
+}
+
+func TestHandleSubscribeToggle(t *testing.T) {
+	subscriptions := newMockSubscriptions()
+	handler := NewCommandHandler(&mockMessageSender{}, newMockSettingsStore(), nil, nil, nil,
+		WithSubscriptions(subscriptions))
+	ctx := context.Background()
+
+	steps := []struct {
+		handle           func(context.Context, int64) error
+		wantUnsubscribed bool
+	}{
+		{handler.HandleSubscribe, false},
+		{handler.HandleUnsubscribe, true},
+		{handler.HandleUnsubscribe, true},
+		{handler.HandleSubscribe, false},
+	}
+	for i, step := range steps {
+		if err := step.handle(ctx, 12345); err != nil {
+			t.Fatalf("step %d failed: %v", i, err)
+		}
+		if _, got := subscriptions.unsubscribed[12345]; got != step.wantUnsubscribed {
+			t.Errorf("after step %d unsubscribed = %v, want %v", i, got, step.wantUnsubscribed)
+		}
+	}
+}
+
+func TestHandleUnsubscribe(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	settings.settings["chat_id"] = "12345"
+	subscriptions := newMockSubscriptions()
+	handler := NewCommandHandler(sender, settings, nil, nil, nil, WithSubscriptions(subscriptions))
+	ctx := context.Background()
+
+	if err := handler.HandleUnsubscribe(ctx, 12345); err != nil {
+		t.Fatalf("HandleUnsubscribe failed: %v", err)
+	}
+
+	if _, ok := subscriptions.unsubscribed[12345]; !ok {
+		t.Error("chat not unsubscribed")
+	}
+	// The chat is kept, so other commands keep working
+	if chatID, _ := settings.GetSetting(ctx, "chat_id"); chatID != "12345" {
+		t.Errorf("chat_id = %q, want it kept", chatID)
+	}
+	if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, "/subscribe") {
+		t.Errorf("confirmation should say how to resume: %+v", sender.sentMessages)
+	}
 }
 
 func TestHandleSettingsCommandDisplay(t *testing.T) {
@@ -202,6 +416,24 @@ func TestHandleSettingsCommandUpdateTime(t *testing.T) {
 	}
 }
 
+func TestHandleSettingsCommandNormalizesTime(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	schedUpdater := &mockScheduleUpdater{}
+
+	handler := NewCommandHandler(sender, settings, schedUpdater, nil, nil)
+	if err := handler.HandleSettings(context.Background(), 12345, "time 9:05"); err != nil {
+		t.Fatalf("HandleSettings failed: %v", err)
+	}
+
+	if v := settings.settings["digest_time"]; v != "09:05" {
+		t.Errorf("digest_time = %q, want '09:05'", v)
+	}
+	if schedUpdater.scheduledTime != "09:05" {
+		t.Errorf("scheduled time = %q, want '09:05'", schedUpdater.scheduledTime)
+	}
+}
+
 func TestHandleSettingsCommandUpdateCount(t *testing.T) {
 	sender := &mockMessageSender{}
 	settings := newMockSettingsStore()
@@ -220,6 +452,97 @@ func TestHandleSettingsCommandUpdateCount(t *testing.T) {
 	}
 }
 
+func TestHandleSettingsCommandExplain(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+
+	handler := NewCommandHandler(sender, settings, nil, nil, nil)
+	ctx := context.Background()
+
+	if err := handler.HandleSettings(ctx, 12345, "explain on"); err != nil {
+		t.Fatalf("HandleSettings failed: %v", err)
+	}
+	if v := settings.settings["explain"]; v != "on" {
+		t.Errorf("explain = %q, want 'on'", v)
+	}
+
+	handler.HandleSettings(ctx, 12345, "")
+	if msg := sender.sentMessages[1].text; !strings.Contains(msg, "Explain Rankings: on") {
+		t.Errorf("settings should show explain state, got: %s", msg)
+	}
+
+	// Invalid values are rejected
+	handler.HandleSettings(ctx, 12345, "explain maybe")
+	if v := settings.settings["explain"]; v != "on" {
+		t.Errorf("explain = %q after invalid value, want unchanged 'on'", v)
+	}
+}
+
+func TestHandleSettingsCommandFormat(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+
+	handler := NewCommandHandler(sender, settings, nil, nil, nil)
+	ctx := context.Background()
+
+	handler.HandleSettings(ctx, 12345, "")
+	if msg := sender.sentMessages[0].text; !strings.Contains(msg, "Article Format: text") {
+		t.Errorf("settings should default to text format, got: %s", msg)
+	}
+
+	if err := handler.HandleSettings(ctx, 12345, "format Photo"); err != nil {
+		t.Fatalf("HandleSettings failed: %v", err)
+	}
+	if v := settings.settings["format"]; v != FormatPhoto {
+		t.Errorf("format = %q, want %q", v, FormatPhoto)
+	}
+
+	handler.HandleSettings(ctx, 12345, "")
+	if msg := sender.sentMessages[2].text; !strings.Contains(msg, "Article Format: photo") {
+		t.Errorf("settings should show photo format, got: %s", msg)
+	}
+
+	// Invalid values are rejected
+	handler.HandleSettings(ctx, 12345, "format video")
+	if v := settings.settings["format"]; v != FormatPhoto {
+		t.Errorf("format = %q after invalid value, want unchanged %q", v, FormatPhoto)
+	}
+}
+
+func TestHandleSettingsCommandPin(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+
+	handler := NewCommandHandler(sender, settings, nil, nil, nil,
+		WithConfig(HandlerConfig{PinnedTags: []string{"go"}}))
+	ctx := context.Background()
+
+	for _, args := range []string{"pin Security", "pin rust", "pin security", "pin go"} {
+		if err := handler.HandleSettings(ctx, 12345, args); err != nil {
+			t.Fatalf("HandleSettings(%q) failed: %v", args, err)
+		}
+	}
+	if v := settings.settings["pinned_tags"]; v != "security,rust" {
+		t.Errorf("pinned_tags = %q, want 'security,rust'", v)
+	}
+
+	handler.HandleSettings(ctx, 12345, "")
+	if msg := sender.sentMessages[len(sender.sentMessages)-1].text; !strings.Contains(msg, "Pinned Tags: go, security, rust") {
+		t.Errorf("settings should list config and stored pins, got: %s", msg)
+	}
+
+	handler.HandleSettings(ctx, 12345, "unpin rust")
+	if v := settings.settings["pinned_tags"]; v != "security" {
+		t.Errorf("pinned_tags = %q after unpin, want 'security'", v)
+	}
+
+	// Tags pinned in the config file stay pinned
+	handler.HandleSettings(ctx, 12345, "unpin go")
+	if msg := sender.sentMessages[len(sender.sentMessages)-1].text; !strings.Contains(msg, "config file") {
+		t.Errorf("unpinning a config tag should explain why it stays, got: %s", msg)
+	}
+}
+
 func TestHandleSettingsCommandInvalidCount(t *testing.T) {
 	sender := &mockMessageSender{}
 	settings := newMockSettingsStore()
@@ -266,7 +589,7 @@ func TestHandleStatsCommand(t *testing.T) {
 	handler := NewCommandHandler(sender, nil, nil, likeTracker, tagStats)
 	ctx := context.Background()
 
-	err := handler.HandleStats(ctx, 12345)
+	err := handler.HandleStats(ctx, 12345, "")
 	if err != nil {
 		t.Fatalf("HandleStats failed: %v", err)
 	}
@@ -281,119 +604,1167 @@ func TestHandleStatsCommand(t *testing.T) {
 	}
 }
 
-func TestHandleStatsCommandNoLikes(t *testing.T) {
+func TestHandleStatsCommandLikesThisWeek(t *testing.T) {
 	sender := &mockMessageSender{}
 	likeTracker := newMockLikeTracker()
-	tagStats := &mockTagStats{topTags: nil}
-
-	handler := NewCommandHandler(sender, nil, nil, likeTracker, tagStats)
-	ctx := context.Background()
+	likeTracker.LikeArticle(context.Background(), 1, 12345)
+	likeTracker.LikeArticle(context.Background(), 2, 12345)
+	likeTracker.likedAt[3] = time.Now().Add(-10 * 24 * time.Hour)
+	likeTracker.liked[3] = true
 
-	handler.HandleStats(ctx, 12345)
+	handler := NewCommandHandler(sender, nil, nil, likeTracker, &mockTagStats{})
+	if err := handler.HandleStats(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleStats failed: %v", err)
+	}
 
 	msg := sender.sentMessages[0].text
-	// Message should mention likes or thumbs-up emoji
-	if !strings.Contains(msg, "👍") && !strings.Contains(msg, "like") {
-		t.Errorf("no-likes message should mention liking, got: %s", msg)
+	if !contains(msg, "Total articles liked: 3") || !contains(msg, "Likes this week: 2") {
+		t.Errorf("stats should show total and weekly likes, got: %s", msg)
 	}
 }
 
-func TestHandleFetchCommand(t *testing.T) {
+func TestHandleStatsCommandTopDomains(t *testing.T) {
 	sender := &mockMessageSender{}
-	digestTrigger := &mockDigestTrigger{}
+	likeTracker := newMockLikeTracker()
+	likeTracker.liked[1] = true
 
-	handler := NewCommandHandler(sender, nil, nil, nil, nil)
-	handler.digestTrigger = digestTrigger
-	ctx := context.Background()
+	tagStats := &mockTagStats{topTags: []TagStat{{Tag: "go", Weight: 2.5}}}
+	domainStats := &mockDomainStats{
+		topDomains: []DomainStat{{Domain: "blog.rust-lang.org", Weight: 1.4}},
+	}
 
-	err := handler.HandleFetch(ctx, 12345)
-	if err != nil {
-		t.Fatalf("HandleFetch failed: %v", err)
+	handler := NewCommandHandler(sender, nil, nil, likeTracker, tagStats, WithDomainStats(domainStats))
+	if err := handler.HandleStats(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleStats failed: %v", err)
 	}
 
-	if !digestTrigger.triggered {
-		t.Error("digest was not triggered")
+	msg := sender.sentMessages[0].text
+	if !contains(msg, "Top Domains") || !contains(msg, "blog.rust-lang.org (1.40)") {
+		t.Errorf("stats should list top domains, got: %s", msg)
 	}
 }
 
-func TestHandleReaction(t *testing.T) {
-	articleLookup := newMockArticleLookup()
-	articleLookup.articles[100] = &ArticleInfo{
-		ID:   12345,
-		Tags: []string{"go", "testing"},
+func TestHandleStatsPages(t *testing.T) {
+	likeTracker := newMockLikeTracker()
+	likeTracker.liked[1] = true
+
+	// 21 tags: two full pages and one tag on the third
+	var tags []TagStat
+	for i := range 21 {
+		tags = append(tags, TagStat{Tag: fmt.Sprintf("tag%d", i+1), Weight: float64(30 - i)})
+	}
+	tagStats := &mockTagStats{topTags: tags}
+
+	tests := []struct {
+		args    string
+		want    []string
+		notWant []string
+	}{
+		{"", []string{"1. tag1 ", "10. tag10 ", "/stats 2 ▶️", "Total articles liked"}, []string{"tag11", "◀️"}},
+		{"2", []string{"page 2", "11. tag11 ", "20. tag20 ", "◀️ /stats 1", "/stats 3 ▶️"}, []string{"tag10 ", "tag21", "Total articles liked"}},
+		{"3", []string{"21. tag21 ", "◀️ /stats 2"}, []string{"tag20", "▶️"}},
+		{"4", []string{"No tags on page 4"}, nil},
+		{"x", []string{"Usage: /stats [page]"}, nil},
+	}
+	for _, tt := range tests {
+		sender := &mockMessageSender{}
+		handler := NewCommandHandler(sender, nil, nil, likeTracker, tagStats)
+		if err := handler.HandleStats(context.Background(), 12345, tt.args); err != nil {
+			t.Fatalf("HandleStats(%q) failed: %v", tt.args, err)
+		}
+		msg := sender.sentMessages[0].text
+		for _, w := range tt.want {
+			if !contains(msg, w) {
+				t.Errorf("/stats %s should contain %q, got:\n%s", tt.args, w, msg)
+			}
+		}
+		for _, w := range tt.notWant {
+			if contains(msg, w) {
+				t.Errorf("/stats %s should not contain %q, got:\n%s", tt.args, w, msg)
+			}
+		}
 	}
+}
 
-	likeTracker := newMockLikeTracker()
-	tagBooster := newMockTagBooster()
+type mockSourceStats struct {
+	counts []SourceStat
+	chatID int64
+	within time.Duration
+}
 
-	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2)
-	ctx := context.Background()
+func (m *mockSourceStats) GetSentCountsBySource(ctx context.Context, chatID int64, within time.Duration) ([]SourceStat, error) {
+	m.chatID = chatID
+	m.within = within
+	return m.counts, nil
+}
 
-	err := handler.HandleReaction(ctx, 100, "👍")
-	if err != nil {
-		t.Fatalf("HandleReaction failed: %v", err)
+func TestHandleSourceStats(t *testing.T) {
+	sender := &mockMessageSender{}
+	sourceStats := &mockSourceStats{
+		counts: []SourceStat{{Source: "top", Count: 12}, {Source: "best", Count: 3}},
 	}
 
-	// Should record like
-	if !likeTracker.liked[12345] {
-		t.Error("article should be liked")
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithSourceStats(sourceStats))
+	if err := handler.HandleSourceStats(context.Background(), 12345); err != nil {
+		t.Fatalf("HandleSourceStats failed: %v", err)
 	}
 
-	// Should boost tags
-	if tagBooster.boosted["go"] != 0.2 {
-		t.Errorf("go boost = %f, want 0.2", tagBooster.boosted["go"])
+	if sourceStats.chatID != 12345 || sourceStats.within != 30*24*time.Hour {
+		t.Errorf("queried chat %d within %v, want chat 12345 within 30 days", sourceStats.chatID, sourceStats.within)
 	}
-	if tagBooster.boosted["testing"] != 0.2 {
-		t.Errorf("testing boost = %f, want 0.2", tagBooster.boosted["testing"])
+
+	msg := sender.sentMessages[0].text
+	for _, want := range []string{"last 30 days", "top: 12", "best: 3", "Total articles sent: 15"} {
+		if !contains(msg, want) {
+			t.Errorf("source stats should contain %q, got: %s", want, msg)
+		}
 	}
 }
 
-func TestHandleReactionNonThumbsUp(t *testing.T) {
-	handler := NewReactionHandler(nil, nil, nil, 0.2)
-	ctx := context.Background()
-
-	// Non-thumbs-up reactions should be ignored (no error)
-	err := handler.HandleReaction(ctx, 100, "❤️")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+func TestHandleSourceStatsEmpty(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithSourceStats(&mockSourceStats{}))
+	if err := handler.HandleSourceStats(context.Background(), 12345); err != nil {
+		t.Fatalf("HandleSourceStats failed: %v", err)
 	}
 
-	err = handler.HandleReaction(ctx, 100, "🎉")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	if msg := sender.sentMessages[0].text; !contains(msg, "No articles sent") {
+		t.Errorf("expected empty message, got: %s", msg)
 	}
 }
 
-func TestHandleReactionUnknownMessage(t *testing.T) {
-	articleLookup := newMockArticleLookup() // Empty
+type mockTagHistory struct {
+	points []TagWeightPoint
+	tag    string
+	since  time.Time
+}
 
-	handler := NewReactionHandler(articleLookup, nil, nil, 0.2)
-	ctx := context.Background()
+func (m *mockTagHistory) GetTagWeightHistory(ctx context.Context, tag string, since time.Time) ([]TagWeightPoint, error) {
+	m.tag = tag
+	m.since = since
+	return m.points, nil
+}
 
-	// Unknown message ID should be silently ignored
-	err := handler.HandleReaction(ctx, 999, "👍")
-	if err != nil {
-		t.Fatalf("unexpected error for unknown message: %v", err)
+func TestHandleHistory(t *testing.T) {
+	sender := &mockMessageSender{}
+	tagHistory := &mockTagHistory{
+		points: []TagWeightPoint{
+			{Weight: 1.5, RecordedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)},
+			{Weight: 1.47, RecordedAt: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		},
 	}
-}
 
-func TestHandleReactionAlreadyLiked(t *testing.T) {
-	articleLookup := newMockArticleLookup()
-	articleLookup.articles[100] = &ArticleInfo{
-		ID:   12345,
-		Tags: []string{"go"},
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithTagHistory(tagHistory),
+		WithClock(func() time.Time { return now }),
+	)
+	if err := handler.HandleHistory(context.Background(), 12345, " go"); err != nil {
+		t.Fatalf("HandleHistory failed: %v", err)
 	}
 
-	likeTracker := newMockLikeTracker()
-	likeTracker.liked[12345] = true // Already liked
+	if tagHistory.tag != "go" {
+		t.Errorf("queried tag = %q, want 'go'", tagHistory.tag)
+	}
+	if want := now.AddDate(0, 0, -30); !tagHistory.since.Equal(want) {
+		t.Errorf("queried since %v, want %v", tagHistory.since, want)
+	}
 
-	tagBooster := newMockTagBooster()
+	msg := sender.sentMessages[0].text
+	for _, want := range []string{"go (last 30 days)", "2026-03-01 09:00  1.50", "2026-03-02 09:00  1.47"} {
+		if !contains(msg, want) {
+			t.Errorf("history should contain %q, got: %s", want, msg)
+		}
+	}
+}
 
-	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2)
-	ctx := context.Background()
+func TestHandleHistoryUsage(t *testing.T) {
+	sender := &mockMessageSender{}
+	tagHistory := &mockTagHistory{}
 
-	err := handler.HandleReaction(ctx, 100, "👍")
-	if err != nil {
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithTagHistory(tagHistory))
+	if err := handler.HandleHistory(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleHistory failed: %v", err)
+	}
+
+	if msg := sender.sentMessages[0].text; !contains(msg, "Usage: /history") {
+		t.Errorf("expected usage message, got: %s", msg)
+	}
+	if tagHistory.tag != "" {
+		t.Errorf("history queried without a tag")
+	}
+}
+
+type mockSentArticles struct {
+	articles []SentArticle
+	chatID   int64
+	limit    int
+}
+
+func (m *mockSentArticles) RecentSentArticles(ctx context.Context, chatID int64, limit int) ([]SentArticle, error) {
+	m.chatID = chatID
+	m.limit = limit
+	return m.articles[:min(limit, len(m.articles))], nil
+}
+
+func TestHandleArticles(t *testing.T) {
+	sender := &mockMessageSender{}
+	sentArticles := &mockSentArticles{
+		articles: []SentArticle{
+			{Title: "Go & Rust", URL: "https://example.com/a?x=1&y=2", SentAt: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+			{Title: "SQLite tips", URL: "https://example.com/b", SentAt: time.Date(2026, 3, 1, 9, 5, 0, 0, time.UTC)},
+		},
+	}
+
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithSentArticles(sentArticles))
+	if err := handler.HandleArticles(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleArticles failed: %v", err)
+	}
+
+	if sentArticles.chatID != 12345 || sentArticles.limit != defaultArticlesListed {
+		t.Errorf("queried chat %d limit %d, want chat 12345 limit %d", sentArticles.chatID, sentArticles.limit, defaultArticlesListed)
+	}
+
+	sent := sender.sentMessages[0]
+	if sent.mode != ParseModeHTML {
+		t.Error("article list should be sent as HTML")
+	}
+	for _, want := range []string{
+		"Last 2 articles",
+		`1. <a href="https://example.com/a?x=1&amp;y=2">Go &amp; Rust</a>`,
+		"2026-03-02 09:00",
+		`2. <a href="https://example.com/b">SQLite tips</a>`,
+		"2026-03-01 09:05",
+	} {
+		if !contains(sent.text, want) {
+			t.Errorf("list should contain %q, got: %s", want, sent.text)
+		}
+	}
+}
+
+func TestHandleArticlesLimit(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		args      string
+		wantLimit int
+	}{
+		{" 3", 3},
+		{" 1000", maxArticlesListed},
+	}
+	for _, tt := range tests {
+		sentArticles := &mockSentArticles{}
+		handler := NewCommandHandler(&mockMessageSender{}, nil, nil, nil, nil, WithSentArticles(sentArticles))
+		if err := handler.HandleArticles(ctx, 12345, tt.args); err != nil {
+			t.Fatalf("HandleArticles(%q) failed: %v", tt.args, err)
+		}
+		if sentArticles.limit != tt.wantLimit {
+			t.Errorf("HandleArticles(%q) limit = %d, want %d", tt.args, sentArticles.limit, tt.wantLimit)
+		}
+	}
+
+	sender := &mockMessageSender{}
+	sentArticles := &mockSentArticles{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithSentArticles(sentArticles))
+	if err := handler.HandleArticles(ctx, 12345, " lots"); err != nil {
+		t.Fatalf("HandleArticles failed: %v", err)
+	}
+	if msg := sender.sentMessages[0].text; !contains(msg, "Usage: /articles") {
+		t.Errorf("expected usage message, got: %s", msg)
+	}
+	if sentArticles.limit != 0 {
+		t.Error("articles queried for an invalid count")
+	}
+}
+
+func TestHandleArticlesRespectsMessageLimit(t *testing.T) {
+	sender := &mockMessageSender{}
+	sentArticles := &mockSentArticles{}
+	longTitle := strings.Repeat("x", 200)
+	for range maxArticlesListed {
+		sentArticles.articles = append(sentArticles.articles, SentArticle{Title: longTitle, URL: "https://example.com", SentAt: time.Now()})
+	}
+
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithSentArticles(sentArticles))
+	if err := handler.HandleArticles(context.Background(), 12345, " 50"); err != nil {
+		t.Fatalf("HandleArticles failed: %v", err)
+	}
+
+	msg := sender.sentMessages[0].text
+	if n := utf8.RuneCountInString(msg); n > maxMessageLength {
+		t.Errorf("message length = %d, want at most %d", n, maxMessageLength)
+	}
+	if !contains(msg, "more") {
+		t.Errorf("truncated list should say how many were left out, got: %s", msg[len(msg)-40:])
+	}
+}
+
+type mockPreferenceResetter struct {
+	calls int
+}
+
+func (m *mockPreferenceResetter) ClearPreferences(ctx context.Context) (*ResetSummary, error) {
+	m.calls++
+	return &ResetSummary{Tags: 12, Domains: 3, Likes: 7, Dislikes: 2}, nil
+}
+
+func TestHandleResetRequiresConfirmation(t *testing.T) {
+	sender := &mockMessageSender{}
+	resetter := &mockPreferenceResetter{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithPreferenceResetter(resetter))
+	ctx := context.Background()
+
+	if err := handler.HandleReset(ctx, 12345, ""); err != nil {
+		t.Fatalf("HandleReset failed: %v", err)
+	}
+	if resetter.calls != 0 {
+		t.Fatal("preferences cleared without confirmation")
+	}
+	if msg := sender.sentMessages[0].text; !contains(msg, "/reset confirm") {
+		t.Errorf("expected confirmation prompt, got: %s", msg)
+	}
+
+	if err := handler.HandleReset(ctx, 12345, " confirm"); err != nil {
+		t.Fatalf("HandleReset confirm failed: %v", err)
+	}
+	if resetter.calls != 1 {
+		t.Fatalf("ClearPreferences calls = %d, want 1", resetter.calls)
+	}
+	msg := sender.sentMessages[1].text
+	for _, want := range []string{"Tags cleared: 12", "Domains cleared: 3", "Likes cleared: 7", "Dislikes cleared: 2"} {
+		if !contains(msg, want) {
+			t.Errorf("reset summary should contain %q, got: %s", want, msg)
+		}
+	}
+}
+
+func TestHandleStatsCommandNoLikes(t *testing.T) {
+	sender := &mockMessageSender{}
+	likeTracker := newMockLikeTracker()
+	tagStats := &mockTagStats{topTags: nil}
+
+	handler := NewCommandHandler(sender, nil, nil, likeTracker, tagStats)
+	ctx := context.Background()
+
+	handler.HandleStats(ctx, 12345, "")
+
+	msg := sender.sentMessages[0].text
+	// Message should mention likes or thumbs-up emoji
+	if !strings.Contains(msg, "👍") && !strings.Contains(msg, "like") {
+		t.Errorf("no-likes message should mention liking, got: %s", msg)
+	}
+}
+
+func TestHandleStatusCommand(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	settings.settings["chat_id"] = "12345"
+	settings.settings["digest_time"] = "07:30"
+	likeTracker := newMockLikeTracker()
+	likeTracker.liked[1] = true
+	likeTracker.liked[2] = true
+
+	loc, _ := time.LoadLocation("Europe/Rome")
+	nextRun := &mockNextRun{next: time.Date(2025, 3, 10, 7, 30, 0, 0, loc)}
+
+	handler := NewCommandHandler(sender, settings, nil, likeTracker, nil,
+		WithStatusProviders(nextRun, &mockArticleStats{sent: 42}),
+		WithConfig(HandlerConfig{
+			ArticleCount: 15,
+			Timezone:     "Europe/Rome",
+			Model:        "gemini-test-model",
+		}),
+	)
+	ctx := context.Background()
+
+	if err := handler.HandleStatus(ctx, 12345); err != nil {
+		t.Fatalf("HandleStatus failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sender.sentMessages))
+	}
+
+	msg := sender.sentMessages[0].text
+	for _, want := range []string{
+		"12345",
+		"07:30",
+		"Europe/Rome",
+		"15",
+		"2025-03-10 07:30",
+		"Articles Sent: 42",
+		"Articles Liked: 2",
+		"gemini-test-model",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("status should contain %q, got: %s", want, msg)
+		}
+	}
+}
+
+func TestHandleStatusReportsSubscription(t *testing.T) {
+	settings := newMockSettingsStore()
+	settings.settings["chat_id"] = "12345"
+	subscriptions := newMockSubscriptions()
+
+	status := func() string {
+		sender := &mockMessageSender{}
+		handler := NewCommandHandler(sender, settings, nil, nil, nil, WithSubscriptions(subscriptions))
+		if err := handler.HandleStatus(context.Background(), 12345); err != nil {
+			t.Fatalf("HandleStatus failed: %v", err)
+		}
+		return sender.sentMessages[0].text
+	}
+
+	if msg := status(); !strings.Contains(msg, "Scheduled Digests: active") {
+		t.Errorf("status should report active digests, got: %s", msg)
+	}
+	subscriptions.unsubscribed[12345] = "unsubscribed with /unsubscribe"
+	if msg := status(); !strings.Contains(msg, "Scheduled Digests: paused") {
+		t.Errorf("status should report paused digests, got: %s", msg)
+	}
+}
+
+func TestHandleStatusCommandNotScheduled(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+
+	handler := NewCommandHandler(sender, settings, nil, nil, nil,
+		WithStatusProviders(&mockNextRun{}, nil),
+	)
+
+	if err := handler.HandleStatus(context.Background(), 12345); err != nil {
+		t.Fatalf("HandleStatus failed: %v", err)
+	}
+
+	msg := sender.sentMessages[0].text
+	if !strings.Contains(msg, "Chat ID: not set") {
+		t.Errorf("status should report missing chat ID, got: %s", msg)
+	}
+	if !strings.Contains(msg, "Next Run: not scheduled") {
+		t.Errorf("status should report no scheduled run, got: %s", msg)
+	}
+}
+
+func TestHandleFetchCommand(t *testing.T) {
+	sender := &mockMessageSender{}
+	digestTrigger := &mockDigestTrigger{}
+
+	handler := NewCommandHandler(sender, nil, nil, nil, nil)
+	handler.digestTrigger = digestTrigger
+	ctx := context.Background()
+
+	err := handler.HandleFetch(ctx, 12345)
+	if err != nil {
+		t.Fatalf("HandleFetch failed: %v", err)
+	}
+
+	if !digestTrigger.triggered {
+		t.Error("digest was not triggered")
+	}
+	if digestTrigger.scheduled {
+		t.Error("/fetch triggered a scheduled digest, want on-demand")
+	}
+}
+
+func TestHandleFetchQuietHours(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	cfg := HandlerConfig{Timezone: "Europe/Rome", QuietHoursStart: "23:00", QuietHoursEnd: "07:00"}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		queuedAt time.Time // Zero if the digest should be sent right away
+	}{
+		{"after midnight", time.Date(2024, 3, 5, 3, 0, 0, 0, rome), time.Date(2024, 3, 5, 7, 0, 0, 0, rome)},
+		{"before midnight", time.Date(2024, 3, 5, 23, 30, 0, 0, rome), time.Date(2024, 3, 6, 7, 0, 0, 0, rome)},
+		{"at start", time.Date(2024, 3, 5, 23, 0, 0, 0, rome), time.Date(2024, 3, 6, 7, 0, 0, 0, rome)},
+		{"at end", time.Date(2024, 3, 5, 7, 0, 0, 0, rome), time.Time{}},
+		{"daytime", time.Date(2024, 3, 5, 14, 0, 0, 0, rome), time.Time{}},
+		// 03:00 in Rome, though 02:00 UTC
+		{"other zone", time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 7, 0, 0, 0, rome)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &mockMessageSender{}
+			digestTrigger := &mockDigestTrigger{}
+			handler := NewCommandHandler(sender, nil, nil, nil, nil,
+				WithDigestTrigger(digestTrigger),
+				WithConfig(cfg),
+				WithClock(func() time.Time { return tt.now }),
+			)
+			if err := handler.HandleFetch(context.Background(), 12345); err != nil {
+				t.Fatalf("HandleFetch failed: %v", err)
+			}
+
+			if tt.queuedAt.IsZero() {
+				if !digestTrigger.triggered || !digestTrigger.queuedAt.IsZero() {
+					t.Errorf("triggered = %v, queued at %v; want sent right away", digestTrigger.triggered, digestTrigger.queuedAt)
+				}
+				if len(sender.sentMessages) != 0 {
+					t.Errorf("replied %v, want no reply", sender.sentMessages)
+				}
+				return
+			}
+			if digestTrigger.triggered || !digestTrigger.queuedAt.Equal(tt.queuedAt) {
+				t.Errorf("triggered = %v, queued at %v; want queued at %v", digestTrigger.triggered, digestTrigger.queuedAt, tt.queuedAt)
+			}
+			if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, "queued until 07:00") {
+				t.Errorf("replied %v, want the queued notice", sender.sentMessages)
+			}
+		})
+	}
+}
+
+func TestHandleFetchWithoutQuietHours(t *testing.T) {
+	digestTrigger := &mockDigestTrigger{}
+	handler := NewCommandHandler(&mockMessageSender{}, nil, nil, nil, nil,
+		WithDigestTrigger(digestTrigger),
+		WithConfig(HandlerConfig{QuietHoursStart: "23:00"}),
+		WithClock(func() time.Time { return time.Date(2024, 3, 5, 23, 30, 0, 0, time.UTC) }),
+	)
+	if err := handler.HandleFetch(context.Background(), 12345); err != nil {
+		t.Fatalf("HandleFetch failed: %v", err)
+	}
+	if !digestTrigger.triggered {
+		t.Error("digest not sent with only a quiet hours start set")
+	}
+}
+
+type mockSubscribers struct {
+	chatIDs []int64
+}
+
+func (m *mockSubscribers) GetSubscribedChatIDs(ctx context.Context) ([]int64, error) {
+	return m.chatIDs, nil
+}
+
+func TestHandleBroadcastRequiresAdmin(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithSubscribers(&mockSubscribers{chatIDs: []int64{100, 200}}),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleBroadcast(context.Background(), 12345, " Maintenance tonight"); err != nil {
+		t.Fatalf("HandleBroadcast failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected only a reply to the sender, got %d messages", len(sender.sentMessages))
+	}
+	sent := sender.sentMessages[0]
+	if sent.chatID != 12345 || !strings.Contains(sent.text, "not authorized") {
+		t.Errorf("expected 'not authorized' reply to chat 12345, got %+v", sent)
+	}
+}
+
+func TestHandleBroadcast(t *testing.T) {
+	sender := &mockMessageSender{
+		errs: map[int64]error{200: errors.New("Forbidden: bot was blocked by the user")},
+	}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithSubscribers(&mockSubscribers{chatIDs: []int64{100, 200, 300}}),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+	handler.broadcastInterval = 0
+
+	if err := handler.HandleBroadcast(context.Background(), 1, " Maintenance tonight "); err != nil {
+		t.Fatalf("HandleBroadcast failed: %v", err)
+	}
+
+	// The failed send to 200 doesn't stop the broadcast
+	if len(sender.sentMessages) != 3 {
+		t.Fatalf("expected 2 broadcasts and a report, got %d messages", len(sender.sentMessages))
+	}
+	for i, want := range []int64{100, 300} {
+		sent := sender.sentMessages[i]
+		if sent.chatID != want || sent.text != "Maintenance tonight" {
+			t.Errorf("message %d = %+v, want broadcast to %d", i, sent, want)
+		}
+	}
+
+	report := sender.sentMessages[2]
+	if report.chatID != 1 {
+		t.Errorf("report sent to chat %d, want 1", report.chatID)
+	}
+	for _, want := range []string{"sent to 2 of 3 chats", "200 (Forbidden: bot was blocked by the user)"} {
+		if !strings.Contains(report.text, want) {
+			t.Errorf("report missing %q: %s", want, report.text)
+		}
+	}
+}
+
+func TestHandleBroadcastUsage(t *testing.T) {
+	sender := &mockMessageSender{}
+	subscribers := &mockSubscribers{chatIDs: []int64{100}}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithSubscribers(subscribers),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleBroadcast(context.Background(), 1, "  "); err != nil {
+		t.Fatalf("HandleBroadcast failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, "Usage: /broadcast") {
+		t.Errorf("expected usage reply only, got %+v", sender.sentMessages)
+	}
+}
+
+type mockURLTester struct {
+	urls []string
+	err  error
+}
+
+func (m *mockURLTester) TestURL(ctx context.Context, url string) (*ArticleForDisplay, error) {
+	m.urls = append(m.urls, url)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &ArticleForDisplay{Title: url, URL: url, Summary: "A summary of the <page>."}, nil
+}
+
+func TestHandleTest(t *testing.T) {
+	sender := &mockMessageSender{}
+	tester := &mockURLTester{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithURLTester(tester),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleTest(context.Background(), 1, " https://example.com/post "); err != nil {
+		t.Fatalf("HandleTest failed: %v", err)
+	}
+
+	if len(tester.urls) != 1 || tester.urls[0] != "https://example.com/post" {
+		t.Fatalf("tested URLs = %v, want the trimmed URL", tester.urls)
+	}
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(sender.sentMessages))
+	}
+	sent := sender.sentMessages[0]
+	if sent.mode != ParseModeHTML || !strings.Contains(sent.text, "A summary of the &lt;page&gt;.") {
+		t.Errorf("expected the formatted article, got %+v", sent)
+	}
+	if strings.Contains(sent.text, "HN Discussion") {
+		t.Errorf("a tested URL has no HN discussion to link:\n%s", sent.text)
+	}
+}
+
+func TestHandleTestRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		chatID int64
+		args   string
+		want   string
+	}{
+		{"not an admin", 12345, " https://example.com/post", "not authorized"},
+		{"no URL", 1, "", "Usage: /test"},
+		{"not a URL", 1, " example", "Usage: /test"},
+		{"not http", 1, " ftp://example.com/file", "Usage: /test"},
+	}
+	for _, tt := range tests {
+		sender := &mockMessageSender{}
+		tester := &mockURLTester{}
+		handler := NewCommandHandler(sender, nil, nil, nil, nil,
+			WithURLTester(tester),
+			WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+		if err := handler.HandleTest(context.Background(), tt.chatID, tt.args); err != nil {
+			t.Fatalf("%s: HandleTest failed: %v", tt.name, err)
+		}
+		if len(tester.urls) != 0 {
+			t.Errorf("%s: tested %v, want nothing tested", tt.name, tester.urls)
+		}
+		if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, tt.want) {
+			t.Errorf("%s: expected a reply containing %q, got %+v", tt.name, tt.want, sender.sentMessages)
+			continue
+		}
+		// "<url>" in the usage would break an HTML reply
+		if mode := sender.sentMessages[0].mode; mode != ParseModeNone {
+			t.Errorf("%s: reply parse mode = %q, want plain text", tt.name, mode)
+		}
+	}
+}
+
+func TestHandleTestReportsFailure(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithURLTester(&mockURLTester{err: errors.New("scrape: 403 Forbidden")}),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleTest(context.Background(), 1, " https://example.com/post"); err != nil {
+		t.Fatalf("HandleTest failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, "scrape: 403 Forbidden") {
+		t.Errorf("expected the failure in the reply, got %+v", sender.sentMessages)
+	}
+}
+
+type mockExpander struct {
+	expanded []*ArticleInfo
+}
+
+func (m *mockExpander) ExpandArticle(ctx context.Context, article *ArticleInfo) (string, error) {
+	m.expanded = append(m.expanded, article)
+	return "A much longer summary of " + article.Title + " & more.", nil
+}
+
+func TestHandleExpand(t *testing.T) {
+	sender := &mockMessageSender{}
+	lookup := newMockArticleLookup()
+	lookup.articles[42] = &ArticleInfo{ID: 7, Title: "Go <Generics>", URL: "https://example.com/generics"}
+	expander := &mockExpander{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithExpander(lookup, expander))
+
+	if err := handler.HandleExpand(context.Background(), 12345, 42); err != nil {
+		t.Fatalf("HandleExpand failed: %v", err)
+	}
+
+	if len(lookup.lookedUpChats) != 1 || lookup.lookedUpChats[0] != 12345 {
+		t.Errorf("looked up chats %v, want [12345]", lookup.lookedUpChats)
+	}
+	if len(expander.expanded) != 1 || expander.expanded[0].ID != 7 {
+		t.Fatalf("expected article 7 to be expanded, got %v", expander.expanded)
+	}
+
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sender.sentMessages))
+	}
+	sent := sender.sentMessages[0]
+	if sent.mode != ParseModeHTML {
+		t.Error("expanded summary should be sent as HTML")
+	}
+	// The reply is message 1, and leads back to the article like message 42
+	if a := lookup.articles[1]; a == nil || a.ID != 7 {
+		t.Errorf("reply recorded as %+v, want article 7", a)
+	}
+	for _, want := range []string{
+		"<b>Go &lt;Generics&gt;</b>",
+		"A much longer summary of Go &lt;Generics&gt; &amp; more.",
+		`<a href="https://example.com/generics">Article</a>`,
+	} {
+		if !strings.Contains(sent.text, want) {
+			t.Errorf("message missing %q:\n%s", want, sent.text)
+		}
+	}
+}
+
+func TestHandleExpandWithoutArticle(t *testing.T) {
+	tests := []struct {
+		name      string
+		replyToID int64
+		want      string
+	}{
+		{"not a reply", 0, "Reply to an article with /expand"},
+		{"reply to another message", 99, "isn't an article"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &mockMessageSender{}
+			expander := &mockExpander{}
+			handler := NewCommandHandler(sender, nil, nil, nil, nil, WithExpander(newMockArticleLookup(), expander))
+
+			if err := handler.HandleExpand(context.Background(), 12345, tt.replyToID); err != nil {
+				t.Fatalf("HandleExpand failed: %v", err)
+			}
+			if len(expander.expanded) != 0 {
+				t.Error("nothing should be expanded")
+			}
+			if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, tt.want) {
+				t.Errorf("expected reply containing %q, got %+v", tt.want, sender.sentMessages)
+			}
+		})
+	}
+}
+
+type mockLikeUndoer struct {
+	likes     []*ArticleInfo // Oldest first
+	minWeight float64
+}
+
+func (m *mockLikeUndoer) UndoLastLike(ctx context.Context, chatID int64, minWeight float64) (*ArticleInfo, error) {
+	m.minWeight = minWeight
+	if len(m.likes) == 0 {
+		return nil, ErrNoLikeToUndo
+	}
+	last := m.likes[len(m.likes)-1]
+	m.likes = m.likes[:len(m.likes)-1]
+	return last, nil
+}
+
+func TestHandleUndo(t *testing.T) {
+	sender := &mockMessageSender{}
+	undoer := &mockLikeUndoer{likes: []*ArticleInfo{{ID: 1, Title: "Go Generics"}}}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithLikeUndoer(undoer),
+		WithConfig(HandlerConfig{MinTagWeight: 0.1}))
+	ctx := context.Background()
+
+	if err := handler.HandleUndo(ctx, 12345); err != nil {
+		t.Fatalf("HandleUndo failed: %v", err)
+	}
+	if undoer.minWeight != 0.1 {
+		t.Errorf("undo floor = %f, want 0.1", undoer.minWeight)
+	}
+	if !strings.Contains(sender.sentMessages[0].text, `Removed your like of "Go Generics"`) {
+		t.Errorf("unexpected reply: %s", sender.sentMessages[0].text)
+	}
+
+	// A second /undo has nothing left to undo
+	if err := handler.HandleUndo(ctx, 12345); err != nil {
+		t.Fatalf("second HandleUndo failed: %v", err)
+	}
+	if !strings.Contains(sender.sentMessages[1].text, "Nothing to undo") {
+		t.Errorf("unexpected reply: %s", sender.sentMessages[1].text)
+	}
+}
+
+func TestRescheduledDigestRunsAsScheduled(t *testing.T) {
+	schedUpdater := &mockScheduleUpdater{}
+	digestTrigger := &mockDigestTrigger{}
+
+	handler := NewCommandHandler(&mockMessageSender{}, newMockSettingsStore(), schedUpdater, nil, nil,
+		WithDigestTrigger(digestTrigger))
+	if err := handler.HandleSettings(context.Background(), 12345, "time 18:30"); err != nil {
+		t.Fatalf("HandleSettings failed: %v", err)
+	}
+
+	schedUpdater.fn()
+
+	if !digestTrigger.scheduled || digestTrigger.triggered {
+		t.Errorf("scheduled = %v, on-demand = %v; want only a scheduled digest", digestTrigger.scheduled, digestTrigger.triggered)
+	}
+}
+
+func TestHandlePreviewCommand(t *testing.T) {
+	sender := &mockMessageSender{}
+	previewer := &mockPreviewer{
+		items: []PreviewItem{
+			{Title: "Go 2.0 Released", HNScore: 500, Score: 2.41, Tags: []string{"go", "programming"}},
+			{Title: "Show HN: New Thing", HNScore: 80, Score: 0.52},
+		},
+	}
+	digestTrigger := &mockDigestTrigger{}
+
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithPreviewer(previewer),
+		WithDigestTrigger(digestTrigger),
+	)
+	if err := handler.HandlePreview(context.Background(), 12345); err != nil {
+		t.Fatalf("HandlePreview failed: %v", err)
+	}
+
+	// A single list message, not one message per article
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(sender.sentMessages))
+	}
+	msg := sender.sentMessages[0].text
+	for _, want := range []string{"Preview", "1. Go 2.0 Released", "2.41 · 500 points · go, programming", "2. Show HN: New Thing"} {
+		if !contains(msg, want) {
+			t.Errorf("preview should contain %q, got: %s", want, msg)
+		}
+	}
+	if digestTrigger.triggered {
+		t.Error("preview should not trigger a digest")
+	}
+}
+
+func TestHandlePreviewCommandEmpty(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil, WithPreviewer(&mockPreviewer{}))
+
+	if err := handler.HandlePreview(context.Background(), 12345); err != nil {
+		t.Fatalf("HandlePreview failed: %v", err)
+	}
+	if len(sender.sentMessages) != 1 || !contains(sender.sentMessages[0].text, "no new articles") {
+		t.Errorf("expected an empty preview message, got %v", sender.sentMessages)
+	}
+}
+
+func TestHandleReaction(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{
+		ID:   12345,
+		Tags: []string{"go", "testing"},
+	}
+
+	likeTracker := newMockLikeTracker()
+	tagBooster := newMockTagBooster()
+
+	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2)
+	ctx := context.Background()
+
+	err := handler.HandleReaction(ctx, 777, 100, "👍")
+	if err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+
+	// Should look up the message in the chat it was reacted to in
+	if len(articleLookup.lookedUpChats) != 1 || articleLookup.lookedUpChats[0] != 777 {
+		t.Errorf("looked up chats = %v, want [777]", articleLookup.lookedUpChats)
+	}
+
+	// Should record like
+	if !likeTracker.liked[12345] {
+		t.Error("article should be liked")
+	}
+
+	// Should boost tags
+	if tagBooster.boosted["go"] != 0.2 {
+		t.Errorf("go boost = %f, want 0.2", tagBooster.boosted["go"])
+	}
+	if tagBooster.boosted["testing"] != 0.2 {
+		t.Errorf("testing boost = %f, want 0.2", tagBooster.boosted["testing"])
+	}
+}
+
+func TestHandleReactionBoostsDomain(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{
+		ID:     12345,
+		Tags:   []string{"go"},
+		Domain: "example.com",
+	}
+
+	likeTracker := newMockLikeTracker()
+	domainBooster := &mockDomainBooster{}
+
+	handler := NewReactionHandler(articleLookup, likeTracker, newMockTagBooster(), 0.2,
+		WithDomainBooster(domainBooster))
+	ctx := context.Background()
+
+	if err := handler.HandleReaction(ctx, 777, 100, "👍"); err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+	// A repeated like must not boost again
+	if err := handler.HandleReaction(ctx, 777, 100, "👍"); err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+
+	if domainBooster.boosted["example.com"] != 0.2 {
+		t.Errorf("example.com boost = %f, want 0.2", domainBooster.boosted["example.com"])
+	}
+}
+
+type mockLikeRecorder struct {
+	chats        map[int64]int64
+	tagBoosts    map[int64]map[string]float64
+	domainBoosts map[int64]map[string]float64
+}
+
+func (m *mockLikeRecorder) RecordLike(ctx context.Context, articleID, chatID int64, tagBoosts, domainBoosts map[string]float64) error {
+	if m.tagBoosts == nil {
+		m.chats = make(map[int64]int64)
+		m.tagBoosts = make(map[int64]map[string]float64)
+		m.domainBoosts = make(map[int64]map[string]float64)
+	}
+	m.chats[articleID] = chatID
+	m.tagBoosts[articleID] = tagBoosts
+	m.domainBoosts[articleID] = domainBoosts
+	return nil
+}
+
+func TestHandleReactionRecordsLikeBoosts(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{ID: 12345, Tags: []string{"go", "rust"}, Domain: "example.com"}
+	recorder := &mockLikeRecorder{}
+	tagBooster := newMockTagBooster()
+	domainBooster := &mockDomainBooster{}
+
+	handler := NewReactionHandler(articleLookup, newMockLikeTracker(), tagBooster, 0.2,
+		WithDomainBooster(domainBooster),
+		WithLikeRecorder(recorder))
+
+	if err := handler.HandleReaction(context.Background(), 777, 100, "👍"); err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+
+	tags := recorder.tagBoosts[12345]
+	if len(tags) != 2 || tags["go"] != 0.2 || tags["rust"] != 0.2 {
+		t.Errorf("recorded tag boosts = %v, want go and rust at 0.2", tags)
+	}
+	if domains := recorder.domainBoosts[12345]; domains["example.com"] != 0.2 {
+		t.Errorf("recorded domain boosts = %v, want example.com at 0.2", domains)
+	}
+	if chat := recorder.chats[12345]; chat != 777 {
+		t.Errorf("recorded like from chat %d, want 777", chat)
+	}
+	// The recorder applies the boosts itself, in the like's transaction
+	if len(tagBooster.boosted) != 0 || len(domainBooster.boosted) != 0 {
+		t.Errorf("boosted outside the recorder: tags %v, domains %v", tagBooster.boosted, domainBooster.boosted)
+	}
+}
+
+func TestHandleReactionBoostCurves(t *testing.T) {
+	// Total boost to "go" after 10 likes, each on a different article
+	growth := func(curve BoostCurve) float64 {
+		articleLookup := newMockArticleLookup()
+		for i := range 10 {
+			articleLookup.articles[int64(100+i)] = &ArticleInfo{ID: int64(i + 1), Tags: []string{"go"}}
+		}
+		tagBooster := newMockTagBooster()
+		handler := NewReactionHandler(articleLookup, newMockLikeTracker(), tagBooster, 0.2,
+			WithBoostCurve(curve, tagBooster))
+
+		for i := range 10 {
+			if err := handler.HandleReaction(context.Background(), 777, int64(100+i), "👍"); err != nil {
+				t.Fatalf("HandleReaction failed: %v", err)
+			}
+		}
+		return tagBooster.boosted["go"]
+	}
+
+	linear, logGrowth, sqrtGrowth := growth(BoostLinear), growth(BoostLog), growth(BoostSqrt)
+	if math.Abs(linear-2.0) > 1e-9 {
+		t.Errorf("linear growth = %f, want 2.0", linear)
+	}
+	// Both diminishing curves still grant the full boost on the first like
+	if logGrowth <= 0.2 || logGrowth >= linear {
+		t.Errorf("log growth = %f, want between 0.2 and linear (%f)", logGrowth, linear)
+	}
+	if sqrtGrowth <= 0.2 || sqrtGrowth >= linear {
+		t.Errorf("sqrt growth = %f, want between 0.2 and linear (%f)", sqrtGrowth, linear)
+	}
+	if got := growth(""); got != linear {
+		t.Errorf("default growth = %f, want linear (%f)", got, linear)
+	}
+}
+
+func TestBoostCurveScale(t *testing.T) {
+	tests := []struct {
+		curve BoostCurve
+		count int
+		want  float64
+	}{
+		{BoostLinear, 0, 0.2},
+		{BoostLinear, 9, 0.2},
+		{BoostLog, 0, 0.2},
+		{BoostLog, 9, 0.2 / (1 + math.Log(10))},
+		{BoostSqrt, 0, 0.2},
+		{BoostSqrt, 3, 0.1},
+		{"unknown", 5, 0.2},
+	}
+	for _, tt := range tests {
+		if got := tt.curve.Scale(0.2, tt.count); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s.Scale(0.2, %d) = %f, want %f", tt.curve, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestHandleReactionNonThumbsUp(t *testing.T) {
+	handler := NewReactionHandler(nil, nil, nil, 0.2)
+	ctx := context.Background()
+
+	// Non-thumbs-up reactions should be ignored (no error)
+	err := handler.HandleReaction(ctx, 777, 100, "❤️")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = handler.HandleReaction(ctx, 777, 100, "🎉")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHandleReactionConfiguredLikeEmojis(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{ID: 12345, Tags: []string{"go"}}
+
+	likeTracker := newMockLikeTracker()
+	tagBooster := newMockTagBooster()
+
+	// Configured with the variation selector, as users typically type it
+	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2,
+		WithLikeEmojis([]string{"❤️", "🔥"}),
+	)
+	ctx := context.Background()
+
+	// Telegram sends the heart without the variation selector
+	if err := handler.HandleReaction(ctx, 777, 100, "❤"); err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+
+	if !likeTracker.liked[12345] {
+		t.Error("❤️ should count as a like when configured")
+	}
+	if tagBooster.boosted["go"] != 0.2 {
+		t.Errorf("go boost = %f, want 0.2", tagBooster.boosted["go"])
+	}
+
+	// 👍 is no longer in the configured set
+	articleLookup.articles[101] = &ArticleInfo{ID: 6789, Tags: []string{"rust"}}
+	if err := handler.HandleReaction(ctx, 777, 101, "👍"); err != nil {
+		t.Fatalf("HandleReaction failed: %v", err)
+	}
+	if likeTracker.liked[6789] {
+		t.Error("👍 should be ignored when not configured")
+	}
+}
+
+func TestHandleReactionDislike(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{ID: 12345, Tags: []string{"crypto"}}
+
+	likeTracker := newMockLikeTracker()
+	dislikeTracker := newMockDislikeTracker()
+	tagBooster := newMockTagBooster()
+
+	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2,
+		WithDislikeEmojis(dislikeTracker, []string{"👎"}),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleReaction(ctx, 777, 100, "👎"); err != nil {
+			t.Fatalf("HandleReaction failed: %v", err)
+		}
+	}
+
+	if !dislikeTracker.disliked[12345] {
+		t.Error("article should be disliked")
+	}
+	if likeTracker.liked[12345] {
+		t.Error("dislike should not record a like")
+	}
+	// Applied once despite two reactions
+	if tagBooster.boosted["crypto"] != -0.2 {
+		t.Errorf("crypto boost = %f, want -0.2", tagBooster.boosted["crypto"])
+	}
+}
+
+func TestHandleReactionUnknownMessage(t *testing.T) {
+	articleLookup := newMockArticleLookup() // Empty
+
+	handler := NewReactionHandler(articleLookup, nil, nil, 0.2)
+	ctx := context.Background()
+
+	// Unknown message ID should be silently ignored
+	err := handler.HandleReaction(ctx, 777, 999, "👍")
+	if err != nil {
+		t.Fatalf("unexpected error for unknown message: %v", err)
+	}
+}
+
+func TestHandleReactionAlreadyLiked(t *testing.T) {
+	articleLookup := newMockArticleLookup()
+	articleLookup.articles[100] = &ArticleInfo{
+		ID:   12345,
+		Tags: []string{"go"},
+	}
+
+	likeTracker := newMockLikeTracker()
+	likeTracker.liked[12345] = true // Already liked
+
+	tagBooster := newMockTagBooster()
+
+	handler := NewReactionHandler(articleLookup, likeTracker, tagBooster, 0.2)
+	ctx := context.Background()
+
+	err := handler.HandleReaction(ctx, 777, 100, "👍")
+	if err != nil {
 		t.Fatalf("HandleReaction failed: %v", err)
 	}
 
@@ -435,6 +1806,107 @@ func TestFormatArticleMessage(t *testing.T) {
 	}
 }
 
+func TestFormatArticleMessageExplanation(t *testing.T) {
+	article := &ArticleForDisplay{
+		ID:          12345,
+		Title:       "Test",
+		URL:         "https://example.com",
+		Explanation: "matched: c++ (1.50)",
+	}
+
+	msg := FormatArticleMessage(article)
+	if !strings.Contains(msg, "🔎 matched: c++ (1.50)") {
+		t.Errorf("message should include explanation, got: %s", msg)
+	}
+
+	article.Explanation = ""
+	if msg := FormatArticleMessage(article); strings.Contains(msg, "🔎") {
+		t.Errorf("message should omit empty explanation, got: %s", msg)
+	}
+}
+
+func TestFormatArticleMessageDiscussion(t *testing.T) {
+	article := &ArticleForDisplay{
+		ID:         12345,
+		Title:      "Test",
+		Summary:    "A summary",
+		URL:        "https://example.com",
+		Discussion: "Commenters are <mostly> skeptical",
+	}
+
+	msg := FormatArticleMessage(article)
+	if !strings.Contains(msg, "<i>A summary</i>\n\n🗣 Commenters are &lt;mostly&gt; skeptical\n\n⬆️") {
+		t.Errorf("message should include the escaped discussion after the summary, got: %s", msg)
+	}
+
+	article.Discussion = ""
+	if msg := FormatArticleMessage(article); strings.Contains(msg, "🗣") {
+		t.Errorf("message should omit empty discussion, got: %s", msg)
+	}
+}
+
+func TestFormatArticleMessageTruncatesLongSummary(t *testing.T) {
+	article := &ArticleForDisplay{
+		ID:          12345,
+		Title:       "Test",
+		Summary:     strings.Repeat("word & more ", 600),
+		HNScore:     100,
+		Comments:    50,
+		URL:         "https://example.com/article",
+		Explanation: "matched: go (1.50)",
+	}
+
+	for _, limit := range []int{0, 500} {
+		article.MaxLength = limit
+		msg := FormatArticleMessage(article)
+
+		want := limit
+		if want == 0 {
+			want = maxMessageLength
+		}
+		if n := utf8.RuneCountInString(msg); n > want {
+			t.Errorf("limit %d: message length = %d, want at most %d", limit, n, want)
+		}
+		if !strings.Contains(msg, "word…</i>") && !strings.Contains(msg, "more…</i>") {
+			t.Errorf("limit %d: summary should end at a whole word with an ellipsis, got: %s", limit, msg)
+		}
+		for _, part := range []string{
+			`<a href="https://example.com/article">Article</a>`,
+			`<a href="https://news.ycombinator.com/item?id=12345">HN Discussion</a>`,
+			"🔎 matched: go (1.50)",
+		} {
+			if !strings.Contains(msg, part) {
+				t.Errorf("limit %d: message should keep %q", limit, part)
+			}
+		}
+	}
+
+	// A summary that fits is left alone
+	article.Summary = "Short & sweet"
+	article.MaxLength = 0
+	if msg := FormatArticleMessage(article); !strings.Contains(msg, "<i>Short &amp; sweet</i>") {
+		t.Errorf("short summary should be unchanged, got: %s", msg)
+	}
+}
+
+func TestFormatArticleMessageTextPost(t *testing.T) {
+	article := &ArticleForDisplay{
+		ID:      12345,
+		Title:   "Ask HN: Something",
+		Summary: "A question",
+		URL:     "https://news.ycombinator.com/item?id=12345",
+	}
+
+	msg := FormatArticleMessage(article)
+
+	if strings.Contains(msg, ">Article</a>") {
+		t.Errorf("text post should not have a separate Article link, got: %s", msg)
+	}
+	if !strings.Contains(msg, `<a href="https://news.ycombinator.com/item?id=12345">HN Discussion</a>`) {
+		t.Errorf("text post should link to HN discussion, got: %s", msg)
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
@@ -448,3 +1920,25 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestHandleTestEscapesURL(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, nil, nil, nil, nil,
+		WithURLTester(&mockURLTester{}),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleTest(context.Background(), 1, ` https://example.com/a"><b>x</b>`); err != nil {
+		t.Fatalf("HandleTest failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(sender.sentMessages))
+	}
+	text := sender.sentMessages[0].text
+	if !strings.Contains(text, `<a href="https://example.com/a&#34;&gt;&lt;b&gt;x&lt;/b&gt;">Article</a>`) {
+		t.Errorf("expected the URL escaped in the link, got:\n%s", text)
+	}
+	if strings.Contains(text, "<b>x</b>") {
+		t.Errorf("the URL's markup reached the reply:\n%s", text)
+	}
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFooterTemplate is the footer articles are sent with unless
+// another template is configured.
+const DefaultFooterTemplate = "⬆️ {score} points | 💬 {comments} comments\n{links}"
+
+// footerFields are the placeholders a footer template may use.
+var footerFields = []string{"score", "comments", "read_time", "age", "source", "by", "trend", "links"}
+
+// footerSeparator separates the parts of a footer line. A part whose
+// placeholders are all empty, such as the read time of an article that
+// couldn't be scraped, is left out along with its separator.
+const footerSeparator = " | "
+
+// readingWPM is the reading speed read times are estimated at.
+const readingWPM = 230
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// FooterTemplate lays out the lines below an article's summary: its
+// score, comment count, read time, age, source, submitter, score trend and
+// links. Each field is shown only if the template has its placeholder.
+type FooterTemplate struct {
+	lines [][]string // Parts of each line, split on footerSeparator
+}
+
+// ParseFooterTemplate parses a footer template, returning the default
+// footer for an empty one. Templates may use the placeholders {score},
+// {comments}, {read_time}, {age}, {source}, {by}, {trend} and {links};
+// {links} is required, since it is the only way to reach the article.
+func ParseFooterTemplate(s string) (*FooterTemplate, error) {
+	if s == "" {
+		s = DefaultFooterTemplate
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		if !slices.Contains(footerFields, m[1]) {
+			return nil, fmt.Errorf("unknown footer placeholder {%s}: want one of {%s}", m[1], strings.Join(footerFields, "}, {"))
+		}
+	}
+	if !strings.Contains(s, "{links}") {
+		return nil, fmt.Errorf("footer template must include {links}")
+	}
+
+	f := &FooterTemplate{}
+	for _, line := range strings.Split(s, "\n") {
+		f.lines = append(f.lines, strings.Split(line, footerSeparator))
+	}
+	return f, nil
+}
+
+// defaultFooter is used for articles that don't set a footer template.
+var defaultFooter, _ = ParseFooterTemplate(DefaultFooterTemplate)
+
+// render returns the footer for an article, with links being its already
+// formatted links.
+func (f *FooterTemplate) render(article *ArticleForDisplay, links string, now time.Time) string {
+	values := map[string]string{
+		"score":    strconv.Itoa(article.HNScore),
+		"comments": strconv.Itoa(article.Comments),
+		"source":   ParseModeHTML.Escape(article.Source),
+		"by":       ParseModeHTML.Escape(article.By),
+		"trend":    formatTrend(article, now),
+		"links":    links,
+	}
+	if article.WordCount > 0 {
+		minutes := max(1, int(math.Round(float64(article.WordCount)/readingWPM)))
+		values["read_time"] = fmt.Sprintf("%d min", minutes)
+	}
+	if !article.PostedAt.IsZero() {
+		values["age"] = formatAge(now.Sub(article.PostedAt))
+	}
+
+	var lines []string
+	for _, parts := range f.lines {
+		var rendered []string
+		for _, part := range parts {
+			if s, ok := renderPart(part, values); ok {
+				rendered = append(rendered, s)
+			}
+		}
+		if line := strings.Join(rendered, footerSeparator); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderPart fills in a part's placeholders, reporting false if it has
+// placeholders and all of them are empty.
+func renderPart(part string, values map[string]string) (string, bool) {
+	matches := placeholderPattern.FindAllStringSubmatch(part, -1)
+	if len(matches) == 0 {
+		return part, true
+	}
+	filled := false
+	for _, m := range matches {
+		if values[m[1]] != "" {
+			filled = true
+		}
+	}
+	if !filled {
+		return "", false
+	}
+	return placeholderPattern.ReplaceAllStringFunc(part, func(p string) string {
+		return values[strings.Trim(p, "{}")]
+	}), true
+}
+
+// formatTrend formats how much an article's score has grown since it was
+// first fetched, such as "▲ 120 since yesterday", or returns "" if it was
+// never fetched before or hasn't gained points.
+func formatTrend(article *ArticleForDisplay, now time.Time) string {
+	delta := article.HNScore - article.FirstScore
+	if article.FirstSeenAt.IsZero() || delta <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("▲ %d %s", delta, formatSince(now.Sub(article.FirstSeenAt)))
+}
+
+// formatSince formats how long ago something happened, as a phrase such as
+// "in 5h", "since yesterday" or "in 3d".
+func formatSince(d time.Duration) string {
+	switch days := int(d.Hours() / 24); {
+	case d < 24*time.Hour:
+		return "in " + formatAge(d)
+	case days == 1:
+		return "since yesterday"
+	default:
+		return fmt.Sprintf("in %dd", days)
+	}
+}
+
+// formatAge formats how long ago an article was posted, such as "45m",
+// "3h" or "2d".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", max(0, int(d.Minutes())))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
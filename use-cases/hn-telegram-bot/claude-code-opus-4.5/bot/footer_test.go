@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatArticleMessageDefaultFooter(t *testing.T) {
+	article := &ArticleForDisplay{
+		ID:        12345,
+		Title:     "Test",
+		Summary:   "Summary",
+		HNScore:   100,
+		Comments:  50,
+		URL:       "https://example.com/article",
+		WordCount: 1000,
+		PostedAt:  time.Now().Add(-3 * time.Hour),
+		Source:    "top",
+	}
+
+	msg := FormatArticleMessage(article)
+
+	want := "⬆️ 100 points | 💬 50 comments\n" +
+		`<a href="https://example.com/article">Article</a> | <a href="https://news.ycombinator.com/item?id=12345">HN Discussion</a>`
+	if !strings.HasSuffix(msg, want) {
+		t.Errorf("default footer changed, got:\n%s", msg)
+	}
+}
+
+func TestFormatArticleMessageCustomFooter(t *testing.T) {
+	footer, err := ParseFooterTemplate("{links}\n⏱ {read_time} | 🕒 {age} ago | 💬 {comments}")
+	if err != nil {
+		t.Fatalf("ParseFooterTemplate failed: %v", err)
+	}
+	article := &ArticleForDisplay{
+		ID:        12345,
+		Title:     "Test",
+		Summary:   "Summary",
+		HNScore:   100,
+		Comments:  50,
+		URL:       "https://example.com/article",
+		WordCount: 920,
+		PostedAt:  time.Now().Add(-3*time.Hour - 10*time.Minute),
+		Source:    "top",
+		Footer:    footer,
+	}
+
+	msg := FormatArticleMessage(article)
+
+	want := "HN Discussion</a>\n⏱ 4 min | 🕒 3h ago | 💬 50"
+	if !strings.HasSuffix(msg, want) {
+		t.Errorf("message should end with %q:\n%s", want, msg)
+	}
+	for _, omitted := range []string{"100", "points", "top"} {
+		if strings.Contains(msg, omitted) {
+			t.Errorf("message should not contain %q:\n%s", omitted, msg)
+		}
+	}
+}
+
+func TestFormatArticleMessageFooterSkipsUnknownFields(t *testing.T) {
+	footer, err := ParseFooterTemplate("⬆️ {score} | ⏱ {read_time} | 🕒 {age} | {source}\n{links}")
+	if err != nil {
+		t.Fatalf("ParseFooterTemplate failed: %v", err)
+	}
+	article := &ArticleForDisplay{
+		ID:      12345,
+		Title:   "Ask HN: Test",
+		Summary: "Summary",
+		HNScore: 42,
+		Footer:  footer,
+	}
+
+	msg := FormatArticleMessage(article)
+
+	if !strings.Contains(msg, "⬆️ 42\n") {
+		t.Errorf("expected only the score on the first footer line:\n%s", msg)
+	}
+}
+
+func TestFormatArticleMessageSubmitterAndTrend(t *testing.T) {
+	footer, err := ParseFooterTemplate("⬆️ {score} | by {by} | {trend}\n{links}")
+	if err != nil {
+		t.Fatalf("ParseFooterTemplate failed: %v", err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		score       int
+		firstScore  int
+		firstSeenAt time.Time
+		want        string
+	}{
+		{"re-trending since yesterday", 220, 100, now.Add(-30 * time.Hour), "⬆️ 220 | by pg | ▲ 120 since yesterday\n"},
+		{"re-trending within hours", 150, 100, now.Add(-5*time.Hour - time.Minute), "⬆️ 150 | by pg | ▲ 50 in 5h\n"},
+		{"re-trending over days", 400, 100, now.Add(-73 * time.Hour), "⬆️ 400 | by pg | ▲ 300 in 3d\n"},
+		{"unchanged score", 100, 100, now.Add(-30 * time.Hour), "⬆️ 100 | by pg\n"},
+		{"lower score", 90, 100, now.Add(-30 * time.Hour), "⬆️ 90 | by pg\n"},
+		{"first seen now", 100, 0, time.Time{}, "⬆️ 100 | by pg\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := FormatArticleMessage(&ArticleForDisplay{
+				ID:          12345,
+				Title:       "Test",
+				Summary:     "Summary",
+				HNScore:     tt.score,
+				By:          "pg",
+				FirstScore:  tt.firstScore,
+				FirstSeenAt: tt.firstSeenAt,
+				Footer:      footer,
+			})
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("footer missing %q:\n%s", tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestParseFooterTemplateInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"unknown placeholder", "{score} | {votes}\n{links}"},
+		{"missing links", "⬆️ {score} points"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseFooterTemplate(tt.template); err == nil {
+			t.Errorf("%s: expected error for %q", tt.name, tt.template)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{45 * time.Minute, "45m"},
+		{3*time.Hour + 59*time.Minute, "3h"},
+		{50 * time.Hour, "2d"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// userList is a list users build up with commands, stored in a setting as
+// comma-separated items.
+type userList struct {
+	name    string // As given to /lists clear
+	icon    string
+	title   string
+	setting string
+	// configured returns the items the config file adds to the list,
+	// which /lists clear leaves in place.
+	configured func(HandlerConfig) []string
+}
+
+// pinnedList holds the tags pinned with /settings pin.
+var pinnedList = userList{
+	name:       "pinned",
+	icon:       "📌",
+	title:      "Pinned tags",
+	setting:    "pinned_tags",
+	configured: func(cfg HandlerConfig) []string { return cfg.PinnedTags },
+}
+
+// userLists are the lists /lists shows and clears, in display order.
+var userLists = []userList{pinnedList}
+
+// HandleLists handles the /lists command, which shows the user's lists,
+// and /lists clear NAME, which empties one of them.
+func (h *CommandHandler) HandleLists(ctx context.Context, chatID int64, args string) error {
+	fields := strings.Fields(strings.ToLower(args))
+	switch {
+	case len(fields) == 0:
+		return h.displayLists(ctx, chatID)
+	case len(fields) == 2 && fields[0] == "clear":
+		for _, list := range userLists {
+			if list.name == fields[1] {
+				return h.clearList(ctx, chatID, list)
+			}
+		}
+	}
+
+	names := make([]string, len(userLists))
+	for i, list := range userLists {
+		names[i] = list.name
+	}
+	msg := fmt.Sprintf("Usage: /lists [clear %s]\nExample: /lists clear %s", strings.Join(names, "|"), names[0])
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+func (h *CommandHandler) displayLists(ctx context.Context, chatID int64) error {
+	var sb strings.Builder
+	sb.WriteString("📋 Your lists:\n")
+	for _, list := range userLists {
+		items := "none"
+		if stored := h.storedList(ctx, list); len(stored) > 0 {
+			items = strings.Join(stored, ", ")
+		}
+		fmt.Fprintf(&sb, "\n%s %s: %s", list.icon, list.title, items)
+		if configured := list.configured(h.config); len(configured) > 0 {
+			fmt.Fprintf(&sb, "\n   From the config file: %s", strings.Join(configured, ", "))
+		}
+	}
+	sb.WriteString("\n\nClear one with /lists clear NAME")
+
+	_, err := h.sender.SendMessage(ctx, chatID, sb.String(), ParseModeNone)
+	return err
+}
+
+func (h *CommandHandler) clearList(ctx context.Context, chatID int64, list userList) error {
+	stored := h.storedList(ctx, list)
+	if len(stored) > 0 {
+		if err := h.settings.SetSetting(ctx, list.setting, ""); err != nil {
+			return fmt.Errorf("clear %s: %w", list.setting, err)
+		}
+	}
+
+	msg := fmt.Sprintf("🗑 Cleared %s: %d removed.", strings.ToLower(list.title), len(stored))
+	if configured := list.configured(h.config); len(configured) > 0 {
+		msg += fmt.Sprintf("\n%s from the config file can't be cleared here.", strings.Join(configured, ", "))
+	}
+	_, err := h.sender.SendMessage(ctx, chatID, msg, ParseModeNone)
+	return err
+}
+
+// storedList returns the items stored in a list.
+func (h *CommandHandler) storedList(ctx context.Context, list userList) []string {
+	v, err := h.settings.GetSetting(ctx, list.setting)
+	if err != nil || v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHandleListsShowsLists(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	settings.settings["pinned_tags"] = "security,rust"
+	handler := NewCommandHandler(sender, settings, nil, nil, nil,
+		WithConfig(HandlerConfig{PinnedTags: []string{"go"}}))
+
+	if err := handler.HandleLists(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleLists failed: %v", err)
+	}
+
+	msg := sender.sentMessages[0].text
+	for _, want := range []string{"📌 Pinned tags: security, rust", "From the config file: go", "/lists clear"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("listing should contain %q, got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestHandleListsShowsEmptyLists(t *testing.T) {
+	sender := &mockMessageSender{}
+	handler := NewCommandHandler(sender, newMockSettingsStore(), nil, nil, nil)
+
+	if err := handler.HandleLists(context.Background(), 12345, ""); err != nil {
+		t.Fatalf("HandleLists failed: %v", err)
+	}
+	if msg := sender.sentMessages[0].text; !strings.Contains(msg, "Pinned tags: none") || strings.Contains(msg, "config file") {
+		t.Errorf("empty listing changed, got:\n%s", msg)
+	}
+}
+
+func TestHandleListsClear(t *testing.T) {
+	sender := &mockMessageSender{}
+	settings := newMockSettingsStore()
+	settings.settings["pinned_tags"] = "security,rust"
+	settings.settings["format"] = FormatPhoto
+	handler := NewCommandHandler(sender, settings, nil, nil, nil,
+		WithConfig(HandlerConfig{PinnedTags: []string{"go"}}))
+
+	if err := handler.HandleLists(context.Background(), 12345, " clear Pinned"); err != nil {
+		t.Fatalf("HandleLists failed: %v", err)
+	}
+
+	if v := settings.settings["pinned_tags"]; v != "" {
+		t.Errorf("pinned_tags = %q, want cleared", v)
+	}
+	if v := settings.settings["format"]; v != FormatPhoto {
+		t.Errorf("format = %q, want other settings untouched", v)
+	}
+	msg := sender.sentMessages[0].text
+	if !strings.Contains(msg, "Cleared pinned tags: 2 removed") || !strings.Contains(msg, "go from the config file") {
+		t.Errorf("clear reply changed, got:\n%s", msg)
+	}
+}
+
+func TestHandleListsRejected(t *testing.T) {
+	for _, args := range []string{" clear", " clear muted", " show pinned"} {
+		sender := &mockMessageSender{}
+		settings := newMockSettingsStore()
+		settings.settings["pinned_tags"] = "security"
+		handler := NewCommandHandler(sender, settings, nil, nil, nil)
+
+		if err := handler.HandleLists(context.Background(), 12345, args); err != nil {
+			t.Fatalf("HandleLists(%q) failed: %v", args, err)
+		}
+		if msg := sender.sentMessages[0].text; !strings.HasPrefix(msg, "Usage: /lists") {
+			t.Errorf("HandleLists(%q) reply = %q, want the usage", args, msg)
+		}
+		if v := settings.settings["pinned_tags"]; v != "security" {
+			t.Errorf("HandleLists(%q) changed pinned_tags to %q", args, v)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"hn-telegram-bot/util"
+)
+
+const (
+	defaultAPIBaseURL  = "https://api.telegram.org"
+	defaultPollTimeout = 30
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = time.Minute
+)
+
+// Update types that can be requested from getUpdates.
+const (
+	UpdateMessage         = "message"
+	UpdateMessageReaction = "message_reaction"
+)
+
+// Update represents a Telegram update with reaction support.
+type Update struct {
+	UpdateID        int               `json:"update_id"`
+	Message         *tgbotapi.Message `json:"message"`
+	MessageReaction *MessageReaction  `json:"message_reaction"`
+}
+
+// MessageReaction represents a reaction update from Telegram.
+type MessageReaction struct {
+	Chat        Chat           `json:"chat"`
+	MessageID   int            `json:"message_id"`
+	Date        int            `json:"date"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
+// AddedEmojis returns the emojis present in the new reaction but not the old one.
+func (r *MessageReaction) AddedEmojis() []string {
+	old := make(map[string]bool, len(r.OldReaction))
+	for _, rt := range r.OldReaction {
+		old[rt.Emoji] = true
+	}
+
+	var added []string
+	for _, rt := range r.NewReaction {
+		if rt.Emoji != "" && !old[rt.Emoji] {
+			added = append(added, rt.Emoji)
+		}
+	}
+	return added
+}
+
+// Chat represents a Telegram chat.
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+// ReactionType represents a reaction emoji.
+type ReactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji"`
+}
+
+// UpdateHandler processes a single update received by the Poller.
+type UpdateHandler func(ctx context.Context, update *Update)
+
+// Poller long-polls the Telegram getUpdates endpoint.
+// The tgbotapi library does not support message_reaction updates,
+// so polling is done manually.
+type Poller struct {
+	token       string
+	baseURL     string
+	timeout     int
+	httpClient  *http.Client
+	backoff     *backoff
+	updateTypes []string
+	heartbeat   func() // Called on each pass of the poll loop, if set
+}
+
+// PollerOption configures a Poller.
+type PollerOption func(*Poller)
+
+// WithAPIBaseURL sets a custom Telegram API base URL, such as a
+// self-hosted Bot API server or a test server. An empty URL keeps the
+// default.
+func WithAPIBaseURL(url string) PollerOption {
+	return func(p *Poller) {
+		if url != "" {
+			p.baseURL = strings.TrimRight(url, "/")
+		}
+	}
+}
+
+// WithBackoff sets the minimum and maximum delay between failed polls.
+func WithBackoff(min, max time.Duration) PollerOption {
+	return func(p *Poller) {
+		p.backoff.min = min
+		p.backoff.max = max
+	}
+}
+
+// WithUpdateTypes sets the update types requested from Telegram. Types
+// not listed are never delivered, so features that depend on them stay
+// inactive.
+func WithUpdateTypes(types ...string) PollerOption {
+	return func(p *Poller) {
+		p.updateTypes = types
+	}
+}
+
+// WithHeartbeat calls beat each time the poll loop comes round, after a
+// poll or a failed poll's backoff, so that a stuck loop can be detected.
+func WithHeartbeat(beat func()) PollerOption {
+	return func(p *Poller) {
+		p.heartbeat = beat
+	}
+}
+
+// NewPoller creates a new long-polling client for the given bot token.
+func NewPoller(token string, opts ...PollerOption) *Poller {
+	p := &Poller{
+		token:       token,
+		baseURL:     defaultAPIBaseURL,
+		timeout:     defaultPollTimeout,
+		backoff:     &backoff{min: defaultMinBackoff, max: defaultMaxBackoff},
+		updateTypes: []string{UpdateMessage, UpdateMessageReaction},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.httpClient = &http.Client{Timeout: time.Duration(p.timeout+10) * time.Second}
+	return p
+}
+
+// Run polls for updates until the context is cancelled, passing each
+// update to handle. Transient errors are retried with exponential backoff.
+func (p *Poller) Run(ctx context.Context, handle UpdateHandler) {
+	offset := 0
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if p.heartbeat != nil {
+			p.heartbeat()
+		}
+
+		updates, next, err := p.getUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			delay := p.backoff.next()
+			slog.Warn("failed to get updates", "attempt", p.backoff.attempt, "retry_in", delay, "error", err)
+
+			if util.Sleep(ctx, delay) != nil {
+				return
+			}
+			continue
+		}
+		p.backoff.reset()
+
+		for i := range updates {
+			offset = updates[i].UpdateID + 1
+			handle(ctx, &updates[i])
+		}
+		// Skipped updates must be confirmed too, or they'd be redelivered
+		offset = max(offset, next)
+	}
+}
+
+// getUpdates fetches the updates after offset. Updates of types this
+// package doesn't know are returned with only their ID set. Updates that
+// can't be decoded are left out; next is the offset that confirms the
+// whole batch, including those, or 0 if the batch is empty.
+func (p *Poller) getUpdates(ctx context.Context, offset int) (updates []Update, next int, err error) {
+	allowedUpdates, err := json.Marshal(p.updateTypes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("encode allowed updates: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("timeout", strconv.Itoa(p.timeout))
+	params.Set("allowed_updates", string(allowedUpdates))
+	endpoint := fmt.Sprintf("%s/bot%s/getUpdates?%s", p.baseURL, p.token, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		// Avoid leaking the bot token, which is part of the request URL
+		if urlErr, ok := err.(*url.Error); ok {
+			return nil, 0, urlErr.Err
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	// Updates are decoded one at a time, so that one Telegram sends in an
+	// unexpected shape doesn't fail the whole batch
+	var result struct {
+		OK     bool              `json:"ok"`
+		Result []json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if !result.OK {
+		return nil, 0, fmt.Errorf("telegram API returned not OK")
+	}
+
+	for _, raw := range result.Result {
+		update, id, ok := decodeUpdate(raw)
+		if id >= next {
+			next = id + 1
+		}
+		if ok {
+			updates = append(updates, update)
+		}
+	}
+	return updates, next, nil
+}
+
+// decodeUpdate decodes a single update, returning its ID even when the
+// rest of it can't be decoded, in which case ok is false. The ID is -1 if
+// even that is missing.
+func decodeUpdate(raw json.RawMessage) (update Update, id int, ok bool) {
+	if err := json.Unmarshal(raw, &update); err == nil {
+		return update, update.UpdateID, true
+	}
+
+	var header struct {
+		UpdateID *int `json:"update_id"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil || header.UpdateID == nil {
+		slog.Warn("skipping update without an ID", "update", string(raw))
+		return Update{}, -1, false
+	}
+	slog.Warn("skipping undecodable update", "update_id", *header.UpdateID)
+	return Update{}, *header.UpdateID, false
+}
+
+// backoff computes capped exponential delays with jitter.
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+// next records a failed attempt and returns how long to wait before retrying.
+// The delay doubles with each attempt up to max, and is jittered into the
+// upper half of that range so that many clients don't retry in lockstep.
+func (b *backoff) next() time.Duration {
+	b.attempt++
+
+	d := b.min
+	for i := 1; i < b.attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// reset clears the attempt count after a successful poll.
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPollerRecoversFromErrors(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	var offsets []string
+
+	// Fail three times, succeed, fail once more, then succeed again
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		offsets = append(offsets, r.URL.Query().Get("offset"))
+		mu.Unlock()
+
+		if r.URL.Path != "/bottest-token/getUpdates" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		switch n {
+		case 1, 2, 3, 5:
+			w.WriteHeader(http.StatusBadGateway)
+		case 4:
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": []map[string]any{{"update_id": 10}},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": []map[string]any{{"update_id": 11}},
+			})
+		}
+	}))
+	defer server.Close()
+
+	beats := 0
+	poller := NewPoller("test-token",
+		WithAPIBaseURL(server.URL),
+		WithBackoff(time.Millisecond, 5*time.Millisecond),
+		WithHeartbeat(func() { beats++ }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var received []int
+	var attemptsAtUpdate []int
+	poller.Run(ctx, func(ctx context.Context, update *Update) {
+		received = append(received, update.UpdateID)
+		attemptsAtUpdate = append(attemptsAtUpdate, poller.backoff.attempt)
+		if len(received) == 2 {
+			cancel()
+		}
+	})
+
+	if len(received) != 2 || received[0] != 10 || received[1] != 11 {
+		t.Fatalf("received updates = %v, want [10 11]", received)
+	}
+
+	// Backoff should be reset after each successful poll
+	for i, attempt := range attemptsAtUpdate {
+		if attempt != 0 {
+			t.Errorf("backoff attempt at update %d = %d, want 0", i, attempt)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Every pass of the loop beats, failed polls included
+	if beats != requests {
+		t.Errorf("heartbeat beat %d times, want once per poll (%d)", beats, requests)
+	}
+	if offsets[0] != "0" {
+		t.Errorf("first offset = %q, want '0'", offsets[0])
+	}
+	if last := offsets[len(offsets)-1]; last != "11" {
+		t.Errorf("offset after first update = %q, want '11'", last)
+	}
+}
+
+func TestPollerRequestsConfiguredUpdateTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []PollerOption
+		want string
+	}{
+		{"default", nil, `["message","message_reaction"]`},
+		{"messages only", []PollerOption{WithUpdateTypes(UpdateMessage)}, `["message"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.URL.Query().Get("allowed_updates")
+				json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": []any{}})
+			}))
+			defer server.Close()
+
+			poller := NewPoller("test-token", append(tt.opts, WithAPIBaseURL(server.URL))...)
+			if _, _, err := poller.getUpdates(context.Background(), 0); err != nil {
+				t.Fatalf("getUpdates failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("allowed_updates = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollerSkipsUnexpectedUpdates(t *testing.T) {
+	var mu sync.Mutex
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		offsets = append(offsets, r.URL.Query().Get("offset"))
+		n := len(offsets)
+		mu.Unlock()
+
+		if n > 1 {
+			json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": []any{}})
+			return
+		}
+		w.Write([]byte(`{"ok": true, "result": [
+			{"update_id": 20, "chat_boost": {"chat": {"id": 5}, "boost": {"boost_id": "x"}}},
+			{"update_id": 21, "message": "not an object"},
+			{"update_id": 22, "message": {"message_id": 1, "date": 0, "chat": {"id": 5, "type": "private"}, "text": "/start"}},
+			{"update_id": 23, "message_reaction": {"chat": 5}}
+		]}`))
+	}))
+	defer server.Close()
+
+	poller := NewPoller("test-token", WithAPIBaseURL(server.URL), WithBackoff(time.Millisecond, time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Stop once the poll after the batch has been made
+	go func() {
+		for ctx.Err() == nil {
+			mu.Lock()
+			polled := len(offsets) > 1
+			mu.Unlock()
+			if polled {
+				cancel()
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var received []int
+	var texts []string
+	poller.Run(ctx, func(ctx context.Context, update *Update) {
+		received = append(received, update.UpdateID)
+		if update.Message != nil {
+			texts = append(texts, update.Message.Text)
+		}
+	})
+
+	// The unknown update type is passed on without content; the malformed
+	// ones are skipped
+	if len(received) != 2 || received[0] != 20 || received[1] != 22 {
+		t.Errorf("received updates = %v, want [20 22]", received)
+	}
+	if len(texts) != 1 || texts[0] != "/start" {
+		t.Errorf("message texts = %v, want [/start]", texts)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(offsets) < 2 || offsets[1] != "24" {
+		t.Errorf("offsets = %v, want the second poll to confirm past update 23", offsets)
+	}
+}
+
+func TestPollerStopsDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	poller := NewPoller("test-token",
+		WithAPIBaseURL(server.URL),
+		WithBackoff(time.Hour, time.Hour),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		poller.Run(ctx, func(ctx context.Context, update *Update) {})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poller did not stop after context cancellation")
+	}
+}
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	b := &backoff{min: 100 * time.Millisecond, max: time.Second}
+
+	wantMax := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, max := range wantMax {
+		d := b.next()
+		if d < max/2 || d > max {
+			t.Errorf("attempt %d: delay = %v, want between %v and %v", i+1, d, max/2, max)
+		}
+	}
+
+	b.reset()
+	if b.attempt != 0 {
+		t.Errorf("attempt after reset = %d, want 0", b.attempt)
+	}
+	if d := b.next(); d > 100*time.Millisecond {
+		t.Errorf("delay after reset = %v, want <= 100ms", d)
+	}
+}
+
+func TestPollerAPIBaseURL(t *testing.T) {
+	if p := NewPoller("test-token", WithAPIBaseURL("")); p.baseURL != defaultAPIBaseURL {
+		t.Errorf("baseURL = %q, want %q", p.baseURL, defaultAPIBaseURL)
+	}
+	if p := NewPoller("test-token", WithAPIBaseURL("http://localhost:8081/")); p.baseURL != "http://localhost:8081" {
+		t.Errorf("baseURL = %q, want trailing slash trimmed", p.baseURL)
+	}
+}
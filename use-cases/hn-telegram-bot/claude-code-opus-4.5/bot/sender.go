@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ErrChatUnavailable is returned when a chat can no longer receive
+// messages, because the user blocked the bot or the chat no longer exists.
+var ErrChatUnavailable = errors.New("chat unavailable")
+
+// ParseMode selects how Telegram parses the text of a message. Article
+// messages are HTML, while command replies are plain text, so that nothing
+// in them can be taken for markup.
+type ParseMode string
+
+// Parse modes accepted by SendMessage.
+const (
+	ParseModeNone ParseMode = ""
+	ParseModeHTML ParseMode = tgbotapi.ModeHTML
+)
+
+// Escape returns text escaped so that Telegram shows it as is when parsing
+// in mode. Every piece of text put into an HTML message goes through it.
+func (m ParseMode) Escape(text string) string {
+	if m == ParseModeHTML {
+		return html.EscapeString(text)
+	}
+	return text
+}
+
+// ChatUnsubscriber records chats that can no longer receive messages.
+type ChatUnsubscriber interface {
+	UnsubscribeChat(ctx context.Context, chatID int64, reason string) error
+}
+
+// NewBotAPI creates a Bot API client for token. baseURL points it at a
+// self-hosted Bot API server instead of the public endpoint; an empty
+// baseURL keeps the default.
+func NewBotAPI(token, baseURL string) (*tgbotapi.BotAPI, error) {
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	return tgbotapi.NewBotAPIWithAPIEndpoint(token, strings.TrimRight(baseURL, "/")+"/bot%s/%s")
+}
+
+// TelegramSender sends messages through the Telegram Bot API.
+type TelegramSender struct {
+	api          *tgbotapi.BotAPI
+	unsubscriber ChatUnsubscriber
+}
+
+// SenderOption configures a TelegramSender.
+type SenderOption func(*TelegramSender)
+
+// WithUnsubscriber marks chats as unsubscribed when Telegram reports that
+// they can no longer receive messages.
+func WithUnsubscriber(unsubscriber ChatUnsubscriber) SenderOption {
+	return func(s *TelegramSender) {
+		s.unsubscriber = unsubscriber
+	}
+}
+
+// NewTelegramSender creates a sender using the given Bot API client.
+func NewTelegramSender(api *tgbotapi.BotAPI, opts ...SenderOption) *TelegramSender {
+	s := &TelegramSender{api: api}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SendMessage sends a text message parsed in mode and returns its message
+// ID. If the chat is no longer reachable, the returned error wraps
+// ErrChatUnavailable.
+func (s *TelegramSender) SendMessage(ctx context.Context, chatID int64, text string, mode ParseMode) (int64, error) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = string(mode)
+	return s.send(ctx, chatID, msg)
+}
+
+// SendPhoto sends the photo at photoURL, which Telegram downloads itself,
+// with an HTML caption, and returns the message ID.
+func (s *TelegramSender) SendPhoto(ctx context.Context, chatID int64, photoURL, caption string) (int64, error) {
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photoURL))
+	photo.Caption = caption
+	photo.ParseMode = tgbotapi.ModeHTML
+	return s.send(ctx, chatID, photo)
+}
+
+func (s *TelegramSender) send(ctx context.Context, chatID int64, c tgbotapi.Chattable) (int64, error) {
+	// The Bot API client builds its requests without a context, so send
+	// through a copy whose client binds each request to ctx; otherwise a
+	// hung connection would outlast the caller's deadline.
+	api := *s.api
+	api.Client = contextClient{ctx: ctx, client: s.api.Client}
+	sent, err := api.Send(c)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, fmt.Errorf("send message: %w", ctxErr)
+		}
+		if isChatUnavailable(err) {
+			s.unsubscribe(ctx, chatID, err)
+			return 0, fmt.Errorf("%w: %v", ErrChatUnavailable, err)
+		}
+		slog.Warn("failed to send message", "chat_id", chatID, "error", err)
+		return 0, err
+	}
+	return int64(sent.MessageID), nil
+}
+
+// contextClient sends every request with ctx, so that cancelling ctx aborts
+// requests made by a Bot API client.
+type contextClient struct {
+	ctx    context.Context
+	client tgbotapi.HTTPClient
+}
+
+func (c contextClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req.WithContext(c.ctx))
+}
+
+func (s *TelegramSender) unsubscribe(ctx context.Context, chatID int64, cause error) {
+	if s.unsubscriber == nil {
+		slog.Warn("chat unavailable", "chat_id", chatID, "error", cause)
+		return
+	}
+	if err := s.unsubscriber.UnsubscribeChat(ctx, chatID, cause.Error()); err != nil {
+		slog.Error("failed to unsubscribe unavailable chat", "chat_id", chatID, "error", err)
+		return
+	}
+	slog.Warn("chat unavailable, digests paused until /subscribe", "chat_id", chatID, "reason", cause)
+}
+
+// isChatUnavailable reports whether err means the chat will never accept
+// messages again without user action.
+func isChatUnavailable(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code != 403 && apiErr.Code != 400 {
+		return false
+	}
+	msg := strings.ToLower(apiErr.Message)
+	return strings.Contains(msg, "bot was blocked by the user") || strings.Contains(msg, "chat not found")
+}
+
+// SendArticle sends an article through sender. An article with an image
+// goes out as a photo captioned with the formatted article, if sender can
+// send photos; otherwise, or if Telegram rejects the photo, it is sent as
+// a text message.
+func SendArticle(ctx context.Context, sender MessageSender, chatID int64, article *ArticleForDisplay) (int64, error) {
+	if photos, ok := sender.(PhotoSender); ok && article.ImageURL != "" {
+		captioned := *article
+		captioned.MaxLength = maxCaptionLength
+		if article.MaxLength > 0 {
+			captioned.MaxLength = min(article.MaxLength, maxCaptionLength)
+		}
+		msgID, err := photos.SendPhoto(ctx, chatID, article.ImageURL, FormatArticleMessage(&captioned))
+		if err == nil || errors.Is(err, ErrChatUnavailable) {
+			return msgID, err
+		}
+		slog.Warn("failed to send article photo, sending as text", "id", article.ID, "image", article.ImageURL, "error", err)
+	}
+	return sender.SendMessage(ctx, chatID, FormatArticleMessage(article), ParseModeHTML)
+}
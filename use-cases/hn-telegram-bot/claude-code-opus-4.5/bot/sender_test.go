@@ -0,0 +1,352 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type mockUnsubscriber struct {
+	chats map[int64]string
+}
+
+func (m *mockUnsubscriber) UnsubscribeChat(ctx context.Context, chatID int64, reason string) error {
+	if m.chats == nil {
+		m.chats = make(map[int64]string)
+	}
+	m.chats[chatID] = reason
+	return nil
+}
+
+// newFakeTelegram starts a Bot API server that answers getMe and replies to
+// sendMessage with the given status and response body.
+func newFakeTelegram(t *testing.T, status int, sendResponse map[string]any) *tgbotapi.BotAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"},
+			})
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(sendResponse)
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPIWithAPIEndpoint failed: %v", err)
+	}
+	return api
+}
+
+func TestNewBotAPIUsesBaseURL(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"ok":     true,
+			"result": map[string]any{"message_id": 7, "date": 0, "chat": map[string]any{"id": 12345}},
+		})
+	}))
+	defer server.Close()
+
+	api, err := NewBotAPI("test-token", server.URL+"/")
+	if err != nil {
+		t.Fatalf("NewBotAPI failed: %v", err)
+	}
+	if api.Self.UserName != "test_bot" {
+		t.Errorf("username = %q, want test_bot", api.Self.UserName)
+	}
+
+	if _, err := NewTelegramSender(api).SendMessage(context.Background(), 12345, "hello", ParseModeNone); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	want := []string{"/bottest-token/getMe", "/bottest-token/sendMessage"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("requested paths = %v, want %v", paths, want)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	api := newFakeTelegram(t, http.StatusOK, map[string]any{
+		"ok":     true,
+		"result": map[string]any{"message_id": 42, "date": 0, "chat": map[string]any{"id": 12345}},
+	})
+	unsubscriber := &mockUnsubscriber{}
+	sender := NewTelegramSender(api, WithUnsubscriber(unsubscriber))
+
+	msgID, err := sender.SendMessage(context.Background(), 12345, "hello", ParseModeNone)
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if msgID != 42 {
+		t.Errorf("message ID = %d, want 42", msgID)
+	}
+	if len(unsubscriber.chats) != 0 {
+		t.Errorf("unsubscribed chats = %v, want none", unsubscriber.chats)
+	}
+}
+
+func TestSendMessageBlockedUnsubscribes(t *testing.T) {
+	api := newFakeTelegram(t, http.StatusForbidden, map[string]any{
+		"ok":          false,
+		"error_code":  403,
+		"description": "Forbidden: bot was blocked by the user",
+	})
+	unsubscriber := &mockUnsubscriber{}
+	sender := NewTelegramSender(api, WithUnsubscriber(unsubscriber))
+
+	_, err := sender.SendMessage(context.Background(), 12345, "hello", ParseModeNone)
+	if !errors.Is(err, ErrChatUnavailable) {
+		t.Fatalf("error = %v, want ErrChatUnavailable", err)
+	}
+	if _, ok := unsubscriber.chats[12345]; !ok {
+		t.Error("chat 12345 should be flagged as unsubscribed")
+	}
+}
+
+func TestSendMessageOtherErrorsKeepSubscription(t *testing.T) {
+	api := newFakeTelegram(t, http.StatusBadRequest, map[string]any{
+		"ok":          false,
+		"error_code":  400,
+		"description": "Bad Request: can't parse entities",
+	})
+	unsubscriber := &mockUnsubscriber{}
+	sender := NewTelegramSender(api, WithUnsubscriber(unsubscriber))
+
+	_, err := sender.SendMessage(context.Background(), 12345, "<b>", ParseModeHTML)
+	if err == nil || errors.Is(err, ErrChatUnavailable) {
+		t.Fatalf("error = %v, want a non-unavailable error", err)
+	}
+	if len(unsubscriber.chats) != 0 {
+		t.Errorf("unsubscribed chats = %v, want none", unsubscriber.chats)
+	}
+}
+
+func TestSendMessageHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"},
+			})
+			return
+		}
+		// Hang like a stalled connection until the client gives up.
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	api, err := NewBotAPI("test-token", server.URL)
+	if err != nil {
+		t.Fatalf("NewBotAPI failed: %v", err)
+	}
+	unsubscriber := &mockUnsubscriber{}
+	sender := NewTelegramSender(api, WithUnsubscriber(unsubscriber))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = sender.SendMessage(ctx, 12345, "hello", ParseModeNone)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %v, want it cut off at the deadline", elapsed)
+	}
+	if len(unsubscriber.chats) != 0 {
+		t.Errorf("unsubscribed chats = %v, want none", unsubscriber.chats)
+	}
+}
+
+func TestSendMessageParseMode(t *testing.T) {
+	var modes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			json.NewEncoder(w).Encode(map[string]any{
+				"ok":     true,
+				"result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"},
+			})
+			return
+		}
+		r.ParseForm()
+		modes = append(modes, r.PostForm.Get("parse_mode"))
+		json.NewEncoder(w).Encode(map[string]any{
+			"ok":     true,
+			"result": map[string]any{"message_id": 7, "date": 0, "chat": map[string]any{"id": 12345}},
+		})
+	}))
+	defer server.Close()
+	api, err := NewBotAPI("test-token", server.URL+"/")
+	if err != nil {
+		t.Fatalf("NewBotAPI failed: %v", err)
+	}
+	sender := NewTelegramSender(api)
+	ctx := context.Background()
+
+	if _, err := sender.SendMessage(ctx, 12345, "Usage: /test <url>", ParseModeNone); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	article := &ArticleForDisplay{ID: 1, Title: "Title", Summary: "Summary", URL: "https://example.com"}
+	if _, err := SendArticle(ctx, sender, 12345, article); err != nil {
+		t.Fatalf("SendArticle failed: %v", err)
+	}
+
+	if len(modes) != 2 || modes[0] != "" || modes[1] != "HTML" {
+		t.Errorf("parse modes = %q, want a plain reply and an HTML article", modes)
+	}
+}
+
+func TestParseModeEscape(t *testing.T) {
+	tests := []struct {
+		mode ParseMode
+		text string
+		want string
+	}{
+		{ParseModeNone, "1 < 2 & *done*", "1 < 2 & *done*"},
+		{ParseModeHTML, "1 < 2 & *done*", "1 &lt; 2 &amp; *done*"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.Escape(tt.text); got != tt.want {
+			t.Errorf("%q.Escape(%q) = %q, want %q", tt.mode, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsChatUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, true},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, true},
+		{&tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after 5"}, false},
+		{errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isChatUnavailable(tt.err); got != tt.want {
+			t.Errorf("isChatUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// mockPhotoSender is a message sender that can also send photos, failing
+// them with photoErr.
+type mockPhotoSender struct {
+	mockMessageSender
+	photoErr error
+	photos   []sentPhoto
+}
+
+type sentPhoto struct {
+	url     string
+	caption string
+}
+
+func (m *mockPhotoSender) SendPhoto(ctx context.Context, chatID int64, photoURL, caption string) (int64, error) {
+	if m.photoErr != nil {
+		return 0, m.photoErr
+	}
+	m.photos = append(m.photos, sentPhoto{photoURL, caption})
+	return 99, nil
+}
+
+func TestSendArticleAsPhoto(t *testing.T) {
+	sender := &mockPhotoSender{}
+	article := &ArticleForDisplay{
+		ID:       1,
+		Title:    "Title",
+		Summary:  strings.Repeat("word ", 500),
+		URL:      "https://example.com",
+		ImageURL: "https://example.com/image.png",
+	}
+
+	msgID, err := SendArticle(context.Background(), sender, 12345, article)
+	if err != nil {
+		t.Fatalf("SendArticle failed: %v", err)
+	}
+	if msgID != 99 || len(sender.photos) != 1 || len(sender.sentMessages) != 0 {
+		t.Fatalf("msgID = %d, photos = %d, messages = %d; want a single photo", msgID, len(sender.photos), len(sender.sentMessages))
+	}
+	photo := sender.photos[0]
+	if photo.url != article.ImageURL {
+		t.Errorf("photo URL = %q, want %q", photo.url, article.ImageURL)
+	}
+	if n := utf8.RuneCountInString(photo.caption); n > maxCaptionLength {
+		t.Errorf("caption length = %d, want at most %d", n, maxCaptionLength)
+	}
+	if !strings.Contains(photo.caption, "<b>Title</b>") {
+		t.Errorf("caption should contain the formatted article, got: %s", photo.caption)
+	}
+}
+
+func TestSendArticleWithoutImageSendsText(t *testing.T) {
+	sender := &mockPhotoSender{}
+	article := &ArticleForDisplay{ID: 1, Title: "Title", Summary: "Summary", URL: "https://example.com"}
+
+	if _, err := SendArticle(context.Background(), sender, 12345, article); err != nil {
+		t.Fatalf("SendArticle failed: %v", err)
+	}
+	if len(sender.photos) != 0 || len(sender.sentMessages) != 1 {
+		t.Fatalf("photos = %d, messages = %d; want a single text message", len(sender.photos), len(sender.sentMessages))
+	}
+	if msg := sender.sentMessages[0]; msg.mode != ParseModeHTML || msg.text != FormatArticleMessage(article) {
+		t.Errorf("message = %+v, want the formatted article as HTML", msg)
+	}
+}
+
+func TestSendArticleFallsBackToText(t *testing.T) {
+	article := &ArticleForDisplay{ID: 1, Title: "Title", Summary: "Summary", ImageURL: "https://example.com/broken.png"}
+
+	// Telegram couldn't fetch the image
+	sender := &mockPhotoSender{photoErr: errors.New("Bad Request: wrong file identifier/HTTP URL specified")}
+	if _, err := SendArticle(context.Background(), sender, 12345, article); err != nil {
+		t.Fatalf("SendArticle failed: %v", err)
+	}
+	if len(sender.sentMessages) != 1 {
+		t.Errorf("messages = %d, want a text fallback", len(sender.sentMessages))
+	}
+
+	// A sender that can't send photos
+	textOnly := &mockMessageSender{}
+	if _, err := SendArticle(context.Background(), textOnly, 12345, article); err != nil {
+		t.Fatalf("SendArticle failed: %v", err)
+	}
+	if len(textOnly.sentMessages) != 1 {
+		t.Errorf("messages = %d, want a text message", len(textOnly.sentMessages))
+	}
+
+	// An unreachable chat is reported, not retried as text
+	blocked := &mockPhotoSender{photoErr: ErrChatUnavailable}
+	if _, err := SendArticle(context.Background(), blocked, 12345, article); !errors.Is(err, ErrChatUnavailable) {
+		t.Errorf("error = %v, want ErrChatUnavailable", err)
+	}
+	if len(blocked.sentMessages) != 0 {
+		t.Errorf("messages = %d, want none for an unavailable chat", len(blocked.sentMessages))
+	}
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// simulateTopTags is how many of the top tags /simulate compares.
+	simulateTopTags = 10
+	// tagPageSize is how many tags are read at a time when reading them all.
+	tagPageSize = 100
+)
+
+// simulateBoost returns weights, ordered by weight and then by tag, as they
+// would be after boosting tag by boost. A tag without a weight starts from
+// 1.0, as it does when a like boosts it. weights is left unchanged.
+func simulateBoost(weights []TagStat, tag string, boost float64) []TagStat {
+	simulated := slices.Clone(weights)
+	if i := slices.IndexFunc(simulated, func(s TagStat) bool { return s.Tag == tag }); i >= 0 {
+		simulated[i].Weight += boost
+	} else {
+		simulated = append(simulated, TagStat{Tag: tag, Weight: 1.0 + boost})
+	}
+	slices.SortStableFunc(simulated, func(a, b TagStat) int {
+		if a.Weight != b.Weight {
+			if a.Weight > b.Weight {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return simulated
+}
+
+// HandleSimulate handles the admin-only /simulate <tag> command, showing
+// how the top tags would reorder if a like boosted tag. The boost is the
+// one a like would give the tag now; it is applied in memory only.
+func (h *CommandHandler) HandleSimulate(ctx context.Context, chatID int64, args string) error {
+	if !slices.Contains(h.config.AdminChatIDs, chatID) {
+		_, err := h.sender.SendMessage(ctx, chatID, "⛔ You are not authorized to use /simulate.", ParseModeNone)
+		return err
+	}
+	if h.tagStats == nil {
+		return nil
+	}
+
+	tag := strings.ToLower(strings.TrimSpace(args))
+	if tag == "" {
+		_, err := h.sender.SendMessage(ctx, chatID, "Usage: /simulate <tag>\nExample: /simulate rust", ParseModeNone)
+		return err
+	}
+
+	weights, err := h.allTagWeights(ctx)
+	if err != nil {
+		return fmt.Errorf("get tag weights: %w", err)
+	}
+	boost, err := likeBoost(ctx, h.boostAmount, h.boostCurve, h.tagCounter, tag)
+	if err != nil {
+		return err
+	}
+	simulated := simulateBoost(weights, tag, boost)
+
+	_, err = h.sender.SendMessage(ctx, chatID, formatSimulation(tag, boost, weights, simulated), ParseModeNone)
+	return err
+}
+
+// allTagWeights returns every tag weight, highest first.
+func (h *CommandHandler) allTagWeights(ctx context.Context) ([]TagStat, error) {
+	var all []TagStat
+	for {
+		page, err := h.tagStats.GetTagWeightsPaged(ctx, tagPageSize, len(all))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < tagPageSize {
+			return all, nil
+		}
+	}
+}
+
+// formatSimulation lists the top tags before and after a simulated boost,
+// marking how far each tag moved.
+func formatSimulation(tag string, boost float64, before, after []TagStat) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧪 Simulated 👍 on %q (+%.2f, not saved)\n\n", tag, boost))
+
+	sb.WriteString("Before:\n")
+	if len(before) == 0 {
+		sb.WriteString("No learned tags yet.\n")
+	}
+	for i, t := range before[:min(len(before), simulateTopTags)] {
+		sb.WriteString(fmt.Sprintf("%d. %s (%.2f)\n", i+1, t.Tag, t.Weight))
+	}
+
+	sb.WriteString("\nAfter:\n")
+	for i, t := range after[:min(len(after), simulateTopTags)] {
+		sb.WriteString(fmt.Sprintf("%d. %s (%.2f)%s\n", i+1, t.Tag, t.Weight, rankChange(before, t.Tag, i)))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// rankChange describes how a tag now at index i of the top tags moved from
+// its index in before, or "" if it didn't.
+func rankChange(before []TagStat, tag string, i int) string {
+	was := slices.IndexFunc(before, func(s TagStat) bool { return s.Tag == tag })
+	switch {
+	case was < 0:
+		return " 🆕"
+	case was >= simulateTopTags:
+		return " ⬆️ into the top"
+	case was > i:
+		return fmt.Sprintf(" ⬆️%d", was-i)
+	case was < i:
+		return fmt.Sprintf(" ⬇️%d", i-was)
+	default:
+		return ""
+	}
+}
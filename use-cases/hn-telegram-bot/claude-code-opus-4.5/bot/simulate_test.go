@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSimulateBoost(t *testing.T) {
+	weights := []TagStat{{"go", 3.0}, {"rust", 2.5}, {"python", 2.0}}
+	original := slices.Clone(weights)
+
+	tests := []struct {
+		name string
+		tag  string
+		want []TagStat
+	}{
+		// Ties are broken by tag, so "python" stays behind "go"
+		{"moves up", "python", []TagStat{{"go", 3.0}, {"python", 3.0}, {"rust", 2.5}}},
+		{"stays first", "go", []TagStat{{"go", 4.0}, {"rust", 2.5}, {"python", 2.0}}},
+		{"new tag", "zig", []TagStat{{"go", 3.0}, {"rust", 2.5}, {"python", 2.0}, {"zig", 2.0}}},
+	}
+	for _, tt := range tests {
+		got := simulateBoost(weights, tt.tag, 1.0)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: simulateBoost = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !slices.Equal(weights, original) {
+		t.Errorf("simulateBoost changed its input to %v", weights)
+	}
+}
+
+func TestHandleSimulate(t *testing.T) {
+	sender := &mockMessageSender{}
+	tagStats := &mockTagStats{topTags: []TagStat{{"go", 3.0}, {"rust", 2.5}, {"python", 2.0}}}
+	original := slices.Clone(tagStats.topTags)
+	booster := newMockTagBooster()
+	handler := NewCommandHandler(sender, nil, nil, nil, tagStats,
+		WithLikeBoost(1.5, BoostLinear, booster),
+		WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+	if err := handler.HandleSimulate(context.Background(), 1, " Python "); err != nil {
+		t.Fatalf("HandleSimulate failed: %v", err)
+	}
+
+	if len(sender.sentMessages) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(sender.sentMessages))
+	}
+	text := sender.sentMessages[0].text
+	_, after, ok := strings.Cut(text, "After:\n")
+	if !ok {
+		t.Fatalf("expected an After list, got:\n%s", text)
+	}
+	want := "1. python (3.50) ⬆️2\n2. go (3.00) ⬇️1\n3. rust (2.50) ⬇️1"
+	if after != want {
+		t.Errorf("After list = %q, want %q", after, want)
+	}
+	if !strings.Contains(text, "not saved") {
+		t.Errorf("expected the reply to say the boost is not saved, got:\n%s", text)
+	}
+
+	if !slices.Equal(tagStats.topTags, original) {
+		t.Errorf("tag weights changed to %v, want them left as they were", tagStats.topTags)
+	}
+	if len(booster.boosted) != 0 {
+		t.Errorf("boosted %v, want nothing persisted", booster.boosted)
+	}
+}
+
+func TestHandleSimulateRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		chatID int64
+		args   string
+		want   string
+	}{
+		{"not an admin", 12345, " go", "not authorized"},
+		{"no tag", 1, " ", "Usage: /simulate"},
+	}
+	for _, tt := range tests {
+		sender := &mockMessageSender{}
+		handler := NewCommandHandler(sender, nil, nil, nil, &mockTagStats{},
+			WithLikeBoost(1.5, BoostLinear, nil),
+			WithConfig(HandlerConfig{AdminChatIDs: []int64{1}}))
+
+		if err := handler.HandleSimulate(context.Background(), tt.chatID, tt.args); err != nil {
+			t.Fatalf("%s: HandleSimulate failed: %v", tt.name, err)
+		}
+		if len(sender.sentMessages) != 1 || !strings.Contains(sender.sentMessages[0].text, tt.want) {
+			t.Errorf("%s: expected a reply containing %q, got %+v", tt.name, tt.want, sender.sentMessages)
+		}
+	}
+}
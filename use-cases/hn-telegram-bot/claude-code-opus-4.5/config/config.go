@@ -1,48 +1,147 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
+
+	"hn-telegram-bot/language"
+	"hn-telegram-bot/logging"
 )
 
 // Config holds all application configuration.
 type Config struct {
-	TelegramToken    string  `yaml:"telegram_token"`
-	GeminiAPIKey     string  `yaml:"gemini_api_key"`
-	ChatID           int64   `yaml:"chat_id"`
-	GeminiModel      string  `yaml:"gemini_model"`
-	DigestTime       string  `yaml:"digest_time"`
-	Timezone         string  `yaml:"timezone"`
-	ArticleCount     int     `yaml:"article_count"`
-	FetchTimeoutSecs int     `yaml:"fetch_timeout_secs"`
-	TagDecayRate     float64 `yaml:"tag_decay_rate"`
-	MinTagWeight     float64 `yaml:"min_tag_weight"`
-	TagBoostOnLike   float64 `yaml:"tag_boost_on_like"`
-	DBPath           string  `yaml:"db_path"`
-	LogLevel         string  `yaml:"log_level"`
+	TelegramToken       string            `yaml:"telegram_token"`
+	TelegramTokenFile   string            `yaml:"telegram_token_file"`
+	GeminiAPIKey        string            `yaml:"gemini_api_key"`
+	GeminiAPIKeyFile    string            `yaml:"gemini_api_key_file"`
+	GeminiAPIKeys       []string          `yaml:"gemini_api_keys"`
+	ChatID              int64             `yaml:"chat_id"`
+	AdminChatIDs        []int64           `yaml:"admin_chat_ids"`
+	GeminiModel         string            `yaml:"gemini_model"`
+	GeminiFallbackModel string            `yaml:"gemini_fallback_model"`
+	HNBaseURL           string            `yaml:"hn_base_url"`
+	HNSearchURL         string            `yaml:"hn_search_url"`
+	TelegramAPIBase     string            `yaml:"telegram_api_base"`
+	HTTPProxy           string            `yaml:"http_proxy"`
+	HTTPSProxy          string            `yaml:"https_proxy"`
+	MaxIdleConnsPerHost int               `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration     `yaml:"idle_conn_timeout"`
+	DigestTime          string            `yaml:"digest_time"`
+	Timezone            string            `yaml:"timezone"`
+	ScheduleJitter      time.Duration     `yaml:"schedule_jitter"`
+	QuietHoursStart     string            `yaml:"quiet_hours_start"`
+	QuietHoursEnd       string            `yaml:"quiet_hours_end"`
+	ArticleCount        int               `yaml:"article_count"`
+	MinArticles         int               `yaml:"min_articles"`
+	CandidateMultiplier int               `yaml:"candidate_multiplier"`
+	MaxStories          int               `yaml:"max_stories"`
+	StoryWindow         string            `yaml:"story_window"`
+	WindowMinScore      int               `yaml:"window_min_score"`
+	MaxArticleAge       time.Duration     `yaml:"max_article_age"`
+	RankBeforeSummarize bool              `yaml:"rank_before_summarize"`
+	DiscussionSummary   bool              `yaml:"discussion_summary"`
+	GroupByTopic        bool              `yaml:"group_by_topic"`
+	FetchTimeoutSecs    int               `yaml:"fetch_timeout_secs"`
+	SummaryBatchSize    int               `yaml:"summary_batch_size"`
+	SummaryMinLength    int               `yaml:"summary_min_length"`
+	SummaryMaxLength    int               `yaml:"summary_max_length"`
+	RefusalPatterns     []string          `yaml:"refusal_patterns"`
+	TagWeighting        string            `yaml:"tag_weighting"`
+	HNConcurrency       int               `yaml:"hn_concurrency"`
+	ScrapeConcurrency   int               `yaml:"scrape_concurrency"`
+	SendDelay           time.Duration     `yaml:"send_delay"`
+	SendJitter          time.Duration     `yaml:"send_jitter"`
+	ItemTimeout         time.Duration     `yaml:"item_timeout"`
+	ScrapeTimeout       time.Duration     `yaml:"scrape_timeout"`
+	SummaryTimeout      time.Duration     `yaml:"summary_timeout"`
+	GeminiReqTimeout    time.Duration     `yaml:"gemini_request_timeout"`
+	SummarizerRPM       int               `yaml:"summarizer_rpm"`
+	SendTimeout         time.Duration     `yaml:"send_timeout"`
+	MaxRunDuration      time.Duration     `yaml:"max_run_duration"`
+	MaxMessageLength    int               `yaml:"max_message_length"`
+	FooterTemplate      string            `yaml:"footer_template"`
+	TagDecayRate        float64           `yaml:"tag_decay_rate"`
+	DecayMode           string            `yaml:"decay_mode"`
+	SelectionMode       string            `yaml:"selection_mode"`
+	MinTagWeight        float64           `yaml:"min_tag_weight"`
+	TagBoostOnLike      float64           `yaml:"tag_boost_on_like"`
+	TagBoostCurve       string            `yaml:"tag_boost_curve"`
+	DomainWeightFactor  float64           `yaml:"domain_weight_factor"`
+	TagCountConfidence  bool              `yaml:"tag_count_confidence"`
+	MaxRankedTags       int               `yaml:"max_ranked_tags"`
+	MinTagScore         float64           `yaml:"min_tag_score"`
+	MinTagScoreLikes    int               `yaml:"min_tag_score_likes"`
+	LikeEmojis          []string          `yaml:"like_emojis"`
+	DislikeEmojis       []string          `yaml:"dislike_emojis"`
+	DisableReactions    bool              `yaml:"disable_reactions"`
+	SeedTags            []string          `yaml:"seed_tags"`
+	PinnedTags          []string          `yaml:"pinned_tags"`
+	TagAliases          map[string]string `yaml:"tag_aliases"`
+	AllowedLanguages    []string          `yaml:"allowed_languages"`
+	NotifyEmptyDigest   bool              `yaml:"notify_empty_digest"`
+	DigestIntro         string            `yaml:"digest_intro"`
+	DigestOutro         string            `yaml:"digest_outro"`
+	ShutdownGraceSecs   int               `yaml:"shutdown_grace_secs"`
+	HealthAddr          string            `yaml:"health_addr"`
+	TriggerSecret       string            `yaml:"trigger_secret"`
+	TriggerSecretFile   string            `yaml:"trigger_secret_file"`
+	DBPath              string            `yaml:"db_path"`
+	CreateDBDir         bool              `yaml:"create_db_dir"`
+	LogLevel            string            `yaml:"log_level"`
+	LogFormat           string            `yaml:"log_format"`
+	LogOutput           string            `yaml:"log_output"`
+	Offline             bool              `yaml:"offline"`
 }
 
-// digestTimeRegex validates HH:MM format with proper ranges.
-var digestTimeRegex = regexp.MustCompile(`^([01][0-9]|2[0-3]):([0-5][0-9])$`)
+// digestTimeRegex matches H:MM or HH:MM; ranges are checked separately.
+var digestTimeRegex = regexp.MustCompile(`^([0-9]{1,2}):([0-9]{2})$`)
 
 // Load reads configuration from a YAML file and applies defaults.
+// If a local override file exists next to it (config.local.yaml for
+// config.yaml), its fields take precedence over the base file.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
 
-	cfg := &Config{}
+	cfg := presetDefaults()
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("parse config yaml: %w", err)
 	}
 
+	// Unmarshaling onto the same struct only replaces fields present in
+	// the local file, so the override is field by field
+	localPath := LocalPath(path)
+	if localData, err := os.ReadFile(localPath); err == nil {
+		if err := yaml.Unmarshal(localData, cfg); err != nil {
+			return nil, fmt.Errorf("parse local config yaml %s: %w", localPath, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read local config file: %w", err)
+	}
+
 	applyDefaults(cfg)
 	applyEnvironmentOverrides(cfg)
+	if err := readSecretFiles(cfg); err != nil {
+		return nil, fmt.Errorf("read secret file: %w", err)
+	}
+	cfg.SeedTags = normalizeTags(cfg.SeedTags)
+	cfg.PinnedTags = normalizeTags(cfg.PinnedTags)
+	cfg.TagAliases = normalizeTagAliases(cfg.TagAliases)
 
 	if err := validate(cfg); err != nil {
 		return nil, fmt.Errorf("validate config: %w", err)
@@ -51,6 +150,37 @@ func Load(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// LocalPath returns the path of the optional local override file for a
+// config path, e.g. "config.local.yaml" for "config.yaml".
+func LocalPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+// ParseDigestTime parses a daily digest time in 24-hour H:MM or HH:MM
+// format, such as "9:00" or "18:30".
+func ParseDigestTime(s string) (hour, minute int, err error) {
+	matches := digestTimeRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+
+	hour, _ = strconv.Atoi(matches[1])
+	minute, _ = strconv.Atoi(matches[2])
+	if hour > 23 {
+		return 0, 0, fmt.Errorf("invalid time %q: hour must be 0-23", s)
+	}
+	if minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q: minute must be 00-59", s)
+	}
+	return hour, minute, nil
+}
+
+// FormatDigestTime returns the canonical HH:MM form of a digest time.
+func FormatDigestTime(hour, minute int) string {
+	return fmt.Sprintf("%02d:%02d", hour, minute)
+}
+
 // GetConfigPath returns the config file path from environment or default.
 func GetConfigPath() string {
 	if path := os.Getenv("HN_BOT_CONFIG"); path != "" {
@@ -59,6 +189,16 @@ func GetConfigPath() string {
 	return "./config.yaml"
 }
 
+// presetDefaults returns the defaults of settings for which zero is a
+// valid choice. They are set before the config is parsed, so that a zero
+// in the file is kept rather than replaced by applyDefaults.
+func presetDefaults() *Config {
+	return &Config{
+		WindowMinScore: 50,
+		SendDelay:      300 * time.Millisecond,
+	}
+}
+
 func applyDefaults(cfg *Config) {
 	if cfg.GeminiModel == "" {
 		cfg.GeminiModel = "gemini-2.0-flash-lite"
@@ -72,24 +212,120 @@ func applyDefaults(cfg *Config) {
 	if cfg.ArticleCount == 0 {
 		cfg.ArticleCount = 30
 	}
+	if cfg.CandidateMultiplier == 0 {
+		cfg.CandidateMultiplier = 2
+	}
 	if cfg.FetchTimeoutSecs == 0 {
 		cfg.FetchTimeoutSecs = 10
 	}
+	if cfg.SummaryMinLength == 0 {
+		cfg.SummaryMinLength = 10
+	}
+	if cfg.SummaryMaxLength == 0 {
+		cfg.SummaryMaxLength = 1000
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = 16
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = 90 * time.Second
+	}
+	if cfg.HNConcurrency == 0 {
+		cfg.HNConcurrency = 8
+	}
+	if cfg.ScrapeConcurrency == 0 {
+		cfg.ScrapeConcurrency = 4
+	}
+	if cfg.MaxMessageLength == 0 {
+		cfg.MaxMessageLength = 4096
+	}
+	if cfg.ItemTimeout == 0 {
+		cfg.ItemTimeout = 15 * time.Second
+	}
+	if cfg.ScrapeTimeout == 0 {
+		cfg.ScrapeTimeout = 30 * time.Second
+	}
+	if cfg.SummaryTimeout == 0 {
+		cfg.SummaryTimeout = 2 * time.Minute
+	}
+	if cfg.SendTimeout == 0 {
+		cfg.SendTimeout = 30 * time.Second
+	}
+	if cfg.GeminiReqTimeout == 0 {
+		cfg.GeminiReqTimeout = 60 * time.Second
+	}
 	if cfg.TagDecayRate == 0 {
 		cfg.TagDecayRate = 0.02
 	}
+	if cfg.StoryWindow == "" {
+		cfg.StoryWindow = "top"
+	}
+	if cfg.DecayMode == "" {
+		cfg.DecayMode = "per_run"
+	}
+	if cfg.SelectionMode == "" {
+		cfg.SelectionMode = "topn"
+	}
 	if cfg.MinTagWeight == 0 {
 		cfg.MinTagWeight = 0.1
 	}
 	if cfg.TagBoostOnLike == 0 {
 		cfg.TagBoostOnLike = 0.2
 	}
+	if cfg.TagBoostCurve == "" {
+		cfg.TagBoostCurve = "linear"
+	}
+	if cfg.TagWeighting == "" {
+		cfg.TagWeighting = "even"
+	}
+	if cfg.DomainWeightFactor == 0 {
+		cfg.DomainWeightFactor = 0.1
+	}
+	if cfg.MinTagScoreLikes == 0 {
+		cfg.MinTagScoreLikes = 10
+	}
+	if len(cfg.LikeEmojis) == 0 {
+		cfg.LikeEmojis = []string{"👍"}
+	}
+	if cfg.ShutdownGraceSecs == 0 {
+		cfg.ShutdownGraceSecs = 60
+	}
 	if cfg.DBPath == "" {
 		cfg.DBPath = "./hn-bot.db"
 	}
 	if cfg.LogLevel == "" {
 		cfg.LogLevel = "info"
 	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "json"
+	}
+	if cfg.LogOutput == "" {
+		cfg.LogOutput = "stdout"
+	}
+}
+
+// normalizeTags lowercases and trims tags to match the summarizer's tags,
+// dropping empty entries.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// normalizeTagAliases normalizes both the aliases and their canonical tags.
+func normalizeTagAliases(aliases map[string]string) map[string]string {
+	if len(aliases) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(aliases))
+	for alias, tag := range aliases {
+		out[strings.ToLower(strings.TrimSpace(alias))] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	return out
 }
 
 func applyEnvironmentOverrides(cfg *Config) {
@@ -98,15 +334,208 @@ func applyEnvironmentOverrides(cfg *Config) {
 	}
 }
 
+// readSecretFiles replaces secrets with the contents of the files set for
+// them, such as Docker or Kubernetes secret mounts, trimmed of whitespace.
+func readSecretFiles(cfg *Config) error {
+	for _, secret := range []struct {
+		name  string
+		path  string
+		value *string
+	}{
+		{"telegram_token_file", cfg.TelegramTokenFile, &cfg.TelegramToken},
+		{"gemini_api_key_file", cfg.GeminiAPIKeyFile, &cfg.GeminiAPIKey},
+		{"trigger_secret_file", cfg.TriggerSecretFile, &cfg.TriggerSecret},
+	} {
+		if secret.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(secret.path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", secret.name, err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return fmt.Errorf("%s: %s is empty", secret.name, secret.path)
+		}
+		*secret.value = value
+	}
+	return nil
+}
+
 func validate(cfg *Config) error {
-	if cfg.TelegramToken == "" {
+	// Offline mode uses local fakes, so it needs no credentials
+	if cfg.TelegramToken == "" && !cfg.Offline {
 		return fmt.Errorf("telegram_token is required")
 	}
-	if cfg.GeminiAPIKey == "" {
-		return fmt.Errorf("gemini_api_key is required")
+	if cfg.GeminiAPIKey == "" && len(cfg.GeminiAPIKeys) == 0 && !cfg.Offline {
+		return fmt.Errorf("gemini_api_key or gemini_api_keys is required")
+	}
+	if slices.Contains(cfg.GeminiAPIKeys, "") {
+		return fmt.Errorf("gemini_api_keys must not contain empty keys")
+	}
+	for alias, tag := range cfg.TagAliases {
+		if alias == "" || tag == "" {
+			return fmt.Errorf("tag_aliases must not contain empty tags")
+		}
+		if alias == tag {
+			return fmt.Errorf("tag_aliases: %q is an alias of itself", alias)
+		}
+		// Aliases resolve in one step, so a chain would leave tags split
+		if _, ok := cfg.TagAliases[tag]; ok {
+			return fmt.Errorf("tag_aliases: %q is an alias of %q, which is itself an alias", alias, tag)
+		}
+	}
+	if slices.Contains(cfg.AdminChatIDs, 0) {
+		return fmt.Errorf("admin_chat_ids must not contain 0")
+	}
+	hour, minute, err := ParseDigestTime(cfg.DigestTime)
+	if err != nil {
+		return fmt.Errorf("digest_time: %w", err)
+	}
+	cfg.DigestTime = FormatDigestTime(hour, minute)
+	if (cfg.QuietHoursStart == "") != (cfg.QuietHoursEnd == "") {
+		return fmt.Errorf("quiet_hours_start and quiet_hours_end must be set together")
+	}
+	if cfg.QuietHoursStart != "" {
+		startHour, startMinute, err := ParseDigestTime(cfg.QuietHoursStart)
+		if err != nil {
+			return fmt.Errorf("quiet_hours_start: %w", err)
+		}
+		endHour, endMinute, err := ParseDigestTime(cfg.QuietHoursEnd)
+		if err != nil {
+			return fmt.Errorf("quiet_hours_end: %w", err)
+		}
+		cfg.QuietHoursStart = FormatDigestTime(startHour, startMinute)
+		cfg.QuietHoursEnd = FormatDigestTime(endHour, endMinute)
+		if cfg.QuietHoursStart == cfg.QuietHoursEnd {
+			return fmt.Errorf("quiet_hours_start and quiet_hours_end must differ, got %s for both", cfg.QuietHoursStart)
+		}
+	}
+	for _, proxy := range []struct{ name, url string }{
+		{"http_proxy", cfg.HTTPProxy},
+		{"https_proxy", cfg.HTTPSProxy},
+	} {
+		if proxy.url == "" {
+			continue
+		}
+		if u, err := url.Parse(proxy.url); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%s must be a URL such as http://proxy:8080, got %q", proxy.name, proxy.url)
+		}
+	}
+	if cfg.HealthAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.HealthAddr); err != nil {
+			return fmt.Errorf("health_addr must be host:port such as :8080, got %q", cfg.HealthAddr)
+		}
+	}
+	if cfg.TriggerSecret != "" && cfg.HealthAddr == "" {
+		return fmt.Errorf("trigger_secret requires health_addr to serve the trigger endpoint")
+	}
+	if cfg.TelegramAPIBase != "" {
+		if u, err := url.Parse(cfg.TelegramAPIBase); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("telegram_api_base must be a URL such as http://localhost:8081, got %q", cfg.TelegramAPIBase)
+		}
+	}
+	for i, lang := range cfg.AllowedLanguages {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if !language.Supported(lang) {
+			return fmt.Errorf("allowed_languages: unsupported language %q", lang)
+		}
+		cfg.AllowedLanguages[i] = lang
+	}
+	if cfg.MaxRankedTags < 0 {
+		return fmt.Errorf("max_ranked_tags must not be negative, got %d", cfg.MaxRankedTags)
+	}
+	if cfg.MinArticles < 0 {
+		return fmt.Errorf("min_articles must not be negative, got %d", cfg.MinArticles)
+	}
+	if cfg.CandidateMultiplier < 1 {
+		return fmt.Errorf("candidate_multiplier must be at least 1, got %d", cfg.CandidateMultiplier)
+	}
+	if cfg.MaxStories < 0 {
+		return fmt.Errorf("max_stories must not be negative, got %d", cfg.MaxStories)
+	}
+	if cfg.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("max_idle_conns_per_host must not be negative, got %d", cfg.MaxIdleConnsPerHost)
+	}
+	if cfg.IdleConnTimeout < 0 {
+		return fmt.Errorf("idle_conn_timeout must not be negative, got %s", cfg.IdleConnTimeout)
+	}
+	if cfg.StoryWindow != "top" && cfg.StoryWindow != "since_last_digest" {
+		return fmt.Errorf("story_window must be top or since_last_digest, got %q", cfg.StoryWindow)
+	}
+	if cfg.WindowMinScore < 0 {
+		return fmt.Errorf("window_min_score must not be negative, got %d", cfg.WindowMinScore)
+	}
+	if cfg.SummaryMinLength < 0 || cfg.SummaryMaxLength < cfg.SummaryMinLength {
+		return fmt.Errorf("summary_min_length (%d) must be non-negative and at most summary_max_length (%d)",
+			cfg.SummaryMinLength, cfg.SummaryMaxLength)
+	}
+	if cfg.DecayMode != "per_run" && cfg.DecayMode != "per_day" {
+		return fmt.Errorf("decay_mode must be per_run or per_day, got %q", cfg.DecayMode)
+	}
+	if cfg.SelectionMode != "topn" && cfg.SelectionMode != "sampled" {
+		return fmt.Errorf("selection_mode must be topn or sampled, got %q", cfg.SelectionMode)
+	}
+	switch cfg.TagBoostCurve {
+	case "linear", "log", "sqrt":
+	default:
+		return fmt.Errorf("tag_boost_curve must be linear, log or sqrt, got %q", cfg.TagBoostCurve)
+	}
+	switch cfg.TagWeighting {
+	case "even", "title", "split":
+	default:
+		return fmt.Errorf("tag_weighting must be even, title or split, got %q", cfg.TagWeighting)
+	}
+	if cfg.SummarizerRPM < 0 {
+		return fmt.Errorf("summarizer_rpm must not be negative, got %d", cfg.SummarizerRPM)
+	}
+	if cfg.ScheduleJitter < 0 || cfg.ScheduleJitter >= 24*time.Hour {
+		return fmt.Errorf("schedule_jitter must be between 0 and 24h, got %v", cfg.ScheduleJitter)
+	}
+	if cfg.SendJitter < 0 {
+		return fmt.Errorf("send_jitter must not be negative, got %v", cfg.SendJitter)
+	}
+	for _, note := range []struct {
+		name string
+		text string
+	}{
+		{"digest_intro", cfg.DigestIntro},
+		{"digest_outro", cfg.DigestOutro},
+	} {
+		if n := utf8.RuneCountInString(note.text); n > 4096 {
+			return fmt.Errorf("%s must be at most Telegram's limit of 4096 characters, got %d", note.name, n)
+		}
+	}
+	if cfg.MaxMessageLength < 0 || cfg.MaxMessageLength > 4096 {
+		return fmt.Errorf("max_message_length must be between 1 and Telegram's limit of 4096, got %d", cfg.MaxMessageLength)
+	}
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"item_timeout", cfg.ItemTimeout},
+		{"scrape_timeout", cfg.ScrapeTimeout},
+		{"summary_timeout", cfg.SummaryTimeout},
+		{"send_timeout", cfg.SendTimeout},
+		{"gemini_request_timeout", cfg.GeminiReqTimeout},
+		{"max_run_duration", cfg.MaxRunDuration},
+		{"max_article_age", cfg.MaxArticleAge},
+	} {
+		if timeout.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", timeout.name, timeout.value)
+		}
+	}
+	if cfg.MinTagScore < 0 {
+		return fmt.Errorf("min_tag_score must not be negative, got %v", cfg.MinTagScore)
+	}
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %w", err)
+	}
+	if cfg.LogFormat != "json" && cfg.LogFormat != "text" {
+		return fmt.Errorf("log_format must be json or text, got %q", cfg.LogFormat)
 	}
-	if !digestTimeRegex.MatchString(cfg.DigestTime) {
-		return fmt.Errorf("digest_time must be in HH:MM format (00:00-23:59), got %q", cfg.DigestTime)
+	if err := logging.ValidateOutput(cfg.LogOutput); err != nil {
+		return fmt.Errorf("log_output: %w", err)
 	}
 	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
 		return fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
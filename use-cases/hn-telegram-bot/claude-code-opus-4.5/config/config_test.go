@@ -1,9 +1,13 @@
 package config
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadDefaults(t *testing.T) {
@@ -36,24 +40,86 @@ gemini_api_key: "test-key"
 	if cfg.ArticleCount != 30 {
 		t.Errorf("ArticleCount = %d, want %d", cfg.ArticleCount, 30)
 	}
+	if cfg.ItemTimeout != 15*time.Second || cfg.ScrapeTimeout != 30*time.Second ||
+		cfg.SummaryTimeout != 2*time.Minute || cfg.SendTimeout != 30*time.Second {
+		t.Errorf("timeouts = %v/%v/%v/%v, want 15s/30s/2m/30s",
+			cfg.ItemTimeout, cfg.ScrapeTimeout, cfg.SummaryTimeout, cfg.SendTimeout)
+	}
+	if cfg.GeminiReqTimeout != 60*time.Second {
+		t.Errorf("GeminiReqTimeout = %v, want 60s", cfg.GeminiReqTimeout)
+	}
+	if cfg.MaxMessageLength != 4096 {
+		t.Errorf("MaxMessageLength = %d, want %d", cfg.MaxMessageLength, 4096)
+	}
+	if cfg.CandidateMultiplier != 2 {
+		t.Errorf("CandidateMultiplier = %d, want %d", cfg.CandidateMultiplier, 2)
+	}
 	if cfg.FetchTimeoutSecs != 10 {
 		t.Errorf("FetchTimeoutSecs = %d, want %d", cfg.FetchTimeoutSecs, 10)
 	}
+	if cfg.SummaryMinLength != 10 || cfg.SummaryMaxLength != 1000 {
+		t.Errorf("summary length bounds = %d-%d, want 10-1000", cfg.SummaryMinLength, cfg.SummaryMaxLength)
+	}
+	if cfg.HNConcurrency != 8 {
+		t.Errorf("HNConcurrency = %d, want %d", cfg.HNConcurrency, 8)
+	}
+	if cfg.ScrapeConcurrency != 4 {
+		t.Errorf("ScrapeConcurrency = %d, want %d", cfg.ScrapeConcurrency, 4)
+	}
+	if cfg.SendDelay != 300*time.Millisecond {
+		t.Errorf("SendDelay = %v, want %v", cfg.SendDelay, 300*time.Millisecond)
+	}
+	if cfg.SendJitter != 0 {
+		t.Errorf("SendJitter = %v, want 0", cfg.SendJitter)
+	}
 	if cfg.TagDecayRate != 0.02 {
 		t.Errorf("TagDecayRate = %f, want %f", cfg.TagDecayRate, 0.02)
 	}
+	if cfg.DecayMode != "per_run" {
+		t.Errorf("DecayMode = %q, want %q", cfg.DecayMode, "per_run")
+	}
+	if cfg.SelectionMode != "topn" {
+		t.Errorf("SelectionMode = %q, want %q", cfg.SelectionMode, "topn")
+	}
 	if cfg.MinTagWeight != 0.1 {
 		t.Errorf("MinTagWeight = %f, want %f", cfg.MinTagWeight, 0.1)
 	}
 	if cfg.TagBoostOnLike != 0.2 {
 		t.Errorf("TagBoostOnLike = %f, want %f", cfg.TagBoostOnLike, 0.2)
 	}
+	if cfg.TagBoostCurve != "linear" {
+		t.Errorf("TagBoostCurve = %q, want linear", cfg.TagBoostCurve)
+	}
+	if cfg.TagWeighting != "even" {
+		t.Errorf("TagWeighting = %q, want even", cfg.TagWeighting)
+	}
+	if cfg.MinTagScore != 0 {
+		t.Errorf("MinTagScore = %f, want 0 (disabled)", cfg.MinTagScore)
+	}
+	if cfg.MaxRankedTags != 0 {
+		t.Errorf("MaxRankedTags = %d, want 0 (every tag)", cfg.MaxRankedTags)
+	}
+	if cfg.MinTagScoreLikes != 10 {
+		t.Errorf("MinTagScoreLikes = %d, want %d", cfg.MinTagScoreLikes, 10)
+	}
+	if cfg.ShutdownGraceSecs != 60 {
+		t.Errorf("ShutdownGraceSecs = %d, want %d", cfg.ShutdownGraceSecs, 60)
+	}
 	if cfg.DBPath != "./hn-bot.db" {
 		t.Errorf("DBPath = %q, want %q", cfg.DBPath, "./hn-bot.db")
 	}
 	if cfg.LogLevel != "info" {
 		t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, "info")
 	}
+	if cfg.LogFormat != "json" || cfg.LogOutput != "stdout" {
+		t.Errorf("log format and output = %q, %q; want json to stdout", cfg.LogFormat, cfg.LogOutput)
+	}
+	if len(cfg.LikeEmojis) != 1 || cfg.LikeEmojis[0] != "👍" {
+		t.Errorf("LikeEmojis = %v, want [👍]", cfg.LikeEmojis)
+	}
+	if len(cfg.DislikeEmojis) != 0 {
+		t.Errorf("DislikeEmojis = %v, want empty", cfg.DislikeEmojis)
+	}
 }
 
 func TestLoadOverrideDefaults(t *testing.T) {
@@ -68,11 +134,20 @@ timezone: "America/New_York"
 article_count: 50
 fetch_timeout_secs: 30
 tag_decay_rate: 0.05
+decay_mode: "per_day"
 min_tag_weight: 0.2
 tag_boost_on_like: 0.5
 db_path: "/data/bot.db"
 log_level: "debug"
 chat_id: 123456
+like_emojis: ["❤️", "🔥"]
+dislike_emojis: ["👎"]
+hn_base_url: "http://localhost:8080"
+send_delay: "1.5s"
+send_jitter: "500ms"
+schedule_jitter: "15m"
+max_run_duration: "20m"
+max_article_age: "48h"
 `
 	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
 		t.Fatal(err)
@@ -107,6 +182,9 @@ chat_id: 123456
 	if cfg.TagDecayRate != 0.05 {
 		t.Errorf("TagDecayRate = %f, want %f", cfg.TagDecayRate, 0.05)
 	}
+	if cfg.DecayMode != "per_day" {
+		t.Errorf("DecayMode = %q, want %q", cfg.DecayMode, "per_day")
+	}
 	if cfg.MinTagWeight != 0.2 {
 		t.Errorf("MinTagWeight = %f, want %f", cfg.MinTagWeight, 0.2)
 	}
@@ -122,6 +200,30 @@ chat_id: 123456
 	if cfg.ChatID != 123456 {
 		t.Errorf("ChatID = %d, want %d", cfg.ChatID, 123456)
 	}
+	if len(cfg.LikeEmojis) != 2 || cfg.LikeEmojis[0] != "❤️" || cfg.LikeEmojis[1] != "🔥" {
+		t.Errorf("LikeEmojis = %v, want [❤️ 🔥]", cfg.LikeEmojis)
+	}
+	if len(cfg.DislikeEmojis) != 1 || cfg.DislikeEmojis[0] != "👎" {
+		t.Errorf("DislikeEmojis = %v, want [👎]", cfg.DislikeEmojis)
+	}
+	if cfg.HNBaseURL != "http://localhost:8080" {
+		t.Errorf("HNBaseURL = %q, want %q", cfg.HNBaseURL, "http://localhost:8080")
+	}
+	if cfg.SendDelay != 1500*time.Millisecond {
+		t.Errorf("SendDelay = %v, want %v", cfg.SendDelay, 1500*time.Millisecond)
+	}
+	if cfg.SendJitter != 500*time.Millisecond {
+		t.Errorf("SendJitter = %v, want %v", cfg.SendJitter, 500*time.Millisecond)
+	}
+	if cfg.ScheduleJitter != 15*time.Minute {
+		t.Errorf("ScheduleJitter = %v, want %v", cfg.ScheduleJitter, 15*time.Minute)
+	}
+	if cfg.MaxRunDuration != 20*time.Minute {
+		t.Errorf("MaxRunDuration = %v, want %v", cfg.MaxRunDuration, 20*time.Minute)
+	}
+	if cfg.MaxArticleAge != 48*time.Hour {
+		t.Errorf("MaxArticleAge = %v, want %v", cfg.MaxArticleAge, 48*time.Hour)
+	}
 }
 
 func TestLoadMissingTelegramToken(t *testing.T) {
@@ -140,6 +242,22 @@ gemini_api_key: "test-key"
 	}
 }
 
+func TestLoadOfflineWithoutCredentials(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("offline: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Offline {
+		t.Error("Offline = false, want true")
+	}
+}
+
 func TestLoadMissingGeminiAPIKey(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -156,12 +274,80 @@ telegram_token: "test-token"
 	}
 }
 
+func TestLoadGeminiAPIKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_keys: ["key-a", "key-b"]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.GeminiAPIKeys) != 2 || cfg.GeminiAPIKeys[0] != "key-a" || cfg.GeminiAPIKeys[1] != "key-b" {
+		t.Errorf("GeminiAPIKeys = %v, want [key-a key-b]", cfg.GeminiAPIKeys)
+	}
+}
+
+func TestLoadAdminChatIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+admin_chat_ids: [12345, -100200]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !slices.Equal(cfg.AdminChatIDs, []int64{12345, -100200}) {
+		t.Errorf("AdminChatIDs = %v, want [12345 -100200]", cfg.AdminChatIDs)
+	}
+
+	content = `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+admin_chat_ids: [0]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for admin chat ID 0")
+	}
+}
+
+func TestLoadEmptyGeminiAPIKeyInList(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_keys: ["key-a", ""]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for an empty key in gemini_api_keys")
+	}
+}
+
 func TestLoadInvalidDigestTime(t *testing.T) {
 	tests := []struct {
 		name string
 		time string
 	}{
-		{"invalid format", "9:00"},
 		{"invalid hours", "25:00"},
 		{"invalid minutes", "09:60"},
 		{"text", "nine"},
@@ -216,68 +402,953 @@ digest_time: "` + tt + `"
 	}
 }
 
-func TestLoadInvalidTimezone(t *testing.T) {
+func TestLoadNormalizesDigestTime(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
 	content := `
 telegram_token: "test-token"
 gemini_api_key: "test-key"
-timezone: "Invalid/Zone"
+digest_time: "9:05"
 `
 	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
 		t.Fatal(err)
 	}
 
-	_, err := Load(configPath)
-	if err == nil {
-		t.Fatal("expected error for invalid timezone")
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.DigestTime != "09:05" {
+		t.Errorf("DigestTime = %q, want %q", cfg.DigestTime, "09:05")
 	}
 }
 
-func TestLoadFileNotFound(t *testing.T) {
-	_, err := Load("/nonexistent/config.yaml")
-	if err == nil {
-		t.Fatal("expected error for missing file")
+func TestParseDigestTime(t *testing.T) {
+	tests := []struct {
+		input   string
+		hour    int
+		minute  int
+		wantErr bool
+	}{
+		{"09:00", 9, 0, false},
+		{"9:00", 9, 0, false},
+		{"0:00", 0, 0, false},
+		{"00:00", 0, 0, false},
+		{"23:59", 23, 59, false},
+		{"12:30", 12, 30, false},
+		{"24:00", 0, 0, true},
+		{"25:00", 0, 0, true},
+		{"12:60", 0, 0, true},
+		{"12:0", 0, 0, true},
+		{"123:00", 0, 0, true},
+		{"-1:00", 0, 0, true},
+		{"0900", 0, 0, true},
+		{"09:00:00", 0, 0, true},
+		{" 09:00", 0, 0, true},
+		{"", 0, 0, true},
+		{"nine", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		hour, minute, err := ParseDigestTime(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDigestTime(%q) should return error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDigestTime(%q) unexpected error: %v", tt.input, err)
+		}
+		if hour != tt.hour || minute != tt.minute {
+			t.Errorf("ParseDigestTime(%q) = (%d, %d), want (%d, %d)", tt.input, hour, minute, tt.hour, tt.minute)
+		}
 	}
 }
 
-func TestLoadInvalidYAML(t *testing.T) {
+func TestFormatDigestTime(t *testing.T) {
+	if got := FormatDigestTime(9, 5); got != "09:05" {
+		t.Errorf("FormatDigestTime(9, 5) = %q, want %q", got, "09:05")
+	}
+	if got := FormatDigestTime(23, 59); got != "23:59" {
+		t.Errorf("FormatDigestTime(23, 59) = %q, want %q", got, "23:59")
+	}
+}
+
+func TestLoadSeedTags(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	content := `invalid: yaml: content:`
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+seed_tags: ["Go", " rust ", "", "ai"]
+`
 	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
 		t.Fatal(err)
 	}
 
-	_, err := Load(configPath)
-	if err == nil {
-		t.Fatal("expected error for invalid YAML")
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := []string{"go", "rust", "ai"}
+	if len(cfg.SeedTags) != len(want) {
+		t.Fatalf("SeedTags = %v, want %v", cfg.SeedTags, want)
+	}
+	for i := range want {
+		if cfg.SeedTags[i] != want[i] {
+			t.Errorf("SeedTags[%d] = %q, want %q", i, cfg.SeedTags[i], want[i])
+		}
 	}
 }
 
-func TestEnvironmentVariableOverride(t *testing.T) {
+func TestLoadPinnedTags(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
 	content := `
 telegram_token: "test-token"
 gemini_api_key: "test-key"
-db_path: "/original/path.db"
+pinned_tags: [" Security", ""]
 `
 	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
 		t.Fatal(err)
 	}
 
-	// Set environment variable
-	os.Setenv("HN_BOT_DB", "/override/path.db")
-	defer os.Unsetenv("HN_BOT_DB")
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.PinnedTags) != 1 || cfg.PinnedTags[0] != "security" {
+		t.Errorf("PinnedTags = %v, want [security]", cfg.PinnedTags)
+	}
+}
+
+func TestLoadTagAliases(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+tag_aliases:
+  Machine-Learning: " ML"
+  golang: go
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
 
 	cfg, err := Load(configPath)
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
+	want := map[string]string{"machine-learning": "ml", "golang": "go"}
+	if !maps.Equal(cfg.TagAliases, want) {
+		t.Errorf("TagAliases = %v, want %v", cfg.TagAliases, want)
+	}
+}
 
-	if cfg.DBPath != "/override/path.db" {
-		t.Errorf("DBPath = %q, want %q (from env)", cfg.DBPath, "/override/path.db")
+func TestLoadInvalidTagAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases string
+	}{
+		{"empty tag", `{"golang": ""}`},
+		{"alias of itself", `{"Go": "go"}`},
+		{"chain", `{"machine learning": "machine-learning", "machine-learning": "ml"}`},
+	}
+	for _, tt := range tests {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+tag_aliases: ` + tt.aliases + `
+`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Load(configPath); err == nil {
+			t.Errorf("%s: expected error for tag_aliases %s", tt.name, tt.aliases)
+		}
+	}
+}
+
+func TestLoadInvalidDecayMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+decay_mode: "hourly"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for invalid decay_mode")
+	}
+}
+
+func TestLoadMaxRankedTags(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"5", 5, false},
+		{"-1", 0, true},
+	}
+	for _, tt := range tests {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+max_ranked_tags: ` + tt.value + `
+`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := Load(configPath)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("max_ranked_tags %s: expected an error", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("max_ranked_tags %s: Load failed: %v", tt.value, err)
+		}
+		if cfg.MaxRankedTags != tt.want {
+			t.Errorf("max_ranked_tags %s: MaxRankedTags = %d, want %d", tt.value, cfg.MaxRankedTags, tt.want)
+		}
+	}
+}
+
+func TestLoadSendDelay(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Duration
+	}{
+		{"", 300 * time.Millisecond},
+		{`send_delay: "0s"`, 0},
+		{`send_delay: "1s"`, time.Second},
+	}
+	for _, tt := range tests {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+` + tt.line + `
+`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := Load(configPath)
+		if err != nil {
+			t.Fatalf("%q: Load failed: %v", tt.line, err)
+		}
+		if cfg.SendDelay != tt.want {
+			t.Errorf("%q: SendDelay = %v, want %v", tt.line, cfg.SendDelay, tt.want)
+		}
+	}
+}
+
+func TestLoadInvalidSelectionMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+selection_mode: "random"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for invalid selection_mode")
+	}
+}
+
+func TestLoadInvalidTagBoostCurve(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+tag_boost_curve: "exponential"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for invalid tag_boost_curve")
+	}
+}
+
+func TestLoadInvalidTagWeighting(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+tag_weighting: "content"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for invalid tag_weighting")
+	}
+}
+
+func TestLoadAllowedLanguages(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+allowed_languages: [" EN", "fr"]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.AllowedLanguages) != 2 || cfg.AllowedLanguages[0] != "en" || cfg.AllowedLanguages[1] != "fr" {
+		t.Errorf("AllowedLanguages = %v, want [en fr]", cfg.AllowedLanguages)
+	}
+
+	content = `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+allowed_languages: ["klingon"]
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for unsupported language")
+	}
+}
+
+func TestLoadInvalidMaxMessageLength(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+max_message_length: 5000
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for max_message_length above Telegram's limit")
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	tokenPath := filepath.Join(tmpDir, "telegram_token")
+	keyPath := filepath.Join(tmpDir, "gemini_api_key")
+	if err := os.WriteFile(tokenPath, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, []byte("  file-key  "), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "inline-token"
+telegram_token_file: "` + tokenPath + `"
+gemini_api_key: "inline-key"
+gemini_api_key_file: "` + keyPath + `"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.TelegramToken != "file-token" {
+		t.Errorf("TelegramToken = %q, want %q from the file", cfg.TelegramToken, "file-token")
+	}
+	if cfg.GeminiAPIKey != "file-key" {
+		t.Errorf("GeminiAPIKey = %q, want %q from the file", cfg.GeminiAPIKey, "file-key")
+	}
+}
+
+func TestLoadInvalidSecretFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	emptyPath := filepath.Join(tmpDir, "empty")
+	if err := os.WriteFile(emptyPath, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"empty", emptyPath},
+		{"missing", filepath.Join(tmpDir, "missing")},
+	}
+	for _, tt := range tests {
+		configPath := filepath.Join(tmpDir, "config.yaml")
+		content := `
+telegram_token_file: "` + tt.path + `"
+gemini_api_key: "test-key"
+`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(configPath); err == nil {
+			t.Errorf("%s: expected error for telegram_token_file %s", tt.name, tt.path)
+		}
+	}
+}
+
+func TestLoadNegativeTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+scrape_timeout: "-1s"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative scrape_timeout")
+	}
+}
+
+func TestLoadNegativeGeminiReqTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+gemini_request_timeout: "-30s"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative gemini_request_timeout")
+	}
+}
+
+func TestLoadInvalidLogSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+	}{
+		{"level", `log_level: "verbose"`},
+		{"format", `log_format: "xml"`},
+		{"output", `log_output: "syslog"`},
+		{"file without path", `log_output: "file:"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+` + tt.setting + "\n"
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Load(configPath); err == nil {
+				t.Errorf("expected error for %s", tt.setting)
+			}
+		})
+	}
+}
+
+func TestLoadQuietHours(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+quiet_hours_start: "23:00"
+quiet_hours_end: "7:00"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.QuietHoursStart != "23:00" || cfg.QuietHoursEnd != "07:00" {
+		t.Errorf("quiet hours = %s-%s, want 23:00-07:00", cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	}
+}
+
+func TestLoadInvalidQuietHours(t *testing.T) {
+	tests := []struct {
+		name  string
+		hours string
+	}{
+		{"start only", `quiet_hours_start: "23:00"`},
+		{"end only", `quiet_hours_end: "07:00"`},
+		{"invalid start", "quiet_hours_start: \"24:00\"\nquiet_hours_end: \"07:00\""},
+		{"invalid end", "quiet_hours_start: \"23:00\"\nquiet_hours_end: \"seven\""},
+		{"same time", "quiet_hours_start: \"7:00\"\nquiet_hours_end: \"07:00\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+` + tt.hours + "\n"
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Load(configPath); err == nil {
+				t.Errorf("expected error for quiet hours %q", tt.hours)
+			}
+		})
+	}
+}
+
+func TestLoadNegativeSummarizerRPM(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+summarizer_rpm: -1
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative summarizer_rpm")
+	}
+}
+
+func TestLoadInvalidCandidateMultiplier(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+candidate_multiplier: -1
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for candidate_multiplier below 1")
+	}
+}
+
+func TestLoadInvalidMaxStories(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+max_stories: -5
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative max_stories")
+	}
+}
+
+func TestLoadInvalidMaxIdleConnsPerHost(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+max_idle_conns_per_host: -1
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative max_idle_conns_per_host")
+	}
+}
+
+func TestLoadInvalidMinArticles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+min_articles: -1
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for negative min_articles")
+	}
+}
+
+func TestLoadStoryWindow(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+story_window: since_last_digest
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.StoryWindow != "since_last_digest" || cfg.WindowMinScore != 50 {
+		t.Errorf("story_window = %q, window_min_score = %d; want since_last_digest and the default 50",
+			cfg.StoryWindow, cfg.WindowMinScore)
+	}
+
+	// Zero takes every story in the window, whatever its score
+	if err := os.WriteFile(configPath, []byte(content+"window_min_score: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.WindowMinScore != 0 {
+		t.Errorf("window_min_score = %d, want 0 as set", cfg.WindowMinScore)
+	}
+
+	content = strings.Replace(content, "since_last_digest", "yesterday", 1)
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for unknown story_window")
+	}
+}
+
+func TestLoadInvalidSummaryLength(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+summary_min_length: 200
+summary_max_length: 100
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for summary_min_length above summary_max_length")
+	}
+}
+
+func TestLoadInvalidProxy(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+https_proxy: "proxy:8080"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for proxy without a scheme")
+	}
+}
+
+func TestLoadInvalidTelegramAPIBase(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+telegram_api_base: "localhost:8081"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for telegram_api_base without a scheme")
+	}
+}
+
+func TestLoadInvalidHealthAddr(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+health_addr: "8080"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for health_addr without a port separator")
+	}
+}
+
+func TestLoadTriggerSecretRequiresHealthAddr(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+trigger_secret: "s3cret"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for trigger_secret without health_addr")
+	}
+}
+
+func TestLoadDigestOutroTooLong(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+digest_intro: "Curated for the team"
+digest_outro: "` + strings.Repeat("é", 4097) + `"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("expected error for digest_outro over Telegram's message limit")
+	}
+}
+
+func TestLoadInvalidTimezone(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+timezone: "Invalid/Zone"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(configPath)
+	if err == nil {
+		t.Fatal("expected error for invalid timezone")
+	}
+}
+
+func TestLoadFileNotFound(t *testing.T) {
+	_, err := Load("/nonexistent/config.yaml")
+	if err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestLoadInvalidYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `invalid: yaml: content:`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(configPath)
+	if err == nil {
+		t.Fatal("expected error for invalid YAML")
+	}
+}
+
+func TestEnvironmentVariableOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `
+telegram_token: "test-token"
+gemini_api_key: "test-key"
+db_path: "/original/path.db"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set environment variable
+	os.Setenv("HN_BOT_DB", "/override/path.db")
+	defer os.Unsetenv("HN_BOT_DB")
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.DBPath != "/override/path.db" {
+		t.Errorf("DBPath = %q, want %q (from env)", cfg.DBPath, "/override/path.db")
+	}
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBaseOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	writeConfig(t, configPath, `
+telegram_token: "base-token"
+gemini_api_key: "base-key"
+article_count: 15
+`)
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.TelegramToken != "base-token" || cfg.ArticleCount != 15 {
+		t.Errorf("got token %q, count %d; want base values", cfg.TelegramToken, cfg.ArticleCount)
+	}
+}
+
+func TestLoadLocalOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	writeConfig(t, configPath, `
+telegram_token: "base-token"
+gemini_api_key: "base-key"
+article_count: 15
+digest_time: "08:00"
+`)
+	writeConfig(t, filepath.Join(tmpDir, "config.local.yaml"), `
+telegram_token: "local-token"
+article_count: 5
+like_emojis: ["🔥"]
+`)
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// Local values win
+	if cfg.TelegramToken != "local-token" {
+		t.Errorf("TelegramToken = %q, want 'local-token'", cfg.TelegramToken)
+	}
+	if cfg.ArticleCount != 5 {
+		t.Errorf("ArticleCount = %d, want 5", cfg.ArticleCount)
+	}
+	if len(cfg.LikeEmojis) != 1 || cfg.LikeEmojis[0] != "🔥" {
+		t.Errorf("LikeEmojis = %v, want [🔥]", cfg.LikeEmojis)
+	}
+
+	// Fields absent from the local file keep their base values
+	if cfg.GeminiAPIKey != "base-key" {
+		t.Errorf("GeminiAPIKey = %q, want 'base-key'", cfg.GeminiAPIKey)
+	}
+	if cfg.DigestTime != "08:00" {
+		t.Errorf("DigestTime = %q, want '08:00'", cfg.DigestTime)
+	}
+}
+
+func TestLoadPartialLocalOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	// The base file alone is invalid; the local file supplies the secret
+	writeConfig(t, configPath, `
+telegram_token: "base-token"
+timezone: "Europe/Rome"
+`)
+	writeConfig(t, filepath.Join(tmpDir, "config.local.yaml"), `
+gemini_api_key: "local-key"
+`)
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.GeminiAPIKey != "local-key" || cfg.TelegramToken != "base-token" || cfg.Timezone != "Europe/Rome" {
+		t.Errorf("got %+v, want base values merged with the local key", cfg)
+	}
+	// Defaults still apply to fields set in neither file
+	if cfg.ArticleCount != 30 {
+		t.Errorf("ArticleCount = %d, want default 30", cfg.ArticleCount)
+	}
+}
+
+func TestLoadInvalidLocalYAML(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	writeConfig(t, configPath, `
+telegram_token: "base-token"
+gemini_api_key: "base-key"
+`)
+	writeConfig(t, filepath.Join(tmpDir, "config.local.yaml"), "article_count: [not a number")
+
+	if _, err := Load(configPath); err == nil {
+		t.Fatal("expected error for invalid local YAML")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := map[string]string{
+		"./config.yaml":       "./config.local.yaml",
+		"/etc/hn-bot/bot.yml": "/etc/hn-bot/bot.local.yml",
+		"config":              "config.local",
+	}
+	for path, want := range tests {
+		if got := LocalPath(path); got != want {
+			t.Errorf("LocalPath(%q) = %q, want %q", path, got, want)
+		}
 	}
 }
 
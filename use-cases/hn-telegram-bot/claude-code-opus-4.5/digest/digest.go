@@ -1,63 +1,188 @@
 package digest
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
+	"math"
+	"math/rand"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"hn-telegram-bot/language"
 	"hn-telegram-bot/ranker"
+	"hn-telegram-bot/util"
 )
 
-const defaultRecencyWindow = 7 * 24 * time.Hour
+const (
+	defaultRecencyWindow     = 7 * 24 * time.Hour
+	defaultHNConcurrency     = 8
+	defaultScrapeConcurrency = 4
+	// defaultSeenWindow is how long the summary of an article that was
+	// processed but not sent is reused if the story comes up again.
+	defaultSeenWindow = 24 * time.Hour
+	// defaultCandidateMultiplier is how many stories are fetched per
+	// article wanted, leaving room for deduplication and filters.
+	defaultCandidateMultiplier = 2
+	// preRankBuffer is how many stories are summarized per article wanted
+	// when ranking before summarizing, leaving room for stories dropped
+	// by the language and tag score filters.
+	preRankBuffer = 1.5
+	// maxDiscussionComments is how many top comments a discussion take is
+	// drawn from.
+	maxDiscussionComments = 5
+	// maxFailedAttempts is how many times an article may fail before it
+	// is no longer retried.
+	maxFailedAttempts = 3
+)
+
+// Stages at which processing an article can fail, as recorded for retry.
+const (
+	stageFetch     = "fetch"
+	stageSummarize = "summarize"
+)
+
+// lastDecaySetting stores when decay was last applied in DecayPerDay mode.
+const lastDecaySetting = "last_decay_at"
+
+// DecayMode controls how often tag and domain weights decay.
+type DecayMode string
+
+const (
+	// DecayPerRun applies the decay rate once per digest run.
+	DecayPerRun DecayMode = "per_run"
+	// DecayPerDay applies the decay rate per elapsed day since the last
+	// decay, regardless of how many digests ran in between.
+	DecayPerDay DecayMode = "per_day"
+)
+
+// SelectionMode determines how the articles to send are picked from the
+// ranked candidates.
+type SelectionMode string
+
+const (
+	// SelectTopN sends the highest-scoring articles.
+	SelectTopN SelectionMode = "topn"
+	// SelectSampled draws articles at random with probability proportional
+	// to their score, so lower-ranked topics occasionally get through.
+	SelectSampled SelectionMode = "sampled"
+)
+
+// Trigger identifies what started a digest run.
+type Trigger string
+
+const (
+	// TriggerScheduled is a run started by the daily schedule.
+	TriggerScheduled Trigger = "scheduled"
+	// TriggerOnDemand is a run the user asked for, e.g. with /fetch.
+	TriggerOnDemand Trigger = "on_demand"
+	// TriggerHTTP is a run requested over HTTP, such as by an external
+	// scheduler. Like on-demand runs, it replies when there is nothing to
+	// send.
+	TriggerHTTP Trigger = "http"
+)
+
+// emptyDigestNotice is sent instead of articles when none survive filtering.
+const emptyDigestNotice = "No new articles matched your interests today."
+
+// ErrChatUnavailable is returned by an ArticleSender when the chat can no
+// longer receive messages. The digest stops at the first such error.
+var ErrChatUnavailable = errors.New("chat unavailable")
 
 // HNItem represents a Hacker News item.
 type HNItem struct {
 	ID          int64
 	Title       string
 	URL         string
+	Text        string // HTML body of text posts such as Ask HN
 	Score       int
 	Descendants int
+	By          string    // Username of the submitter
+	Kids        []int64   // Top-level comment IDs, in HN's ranked order
+	Time        time.Time // When the story was posted, zero if unknown
 }
 
 // SummaryResult contains summarization output.
 type SummaryResult struct {
 	Summary string
 	Tags    []string
+	Model   string // Model that produced the summary, if known
+	Err     error  // Set on a batch result that failed, to summarize on its own
+}
+
+// SummaryInput is a single article to summarize as part of a batch.
+type SummaryInput struct {
+	Title   string
+	Content string
 }
 
 // StoredArticle represents an article in storage.
 type StoredArticle struct {
-	ID            int64
-	Title         string
-	URL           string
-	Summary       string
-	Tags          []string
-	HNScore       int
-	FetchedAt     time.Time
-	SentAt        *time.Time
-	TelegramMsgID *int64
+	ID           int64
+	Title        string
+	URL          string
+	Summary      string
+	SummaryModel string
+	ContentHash  string
+	Tags         []string
+	HNScore      int
+	FetchedAt    time.Time
+	ImageURL     string // Main image of the article's page, "" if none
 }
 
+// SourceTop identifies articles fetched from the HN top stories list, the
+// default story source.
+const SourceTop = "top"
+
 // ProcessedArticle is an article ready for ranking.
 type ProcessedArticle struct {
-	ID       int64
-	Title    string
-	URL      string
-	Summary  string
-	Tags     []string
-	HNScore  int
-	Comments int
+	ID           int64
+	Title        string
+	URL          string
+	CanonicalURL string // URL of the page without AMP or tracking variants
+	Summary      string
+	SummaryModel string
+	ContentHash  string // Hash of the content that was summarized
+	Tags         []string
+	HNScore      int
+	Comments     int
+	By           string  // Username of the submitter
+	CommentIDs   []int64 // Top-level comments, best first
+	Source       string
+	PostedAt     time.Time
+	WordCount    int    // Words in the scraped page, 0 if it wasn't scraped
+	ImageURL     string // Main image of the article's page, "" if none
 }
 
 // ArticleToSend contains data for sending an article to Telegram.
 type ArticleToSend struct {
-	ID       int64
-	Title    string
-	URL      string
-	Summary  string
-	HNScore  int
-	Comments int
+	ID          int64
+	Title       string
+	URL         string
+	Summary     string
+	HNScore     int
+	Comments    int
+	Explanation string // Empty unless explanations are enabled
+	Discussion  string // Gist of the comments, empty unless enabled
+	WordCount   int    // Words in the scraped page, 0 if unknown
+	PostedAt    time.Time
+	Source      string
+	By          string // Username of the submitter
+	ImageURL    string // Main image of the article's page, "" if none
+	// FirstScore is the score the article had when a digest first came
+	// across it, at FirstSeenAt. FirstSeenAt is zero if this run is the
+	// first.
+	FirstScore  int
+	FirstSeenAt time.Time
 }
 
 // HNClient fetches data from Hacker News.
@@ -71,37 +196,149 @@ type Scraper interface {
 	Scrape(ctx context.Context, url string) (string, error)
 }
 
+// ScrapedPage is what a PageScraper reads from an article's page.
+type ScrapedPage struct {
+	Content   string
+	Canonical string // Canonical URL of the page, such as the source page of an AMP URL
+	ImageURL  string // Main image of the page, "" if it declares none
+}
+
+// PageScraper extracts content from URLs along with what the page declares
+// about itself. CanonicalURL collapses AMP and tracking variants of a URL
+// without fetching it, for pages that can't be scraped.
+type PageScraper interface {
+	ScrapePage(ctx context.Context, url string) (*ScrapedPage, error)
+	CanonicalURL(url string) string
+}
+
 // Summarizer generates summaries.
 type Summarizer interface {
 	Summarize(ctx context.Context, title, content string) (*SummaryResult, error)
 }
 
+// BatchSummarizer summarizes several articles in one request. Results
+// must be in the same order as inputs.
+type BatchSummarizer interface {
+	SummarizeBatch(ctx context.Context, inputs []SummaryInput) ([]SummaryResult, error)
+}
+
+// DiscussionSummarizer sums up an HN discussion from its top comments.
+type DiscussionSummarizer interface {
+	SummarizeDiscussion(ctx context.Context, comments []string) (string, error)
+}
+
 // Storage provides persistence operations.
 type Storage interface {
-	GetRecentlySentArticleIDs(ctx context.Context, within time.Duration) ([]int64, error)
+	GetRecentlySentArticleIDs(ctx context.Context, chatID int64, within time.Duration) ([]int64, error)
+	// WasSent reports whether an article was sent to a chat and when it
+	// last was, for checking a few articles without loading every recent
+	// ID.
+	WasSent(ctx context.Context, chatID, articleID int64) (sent bool, sentAt time.Time, err error)
 	GetAllTagWeights(ctx context.Context) (map[string]float64, error)
-	ApplyTagDecay(ctx context.Context, decayRate, minWeight float64) error
+	// GetAllTagCounts returns how many times each tag has been boosted.
+	GetAllTagCounts(ctx context.Context) (map[string]int, error)
+	// ApplyDecay decays tag and domain weights and records a snapshot of
+	// the tag weights, changing nothing if any step fails.
+	ApplyDecay(ctx context.Context, decayRate, minWeight float64) error
+	GetAllDomainWeights(ctx context.Context) (map[string]float64, error)
+	GetArticleTags(ctx context.Context, articleID int64) ([]string, error)
+	// GetFirstScore returns the score a stored article was first saved
+	// with and when, or a zero time if it hasn't been stored.
+	GetFirstScore(ctx context.Context, articleID int64) (score int, seenAt time.Time, err error)
+	// GetSummaryByContentHash returns the stored summary of an article if
+	// it was summarized from content with the given hash, or nil if not.
+	GetSummaryByContentHash(ctx context.Context, articleID int64, hash string) (*SummaryResult, error)
+	// GetSeenArticle returns the stored article if it was marked seen
+	// within the given duration, or nil if it wasn't.
+	GetSeenArticle(ctx context.Context, articleID int64, within time.Duration) (*StoredArticle, error)
+	MarkArticleSeen(ctx context.Context, articleID int64) error
+	GetLikeCount(ctx context.Context) (int, error)
 	SaveArticle(ctx context.Context, article *StoredArticle) error
-	MarkArticleSent(ctx context.Context, articleID int64, telegramMsgID int64) error
+	MarkArticleSent(ctx context.Context, articleID, chatID, telegramMsgID int64, source string) error
 	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	// RecordFailedArticle records a failed attempt to process an article,
+	// so that it is retried by later runs.
+	RecordFailedArticle(ctx context.Context, articleID int64, stage, errMsg string) error
+	// GetFailedArticleIDs returns articles that last failed within the
+	// given duration and fewer than maxAttempts times.
+	GetFailedArticleIDs(ctx context.Context, maxAttempts int, within time.Duration) ([]int64, error)
+	ClearFailedArticle(ctx context.Context, articleID int64) error
 }
 
-// ArticleSender sends articles to Telegram.
+// SentFilter drops the articles recently sent to a chat from a list of
+// candidates, without loading every recently sent ID.
+type SentFilter interface {
+	// UnsentArticleIDs returns the IDs, in order, of the articles not sent
+	// to the chat within the given duration.
+	UnsentArticleIDs(ctx context.Context, chatID int64, ids []int64, within time.Duration) ([]int64, error)
+}
+
+// ArticleSender sends articles, and plain notices about the digest, to
+// Telegram.
 type ArticleSender interface {
 	SendArticle(ctx context.Context, chatID int64, article *ArticleToSend) (int64, error)
+	SendNotice(ctx context.Context, chatID int64, text string) error
+}
+
+// Timeouts bounds each outbound call of a run, so that one stuck request
+// can't hold up the rest. A zero duration leaves that call bounded only by
+// the run's context.
+type Timeouts struct {
+	Item      time.Duration // Fetching one HN item
+	Scrape    time.Duration // Scraping one article
+	Summarize time.Duration // One summary or batch request
+	Send      time.Duration // Sending one message
 }
 
 // Runner orchestrates the digest workflow.
 type Runner struct {
-	hnClient     HNClient
-	scraper      Scraper
-	summarizer   Summarizer
-	storage      Storage
-	sender       ArticleSender
-	chatID       int64
-	articleCount int
-	decayRate    float64
-	minTagWeight float64
+	hnClient       HNClient
+	windowed       WindowedHNClient
+	windowMinScore int
+	scraper        Scraper
+	pages          PageScraper
+	summarizer     Summarizer
+	storage        Storage
+	sentFilter     SentFilter
+	sender         ArticleSender
+	chatID         int64
+	articleCount   int
+	minArticles    int
+	multiplier     int
+	decayRate      float64
+	decayMode      DecayMode
+	selector       Selector
+	minTagWeight   float64
+	explain        bool
+	trigger        Trigger
+	emptyNotice    bool
+	intro          string
+	outro          string
+	minTagScore    float64
+	minTagLikes    int
+	domainFactor   float64
+	tagCounts      bool
+	maxTags        int
+	batcher        BatchSummarizer
+	batchSize      int
+	hnWorkers      int
+	scrapeWorkers  int
+	sendDelay      time.Duration
+	sendJitter     time.Duration
+	timeouts       Timeouts
+	maxDuration    time.Duration
+	maxAge         time.Duration
+	languages      map[string]bool
+	pinned         map[string]bool
+	aliases        map[string]string
+	preRank        bool
+	observer       DeliveryObserver
+	batches        BatchStore
+	discussion     DiscussionSummarizer
+	byTopic        bool
+	wait           func(ctx context.Context, d time.Duration) error
+	now            func() time.Time
 }
 
 // Option configures a Runner.
@@ -121,6 +358,17 @@ func WithArticleCount(count int) Option {
 	}
 }
 
+// WithCandidateMultiplier sets how many top stories are fetched per article
+// wanted, so that articles dropped as recently sent or by filters can be
+// replaced. Values below 1 are ignored.
+func WithCandidateMultiplier(multiplier int) Option {
+	return func(r *Runner) {
+		if multiplier >= 1 {
+			r.multiplier = multiplier
+		}
+	}
+}
+
 // WithDecayRate sets the tag decay rate.
 func WithDecayRate(rate float64) Option {
 	return func(r *Runner) {
@@ -128,6 +376,22 @@ func WithDecayRate(rate float64) Option {
 	}
 }
 
+// WithDecayMode sets whether the decay rate applies per digest run or per
+// elapsed day.
+func WithDecayMode(mode DecayMode) Option {
+	return func(r *Runner) {
+		r.decayMode = mode
+	}
+}
+
+// WithSelector sets how articles are picked from the ranking. By default
+// the top ranked articles are sent.
+func WithSelector(selector Selector) Option {
+	return func(r *Runner) {
+		r.selector = selector
+	}
+}
+
 // WithMinTagWeight sets the minimum tag weight floor.
 func WithMinTagWeight(weight float64) Option {
 	return func(r *Runner) {
@@ -135,6 +399,238 @@ func WithMinTagWeight(weight float64) Option {
 	}
 }
 
+// WithDomainWeightFactor sets how strongly learned domain weights
+// contribute to the ranking score. Zero disables domain ranking.
+func WithDomainWeightFactor(factor float64) Option {
+	return func(r *Runner) {
+		r.domainFactor = factor
+	}
+}
+
+// WithTagCountConfidence scales each learned tag weight in the ranking by
+// how many times the tag has been liked, so that a tag reinforced many
+// times counts for more than one liked once at the same weight.
+func WithTagCountConfidence(enabled bool) Option {
+	return func(r *Runner) {
+		r.tagCounts = enabled
+	}
+}
+
+// WithMaxRankedTags only counts each article's n highest weighted tags in
+// the ranking, so that an article the summarizer gave many tags doesn't
+// dominate on their number. All tags are still stored and learned from.
+// Zero counts every tag.
+func WithMaxRankedTags(n int) Option {
+	return func(r *Runner) {
+		r.maxTags = n
+	}
+}
+
+// WithHNConcurrency sets how many HN items are fetched in parallel.
+func WithHNConcurrency(n int) Option {
+	return func(r *Runner) {
+		r.hnWorkers = n
+	}
+}
+
+// WithScrapeConcurrency sets how many articles are scraped and summarized
+// in parallel. Scraping hits slow third-party sites, so it is usually kept
+// lower than the HN concurrency.
+func WithScrapeConcurrency(n int) Option {
+	return func(r *Runner) {
+		r.scrapeWorkers = n
+	}
+}
+
+// WithSendDelay pauses between sent articles so a digest doesn't arrive as a
+// burst of notifications. Each pause is delay plus a random amount up to
+// jitter. A zero delay and jitter send articles back to back.
+func WithSendDelay(delay, jitter time.Duration) Option {
+	return func(r *Runner) {
+		r.sendDelay = delay
+		r.sendJitter = jitter
+	}
+}
+
+// WithBatchSummarizer summarizes up to size articles per request. If a
+// batch fails, its articles are summarized individually. Batching is
+// disabled when size is less than 2.
+func WithBatchSummarizer(batcher BatchSummarizer, size int) Option {
+	return func(r *Runner) {
+		r.batcher = batcher
+		r.batchSize = size
+	}
+}
+
+// WithExplain enables a per-article line explaining which tags matched.
+func WithExplain(explain bool) Option {
+	return func(r *Runner) {
+		r.explain = explain
+	}
+}
+
+// WithTimeouts sets the per-call timeouts.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(r *Runner) {
+		r.timeouts = timeouts
+	}
+}
+
+// WithTrigger records what started the run. On-demand runs always reply
+// when there is nothing to send.
+func WithTrigger(trigger Trigger) Option {
+	return func(r *Runner) {
+		r.trigger = trigger
+	}
+}
+
+// WithMinArticles makes scheduled runs send nothing when fewer than n
+// articles are selected, capped at the article count, so that a digest of
+// one or two articles is skipped in favour of the next. Runs of other
+// triggers send whatever they find. Zero sends any number.
+func WithMinArticles(n int) Option {
+	return func(r *Runner) {
+		r.minArticles = n
+	}
+}
+
+// WithEmptyNotice makes scheduled runs send a notice when there is nothing
+// to send, instead of staying silent.
+func WithEmptyNotice(enabled bool) Option {
+	return func(r *Runner) {
+		r.emptyNotice = enabled
+	}
+}
+
+// WithMinTagScore only includes articles whose summed matched-tag weight is
+// at least minScore. The filter is skipped until the user has liked at least
+// minLikes articles, so a cold start doesn't produce an empty digest.
+func WithMinTagScore(minScore float64, minLikes int) Option {
+	return func(r *Runner) {
+		r.minTagScore = minScore
+		r.minTagLikes = minLikes
+	}
+}
+
+// WithAllowedLanguages drops scraped articles whose detected language, as
+// an ISO 639-1 code, is not one of langs. Articles whose language can't be
+// determined, or that fell back to their title, are kept. No languages
+// disables the filter.
+func WithAllowedLanguages(langs ...string) Option {
+	return func(r *Runner) {
+		r.languages = nil
+		if len(langs) > 0 {
+			r.languages = make(map[string]bool, len(langs))
+			for _, lang := range langs {
+				r.languages[lang] = true
+			}
+		}
+	}
+}
+
+// WithPinnedTags guarantees each digest at least one article tagged with
+// one of tags, if any candidate has one, whatever its score. Tags are
+// case-insensitive.
+func WithPinnedTags(tags ...string) Option {
+	return func(r *Runner) {
+		r.pinned = nil
+		for _, tag := range tags {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				if r.pinned == nil {
+					r.pinned = make(map[string]bool)
+				}
+				r.pinned[tag] = true
+			}
+		}
+	}
+}
+
+// WithTagAliases ranks articles by their canonical tags, aliases mapping
+// each alias to its canonical tag, so that the model's variants of a tag
+// share its learned weight.
+func WithTagAliases(aliases map[string]string) Option {
+	return func(r *Runner) {
+		r.aliases = aliases
+	}
+}
+
+// WithRankBeforeSummarize ranks the candidates on their HN score, domain
+// and any tags stored from earlier runs before scraping them, and only
+// scrapes and summarizes the best of them, preRankBuffer times the article
+// count. The final ranking then only chooses among those.
+func WithRankBeforeSummarize(enabled bool) Option {
+	return func(r *Runner) {
+		r.preRank = enabled
+	}
+}
+
+// WithDiscussionSummary adds a one-line take on each sent article's HN
+// discussion, summarized from its top comments. This costs an extra
+// summarizer request per article. A nil summarizer disables it.
+func WithDiscussionSummary(summarizer DiscussionSummarizer) Option {
+	return func(r *Runner) {
+		r.discussion = summarizer
+	}
+}
+
+// WithIntroOutro frames each scheduled digest with an intro sent before
+// its first article and an outro sent after its last, as plain text. An
+// empty string leaves that message out.
+func WithIntroOutro(intro, outro string) Option {
+	return func(r *Runner) {
+		r.intro = intro
+		r.outro = outro
+	}
+}
+
+// WithTopicSections groups the sent articles into sections by their
+// dominant tag, each announced by a header message, instead of sending
+// them in rank order.
+func WithTopicSections(enabled bool) Option {
+	return func(r *Runner) {
+		r.byTopic = enabled
+	}
+}
+
+// WithMaxArticleAge skips stories submitted to HN longer ago than maxAge,
+// such as old posts that climb back up the front page. Stories whose
+// submission time is unknown are kept. Zero disables the filter.
+func WithMaxArticleAge(maxAge time.Duration) Option {
+	return func(r *Runner) {
+		r.maxAge = maxAge
+	}
+}
+
+// WithMaxRunDuration bounds the time a run spends preparing articles.
+// Once it has passed, no further candidates are fetched, scraped or
+// summarized, and the articles already prepared are ranked and sent. Zero
+// means no limit.
+func WithMaxRunDuration(d time.Duration) Option {
+	return func(r *Runner) {
+		r.maxDuration = d
+	}
+}
+
+// WithSentFilter drops recently sent candidates with filter instead of
+// loading every article sent within the recency window. A nil filter
+// keeps the default.
+func WithSentFilter(filter SentFilter) Option {
+	return func(r *Runner) {
+		r.sentFilter = filter
+	}
+}
+
+// WithPageScraper scrapes articles with scraper instead of the runner's
+// Scraper, so that articles are deduplicated, ranked by domain and stored
+// under their canonical URL, and stored with their page's main image.
+// Articles are still sent with the URL HN links to. A nil scraper disables
+// it.
+func WithPageScraper(scraper PageScraper) Option {
+	return func(r *Runner) {
+		r.pages = scraper
+	}
+}
+
 // NewRunner creates a new digest runner.
 func NewRunner(
 	hnClient HNClient,
@@ -145,14 +641,23 @@ func NewRunner(
 	opts ...Option,
 ) *Runner {
 	r := &Runner{
-		hnClient:     hnClient,
-		scraper:      scraper,
-		summarizer:   summarizer,
-		storage:      storage,
-		sender:       sender,
-		articleCount: 30,
-		decayRate:    0.02,
-		minTagWeight: 0.1,
+		hnClient:      hnClient,
+		scraper:       scraper,
+		summarizer:    summarizer,
+		storage:       storage,
+		sender:        sender,
+		articleCount:  30,
+		multiplier:    defaultCandidateMultiplier,
+		decayRate:     0.02,
+		decayMode:     DecayPerRun,
+		selector:      TopN{},
+		trigger:       TriggerScheduled,
+		minTagWeight:  0.1,
+		hnWorkers:     defaultHNConcurrency,
+		scrapeWorkers: defaultScrapeConcurrency,
+		observer:      noopObserver{},
+		wait:          util.Sleep,
+		now:           time.Now,
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -166,23 +671,363 @@ func (r *Runner) Run(ctx context.Context) error {
 		return fmt.Errorf("chat_id not set")
 	}
 
+	stats := RunStats{ChatID: r.chatID, Trigger: r.trigger}
+	if err := r.run(ctx, &stats); err != nil {
+		return err
+	}
+	r.observer.OnRunComplete(stats)
+	return nil
+}
+
+func (r *Runner) run(ctx context.Context, stats *RunStats) error {
+	startedAt := r.now()
 	slog.Info("starting digest run", "chat_id", r.chatID, "article_count", r.articleCount)
 
-	// Step 1: Apply tag decay
-	if err := r.storage.ApplyTagDecay(ctx, r.decayRate, r.minTagWeight); err != nil {
-		slog.Warn("failed to apply tag decay", "error", err)
+	// Step 1: Apply tag and domain decay
+	decayRate := r.effectiveDecayRate(ctx)
+	if err := r.storage.ApplyDecay(ctx, decayRate, r.minTagWeight); err != nil {
+		slog.Warn("failed to apply decay", "error", err)
+	}
+
+	// Candidates are prepared under the maximum run duration; sending what
+	// was prepared isn't cut short by it
+	prepCtx, cancel := withTimeout(ctx, r.maxDuration)
+	defer cancel()
+
+	// Steps 2-3: Fetch top stories and filter recently sent
+	filteredIDs, err := r.candidateIDs(prepCtx)
+	if err != nil {
+		return err
+	}
+
+	// Articles that failed in earlier runs are tried again first
+	retryIDs := r.retryIDs(prepCtx, filteredIDs)
+	candidateIDs := append(retryIDs, filteredIDs...)
+
+	// Step 4: Fetch, scrape and summarize each story
+	items, fetchErrs := r.fetchItems(prepCtx, candidateIDs)
+	items = r.filterByAge(items)
+	if r.preRank {
+		items = r.rankBeforeSummarize(prepCtx, items)
+	}
+	processed := dedupeByURL(r.processItems(prepCtx, items))
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if prepCtx.Err() != nil {
+		slog.Warn("digest run truncated at max run duration",
+			"max_run_duration", r.maxDuration, "prepared", len(processed), "candidates", len(candidateIDs))
+	}
+	// Failures caused by the deadline aren't recorded, as recordFailure
+	// skips them once prepCtx is done
+	for id, err := range fetchErrs {
+		r.recordFailure(prepCtx, id, stageFetch, err)
+	}
+	r.clearRetried(ctx, retryIDs, processed)
+	slog.Info("processed articles", "count", len(processed))
+	stats.Candidates = len(processed)
+
+	if len(processed) == 0 {
+		return r.reportEmpty(ctx)
+	}
+
+	// Step 5: Rank articles
+	rankableArticles := make([]ranker.RankableArticle, len(processed))
+	for i, a := range processed {
+		rankableArticles[i] = ranker.RankableArticle{
+			ID:      a.ID,
+			Tags:    a.Tags,
+			HNScore: a.HNScore,
+			Domain:  ranker.Domain(a.CanonicalURL),
+		}
+	}
+	all := r.rank(ctx, rankableArticles)
+	ranked := r.filterByTagScore(ctx, all)
+	selected := r.includePinned(all, r.selector.Select(ranked, r.articleCount))
+	if r.tooFew(len(selected)) {
+		// Marking them all seen keeps their summaries for the next run
+		slog.Info("too few articles, skipping", "selected", len(selected), "min_articles", r.minArticles)
+		r.markSeen(ctx, processed, nil)
+		return nil
+	}
+	r.markSeen(ctx, processed, selected)
+	if len(selected) == 0 {
+		return r.reportEmpty(ctx)
+	}
+	stats.Selected = len(selected)
+
+	// Map ranked back to processed articles
+	processedByID := make(map[int64]*ProcessedArticle)
+	for _, a := range processed {
+		processedByID[a.ID] = a
+	}
+
+	// Each section's header is sent along with its first article
+	sectionOf := make(map[int64]string)
+	headers := make(map[string]string)
+	if r.byTopic {
+		var ordered []ranker.RankedArticle
+		for i, section := range GroupByTopic(selected) {
+			headers[section.Tag] = sectionHeader(i, section.Tag)
+			for _, a := range section.Articles {
+				sectionOf[a.ID] = section.Tag
+				ordered = append(ordered, a)
+			}
+		}
+		selected = ordered
+	}
+
+	// Step 6: Prepare the selected articles for sending
+	var batch []*ArticleToSend
+	scores := make(map[int64]float64)
+	for _, rankedArticle := range selected {
+		if toSend := r.prepareArticle(ctx, processedByID[rankedArticle.ID], rankedArticle); toSend != nil {
+			batch = append(batch, toSend)
+			scores[toSend.ID] = rankedArticle.FinalScore
+		}
 	}
 
-	// Step 2: Fetch top stories (2x buffer for filtering)
-	fetchCount := r.articleCount * 2
-	storyIDs, err := r.hnClient.GetTopStories(ctx, fetchCount)
+	// Step 7: Send them, keeping the batch until it has been sent so that
+	// an interrupted run can be resumed
+	batchID := r.savePendingBatch(ctx, batch)
+	defer func() {
+		if ctx.Err() == nil {
+			r.deletePendingBatch(ctx, batchID)
+		}
+	}()
+
+	intro, outro := r.intro, r.outro
+	if r.trigger != TriggerScheduled {
+		intro, outro = "", ""
+	}
+	for i, toSend := range batch {
+		// An interrupted run stops here, leaving the rest to Resume
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("send articles: %w", err)
+		}
+		if i > 0 {
+			if err := r.wait(ctx, r.sendPause()); err != nil {
+				return err
+			}
+		}
+
+		if intro != "" {
+			if err := r.sendOptionalNotice(ctx, "intro", intro); err != nil {
+				return err
+			}
+			intro = ""
+		}
+		if header, ok := headers[sectionOf[toSend.ID]]; ok {
+			delete(headers, sectionOf[toSend.ID])
+			if err := r.sendOptionalNotice(ctx, "section header", header); err != nil {
+				return err
+			}
+		}
+
+		sent, err := r.deliver(ctx, toSend)
+		if err != nil {
+			return err
+		}
+		if sent {
+			stats.Sent++
+			slog.Info("sent article", "id", toSend.ID, "title", toSend.Title, "score", scores[toSend.ID])
+		}
+	}
+	if stats.Sent > 0 {
+		r.recordDigestTime(ctx, startedAt)
+	}
+
+	if outro != "" && stats.Sent > 0 {
+		if err := r.wait(ctx, r.sendPause()); err != nil {
+			return err
+		}
+		if err := r.sendOptionalNotice(ctx, "outro", outro); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("digest run complete", "sent", stats.Sent)
+	return nil
+}
+
+// prepareArticle turns a selected article into the message to send, and
+// saves it. It returns nil if the article can't be saved, or if preparing
+// it panics.
+func (r *Runner) prepareArticle(ctx context.Context, article *ProcessedArticle, rankedArticle ranker.RankedArticle) *ArticleToSend {
+	defer skipPanicked(func() int64 { return rankedArticle.ID })
+	toSend := &ArticleToSend{
+		ID:        article.ID,
+		Title:     article.Title,
+		URL:       article.URL,
+		Summary:   article.Summary,
+		HNScore:   article.HNScore,
+		Comments:  article.Comments,
+		WordCount: article.WordCount,
+		PostedAt:  article.PostedAt,
+		Source:    article.Source,
+		By:        article.By,
+		ImageURL:  article.ImageURL,
+	}
+	// Look up the first score before saving records this one
+	if score, seenAt, err := r.storage.GetFirstScore(ctx, article.ID); err != nil {
+		slog.Warn("failed to look up first score", "id", article.ID, "error", err)
+	} else {
+		toSend.FirstScore, toSend.FirstSeenAt = score, seenAt
+	}
+	if r.explain {
+		toSend.Explanation = explainMatch(rankedArticle.MatchedTags)
+	}
+	if r.discussion != nil {
+		toSend.Discussion = r.summarizeDiscussion(ctx, article)
+	}
+
+	// Save before sending so that a live message always has a stored
+	// article behind it, even if marking it sent fails afterwards
+	stored := &StoredArticle{
+		ID:           article.ID,
+		Title:        article.Title,
+		URL:          article.CanonicalURL,
+		Summary:      article.Summary,
+		SummaryModel: article.SummaryModel,
+		ContentHash:  article.ContentHash,
+		Tags:         article.Tags,
+		HNScore:      article.HNScore,
+		FetchedAt:    r.now(),
+		ImageURL:     article.ImageURL,
+	}
+	if err := r.storage.SaveArticle(ctx, stored); err != nil {
+		slog.Warn("failed to save article, skipping", "id", article.ID, "error", err)
+		return nil
+	}
+	return toSend
+}
+
+// deliver sends an article and records it as sent, reporting whether it
+// was delivered, and forgets any failures it had in earlier runs. Only an
+// unavailable chat is an error; a failed article is skipped otherwise.
+func (r *Runner) deliver(ctx context.Context, toSend *ArticleToSend) (bool, error) {
+	defer skipPanicked(func() int64 { return toSend.ID })
+	sendCtx, cancel := withTimeout(ctx, r.timeouts.Send)
+	msgID, err := r.sender.SendArticle(sendCtx, r.chatID, toSend)
+	cancel()
+	if errors.Is(err, ErrChatUnavailable) {
+		return false, fmt.Errorf("send article: %w", err)
+	}
+	if err != nil {
+		slog.Warn("failed to send article", "id", toSend.ID, "error", err)
+		return false, nil
+	}
+
+	if err := r.storage.MarkArticleSent(ctx, toSend.ID, r.chatID, msgID, toSend.Source); err != nil {
+		slog.Error("article sent but not marked as sent; it may be resent and reactions to it will be ignored",
+			"id", toSend.ID, "message_id", msgID, "error", err)
+	}
+	// However it came to be sent, a retry or a top story again, it no
+	// longer needs retrying
+	if err := r.storage.ClearFailedArticle(ctx, toSend.ID); err != nil {
+		slog.Warn("failed to clear failed article", "id", toSend.ID, "error", err)
+	}
+	r.observer.OnArticleSent(toSend, msgID)
+	return true, nil
+}
+
+// sendOptionalNotice sends a notice the digest can do without, such as a
+// topic section's header, described by what. Only an unavailable chat is
+// an error; the digest goes on without the notice otherwise.
+func (r *Runner) sendOptionalNotice(ctx context.Context, what, text string) error {
+	sendCtx, cancel := withTimeout(ctx, r.timeouts.Send)
+	defer cancel()
+	err := r.sender.SendNotice(sendCtx, r.chatID, text)
+	if errors.Is(err, ErrChatUnavailable) {
+		return fmt.Errorf("send %s: %w", what, err)
+	}
 	if err != nil {
-		return fmt.Errorf("fetch top stories: %w", err)
+		slog.Warn("failed to send optional notice", "notice", what, "error", err)
+	}
+	return nil
+}
+
+// effectiveDecayRate returns the decay rate to apply in this run. In
+// DecayPerDay mode the configured rate is compounded over the days elapsed
+// since the last decay, so several runs in one day decay no more than one
+// daily run would, and skipped days still decay. The first per-day run only
+// records a starting time.
+func (r *Runner) effectiveDecayRate(ctx context.Context) float64 {
+	if r.decayMode != DecayPerDay {
+		return r.decayRate
+	}
+
+	now := r.now()
+	defer func() {
+		if err := r.storage.SetSetting(ctx, lastDecaySetting, now.UTC().Format(time.RFC3339)); err != nil {
+			slog.Warn("failed to record decay time", "error", err)
+		}
+	}()
+
+	value, err := r.storage.GetSetting(ctx, lastDecaySetting)
+	if err != nil {
+		return 0
+	}
+	last, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		slog.Warn("invalid last decay time, restarting decay schedule", "value", value, "error", err)
+		return 0
+	}
+
+	days := now.Sub(last).Hours() / 24
+	if days <= 0 {
+		return 0
+	}
+	return 1 - math.Pow(1-r.decayRate, days)
+}
+
+// tooFew reports whether a scheduled run should skip sending n selected
+// articles as too few. No articles at all are left to reportEmpty.
+func (r *Runner) tooFew(n int) bool {
+	return r.trigger == TriggerScheduled && n > 0 && n < min(r.minArticles, r.articleCount)
+}
+
+// reportEmpty handles a run that has no articles to send. On-demand runs,
+// and scheduled runs with the empty notice enabled, tell the user so that
+// an empty digest isn't mistaken for one that never ran.
+func (r *Runner) reportEmpty(ctx context.Context) error {
+	slog.Info("no articles to send", "trigger", r.trigger)
+	if r.trigger == TriggerScheduled && !r.emptyNotice {
+		return nil
+	}
+
+	sendCtx, cancel := withTimeout(ctx, r.timeouts.Send)
+	defer cancel()
+	err := r.sender.SendNotice(sendCtx, r.chatID, emptyDigestNotice)
+	if errors.Is(err, ErrChatUnavailable) {
+		return fmt.Errorf("send notice: %w", err)
+	}
+	if err != nil {
+		slog.Warn("failed to send empty digest notice", "error", err)
+	}
+	return nil
+}
+
+// candidateIDs fetches story IDs (multiplier x article count) and drops
+// those recently sent to the chat.
+func (r *Runner) candidateIDs(ctx context.Context) ([]int64, error) {
+	fetchCount := r.articleCount * r.multiplier
+	storyIDs, err := r.fetchStoryIDs(ctx, fetchCount)
+	if err != nil {
+		return nil, err
 	}
 	slog.Info("fetched story IDs", "count", len(storyIDs))
 
-	// Step 3: Filter recently sent
-	recentIDs, err := r.storage.GetRecentlySentArticleIDs(ctx, defaultRecencyWindow)
+	if r.sentFilter != nil {
+		filteredIDs, err := r.sentFilter.UnsentArticleIDs(ctx, r.chatID, storyIDs, defaultRecencyWindow)
+		if err == nil {
+			slog.Info("filtered stories", "before", len(storyIDs), "after", len(filteredIDs))
+			return filteredIDs, nil
+		}
+		slog.Warn("failed to filter sent stories, loading recently sent IDs", "error", err)
+	}
+
+	recentIDs, err := r.storage.GetRecentlySentArticleIDs(ctx, r.chatID, defaultRecencyWindow)
 	if err != nil {
 		slog.Warn("failed to get recently sent IDs", "error", err)
 	}
@@ -198,135 +1043,716 @@ func (r *Runner) Run(ctx context.Context) error {
 		}
 	}
 	slog.Info("filtered stories", "before", len(storyIDs), "after", len(filteredIDs))
+	return filteredIDs, nil
+}
 
-	// Step 4: Process each story
-	var processed []*ProcessedArticle
-	for _, id := range filteredIDs {
-		article, err := r.processStory(ctx, id)
+// rank scores articles using the learned tag and domain weights.
+func (r *Runner) rank(ctx context.Context, articles []ranker.RankableArticle) []ranker.RankedArticle {
+	tagWeights, err := r.storage.GetAllTagWeights(ctx)
+	if err != nil {
+		slog.Warn("failed to get tag weights", "error", err)
+		tagWeights = make(map[string]float64)
+	}
+
+	domainWeights, err := r.storage.GetAllDomainWeights(ctx)
+	if err != nil {
+		slog.Warn("failed to get domain weights", "error", err)
+		domainWeights = make(map[string]float64)
+	}
+
+	var tagCounts map[string]int
+	if r.tagCounts {
+		tagCounts, err = r.storage.GetAllTagCounts(ctx)
 		if err != nil {
-			slog.Warn("failed to process story", "id", id, "error", err)
-			continue
+			slog.Warn("failed to get tag counts, ranking without them", "error", err)
 		}
-		processed = append(processed, article)
 	}
-	slog.Info("processed articles", "count", len(processed))
 
-	if len(processed) == 0 {
-		slog.Info("no articles to send")
-		return nil
+	articleRanker := ranker.NewRanker(0.7, 0.3,
+		ranker.WithDomainWeights(domainWeights, r.domainFactor),
+		ranker.WithTagAliases(r.aliases),
+		ranker.WithTagCounts(tagCounts),
+		ranker.WithMaxTags(r.maxTags))
+	ranked := articleRanker.Rank(articles, tagWeights)
+	logRanking(ctx, ranked)
+	return ranked
+}
+
+// logRanking logs every ranked candidate with its score components, in
+// rank order, at debug level.
+func logRanking(ctx context.Context, ranked []ranker.RankedArticle) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
 	}
 
-	// Step 5: Rank articles
-	tagWeights, err := r.storage.GetAllTagWeights(ctx)
+	for i, a := range ranked {
+		matched := make([]string, len(a.MatchedTags))
+		for j, m := range a.MatchedTags {
+			matched[j] = fmt.Sprintf("%s=%.2f", m.Tag, m.Weight)
+		}
+		slog.DebugContext(ctx, "ranked candidate", slog.Group("article",
+			"rank", i+1,
+			"id", a.ID,
+			"hn_score", a.HNScore,
+			"hn_component", a.HNScoreComponent,
+			"matched_tags", matched,
+			"tag_score", a.TagScore,
+			"domain", a.Domain,
+			"domain_score", a.DomainScore,
+			"final_score", a.FinalScore,
+		))
+	}
+}
+
+// filterByTagScore drops articles below the minimum matched-tag score,
+// once enough likes have been recorded for tag weights to be meaningful.
+func (r *Runner) filterByTagScore(ctx context.Context, ranked []ranker.RankedArticle) []ranker.RankedArticle {
+	if r.minTagScore <= 0 {
+		return ranked
+	}
+
+	likes, err := r.storage.GetLikeCount(ctx)
 	if err != nil {
-		slog.Warn("failed to get tag weights", "error", err)
-		tagWeights = make(map[string]float64)
+		slog.Warn("failed to get like count, skipping tag score filter", "error", err)
+		return ranked
+	}
+	if likes < r.minTagLikes {
+		slog.Info("too few likes for tag score filter", "likes", likes, "required", r.minTagLikes)
+		return ranked
 	}
 
-	rankableArticles := make([]ranker.RankableArticle, len(processed))
-	for i, a := range processed {
-		rankableArticles[i] = ranker.RankableArticle{
-			ID:      a.ID,
-			Tags:    a.Tags,
-			HNScore: a.HNScore,
+	var kept []ranker.RankedArticle
+	for _, a := range ranked {
+		if a.MatchedScore() >= r.minTagScore {
+			kept = append(kept, a)
 		}
 	}
+	slog.Info("filtered by tag score", "min_score", r.minTagScore, "before", len(ranked), "after", len(kept))
+	return kept
+}
 
-	articleRanker := ranker.NewRanker(0.7, 0.3)
-	ranked := articleRanker.Rank(rankableArticles, tagWeights)
+// includePinned makes sure selected has an article with a pinned tag when
+// one of the candidates has one. If none does, the best-ranked pinned
+// candidate takes the place of the lowest-ranked selected article, or is
+// added if there is room. The result stays in rank order.
+func (r *Runner) includePinned(candidates, selected []ranker.RankedArticle) []ranker.RankedArticle {
+	if len(r.pinned) == 0 || r.articleCount < 1 || slices.ContainsFunc(selected, r.isPinned) {
+		return selected
+	}
+	i := slices.IndexFunc(candidates, r.isPinned)
+	if i < 0 {
+		return selected
+	}
 
-	// Map ranked back to processed articles
-	processedByID := make(map[int64]*ProcessedArticle)
-	for _, a := range processed {
-		processedByID[a.ID] = a
+	pin := candidates[i]
+	result := slices.Clone(selected[:min(len(selected), r.articleCount-1)])
+	result = append(result, pin)
+	slices.SortStableFunc(result, func(a, b ranker.RankedArticle) int {
+		return cmp.Compare(b.FinalScore, a.FinalScore)
+	})
+	slog.Info("including article with pinned tag", "id", pin.ID, "score", pin.FinalScore)
+	return result
+}
+
+// isPinned reports whether a has a pinned tag. Ranked tags are canonical,
+// so a pinned alias matches by its canonical tag.
+func (r *Runner) isPinned(a ranker.RankedArticle) bool {
+	for pin := range r.pinned {
+		if tag, ok := r.aliases[pin]; ok {
+			pin = tag
+		}
+		for _, tag := range a.Tags {
+			if strings.ToLower(tag) == pin {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	// Step 6: Send top N articles
-	sendCount := r.articleCount
-	if sendCount > len(ranked) {
-		sendCount = len(ranked)
+// fetchedStory is an HN item with the content to summarize.
+type fetchedStory struct {
+	item      *HNItem
+	content   string
+	scraped   bool   // Whether content came from the article's page
+	hash      string // Hex SHA-256 of content
+	canonical string // Canonical URL of the article's page, if known
+	image     string // Main image of the article's page, if known
+}
+
+// retryIDs returns the articles that failed in recent runs and may be
+// tried again, leaving out those already among the candidate ids and those
+// sent since they failed.
+func (r *Runner) retryIDs(ctx context.Context, ids []int64) []int64 {
+	failedIDs, err := r.storage.GetFailedArticleIDs(ctx, maxFailedAttempts, defaultRecencyWindow)
+	if err != nil {
+		slog.Warn("failed to get failed articles to retry", "error", err)
+		return nil
 	}
+	retry := slices.DeleteFunc(failedIDs, func(id int64) bool {
+		if slices.Contains(ids, id) {
+			return true
+		}
+		sent, err := r.recentlySent(ctx, id)
+		if err != nil {
+			slog.Warn("failed to check whether article was sent", "id", id, "error", err)
+		}
+		return sent
+	})
+	if len(retry) > 0 {
+		slog.Info("retrying failed articles", "count", len(retry))
+	}
+	return retry
+}
 
-	for i := 0; i < sendCount; i++ {
-		rankedArticle := ranked[i]
-		article := processedByID[rankedArticle.ID]
+// recentlySent reports whether an article was sent to the chat within the
+// recency window.
+func (r *Runner) recentlySent(ctx context.Context, id int64) (bool, error) {
+	sent, sentAt, err := r.storage.WasSent(ctx, r.chatID, id)
+	if err != nil {
+		return false, err
+	}
+	return sent && r.now().Sub(sentAt) < defaultRecencyWindow, nil
+}
 
-		toSend := &ArticleToSend{
-			ID:       article.ID,
-			Title:    article.Title,
-			URL:      article.URL,
-			Summary:  article.Summary,
-			HNScore:  article.HNScore,
-			Comments: article.Comments,
+// recordFailure records that an article failed at the given stage, unless
+// the run itself was cancelled.
+func (r *Runner) recordFailure(ctx context.Context, id int64, stage string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err := r.storage.RecordFailedArticle(ctx, id, stage, err.Error()); err != nil {
+		slog.Warn("failed to record failed article", "id", id, "error", err)
+	}
+}
+
+// clearRetried forgets the failures of retried articles that were
+// processed this time, even if they aren't sent. Articles that are sent
+// have their failures cleared by deliver.
+func (r *Runner) clearRetried(ctx context.Context, retryIDs []int64, processed []*ProcessedArticle) {
+	for _, a := range processed {
+		if !slices.Contains(retryIDs, a.ID) {
+			continue
+		}
+		if err := r.storage.ClearFailedArticle(ctx, a.ID); err != nil {
+			slog.Warn("failed to clear failed article", "id", a.ID, "error", err)
 		}
+	}
+}
 
-		msgID, err := r.sender.SendArticle(ctx, r.chatID, toSend)
+// fetchItems fetches HN items in parallel, keeping the order of ids and
+// skipping items that fail. The errors of the items that failed are
+// returned by ID.
+func (r *Runner) fetchItems(ctx context.Context, ids []int64) ([]*HNItem, map[int64]error) {
+	items := make([]*HNItem, len(ids))
+	errs := make([]error, len(ids))
+	forEach(ctx, r.hnWorkers, len(ids), func(i int) {
+		defer skipPanicked(func() int64 { return ids[i] })
+		itemCtx, cancel := withTimeout(ctx, r.timeouts.Item)
+		defer cancel()
+		item, err := r.hnClient.GetItem(itemCtx, ids[i])
 		if err != nil {
-			slog.Warn("failed to send article", "id", article.ID, "error", err)
-			continue
+			errs[i] = fmt.Errorf("fetch item: %w", err)
+			slog.Warn("failed to process story", "id", ids[i], "error", errs[i])
+			return
+		}
+		items[i] = item
+	})
+
+	failed := make(map[int64]error)
+	for i, err := range errs {
+		if err != nil {
+			failed[ids[i]] = err
+		}
+	}
+	return compact(items), failed
+}
+
+// rankItems ranks items without scraping or summarizing them. Only
+// articles summarized in earlier runs have tags, so new stories are ranked
+// on HN score and domain alone.
+func (r *Runner) rankItems(ctx context.Context, items []*HNItem) []ranker.RankedArticle {
+	rankable := make([]ranker.RankableArticle, len(items))
+	for i, item := range items {
+		tags, err := r.storage.GetArticleTags(ctx, item.ID)
+		if err != nil {
+			slog.Warn("failed to get stored tags", "id", item.ID, "error", err)
+		}
+		rankable[i] = ranker.RankableArticle{
+			ID:      item.ID,
+			Tags:    tags,
+			HNScore: item.Score,
+			Domain:  ranker.Domain(item.URL),
+		}
+	}
+	return r.rank(ctx, rankable)
+}
+
+// rankBeforeSummarize keeps the items that rank best before summarizing,
+// in their original order, along with the best one with a pinned tag.
+func (r *Runner) rankBeforeSummarize(ctx context.Context, items []*HNItem) []*HNItem {
+	keep := int(math.Ceil(float64(r.articleCount) * preRankBuffer))
+	if len(items) <= keep {
+		return items
+	}
+
+	ranked := r.rankItems(ctx, items)
+	keepIDs := make(map[int64]bool, keep+1)
+	for _, a := range ranked[:keep] {
+		keepIDs[a.ID] = true
+	}
+	if i := slices.IndexFunc(ranked, r.isPinned); i >= keep {
+		keepIDs[ranked[i].ID] = true
+	}
+
+	kept := slices.DeleteFunc(slices.Clone(items), func(item *HNItem) bool {
+		return !keepIDs[item.ID]
+	})
+	slog.Info("ranked before summarizing", "candidates", len(items), "kept", len(kept))
+	return kept
+}
+
+// processItems scrapes and summarizes items in parallel. When batching is
+// enabled, items are scraped in parallel and then summarized in batches.
+// Items seen in a recent run, and content that was summarized before, are
+// not summarized again.
+func (r *Runner) processItems(ctx context.Context, items []*HNItem) []*ProcessedArticle {
+	batching := r.batcher != nil && r.batchSize > 1
+
+	stories := make([]*fetchedStory, len(items))
+	articles := make([]*ProcessedArticle, len(items))
+	forEach(ctx, r.scrapeWorkers, len(items), func(i int) {
+		defer skipPanicked(func() int64 { return items[i].ID })
+		if article := r.reuseSeen(ctx, items[i]); article != nil {
+			articles[i] = article
+			return
+		}
+		content, page, scraped := r.scrapeContent(ctx, items[i])
+		if ctx.Err() != nil {
+			// Out of time: the title fallback isn't worth summarizing
+			return
+		}
+		if scraped && !r.languageAllowed(items[i], content) {
+			return
+		}
+		sum := sha256.Sum256([]byte(content))
+		story := &fetchedStory{item: items[i], content: content, scraped: scraped, hash: hex.EncodeToString(sum[:]), canonical: page.Canonical, image: page.ImageURL}
+		if article := r.reuseSummary(ctx, story); article != nil {
+			articles[i] = article
+			return
 		}
+		if batching {
+			stories[i] = story
+			return
+		}
+		articles[i] = r.summarizeStory(ctx, story)
+	})
+
+	processed := compact(articles)
+	if !batching {
+		return processed
+	}
 
-		// Save and mark as sent
+	stories = compact(stories)
+	for start := 0; start < len(stories); start += r.batchSize {
+		end := min(start+r.batchSize, len(stories))
+		processed = append(processed, r.summarizeStories(ctx, stories[start:end])...)
+	}
+	return processed
+}
+
+// reuseSeen returns the item as a processed article using the stored
+// summary from a recent run that didn't send it, or nil if there is none.
+// Such items need neither scraping nor summarizing.
+func (r *Runner) reuseSeen(ctx context.Context, item *HNItem) *ProcessedArticle {
+	stored, err := r.storage.GetSeenArticle(ctx, item.ID, defaultSeenWindow)
+	if err != nil {
+		slog.Warn("failed to look up seen article", "id", item.ID, "error", err)
+		return nil
+	}
+	if stored == nil {
+		return nil
+	}
+	slog.Debug("article seen recently, reusing summary", "id", item.ID)
+	story := &fetchedStory{item: item, hash: stored.ContentHash, canonical: stored.URL, image: stored.ImageURL}
+	return r.newProcessedArticle(story, &SummaryResult{Summary: stored.Summary, Tags: stored.Tags, Model: stored.SummaryModel})
+}
+
+// markSeen stores the processed articles that weren't selected and marks
+// them seen, so that their summaries are reused if they come up again.
+func (r *Runner) markSeen(ctx context.Context, processed []*ProcessedArticle, selected []ranker.RankedArticle) {
+	selectedIDs := make(map[int64]bool, len(selected))
+	for _, a := range selected {
+		selectedIDs[a.ID] = true
+	}
+
+	for _, article := range processed {
+		if selectedIDs[article.ID] {
+			continue
+		}
 		stored := &StoredArticle{
-			ID:        article.ID,
-			Title:     article.Title,
-			URL:       article.URL,
-			Summary:   article.Summary,
-			Tags:      article.Tags,
-			HNScore:   article.HNScore,
-			FetchedAt: time.Now(),
+			ID:           article.ID,
+			Title:        article.Title,
+			URL:          article.CanonicalURL,
+			Summary:      article.Summary,
+			SummaryModel: article.SummaryModel,
+			ContentHash:  article.ContentHash,
+			Tags:         article.Tags,
+			HNScore:      article.HNScore,
+			FetchedAt:    r.now(),
+			ImageURL:     article.ImageURL,
 		}
 		if err := r.storage.SaveArticle(ctx, stored); err != nil {
-			slog.Warn("failed to save article", "id", article.ID, "error", err)
+			slog.Warn("failed to save seen article", "id", article.ID, "error", err)
+			continue
 		}
-		if err := r.storage.MarkArticleSent(ctx, article.ID, msgID); err != nil {
-			slog.Warn("failed to mark article sent", "id", article.ID, "error", err)
+		if err := r.storage.MarkArticleSeen(ctx, article.ID); err != nil {
+			slog.Warn("failed to mark article seen", "id", article.ID, "error", err)
 		}
-
-		slog.Info("sent article", "id", article.ID, "title", article.Title, "score", rankedArticle.FinalScore)
 	}
+}
 
-	slog.Info("digest run complete", "sent", sendCount)
-	return nil
+// reuseSummary returns the story as a processed article using its stored
+// summary if its content is unchanged, or nil if there is none. Summaries
+// are never shared between articles: unrelated pages, such as cookie walls
+// or title fallbacks, can have identical content.
+func (r *Runner) reuseSummary(ctx context.Context, story *fetchedStory) *ProcessedArticle {
+	result, err := r.storage.GetSummaryByContentHash(ctx, story.item.ID, story.hash)
+	if err != nil {
+		slog.Warn("failed to look up summary by content hash", "id", story.item.ID, "error", err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	slog.Debug("content unchanged, reusing summary", "id", story.item.ID)
+	return r.newProcessedArticle(story, result)
 }
 
-func (r *Runner) processStory(ctx context.Context, id int64) (*ProcessedArticle, error) {
-	// Fetch item details
-	item, err := r.hnClient.GetItem(ctx, id)
+// scrapeContent returns the text to summarize for an item, using the title
+// as a fallback. Text posts such as Ask HN have no URL, so their HN text is
+// used instead. scraped reports whether the content came from the article's
+// page, and page holds the canonical URL and image a PageScraper found.
+func (r *Runner) scrapeContent(ctx context.Context, item *HNItem) (content string, page ScrapedPage, scraped bool) {
+	content = item.Title
+	if item.URL == "" {
+		if text := htmlToText(item.Text); text != "" {
+			content = text
+		}
+		return content, page, false
+	}
+
+	scrapeCtx, cancel := withTimeout(ctx, r.timeouts.Scrape)
+	defer cancel()
+	var err error
+	if r.pages != nil {
+		var scrapedPage *ScrapedPage
+		if scrapedPage, err = r.pages.ScrapePage(scrapeCtx, item.URL); err == nil {
+			page = *scrapedPage
+		} else {
+			// Variants of the URL still need to be deduplicated
+			page.Canonical = r.pages.CanonicalURL(item.URL)
+		}
+	} else {
+		page.Content, err = r.scraper.Scrape(scrapeCtx, item.URL)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("fetch item: %w", err)
+		slog.Warn("scrape failed, using title as content", "url", item.URL, "error", err)
+	} else if page.Content != "" {
+		content, scraped = page.Content, true
 	}
+	return content, page, scraped
+}
 
-	// Scrape content (use title as fallback)
-	content := item.Title
-	if item.URL != "" {
-		scraped, err := r.scraper.Scrape(ctx, item.URL)
-		if err != nil {
-			slog.Warn("scrape failed, using title as content", "url", item.URL, "error", err)
-		} else if scraped != "" {
-			content = scraped
+// filterByAge drops items submitted longer than maxAge ago.
+func (r *Runner) filterByAge(items []*HNItem) []*HNItem {
+	if r.maxAge <= 0 {
+		return items
+	}
+	cutoff := r.now().Add(-r.maxAge)
+	return slices.DeleteFunc(items, func(item *HNItem) bool {
+		if item.Time.IsZero() || !item.Time.Before(cutoff) {
+			return false
+		}
+		slog.Info("skipping old story", "id", item.ID, "posted_at", item.Time)
+		return true
+	})
+}
+
+// languageAllowed reports whether scraped content passes the language
+// filter.
+func (r *Runner) languageAllowed(item *HNItem, content string) bool {
+	if r.languages == nil {
+		return true
+	}
+	lang := language.Detect(content)
+	if lang == "" || r.languages[lang] {
+		return true
+	}
+	slog.Info("skipping article in unwanted language", "id", item.ID, "language", lang)
+	return false
+}
+
+// summarizeStories summarizes stories in a single batch request, falling
+// back to one request per story if the batch fails, or for each story
+// whose batch result failed.
+func (r *Runner) summarizeStories(ctx context.Context, stories []*fetchedStory) []*ProcessedArticle {
+	if len(stories) > 1 {
+		inputs := make([]SummaryInput, len(stories))
+		for i, s := range stories {
+			inputs[i] = SummaryInput{Title: s.item.Title, Content: s.content}
+		}
+
+		results, err := r.batchSummaries(ctx, inputs)
+		if err == nil && len(results) != len(stories) {
+			err = fmt.Errorf("got %d results for %d articles", len(results), len(stories))
+		}
+		if err == nil {
+			var processed []*ProcessedArticle
+			for i, s := range stories {
+				if results[i].Err == nil {
+					processed = append(processed, r.newProcessedArticle(s, &results[i]))
+					continue
+				}
+				slog.Warn("batch summary failed, summarizing individually", "id", s.item.ID, "error", results[i].Err)
+				if article := r.summarizeStory(ctx, s); article != nil {
+					processed = append(processed, article)
+				}
+			}
+			return processed
 		}
+		slog.Warn("batch summarization failed, summarizing individually", "count", len(stories), "error", err)
 	}
 
-	// Summarize
-	result, err := r.summarizer.Summarize(ctx, item.Title, content)
+	var processed []*ProcessedArticle
+	for _, s := range stories {
+		if article := r.summarizeStory(ctx, s); article != nil {
+			processed = append(processed, article)
+		}
+	}
+	return processed
+}
+
+// batchSummaries summarizes inputs in one batch request. A panic is
+// returned as an error, so that the stories are summarized one at a time
+// instead.
+func (r *Runner) batchSummaries(ctx context.Context, inputs []SummaryInput) (results []SummaryResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("panic while summarizing batch", "count", len(inputs), "panic", p, "stack", string(debug.Stack()))
+			err = fmt.Errorf("batch summary panicked: %v", p)
+		}
+	}()
+	batchCtx, cancel := withTimeout(ctx, r.timeouts.Summarize)
+	defer cancel()
+	return r.batcher.SummarizeBatch(batchCtx, inputs)
+}
+
+// summarizeStory summarizes a single story, returning nil on failure.
+// Failures are recorded so that the story is retried by a later run.
+func (r *Runner) summarizeStory(ctx context.Context, story *fetchedStory) *ProcessedArticle {
+	defer skipPanicked(func() int64 { return story.item.ID })
+	summarizeCtx, cancel := withTimeout(ctx, r.timeouts.Summarize)
+	defer cancel()
+	result, err := r.summarizer.Summarize(summarizeCtx, story.item.Title, story.content)
 	if err != nil {
-		return nil, fmt.Errorf("summarize: %w", err)
+		err = fmt.Errorf("summarize: %w", err)
+		slog.Warn("failed to process story", "id", story.item.ID, "error", err)
+		r.recordFailure(ctx, story.item.ID, stageSummarize, err)
+		return nil
 	}
+	return r.newProcessedArticle(story, result)
+}
 
+func (r *Runner) newProcessedArticle(story *fetchedStory, result *SummaryResult) *ProcessedArticle {
+	item := story.item
 	url := item.URL
 	if url == "" {
 		// For Ask HN, Show HN, etc. - use the HN discussion page
 		url = fmt.Sprintf("https://news.ycombinator.com/item?id=%d", item.ID)
 	}
 
-	return &ProcessedArticle{
-		ID:       item.ID,
-		Title:    item.Title,
-		URL:      url,
-		Summary:  result.Summary,
-		Tags:     result.Tags,
-		HNScore:  item.Score,
-		Comments: item.Descendants,
-	}, nil
+	canonical := story.canonical
+	if canonical == "" {
+		canonical = url
+	}
+
+	article := &ProcessedArticle{
+		ID:           item.ID,
+		Title:        item.Title,
+		URL:          url,
+		CanonicalURL: canonical,
+		ImageURL:     story.image,
+		Summary:      result.Summary,
+		SummaryModel: result.Model,
+		ContentHash:  story.hash,
+		Tags:         result.Tags,
+		HNScore:      item.Score,
+		Comments:     item.Descendants,
+		By:           item.By,
+		CommentIDs:   item.Kids,
+		Source:       r.source(),
+		PostedAt:     item.Time,
+	}
+	if story.scraped {
+		article.WordCount = len(strings.Fields(story.content))
+	}
+	return article
+}
+
+// summarizeDiscussion returns a take on the article's discussion, or ""
+// if it has no comments or summarizing fails.
+func (r *Runner) summarizeDiscussion(ctx context.Context, article *ProcessedArticle) string {
+	defer skipPanicked(func() int64 { return article.ID })
+	comments := r.topComments(ctx, article.CommentIDs)
+	if len(comments) == 0 {
+		return ""
+	}
+
+	sumCtx, cancel := withTimeout(ctx, r.timeouts.Summarize)
+	defer cancel()
+	take, err := r.discussion.SummarizeDiscussion(sumCtx, comments)
+	if err != nil {
+		slog.Warn("failed to summarize discussion", "id", article.ID, "error", err)
+		return ""
+	}
+	return take
+}
+
+// topComments fetches the text of the first maxDiscussionComments comments
+// in parallel, keeping their order. Deleted comments and comments that
+// fail to fetch are skipped.
+func (r *Runner) topComments(ctx context.Context, ids []int64) []string {
+	ids = ids[:min(len(ids), maxDiscussionComments)]
+	texts := make([]*string, len(ids))
+	forEach(ctx, r.hnWorkers, len(ids), func(i int) {
+		defer skipPanicked(func() int64 { return ids[i] })
+		itemCtx, cancel := withTimeout(ctx, r.timeouts.Item)
+		defer cancel()
+		item, err := r.hnClient.GetItem(itemCtx, ids[i])
+		if err != nil {
+			slog.Debug("failed to fetch comment", "id", ids[i], "error", err)
+			return
+		}
+		if text := htmlToText(item.Text); text != "" {
+			texts[i] = &text
+		}
+	})
+
+	comments := make([]string, 0, len(ids))
+	for _, text := range compact(texts) {
+		comments = append(comments, *text)
+	}
+	return comments
+}
+
+// explainMatch describes the tags that contributed to an article's rank,
+// e.g. "matched: go (2.10), testing (1.30)".
+func explainMatch(matched []ranker.TagContribution) string {
+	if len(matched) == 0 {
+		return "matched: no learned tags"
+	}
+	parts := make([]string, len(matched))
+	for i, m := range matched {
+		parts[i] = fmt.Sprintf("%s (%.2f)", m.Tag, m.Weight)
+	}
+	return "matched: " + strings.Join(parts, ", ")
+}
+
+var (
+	htmlBreakRegex = regexp.MustCompile(`(?i)<\s*(p|br)\s*/?>`)
+	htmlTagRegex   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// htmlToText converts the limited HTML used in HN item text to plain text.
+func htmlToText(s string) string {
+	s = htmlBreakRegex.ReplaceAllString(s, "\n")
+	s = htmlTagRegex.ReplaceAllString(s, "")
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// sendPause returns the delay before the next article, including jitter.
+func (r *Runner) sendPause() time.Duration {
+	d := r.sendDelay
+	if r.sendJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(r.sendJitter) + 1))
+	}
+	return d
+}
+
+// withTimeout derives a context bounded by d, or returns ctx unchanged if d
+// is not positive.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// skipPanicked recovers from a panic while processing the item whose ID id
+// returns, such as one with malformed fields, and logs it, so that the
+// rest of the digest goes on without the item. The panicking function
+// returns its zero values. It must be deferred directly. The ID is only
+// looked up after a panic, so a nil item can't panic again outside the
+// recover.
+func skipPanicked(id func() int64) {
+	if p := recover(); p != nil {
+		slog.Error("panic while processing item, skipping it", "id", panickedID(id), "panic", p, "stack", string(debug.Stack()))
+	}
+}
+
+// panickedID returns id(), or 0 if looking up the ID panics too.
+func panickedID(id func() int64) int64 {
+	defer func() { _ = recover() }()
+	return id()
+}
+
+// forEach calls fn for each index in [0, n), running at most limit calls at
+// once. Indexes not yet started when ctx is cancelled are skipped.
+func forEach(ctx context.Context, limit, n int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case <-ctx.Done():
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fn(i)
+		})
+	}
+	wg.Wait()
+}
+
+// dedupeByURL drops articles whose canonical URL matches an earlier one's,
+// such as a story submitted once as an AMP link and once directly.
+func dedupeByURL(articles []*ProcessedArticle) []*ProcessedArticle {
+	seen := make(map[string]int64, len(articles))
+	out := articles[:0]
+	for _, a := range articles {
+		if id, ok := seen[a.CanonicalURL]; ok {
+			slog.Info("skipping duplicate article", "id", a.ID, "duplicate_of", id, "url", a.CanonicalURL)
+			continue
+		}
+		seen[a.CanonicalURL] = a.ID
+		out = append(out, a)
+	}
+	return out
+}
+
+// compact returns s without nil entries.
+func compact[T any](s []*T) []*T {
+	out := s[:0]
+	for _, v := range s {
+		if v != nil {
+			out = append(out, v)
+		}
+	}
+	return out
 }
@@ -1,10 +1,20 @@
 package digest
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"hn-telegram-bot/ranker"
 )
 
 // Mocks
@@ -13,9 +23,11 @@ type mockHNClient struct {
 	topStories []int64
 	items      map[int64]*HNItem
 	fetchError error
+	limit      int // Last limit passed to GetTopStories
 }
 
 func (m *mockHNClient) GetTopStories(ctx context.Context, limit int) ([]int64, error) {
+	m.limit = limit
 	if m.fetchError != nil {
 		return nil, m.fetchError
 	}
@@ -35,9 +47,34 @@ func (m *mockHNClient) GetItem(ctx context.Context, id int64) (*HNItem, error) {
 type mockScraper struct {
 	contents   map[string]string
 	shouldFail bool
+	delay      time.Duration
+
+	mu            sync.Mutex
+	scraped       []string
+	inFlight      int
+	maxConcurrent int
 }
 
 func (m *mockScraper) Scrape(ctx context.Context, url string) (string, error) {
+	m.mu.Lock()
+	m.scraped = append(m.scraped, url)
+	m.inFlight++
+	m.maxConcurrent = max(m.maxConcurrent, m.inFlight)
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
 	if m.shouldFail {
 		return "", errors.New("scrape failed")
 	}
@@ -50,9 +87,19 @@ func (m *mockScraper) Scrape(ctx context.Context, url string) (string, error) {
 type mockSummarizer struct {
 	results    map[string]*SummaryResult
 	shouldFail bool
+
+	mu       sync.Mutex
+	contents map[string]string
 }
 
 func (m *mockSummarizer) Summarize(ctx context.Context, title, content string) (*SummaryResult, error) {
+	m.mu.Lock()
+	if m.contents == nil {
+		m.contents = make(map[string]string)
+	}
+	m.contents[title] = content
+	m.mu.Unlock()
+
 	if m.shouldFail {
 		return nil, errors.New("summarization failed")
 	}
@@ -62,38 +109,139 @@ func (m *mockSummarizer) Summarize(ctx context.Context, title, content string) (
 	return &SummaryResult{
 		Summary: "Default summary for " + title,
 		Tags:    []string{"default"},
+		Model:   "mock-model",
 	}, nil
 }
 
+// blockingStage blocks calls for one key until their context is done,
+// recording the context error.
+type blockingStage struct {
+	key string
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func (b *blockingStage) block(ctx context.Context, key string) error {
+	if key != b.key {
+		return nil
+	}
+	<-ctx.Done()
+	b.mu.Lock()
+	b.errs = append(b.errs, ctx.Err())
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+type blockingScraper struct {
+	mockScraper
+	blockingStage
+}
+
+func (s *blockingScraper) Scrape(ctx context.Context, url string) (string, error) {
+	if err := s.block(ctx, url); err != nil {
+		return "", err
+	}
+	return s.mockScraper.Scrape(ctx, url)
+}
+
+type blockingSummarizer struct {
+	mockSummarizer
+	blockingStage
+}
+
+func (s *blockingSummarizer) Summarize(ctx context.Context, title, content string) (*SummaryResult, error) {
+	if err := s.block(ctx, title); err != nil {
+		return nil, err
+	}
+	return s.mockSummarizer.Summarize(ctx, title, content)
+}
+
+type mockBatchSummarizer struct {
+	batches    [][]SummaryInput
+	shouldFail bool
+	failTitles map[string]bool // Titles whose results fail on their own
+}
+
+func (m *mockBatchSummarizer) SummarizeBatch(ctx context.Context, inputs []SummaryInput) ([]SummaryResult, error) {
+	m.batches = append(m.batches, inputs)
+	if m.shouldFail {
+		return nil, errors.New("parse batch JSON: unexpected end of input")
+	}
+	results := make([]SummaryResult, len(inputs))
+	for i, in := range inputs {
+		if m.failTitles[in.Title] {
+			results[i] = SummaryResult{Err: errors.New("bad summary: refusal")}
+			continue
+		}
+		results[i] = SummaryResult{Summary: "Batch summary for " + in.Title, Tags: []string{"batch"}}
+	}
+	return results, nil
+}
+
 type mockStorage struct {
 	articles        map[int64]*StoredArticle
-	recentlySent    []int64
+	firstSaved      map[int64]*StoredArticle
+	recentlySent    map[int64][]int64 // Keyed by chat ID
+	sentAt          map[int64]time.Time
 	tagWeights      map[string]float64
+	tagCounts       map[string]int
+	domainWeights   map[string]float64
 	likedArticles   map[int64]bool
 	settings        map[string]string
 	sentArticleIDs  []int64
+	sentSources     []string
+	tagHistory      map[string][]float64
+	seen            map[int64]bool
+	saveErr         error
+	markSentErr     error
+
+	mu     sync.Mutex
+	failed map[int64]int // Attempts by article ID
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{
 		articles:       make(map[int64]*StoredArticle),
-		recentlySent:   []int64{},
+		firstSaved:     make(map[int64]*StoredArticle),
+		recentlySent:   make(map[int64][]int64),
+		sentAt:         make(map[int64]time.Time),
 		tagWeights:     make(map[string]float64),
+		domainWeights:  make(map[string]float64),
 		likedArticles:  make(map[int64]bool),
 		settings:       make(map[string]string),
 		sentArticleIDs: []int64{},
+		tagHistory:     make(map[string][]float64),
+		seen:           make(map[int64]bool),
+		failed:         make(map[int64]int),
 	}
 }
 
-func (m *mockStorage) GetRecentlySentArticleIDs(ctx context.Context, within time.Duration) ([]int64, error) {
-	return m.recentlySent, nil
+func (m *mockStorage) GetRecentlySentArticleIDs(ctx context.Context, chatID int64, within time.Duration) ([]int64, error) {
+	return m.recentlySent[chatID], nil
+}
+
+// WasSent reports articles in sentAt as sent to every chat then, and the
+// chat's articles in recentlySent as sent now.
+func (m *mockStorage) WasSent(ctx context.Context, chatID, articleID int64) (bool, time.Time, error) {
+	if t, ok := m.sentAt[articleID]; ok {
+		return true, t, nil
+	}
+	if slices.Contains(m.recentlySent[chatID], articleID) {
+		return true, time.Now(), nil
+	}
+	return false, time.Time{}, nil
 }
 
 func (m *mockStorage) GetAllTagWeights(ctx context.Context) (map[string]float64, error) {
 	return m.tagWeights, nil
 }
 
-func (m *mockStorage) ApplyTagDecay(ctx context.Context, decayRate, minWeight float64) error {
+func (m *mockStorage) GetAllTagCounts(ctx context.Context) (map[string]int, error) {
+	return m.tagCounts, nil
+}
+
+func (m *mockStorage) ApplyDecay(ctx context.Context, decayRate, minWeight float64) error {
 	for tag := range m.tagWeights {
 		newWeight := m.tagWeights[tag] * (1 - decayRate)
 		if newWeight < minWeight {
@@ -101,21 +249,74 @@ func (m *mockStorage) ApplyTagDecay(ctx context.Context, decayRate, minWeight fl
 		}
 		m.tagWeights[tag] = newWeight
 	}
+	for domain, weight := range m.domainWeights {
+		m.domainWeights[domain] = math.Max(weight*(1-decayRate), minWeight)
+	}
+	for tag, weight := range m.tagWeights {
+		m.tagHistory[tag] = append(m.tagHistory[tag], weight)
+	}
+	return nil
+}
+
+func (m *mockStorage) GetAllDomainWeights(ctx context.Context) (map[string]float64, error) {
+	return m.domainWeights, nil
+}
+
+func (m *mockStorage) GetArticleTags(ctx context.Context, articleID int64) ([]string, error) {
+	if a, ok := m.articles[articleID]; ok {
+		return a.Tags, nil
+	}
+	return nil, nil
+}
+
+func (m *mockStorage) GetFirstScore(ctx context.Context, articleID int64) (int, time.Time, error) {
+	if a, ok := m.firstSaved[articleID]; ok {
+		return a.HNScore, a.FetchedAt, nil
+	}
+	return 0, time.Time{}, nil
+}
+
+func (m *mockStorage) GetSummaryByContentHash(ctx context.Context, articleID int64, hash string) (*SummaryResult, error) {
+	if a, ok := m.articles[articleID]; ok && a.ContentHash == hash {
+		return &SummaryResult{Summary: a.Summary, Tags: a.Tags, Model: a.SummaryModel}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockStorage) GetSeenArticle(ctx context.Context, articleID int64, within time.Duration) (*StoredArticle, error) {
+	if !m.seen[articleID] {
+		return nil, nil
+	}
+	return m.articles[articleID], nil
+}
+
+func (m *mockStorage) MarkArticleSeen(ctx context.Context, articleID int64) error {
+	m.seen[articleID] = true
 	return nil
 }
 
+func (m *mockStorage) GetLikeCount(ctx context.Context) (int, error) {
+	return len(m.likedArticles), nil
+}
+
 func (m *mockStorage) SaveArticle(ctx context.Context, article *StoredArticle) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
 	m.articles[article.ID] = article
+	if _, ok := m.firstSaved[article.ID]; !ok {
+		m.firstSaved[article.ID] = article
+	}
 	return nil
 }
 
-func (m *mockStorage) MarkArticleSent(ctx context.Context, articleID int64, telegramMsgID int64) error {
-	m.sentArticleIDs = append(m.sentArticleIDs, articleID)
-	if a, ok := m.articles[articleID]; ok {
-		now := time.Now()
-		a.SentAt = &now
-		a.TelegramMsgID = &telegramMsgID
+func (m *mockStorage) MarkArticleSent(ctx context.Context, articleID, chatID, telegramMsgID int64, source string) error {
+	if m.markSentErr != nil {
+		return m.markSentErr
 	}
+	m.sentArticleIDs = append(m.sentArticleIDs, articleID)
+	m.sentSources = append(m.sentSources, source)
+	m.recentlySent[chatID] = append(m.recentlySent[chatID], articleID)
 	return nil
 }
 
@@ -126,11 +327,58 @@ func (m *mockStorage) GetSetting(ctx context.Context, key string) (string, error
 	return "", errors.New("setting not found")
 }
 
+func (m *mockStorage) SetSetting(ctx context.Context, key, value string) error {
+	m.settings[key] = value
+	return nil
+}
+
+func (m *mockStorage) RecordFailedArticle(ctx context.Context, articleID int64, stage, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed[articleID]++
+	return nil
+}
+
+func (m *mockStorage) GetFailedArticleIDs(ctx context.Context, maxAttempts int, within time.Duration) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []int64
+	for id, attempts := range m.failed {
+		if attempts < maxAttempts {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (m *mockStorage) ClearFailedArticle(ctx context.Context, articleID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.failed, articleID)
+	return nil
+}
+
 type mockArticleSender struct {
 	sentArticles []*ArticleToSend
+	notices      []string
+	err          error
+	attempts     int
+}
+
+func (m *mockArticleSender) SendNotice(ctx context.Context, chatID int64, text string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.notices = append(m.notices, text)
+	return nil
 }
 
 func (m *mockArticleSender) SendArticle(ctx context.Context, chatID int64, article *ArticleToSend) (int64, error) {
+	m.attempts++
+	if m.err != nil {
+		return 0, m.err
+	}
 	m.sentArticles = append(m.sentArticles, article)
 	return int64(len(m.sentArticles)), nil
 }
@@ -199,6 +447,60 @@ func TestRunDigest(t *testing.T) {
 	if !found {
 		t.Error("Article 1 should have been sent (highest tag score)")
 	}
+
+	for i, source := range storage.sentSources {
+		if source != SourceTop {
+			t.Errorf("sent source %d = %q, want %q", i, source, SourceTop)
+		}
+	}
+}
+
+func TestRunDigestScoreTrend(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 220, By: "pg"},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 80, By: "dang"},
+		},
+	}
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Article 1": {Summary: "Summary 1", Tags: []string{"go"}},
+			"Article 2": {Summary: "Summary 2", Tags: []string{"go"}},
+		},
+	}
+
+	// Article 1 came up in yesterday's run with fewer points
+	storage := newMockStorage()
+	firstSeen := time.Now().Add(-24 * time.Hour)
+	storage.SaveArticle(context.Background(), &StoredArticle{ID: 1, Title: "Article 1", HNScore: 100, FetchedAt: firstSeen})
+
+	sender := &mockArticleSender{}
+	runner := NewRunner(hnClient, &mockScraper{}, summarizer, storage, sender,
+		WithChatID(12345), WithArticleCount(2))
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	sent := make(map[int64]*ArticleToSend)
+	for _, a := range sender.sentArticles {
+		sent[a.ID] = a
+	}
+	if a := sent[1]; a == nil || a.FirstScore != 100 || !a.FirstSeenAt.Equal(firstSeen) || a.HNScore-a.FirstScore != 120 {
+		t.Errorf("re-trending article = %+v, want first score 100 at %v", a, firstSeen)
+	}
+	if a := sent[1]; a != nil && a.By != "pg" {
+		t.Errorf("By = %q, want pg", a.By)
+	}
+	if a := sent[2]; a == nil || !a.FirstSeenAt.IsZero() {
+		t.Errorf("new article = %+v, want no first score", a)
+	}
+
+	// Sending keeps the first score for the next run
+	if score, seenAt, _ := storage.GetFirstScore(context.Background(), 1); score != 100 || !seenAt.Equal(firstSeen) {
+		t.Errorf("first score after sending = %d at %v, want 100 at %v", score, seenAt, firstSeen)
+	}
 }
 
 func TestRunDigestWithDecay(t *testing.T) {
@@ -229,6 +531,104 @@ func TestRunDigestWithDecay(t *testing.T) {
 	}
 }
 
+func TestRunDigestRecordsTagHistory(t *testing.T) {
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 2.0
+	storage.tagWeights["rust"] = 1.0
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, storage, &mockArticleSender{},
+		WithChatID(12345),
+		WithDecayRate(0.5),
+		WithMinTagWeight(0.1),
+	)
+
+	for i := 0; i < 2; i++ {
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run %d failed: %v", i+1, err)
+		}
+	}
+
+	// One snapshot per digest, taken after decay
+	want := map[string][]float64{
+		"go":   {1.0, 0.5},
+		"rust": {0.5, 0.25},
+	}
+	for tag, weights := range want {
+		got := storage.tagHistory[tag]
+		if len(got) != len(weights) {
+			t.Fatalf("%s history = %v, want %v", tag, got, weights)
+		}
+		for i := range weights {
+			if got[i] != weights[i] {
+				t.Errorf("%s history[%d] = %f, want %f", tag, i, got[i], weights[i])
+			}
+		}
+	}
+}
+
+func TestRunDigestDecayPerRun(t *testing.T) {
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 1.0
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, storage, &mockArticleSender{},
+		WithChatID(12345),
+		WithDecayRate(0.1),
+		WithDecayMode(DecayPerRun),
+	)
+
+	// Three runs on the same day decay three times
+	for i := 0; i < 3; i++ {
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	}
+	if w := storage.tagWeights["go"]; math.Abs(w-0.729) > 1e-9 {
+		t.Errorf("go weight = %f, want 0.729", w)
+	}
+	if _, ok := storage.settings[lastDecaySetting]; ok {
+		t.Error("per_run mode should not record a decay time")
+	}
+}
+
+func TestRunDigestDecayPerDay(t *testing.T) {
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 1.0
+	storage.domainWeights["example.com"] = 1.0
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, storage, &mockArticleSender{},
+		WithChatID(12345),
+		WithDecayRate(0.1),
+		WithDecayMode(DecayPerDay),
+	)
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{"first run records start", start, 1.0},
+		{"same day run decays proportionally", start.Add(12 * time.Hour), math.Pow(0.9, 0.5)},
+		{"end of first day", start.Add(24 * time.Hour), 0.9},
+		{"three day gap", start.Add(4 * 24 * time.Hour), 0.9 * 0.9 * 0.9 * 0.9},
+	}
+	for _, tt := range tests {
+		runner.now = func() time.Time { return tt.at }
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("%s: Run failed: %v", tt.name, err)
+		}
+		if w := storage.tagWeights["go"]; math.Abs(w-tt.want) > 1e-9 {
+			t.Errorf("%s: go weight = %f, want %f", tt.name, w, tt.want)
+		}
+		if w := storage.domainWeights["example.com"]; math.Abs(w-tt.want) > 1e-9 {
+			t.Errorf("%s: domain weight = %f, want %f", tt.name, w, tt.want)
+		}
+	}
+}
+
 func TestRunDigestWithDecayFloor(t *testing.T) {
 	hnClient := &mockHNClient{
 		topStories: []int64{1},
@@ -257,93 +657,1791 @@ func TestRunDigestWithDecayFloor(t *testing.T) {
 	}
 }
 
-func TestRunDigestFiltersRecentlySent(t *testing.T) {
-	hnClient := &mockHNClient{
-		topStories: []int64{1, 2, 3},
-		items: map[int64]*HNItem{
-			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
-			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
-			3: {ID: 3, Title: "Article 3", URL: "https://example.com/3", Score: 50},
-		},
+// panickingHNClient panics when fetching one item.
+type panickingHNClient struct {
+	*mockHNClient
+	panicID int64
+}
+
+func (c *panickingHNClient) GetItem(ctx context.Context, id int64) (*HNItem, error) {
+	if id == c.panicID {
+		panic("malformed item")
 	}
+	return c.mockHNClient.GetItem(ctx, id)
+}
 
-	storage := newMockStorage()
-	storage.recentlySent = []int64{2} // Article 2 was recently sent
+// panickingScraper panics when scraping one URL.
+type panickingScraper struct {
+	*mockScraper
+	panicURL string
+}
+
+func (s *panickingScraper) Scrape(ctx context.Context, url string) (string, error) {
+	if url == s.panicURL {
+		panic("weird URL")
+	}
+	return s.mockScraper.Scrape(ctx, url)
+}
+
+// panickingSummarizer panics when summarizing one title.
+type panickingSummarizer struct {
+	*mockSummarizer
+	panicTitle string
+}
+
+func (s *panickingSummarizer) Summarize(ctx context.Context, title, content string) (*SummaryResult, error) {
+	if title == s.panicTitle {
+		panic("nil result")
+	}
+	return s.mockSummarizer.Summarize(ctx, title, content)
+}
+
+// panickingSender panics when sending one article.
+type panickingSender struct {
+	*mockArticleSender
+	panicID int64
+}
+
+func (s *panickingSender) SendArticle(ctx context.Context, chatID int64, article *ArticleToSend) (int64, error) {
+	if article.ID == s.panicID {
+		panic("nil field")
+	}
+	return s.mockArticleSender.SendArticle(ctx, chatID, article)
+}
+
+func TestRunDigestSkipsPanickingArticle(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap func(hn *mockHNClient, sc *mockScraper, su *mockSummarizer, se *mockArticleSender) (HNClient, Scraper, Summarizer, ArticleSender)
+	}{
+		{"fetch", func(hn *mockHNClient, sc *mockScraper, su *mockSummarizer, se *mockArticleSender) (HNClient, Scraper, Summarizer, ArticleSender) {
+			return &panickingHNClient{hn, 2}, sc, su, se
+		}},
+		{"scrape", func(hn *mockHNClient, sc *mockScraper, su *mockSummarizer, se *mockArticleSender) (HNClient, Scraper, Summarizer, ArticleSender) {
+			return hn, &panickingScraper{sc, "https://example.com/2"}, su, se
+		}},
+		{"summarize", func(hn *mockHNClient, sc *mockScraper, su *mockSummarizer, se *mockArticleSender) (HNClient, Scraper, Summarizer, ArticleSender) {
+			return hn, sc, &panickingSummarizer{su, "Article 2"}, se
+		}},
+		{"send", func(hn *mockHNClient, sc *mockScraper, su *mockSummarizer, se *mockArticleSender) (HNClient, Scraper, Summarizer, ArticleSender) {
+			return hn, sc, su, &panickingSender{se, 2}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hnClient := &mockHNClient{
+				topStories: []int64{1, 2, 3},
+				items: map[int64]*HNItem{
+					1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+					2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 300},
+					3: {ID: 3, Title: "Article 3", URL: "https://example.com/3", Score: 50},
+				},
+			}
+			summarizer := &mockSummarizer{
+				results: map[string]*SummaryResult{
+					"Article 1": {Summary: "Summary 1", Tags: []string{"go"}},
+					"Article 2": {Summary: "Summary 2", Tags: []string{"go"}},
+					"Article 3": {Summary: "Summary 3", Tags: []string{"go"}},
+				},
+			}
+			sender := &mockArticleSender{}
+			hn, sc, su, se := tt.wrap(hnClient, &mockScraper{}, summarizer, sender)
+
+			runner := NewRunner(hn, sc, su, newMockStorage(), se, WithChatID(12345), WithArticleCount(3))
+			if err := runner.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			var sent []int64
+			for _, a := range sender.sentArticles {
+				sent = append(sent, a.ID)
+			}
+			slices.Sort(sent)
+			if !slices.Equal(sent, []int64{1, 3}) {
+				t.Errorf("sent articles %v, want [1 3]", sent)
+			}
+		})
+	}
+}
+
+// panickingBatchSummarizer panics on every batch.
+type panickingBatchSummarizer struct{}
+
+func (panickingBatchSummarizer) SummarizeBatch(ctx context.Context, inputs []SummaryInput) ([]SummaryResult, error) {
+	panic("malformed batch")
+}
 
+func TestRunDigestBatchPanicFallsBack(t *testing.T) {
+	summarizer := &mockSummarizer{}
 	sender := &mockArticleSender{}
 
 	runner := NewRunner(
-		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		newBatchFixture(), &mockScraper{}, summarizer, newMockStorage(), sender,
 		WithChatID(12345),
 		WithArticleCount(3),
+		WithBatchSummarizer(panickingBatchSummarizer{}, 5),
 	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
 
-	ctx := context.Background()
-	runner.Run(ctx)
-
-	// Should not have sent Article 2
-	for _, a := range sender.sentArticles {
-		if a.ID == 2 {
-			t.Error("Article 2 should not have been sent (recently sent)")
-		}
+	if len(sender.sentArticles) != 3 || len(summarizer.contents) != 3 {
+		t.Errorf("sent %d articles with %d individual summaries, want 3 of each", len(sender.sentArticles), len(summarizer.contents))
 	}
 }
 
-func TestRunDigestScrapeFailure(t *testing.T) {
-	hnClient := &mockHNClient{
-		topStories: []int64{1},
-		items: map[int64]*HNItem{
-			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
-		},
+// panickingStorage panics when looking up the first score of one article,
+// while its message is being prepared.
+type panickingStorage struct {
+	*mockStorage
+	panicID int64
+}
+
+func (s *panickingStorage) GetFirstScore(ctx context.Context, articleID int64) (int, time.Time, error) {
+	if articleID == s.panicID {
+		panic("corrupt row")
 	}
+	return s.mockStorage.GetFirstScore(ctx, articleID)
+}
 
-	scraper := &mockScraper{shouldFail: true}
-	summarizer := &mockSummarizer{}
-	storage := newMockStorage()
+func TestRunDigestSkipsArticlePanickingWhilePrepared(t *testing.T) {
 	sender := &mockArticleSender{}
-
 	runner := NewRunner(
-		hnClient, scraper, summarizer, storage, sender,
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, &panickingStorage{newMockStorage(), 2}, sender,
 		WithChatID(12345),
-		WithArticleCount(1),
+		WithArticleCount(3),
 	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
 
-	ctx := context.Background()
-	err := runner.Run(ctx)
+	var sent []int64
+	for _, a := range sender.sentArticles {
+		sent = append(sent, a.ID)
+	}
+	slices.Sort(sent)
+	if !slices.Equal(sent, []int64{1, 3}) {
+		t.Errorf("sent articles %v, want [1 3]", sent)
+	}
+}
 
-	// Should not fail entirely - scraper failure should be gracefully handled
-	// The article should use title as fallback content
-	if err != nil {
-		t.Logf("Run returned error (may be acceptable): %v", err)
+func TestProcessItemsSkipsNilItem(t *testing.T) {
+	runner := NewRunner(&mockHNClient{}, &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{}, WithChatID(12345))
+	items := []*HNItem{
+		{ID: 1, Title: "Article 1", URL: "https://example.com/1"},
+		nil,
+		{ID: 3, Title: "Article 3", URL: "https://example.com/3"},
+	}
+
+	var ids []int64
+	for _, a := range runner.processItems(context.Background(), items) {
+		ids = append(ids, a.ID)
+	}
+	if !slices.Equal(ids, []int64{1, 3}) {
+		t.Errorf("processed articles %v, want [1 3]", ids)
 	}
 }
 
-func TestRunDigestSummarizerFailure(t *testing.T) {
+func TestRunDigestMinArticles(t *testing.T) {
+	tests := []struct {
+		name         string
+		trigger      Trigger
+		articleCount int
+		minArticles  int
+		wantSent     int
+	}{
+		{"scheduled below minimum", TriggerScheduled, 5, 3, 0},
+		{"scheduled at minimum", TriggerScheduled, 5, 2, 2},
+		{"fetch below minimum", TriggerOnDemand, 5, 3, 2},
+		{"minimum above article count", TriggerScheduled, 2, 3, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hnClient := &mockHNClient{
+				topStories: []int64{1, 2},
+				items: map[int64]*HNItem{
+					1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+					2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+				},
+			}
+			summarizer := &mockSummarizer{
+				results: map[string]*SummaryResult{
+					"Article 1": {Summary: "Summary 1", Tags: []string{"go"}},
+					"Article 2": {Summary: "Summary 2", Tags: []string{"rust"}},
+				},
+			}
+			storage := newMockStorage()
+			sender := &mockArticleSender{}
+
+			runner := NewRunner(hnClient, &mockScraper{}, summarizer, storage, sender,
+				WithChatID(12345),
+				WithArticleCount(tt.articleCount),
+				WithMinArticles(tt.minArticles),
+				WithTrigger(tt.trigger),
+				WithIntroOutro("Good morning", "That's all"),
+			)
+			if err := runner.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if len(sender.sentArticles) != tt.wantSent {
+				t.Errorf("sent %d articles, want %d", len(sender.sentArticles), tt.wantSent)
+			}
+			if tt.wantSent == 0 {
+				if len(sender.notices) != 0 {
+					t.Errorf("sent notices %q for a skipped digest", sender.notices)
+				}
+				// The summaries are kept for the next run
+				if !storage.seen[1] || !storage.seen[2] {
+					t.Errorf("skipped articles not marked seen: %v", storage.seen)
+				}
+			}
+		})
+	}
+}
+
+func TestRunDigestFiltersRecentlySent(t *testing.T) {
 	hnClient := &mockHNClient{
-		topStories: []int64{1, 2},
+		topStories: []int64{1, 2, 3},
 		items: map[int64]*HNItem{
 			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
 			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+			3: {ID: 3, Title: "Article 3", URL: "https://example.com/3", Score: 50},
 		},
 	}
 
-	summarizer := &mockSummarizer{shouldFail: true}
 	storage := newMockStorage()
+	storage.recentlySent[12345] = []int64{2} // Article 2 was recently sent to this chat
+	storage.recentlySent[99999] = []int64{1} // Article 1 was only sent to another chat
+
 	sender := &mockArticleSender{}
 
 	runner := NewRunner(
-		hnClient, &mockScraper{}, summarizer, storage, sender,
+		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+	)
+
+	ctx := context.Background()
+	runner.Run(ctx)
+
+	// Should not have sent Article 2
+	for _, a := range sender.sentArticles {
+		if a.ID == 2 {
+			t.Error("Article 2 should not have been sent (recently sent)")
+		}
+	}
+	if len(sender.sentArticles) != 2 {
+		t.Errorf("sent %v, want articles 1 and 3", sentIDs(sender))
+	}
+	if ids := storage.recentlySent[12345]; len(ids) != 3 {
+		t.Errorf("chat 12345 sent IDs = %v, want newly sent articles recorded for the chat", ids)
+	}
+}
+
+// mockSentFilter drops the IDs in sent, or fails with err.
+type mockSentFilter struct {
+	sent map[int64]bool
+	err  error
+}
+
+func (m *mockSentFilter) UnsentArticleIDs(ctx context.Context, chatID int64, ids []int64, within time.Duration) ([]int64, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var unsent []int64
+	for _, id := range ids {
+		if !m.sent[id] {
+			unsent = append(unsent, id)
+		}
+	}
+	return unsent, nil
+}
+
+func TestRunDigestSentFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *mockSentFilter
+		want   []int64
+	}{
+		{"filter drops sent", &mockSentFilter{sent: map[int64]bool{1: true}}, []int64{2, 3}},
+		// A failing filter falls back to the recently sent IDs
+		{"filter fails", &mockSentFilter{err: errors.New("database locked")}, []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		hnClient := &mockHNClient{
+			topStories: []int64{1, 2, 3},
+			items: map[int64]*HNItem{
+				1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 300},
+				2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+				3: {ID: 3, Title: "Article 3", URL: "https://example.com/3", Score: 100},
+			},
+		}
+		storage := newMockStorage()
+		storage.recentlySent[12345] = []int64{2}
+		sender := &mockArticleSender{}
+
+		runner := NewRunner(
+			hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+			WithChatID(12345),
+			WithArticleCount(3),
+			WithSentFilter(tt.filter),
+		)
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("%s: Run failed: %v", tt.name, err)
+		}
+
+		got := sentIDs(sender)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: sent %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunDigestFiltersOldStories(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Fresh", URL: "https://example.com/1", Score: 100, Time: now.Add(-2 * time.Hour)},
+			2: {ID: 2, Title: "Resurrected", URL: "https://example.com/2", Score: 500, Time: now.Add(-72 * time.Hour)},
+			3: {ID: 3, Title: "Unknown age", URL: "https://example.com/3", Score: 50},
+		},
+	}
+	scraper := &mockScraper{}
+	sender := &mockArticleSender{}
+
+	storage := newMockStorage()
+	runner := NewRunner(hnClient, scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithMaxArticleAge(48*time.Hour),
+	)
+	runner.now = func() time.Time { return now }
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if ids := sentIDs(sender); len(ids) != 2 || slices.Contains(ids, 2) {
+		t.Errorf("sent %v, want articles 1 and 3 but not the 72h old article 2", ids)
+	}
+	if slices.Contains(scraper.scraped, "https://example.com/2") {
+		t.Error("old story should be skipped before scraping")
+	}
+	for id, article := range storage.articles {
+		if !article.FetchedAt.Equal(now) {
+			t.Errorf("article %d fetched at %v, want the runner clock %v", id, article.FetchedAt, now)
+		}
+	}
+}
+
+func TestRunDigestCandidateMultiplier(t *testing.T) {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 40; id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id), Score: 100}
+	}
+
+	storage := newMockStorage()
+	for id := int64(1); id <= 25; id++ {
+		storage.recentlySent[12345] = append(storage.recentlySent[12345], id)
+	}
+
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(10),
+		WithCandidateMultiplier(3),
+	)
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if hnClient.limit != 30 {
+		t.Errorf("requested %d top stories, want 30", hnClient.limit)
+	}
+	// Only stories 26-30 of the 30 candidates survive the recency filter
+	if len(sender.sentArticles) != 5 {
+		t.Errorf("sent %v, want the 5 unsent candidates", sentIDs(sender))
+	}
+	for _, a := range sender.sentArticles {
+		if a.ID <= 25 || a.ID > 30 {
+			t.Errorf("sent article %d, want only candidates 26-30", a.ID)
+		}
+	}
+}
+
+func TestRunDigestDefaultCandidateMultiplier(t *testing.T) {
+	hnClient := &mockHNClient{}
+	runner := NewRunner(hnClient, &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(10),
+		WithCandidateMultiplier(0),
+	)
+	runner.Run(context.Background())
+
+	if hnClient.limit != 20 {
+		t.Errorf("requested %d top stories, want 20", hnClient.limit)
+	}
+}
+
+func TestRunDigestRankBeforeSummarize(t *testing.T) {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 20; id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id), Score: int(id) * 10}
+	}
+	summarizer := &mockSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(4),
+		WithCandidateMultiplier(5),
+		WithRankBeforeSummarize(true),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// 4 articles x 1.5 buffer: only the 6 highest-scoring stories
+	if len(summarizer.contents) != 6 {
+		t.Errorf("summarized %d articles, want 6", len(summarizer.contents))
+	}
+	for id := 15; id <= 20; id++ {
+		if _, ok := summarizer.contents[fmt.Sprintf("Article %d", id)]; !ok {
+			t.Errorf("Article %d not summarized, want the top 6 by HN score", id)
+		}
+	}
+	if len(sender.sentArticles) != 4 {
+		t.Errorf("sent %v, want 4 articles", sentIDs(sender))
+	}
+}
+
+func TestRunDigestSummarizesAllWithoutRankBeforeSummarize(t *testing.T) {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 20; id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id), Score: 100}
+	}
+	summarizer := &mockSummarizer{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, summarizer, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(4),
+		WithCandidateMultiplier(5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(summarizer.contents) != 20 {
+		t.Errorf("summarized %d articles, want all 20 candidates", len(summarizer.contents))
+	}
+}
+
+func TestRunDigestEmptyNotice(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantMsg bool
+	}{
+		{"on demand", []Option{WithTrigger(TriggerOnDemand)}, true},
+		{"scheduled", []Option{WithTrigger(TriggerScheduled)}, false},
+		{"scheduled with notice", []Option{WithTrigger(TriggerScheduled), WithEmptyNotice(true)}, true},
+		{"default trigger", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hnClient := &mockHNClient{
+				topStories: []int64{1, 2},
+				items: map[int64]*HNItem{
+					1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+					2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+				},
+			}
+			storage := newMockStorage()
+			storage.recentlySent[12345] = []int64{1, 2}
+			sender := &mockArticleSender{}
+
+			runner := NewRunner(hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+				append([]Option{WithChatID(12345)}, tt.opts...)...)
+			if err := runner.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if len(sender.sentArticles) != 0 {
+				t.Errorf("sent %v, want nothing", sentIDs(sender))
+			}
+			if tt.wantMsg {
+				if len(sender.notices) != 1 || sender.notices[0] != emptyDigestNotice {
+					t.Errorf("notices = %q, want %q", sender.notices, emptyDigestNotice)
+				}
+			} else if len(sender.notices) != 0 {
+				t.Errorf("notices = %q, want none", sender.notices)
+			}
+		})
+	}
+}
+
+// sequenceSender records articles and notices in the order they are sent.
+type sequenceSender struct {
+	mockArticleSender
+	sequence []string
+}
+
+func (s *sequenceSender) SendNotice(ctx context.Context, chatID int64, text string) error {
+	s.sequence = append(s.sequence, text)
+	return s.mockArticleSender.SendNotice(ctx, chatID, text)
+}
+
+func (s *sequenceSender) SendArticle(ctx context.Context, chatID int64, article *ArticleToSend) (int64, error) {
+	s.sequence = append(s.sequence, article.Title)
+	return s.mockArticleSender.SendArticle(ctx, chatID, article)
+}
+
+func TestRunDigestIntroOutro(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			"configured",
+			[]Option{WithTrigger(TriggerScheduled), WithIntroOutro("Curated for the team", "Reply with feedback to /feedback")},
+			[]string{"Curated for the team", "Article 3", "Article 2", "Reply with feedback to /feedback"},
+		},
+		{
+			"empty",
+			[]Option{WithTrigger(TriggerScheduled), WithIntroOutro("", "")},
+			[]string{"Article 3", "Article 2"},
+		},
+		{
+			"intro only",
+			[]Option{WithTrigger(TriggerScheduled), WithIntroOutro("Curated for the team", "")},
+			[]string{"Curated for the team", "Article 3", "Article 2"},
+		},
+		{
+			"on demand",
+			[]Option{WithTrigger(TriggerOnDemand), WithIntroOutro("Curated for the team", "Reply with feedback to /feedback")},
+			[]string{"Article 3", "Article 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &sequenceSender{}
+			opts := append([]Option{WithChatID(12345), WithArticleCount(2)}, tt.opts...)
+			runner := NewRunner(newBatchFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender, opts...)
+			if err := runner.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if !slices.Equal(sender.sequence, tt.want) {
+				t.Errorf("sent %q, want %q", sender.sequence, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDigestEmptyNoticeAfterTagFilter(t *testing.T) {
+	hnClient, summarizer := newTagScoreFixture()
+
+	storage := newMockStorage()
+	for id := int64(100); id < 105; id++ {
+		storage.likedArticles[id] = true
+	}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(hnClient, &mockScraper{}, summarizer, storage, sender,
+		WithChatID(12345),
+		WithTrigger(TriggerOnDemand),
+		WithMinTagScore(100, 5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(sender.sentArticles) != 0 || len(sender.notices) != 1 {
+		t.Errorf("sent %v and notices %q, want only the empty notice", sentIDs(sender), sender.notices)
+	}
+}
+
+func TestRunDigestStageTimeouts(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+
+	t.Run("scrape", func(t *testing.T) {
+		scraper := &blockingScraper{blockingStage: blockingStage{key: "https://example.com/1"}}
+		sender := &mockArticleSender{}
+		runner := NewRunner(newBatchFixture(), scraper, &mockSummarizer{}, newMockStorage(), sender,
+			WithChatID(12345),
+			WithArticleCount(3),
+			WithTimeouts(Timeouts{Scrape: timeout}),
+		)
+
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(scraper.errs) != 1 || !errors.Is(scraper.errs[0], context.DeadlineExceeded) {
+			t.Errorf("scrape errors = %v, want one deadline exceeded", scraper.errs)
+		}
+		// The timed-out article falls back to its title, like any scrape failure
+		if len(sender.sentArticles) != 3 {
+			t.Errorf("sent %v, want all 3 articles", sentIDs(sender))
+		}
+	})
+
+	t.Run("summarize", func(t *testing.T) {
+		summarizer := &blockingSummarizer{blockingStage: blockingStage{key: "Article 2"}}
+		sender := &mockArticleSender{}
+		runner := NewRunner(newBatchFixture(), &mockScraper{}, summarizer, newMockStorage(), sender,
+			WithChatID(12345),
+			WithArticleCount(3),
+			WithTimeouts(Timeouts{Summarize: timeout}),
+		)
+
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(summarizer.errs) != 1 || !errors.Is(summarizer.errs[0], context.DeadlineExceeded) {
+			t.Errorf("summarize errors = %v, want one deadline exceeded", summarizer.errs)
+		}
+		for _, a := range sender.sentArticles {
+			if a.ID == 2 {
+				t.Error("article 2 was sent without a summary")
+			}
+		}
+		if len(sender.sentArticles) != 2 {
+			t.Errorf("sent %v, want the other 2 articles", sentIDs(sender))
+		}
+	})
+}
+
+func TestRunDigestScrapeFailure(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+
+	scraper := &mockScraper{shouldFail: true}
+	summarizer := &mockSummarizer{}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, summarizer, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+
+	ctx := context.Background()
+	err := runner.Run(ctx)
+
+	// Should not fail entirely - scraper failure should be gracefully handled
+	// The article should use title as fallback content
+	if err != nil {
+		t.Logf("Run returned error (may be acceptable): %v", err)
+	}
+}
+
+func TestRunDigestFiltersUnwantedLanguages(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "English article", URL: "https://example.com/en", Score: 100},
+			2: {ID: 2, Title: "French article", URL: "https://example.fr/fr", Score: 200},
+			3: {ID: 3, Title: "Unreachable article", URL: "https://example.fr/down", Score: 300},
+		},
+	}
+	scraper := &mockScraper{contents: map[string]string{
+		"https://example.com/en": "The new release of the compiler is faster than the previous one, and it was " +
+			"built with a focus on memory usage. Users have reported that their builds are now much quicker.",
+		"https://example.fr/fr": "Le nouveau compilateur est plus rapide que la version précédente, et il a été " +
+			"conçu pour réduire la mémoire utilisée. Les utilisateurs disent que leurs projets sont plus rapides.",
+		// Empty content falls back to the title, which bypasses the filter
+		"https://example.fr/down": "",
+	}}
+	summarizer := &mockSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithAllowedLanguages("en"),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if _, ok := summarizer.contents["French article"]; ok {
+		t.Error("French article should be dropped before summarizing")
+	}
+	sent := make(map[int64]bool)
+	for _, a := range sender.sentArticles {
+		sent[a.ID] = true
+	}
+	if len(sent) != 2 || !sent[1] || !sent[3] {
+		t.Errorf("sent articles = %v, want the English article and the title-only fallback", sent)
+	}
+}
+
+func TestRunDigestReusesSummaryForUnchangedContent(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+	scraper := &mockScraper{contents: map[string]string{"https://example.com/1": "Original content"}}
+	storage := newMockStorage()
+
+	// Each run targets another chat, so the article isn't deduplicated
+	run := func(chatID int64) (*mockSummarizer, *mockArticleSender) {
+		t.Helper()
+		summarizer := &mockSummarizer{}
+		sender := &mockArticleSender{}
+		runner := NewRunner(hnClient, scraper, summarizer, storage, sender,
+			WithChatID(chatID),
+			WithArticleCount(1),
+		)
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return summarizer, sender
+	}
+
+	summarizer, _ := run(1)
+	if len(summarizer.contents) != 1 {
+		t.Fatalf("first run summarized %d articles, want 1", len(summarizer.contents))
+	}
+	if storage.articles[1].ContentHash == "" {
+		t.Fatal("stored article should record its content hash")
+	}
+
+	// The same bytes again reuse the stored summary
+	summarizer, sender := run(2)
+	if len(summarizer.contents) != 0 {
+		t.Errorf("unchanged re-scrape summarized %d articles, want 0", len(summarizer.contents))
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].Summary != "Default summary for Article 1" {
+		t.Errorf("sent = %+v, want article 1 with its stored summary", sender.sentArticles)
+	}
+
+	// Changed content is summarized again
+	scraper.contents["https://example.com/1"] = "Updated content"
+	summarizer, _ = run(3)
+	if len(summarizer.contents) != 1 {
+		t.Errorf("changed content summarized %d articles, want 1", len(summarizer.contents))
+	}
+
+	// Another article with the same content, such as the same paywall
+	// stub, gets its own summary
+	hnClient.topStories = []int64{2}
+	hnClient.items[2] = &HNItem{ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 100}
+	scraper.contents["https://example.com/2"] = "Updated content"
+	summarizer, sender = run(4)
+	if len(summarizer.contents) != 1 {
+		t.Errorf("other article with the same content summarized %d articles, want 1", len(summarizer.contents))
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].Summary != "Default summary for Article 2" {
+		t.Errorf("sent = %+v, want article 2 with its own summary", sender.sentArticles)
+	}
+}
+
+func TestRunDigestReusesSeenArticles(t *testing.T) {
+	storage := newMockStorage()
+	scraper := &mockScraper{}
+	summarizer := &mockSummarizer{}
+
+	// First run: all three are summarized, only the top one is sent
+	runner := NewRunner(newBatchFixture(), scraper, summarizer, storage, &mockArticleSender{},
+		WithChatID(1),
+		WithArticleCount(1),
+		WithCandidateMultiplier(3),
+		WithDecayRate(0),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if len(summarizer.contents) != 3 {
+		t.Fatalf("first run summarized %d articles, want 3", len(summarizer.contents))
+	}
+	if !storage.seen[1] || !storage.seen[2] || storage.seen[3] {
+		t.Errorf("seen = %v, want the two unsent articles", storage.seen)
+	}
+
+	// Second run: the unsent articles resurface and reuse their summaries
+	scraper.scraped = nil
+	summarizer.contents = nil
+	sender := &mockArticleSender{}
+	runner = NewRunner(newBatchFixture(), scraper, summarizer, storage, sender,
+		WithChatID(1),
+		WithArticleCount(2),
+		WithDecayRate(0),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+
+	if len(summarizer.contents) != 0 || len(scraper.scraped) != 0 {
+		t.Errorf("summarized %v and scraped %v, want seen articles reused", summarizer.contents, scraper.scraped)
+	}
+	if len(sender.sentArticles) != 2 {
+		t.Fatalf("sent %v, want articles 1 and 2", sentIDs(sender))
+	}
+	for _, a := range sender.sentArticles {
+		if a.Summary != "Default summary for "+a.Title {
+			t.Errorf("article %d summary = %q, want the stored summary", a.ID, a.Summary)
+		}
+	}
+}
+
+func TestRunDigestSummarizerFailure(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+		},
+	}
+
+	summarizer := &mockSummarizer{shouldFail: true}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, summarizer, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(2),
+	)
+
+	ctx := context.Background()
+	runner.Run(ctx)
+
+	// With summarizer failing, no articles should be sent
+	if len(sender.sentArticles) > 0 {
+		t.Error("No articles should be sent when summarizer fails")
+	}
+}
+
+func TestRunDigestAskHNUsesItemText(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {
+				ID:    1,
+				Title: "Ask HN: How do you test Go code?",
+				Text:  "I&#x27;m curious how people <i>structure</i> tests.<p>Table-driven or not?",
+				Score: 80,
+			},
+		},
+	}
+
+	scraper := &mockScraper{}
+	summarizer := &mockSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(scraper.scraped) != 0 {
+		t.Errorf("scraper should not be called for text posts, got %v", scraper.scraped)
+	}
+
+	content := summarizer.contents["Ask HN: How do you test Go code?"]
+	want := "I'm curious how people structure tests.\nTable-driven or not?"
+	if content != want {
+		t.Errorf("summarized content = %q, want %q", content, want)
+	}
+
+	if len(sender.sentArticles) != 1 {
+		t.Fatalf("sent %d articles, want 1", len(sender.sentArticles))
+	}
+	if url := sender.sentArticles[0].URL; url != "https://news.ycombinator.com/item?id=1" {
+		t.Errorf("URL = %q, want HN discussion link", url)
+	}
+}
+
+// pageScraper reports a canonical URL and an image for some pages.
+type pageScraper struct {
+	mockScraper
+	canonicals map[string]string
+	images     map[string]string
+}
+
+func (s *pageScraper) ScrapePage(ctx context.Context, url string) (*ScrapedPage, error) {
+	content, err := s.Scrape(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &ScrapedPage{Content: content, Canonical: s.CanonicalURL(url), ImageURL: s.images[url]}, nil
+}
+
+func (s *pageScraper) CanonicalURL(url string) string {
+	if canonical, ok := s.canonicals[url]; ok {
+		return canonical
+	}
+	return url
+}
+
+func TestRunDigestCanonicalURLs(t *testing.T) {
+	ampURL := "https://example-com.cdn.ampproject.org/c/s/example.com/story"
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Story", URL: ampURL, Score: 100},
+			2: {ID: 2, Title: "Story again", URL: "https://example.com/story?utm_source=hn", Score: 90},
+			3: {ID: 3, Title: "Other", URL: "https://other.org/post", Score: 80},
+		},
+	}
+	scraper := &pageScraper{
+		canonicals: map[string]string{
+			ampURL:                                   "https://example.com/story",
+			"https://example.com/story?utm_source=hn": "https://example.com/story",
+		},
+	}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithScrapeConcurrency(1),
+		WithPageScraper(scraper),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The second submission of the same page is dropped
+	if len(sender.sentArticles) != 2 {
+		t.Fatalf("sent %d articles, want 2", len(sender.sentArticles))
+	}
+	for _, a := range sender.sentArticles {
+		if a.ID == 2 {
+			t.Error("duplicate of article 1 should not be sent")
+		}
+		if a.ID == 1 && a.URL != ampURL {
+			t.Errorf("sent URL = %q, want the submitted %q", a.URL, ampURL)
+		}
+	}
+	if got := storage.articles[1].URL; got != "https://example.com/story" {
+		t.Errorf("stored URL = %q, want the canonical URL", got)
+	}
+}
+
+func TestRunDigestCanonicalURLsOfUnscrapedPages(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Story", URL: "https://example.com/story/amp", Score: 100},
+			2: {ID: 2, Title: "Story again", URL: "https://example.com/story?utm_source=hn", Score: 90},
+		},
+	}
+	scraper := &pageScraper{
+		mockScraper: mockScraper{shouldFail: true},
+		canonicals: map[string]string{
+			"https://example.com/story/amp":           "https://example.com/story",
+			"https://example.com/story?utm_source=hn": "https://example.com/story",
+		},
+	}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(2),
+		WithPageScraper(scraper),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].ID != 1 {
+		t.Fatalf("sent %v, want only article 1", sentIDs(sender))
+	}
+	if got := storage.articles[1].URL; got != "https://example.com/story" {
+		t.Errorf("stored URL = %q, want the canonical URL", got)
+	}
+}
+
+func TestRunDigestKeepsScrapedImages(t *testing.T) {
+	storage := newMockStorage()
+	scraper := &pageScraper{
+		images: map[string]string{
+			"https://example.com/2": "https://example.com/2.png",
+			"https://example.com/3": "https://example.com/3.png",
+		},
+	}
+
+	// First run: article 3 is sent, article 2 is only seen
+	sender := &mockArticleSender{}
+	runner := NewRunner(newBatchFixture(), scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(1),
+		WithArticleCount(1),
+		WithCandidateMultiplier(3),
+		WithDecayRate(0),
+		WithPageScraper(scraper),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].ImageURL != "https://example.com/3.png" {
+		t.Fatalf("sent %v, want article 3 with its image", sentIDs(sender))
+	}
+	for id, want := range map[int64]string{1: "", 2: "https://example.com/2.png", 3: "https://example.com/3.png"} {
+		if got := storage.articles[id].ImageURL; got != want {
+			t.Errorf("article %d stored image = %q, want %q", id, got, want)
+		}
+	}
+
+	// Second run: the seen article is sent with its stored image, unscraped
+	scraper.scraped = nil
+	sender = &mockArticleSender{}
+	runner = NewRunner(newBatchFixture(), scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(1),
+		WithArticleCount(1),
+		WithDecayRate(0),
+		WithPageScraper(scraper),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if len(scraper.scraped) != 0 {
+		t.Errorf("scraped %v, want the seen articles reused", scraper.scraped)
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].ImageURL != "https://example.com/2.png" {
+		t.Errorf("sent %v, want article 2 with its stored image", sentIDs(sender))
+	}
+}
+
+func TestRunDigestExplanation(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Article 1": {Summary: "Summary 1", Tags: []string{"testing", "go", "tooling"}},
+		},
+	}
+
+	for _, explain := range []bool{false, true} {
+		storage := newMockStorage()
+		storage.tagWeights["go"] = 2.1
+		storage.tagWeights["testing"] = 1.3
+		sender := &mockArticleSender{}
+
+		runner := NewRunner(
+			hnClient, &mockScraper{}, summarizer, storage, sender,
+			WithChatID(12345),
+			WithArticleCount(1),
+			WithDecayRate(0),
+			WithExplain(explain),
+		)
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		got := sender.sentArticles[0].Explanation
+		want := ""
+		if explain {
+			want = "matched: go (2.10), testing (1.30)"
+		}
+		if got != want {
+			t.Errorf("explain=%v: Explanation = %q, want %q", explain, got, want)
+		}
+	}
+}
+
+type mockDiscussionSummarizer struct {
+	mu       sync.Mutex
+	comments [][]string
+	err      error
+}
+
+func (m *mockDiscussionSummarizer) SummarizeDiscussion(ctx context.Context, comments []string) (string, error) {
+	m.mu.Lock()
+	m.comments = append(m.comments, comments)
+	m.mu.Unlock()
+	if m.err != nil {
+		return "", m.err
+	}
+	return fmt.Sprintf("Take on %d comments", len(comments)), nil
+}
+
+func TestRunDigestDiscussionSummary(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 200, Kids: []int64{101, 102, 103, 104, 105, 106, 107}},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 100},
+			// 102 is deleted and 104 fails to fetch
+			101: {ID: 101, Text: "First &amp; <i>best</i>"},
+			102: {ID: 102},
+			103: {ID: 103, Text: "Third<p>with a paragraph"},
+			105: {ID: 105, Text: "Fifth"},
+			106: {ID: 106, Text: "Sixth, past the limit"},
+		},
+	}
+	discussion := &mockDiscussionSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(2),
+		WithDiscussionSummary(discussion),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Only article 1 has comments, drawn from its first five
+	want := []string{"First & best", "Third\nwith a paragraph", "Fifth"}
+	if len(discussion.comments) != 1 || !slices.Equal(discussion.comments[0], want) {
+		t.Errorf("summarized comments = %q, want %q", discussion.comments, want)
+	}
+
+	takes := make(map[int64]string)
+	for _, a := range sender.sentArticles {
+		takes[a.ID] = a.Discussion
+	}
+	if takes[1] != "Take on 3 comments" {
+		t.Errorf("article 1 discussion = %q, want the take", takes[1])
+	}
+	if takes[2] != "" {
+		t.Errorf("article 2 discussion = %q, want none without comments", takes[2])
+	}
+}
+
+func TestRunDigestDiscussionSummaryFailureStillSends(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1:   {ID: 1, Title: "Article 1", URL: "https://example.com/1", Kids: []int64{101}},
+			101: {ID: 101, Text: "A comment"},
+		},
+	}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithDiscussionSummary(&mockDiscussionSummarizer{err: errors.New("quota exceeded")}),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].Discussion != "" {
+		t.Errorf("sent %+v, want the article without a discussion line", sender.sentArticles)
+	}
+}
+
+func TestRunDigestSavesBeforeSending(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+
+	storage := newMockStorage()
+	storage.markSentErr = errors.New("database is locked")
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(sender.sentArticles) != 1 {
+		t.Fatalf("sent %d articles, want 1", len(sender.sentArticles))
+	}
+
+	// The row must exist even though MarkArticleSent failed
+	if _, ok := storage.articles[1]; !ok {
+		t.Error("article should be saved even when marking sent fails")
+	}
+}
+
+func TestRunDigestSavesSummaryModel(t *testing.T) {
+	storage := newMockStorage()
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, storage, &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The sent article, and the candidate that missed the cut
+	if len(storage.articles) != 2 {
+		t.Fatalf("saved %d articles, want 2", len(storage.articles))
+	}
+	for _, a := range storage.articles {
+		if a.SummaryModel != "mock-model" {
+			t.Errorf("SummaryModel = %q, want 'mock-model'", a.SummaryModel)
+		}
+	}
+}
+
+func TestRunDigestSkipsSendWhenSaveFails(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+
+	storage := newMockStorage()
+	storage.saveErr = errors.New("disk full")
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+	runner.Run(context.Background())
+
+	if len(sender.sentArticles) != 0 {
+		t.Error("article should not be sent when it cannot be saved")
+	}
+}
+
+func newBatchFixture() *mockHNClient {
+	return &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 200},
+			3: {ID: 3, Title: "Article 3", URL: "https://example.com/3", Score: 300},
+		},
+	}
+}
+
+func TestRunDigestMaxRunDuration(t *testing.T) {
+	const maxDuration = 50 * time.Millisecond
+
+	// Article 2 is still scraping when the run runs out of time, so article
+	// 3 is never started
+	scraper := &blockingScraper{blockingStage: blockingStage{key: "https://example.com/2"}}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+	runner := NewRunner(newBatchFixture(), scraper, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithScrapeConcurrency(1),
+		WithMaxRunDuration(maxDuration),
+	)
+
+	start := time.Now()
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > maxDuration+time.Second {
+		t.Errorf("run took %v, want about %v", elapsed, maxDuration)
+	}
+
+	if ids := sentIDs(sender); !slices.Equal(ids, []int64{1}) {
+		t.Errorf("sent %v, want only article 1", ids)
+	}
+	if !slices.Equal(storage.sentArticleIDs, []int64{1}) {
+		t.Errorf("marked sent %v, want only article 1", storage.sentArticleIDs)
+	}
+	if len(storage.failed) != 0 {
+		t.Errorf("failures recorded %v, want none for articles cut off by the deadline", storage.failed)
+	}
+	if slices.Contains(scraper.scraped, "https://example.com/3") {
+		t.Error("article 3 should not be scraped after the deadline")
+	}
+}
+
+func TestRunDigestBatchSummarization(t *testing.T) {
+	summarizer := &mockSummarizer{}
+	batcher := &mockBatchSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithBatchSummarizer(batcher, 2),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(batcher.batches) != 1 || len(batcher.batches[0]) != 2 {
+		t.Fatalf("batches = %v, want one batch of 2", batcher.batches)
+	}
+	// The trailing single article is summarized on its own
+	if len(summarizer.contents) != 1 {
+		t.Errorf("individual summaries = %d, want 1", len(summarizer.contents))
+	}
+	if len(sender.sentArticles) != 3 {
+		t.Fatalf("sent %d articles, want 3", len(sender.sentArticles))
+	}
+	for _, a := range sender.sentArticles {
+		if a.ID != 3 && a.Summary != "Batch summary for "+a.Title {
+			t.Errorf("article %d summary = %q, want batch summary", a.ID, a.Summary)
+		}
+	}
+}
+
+func TestRunDigestBatchFailureFallsBack(t *testing.T) {
+	summarizer := &mockSummarizer{}
+	batcher := &mockBatchSummarizer{shouldFail: true}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithBatchSummarizer(batcher, 5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(batcher.batches) != 1 {
+		t.Errorf("batch requests = %d, want 1", len(batcher.batches))
+	}
+	if len(summarizer.contents) != 3 {
+		t.Errorf("individual summaries = %d, want 3 after batch failure", len(summarizer.contents))
+	}
+	if len(sender.sentArticles) != 3 {
+		t.Errorf("sent %d articles, want 3", len(sender.sentArticles))
+	}
+}
+
+func TestRunDigestBatchItemFailureFallsBack(t *testing.T) {
+	summarizer := &mockSummarizer{}
+	batcher := &mockBatchSummarizer{failTitles: map[string]bool{"Article 2": true}}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, summarizer, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithBatchSummarizer(batcher, 5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Only the failed item is summarized again
+	if len(summarizer.contents) != 1 {
+		t.Errorf("individual summaries = %d, want 1 for the failed item", len(summarizer.contents))
+	}
+	if len(sender.sentArticles) != 3 {
+		t.Fatalf("sent %d articles, want 3", len(sender.sentArticles))
+	}
+	for _, a := range sender.sentArticles {
+		batched := a.Summary == "Batch summary for "+a.Title
+		if batched == (a.Title == "Article 2") {
+			t.Errorf("article %q summary = %q, want a batch summary for all but the failed item", a.Title, a.Summary)
+		}
+	}
+}
+
+func TestRunDigestRetriesFailedArticles(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 100},
+		},
+	}
+	summarizer := &mockSummarizer{shouldFail: true}
+	storage := newMockStorage()
+	sender := &mockArticleSender{}
+	newRunner := func() *Runner {
+		return NewRunner(hnClient, &mockScraper{}, summarizer, storage, sender, WithChatID(12345), WithArticleCount(2))
+	}
+
+	// Article 1 fails to summarize, and article 3 to fetch
+	if err := newRunner().Run(context.Background()); err != nil {
+		t.Fatalf("first Run failed: %v", err)
+	}
+	if storage.failed[1] != 1 || storage.failed[3] != 1 {
+		t.Fatalf("failed attempts = %v, want one each for articles 1 and 3", storage.failed)
+	}
+
+	// Article 1 is no longer a top story, but is retried and now succeeds
+	hnClient.topStories = []int64{2}
+	summarizer.shouldFail = false
+	if err := newRunner().Run(context.Background()); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if !slices.Contains(storage.sentArticleIDs, 1) || !slices.Contains(storage.sentArticleIDs, 2) {
+		t.Errorf("sent %v, want the retried article 1 and article 2", storage.sentArticleIDs)
+	}
+	if _, ok := storage.failed[1]; ok {
+		t.Error("article 1 still recorded as failed after succeeding")
+	}
+	if storage.failed[3] != 2 {
+		t.Errorf("article 3 attempts = %d, want 2", storage.failed[3])
+	}
+}
+
+func TestRunDigestClearsFailureOfSentTopStory(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+		},
+	}
+	summarizer := &mockSummarizer{shouldFail: true}
+	storage := newMockStorage()
+	newRunner := func() *Runner {
+		return NewRunner(hnClient, &mockScraper{}, summarizer, storage, &mockArticleSender{}, WithChatID(12345), WithArticleCount(1))
+	}
+
+	if err := newRunner().Run(context.Background()); err != nil {
+		t.Fatalf("first Run failed: %v", err)
+	}
+	if storage.failed[1] != 1 {
+		t.Fatalf("failed attempts = %v, want one for article 1", storage.failed)
+	}
+
+	// Still a top story, article 1 is sent without taking the retry path
+	summarizer.shouldFail = false
+	if err := newRunner().Run(context.Background()); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if !slices.Contains(storage.sentArticleIDs, 1) {
+		t.Fatalf("sent %v, want article 1", storage.sentArticleIDs)
+	}
+	if _, ok := storage.failed[1]; ok {
+		t.Error("article 1 still recorded as failed after being sent")
+	}
+}
+
+func TestRunDigestSkipsRetryOfSentArticle(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Article 2", URL: "https://example.com/2", Score: 100},
+		},
+	}
+	storage := newMockStorage()
+	storage.failed[1] = 1
+	storage.failed[2] = 1
+	// Article 1 was sent after it failed; article 2's send is too old to count
+	storage.sentAt[1] = time.Now().Add(-time.Hour)
+	storage.sentAt[2] = time.Now().Add(-2 * defaultRecencyWindow)
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender, WithChatID(12345), WithArticleCount(2))
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !slices.Equal(storage.sentArticleIDs, []int64{2}) {
+		t.Errorf("sent %v, want only article 2 retried", storage.sentArticleIDs)
+	}
+}
+
+func TestRunDigestGivesUpOnFailedArticles(t *testing.T) {
+	hnClient := &mockHNClient{items: map[int64]*HNItem{}}
+	storage := newMockStorage()
+	storage.failed[7] = maxFailedAttempts
+
+	runner := NewRunner(hnClient, &mockScraper{}, &mockSummarizer{}, storage, &mockArticleSender{}, WithChatID(12345))
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if storage.failed[7] != maxFailedAttempts {
+		t.Errorf("attempts = %d, want %d with no further retry", storage.failed[7], maxFailedAttempts)
+	}
+}
+
+func TestRunDigestScrapeConcurrencyLimit(t *testing.T) {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 8; id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id)}
+	}
+	scraper := &mockScraper{delay: 20 * time.Millisecond}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(8),
+		WithHNConcurrency(8),
+		WithScrapeConcurrency(3),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if scraper.maxConcurrent != 3 {
+		t.Errorf("max concurrent scrapes = %d, want 3", scraper.maxConcurrent)
+	}
+	if len(sender.sentArticles) != 8 {
+		t.Errorf("sent %d articles, want 8", len(sender.sentArticles))
+	}
+}
+
+func TestRunDigestCancelledDuringScrape(t *testing.T) {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 8; id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id)}
+	}
+	scraper := &mockScraper{delay: time.Hour}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, scraper, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(8),
+		WithScrapeConcurrency(2),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := runner.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Only the first workers started; the rest were skipped
+	if n := len(scraper.scraped); n != 2 {
+		t.Errorf("scrapes started = %d, want 2", n)
+	}
+	if len(sender.sentArticles) != 0 {
+		t.Errorf("sent %d articles after cancellation, want 0", len(sender.sentArticles))
+	}
+}
+
+func TestRunDigestStopsWhenChatUnavailable(t *testing.T) {
+	storage := newMockStorage()
+	sender := &mockArticleSender{err: fmt.Errorf("%w: Forbidden: bot was blocked by the user", ErrChatUnavailable)}
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+	)
+	err := runner.Run(context.Background())
+	if !errors.Is(err, ErrChatUnavailable) {
+		t.Fatalf("Run error = %v, want ErrChatUnavailable", err)
+	}
+
+	if sender.attempts != 1 {
+		t.Errorf("send attempts = %d, want 1", sender.attempts)
+	}
+	if len(storage.sentArticleIDs) != 0 {
+		t.Errorf("marked sent = %v, want none", storage.sentArticleIDs)
+	}
+}
+
+func newTagScoreFixture() (*mockHNClient, *mockSummarizer) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Go Article", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Gardening Article", URL: "https://example.com/2", Score: 500},
+		},
+	}
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Go Article":        {Summary: "About Go", Tags: []string{"go"}},
+			"Gardening Article": {Summary: "About plants", Tags: []string{"gardening"}},
+		},
+	}
+	return hnClient, summarizer
+}
+
+func TestRunDigestMinTagScoreWarm(t *testing.T) {
+	hnClient, summarizer := newTagScoreFixture()
+
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 2.0
+	for id := int64(100); id < 105; id++ {
+		storage.likedArticles[id] = true
+	}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, summarizer, storage, sender,
 		WithChatID(12345),
 		WithArticleCount(2),
+		WithDecayRate(0),
+		WithMinTagScore(1.0, 5),
 	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
 
-	ctx := context.Background()
-	runner.Run(ctx)
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].ID != 1 {
+		t.Errorf("sent %v, want only the matching Go article", sentIDs(sender))
+	}
+}
 
-	// With summarizer failing, no articles should be sent
-	if len(sender.sentArticles) > 0 {
-		t.Error("No articles should be sent when summarizer fails")
+func TestRunDigestMinTagScoreCold(t *testing.T) {
+	hnClient, summarizer := newTagScoreFixture()
+
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 2.0
+	storage.likedArticles[100] = true // Below the 5-like threshold
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, summarizer, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(2),
+		WithMinTagScore(1.0, 5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(sender.sentArticles) != 2 {
+		t.Errorf("sent %v, want both articles during cold start", sentIDs(sender))
+	}
+}
+
+func TestRunDigestDomainWeights(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Article 1", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Article 2", URL: "https://www.favorite.dev/2", Score: 100},
+		},
+	}
+
+	storage := newMockStorage()
+	storage.domainWeights["favorite.dev"] = 3.0
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(2),
+		WithDecayRate(0.5),
+		WithMinTagWeight(0.1),
+		WithDomainWeightFactor(0.5),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Domain weights decay with tags: 3.0 * 0.5 = 1.5
+	if w := storage.domainWeights["favorite.dev"]; w != 1.5 {
+		t.Errorf("favorite.dev weight = %f, want 1.5", w)
+	}
+
+	ids := sentIDs(sender)
+	if len(ids) != 2 || ids[0] != 2 {
+		t.Errorf("sent %v, want the preferred domain first", ids)
+	}
+}
+
+func TestRunDigestPinnedTags(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Go Article", URL: "https://example.com/1", Score: 500},
+			2: {ID: 2, Title: "Rust Article", URL: "https://example.com/2", Score: 400},
+			3: {ID: 3, Title: "Security Article", URL: "https://example.com/3", Score: 10},
+		},
+	}
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Go Article":       {Summary: "About Go", Tags: []string{"go"}},
+			"Rust Article":     {Summary: "About Rust", Tags: []string{"rust"}},
+			"Security Article": {Summary: "About a CVE", Tags: []string{"Security"}},
+		},
+	}
+
+	run := func(opts ...Option) []int64 {
+		storage := newMockStorage()
+		storage.tagWeights["go"] = 2.0
+		storage.tagWeights["rust"] = 1.5
+		sender := &mockArticleSender{}
+		runner := NewRunner(hnClient, &mockScraper{}, summarizer, storage, sender,
+			append([]Option{WithChatID(12345), WithArticleCount(2), WithDecayRate(0)}, opts...)...)
+		if err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return sentIDs(sender)
+	}
+
+	if ids := run(); !slices.Equal(ids, []int64{1, 2}) {
+		t.Fatalf("sent %v without pins, want [1 2]", ids)
+	}
+
+	// The low-scoring security article takes the place of the Rust one
+	if ids := run(WithPinnedTags("security")); !slices.Equal(ids, []int64{1, 3}) {
+		t.Errorf("sent %v with security pinned, want [1 3]", ids)
 	}
+
+	// A pin already satisfied by the selection changes nothing
+	if ids := run(WithPinnedTags("go", "security")); !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("sent %v with go pinned, want [1 2]", ids)
+	}
+
+	// A pin no candidate matches changes nothing
+	if ids := run(WithPinnedTags("gardening")); !slices.Equal(ids, []int64{1, 2}) {
+		t.Errorf("sent %v with gardening pinned, want [1 2]", ids)
+	}
+}
+
+func TestIncludePinnedAddsWhenThereIsRoom(t *testing.T) {
+	ranked := []ranker.RankedArticle{
+		{RankableArticle: ranker.RankableArticle{ID: 1, Tags: []string{"go"}}, FinalScore: 3},
+		{RankableArticle: ranker.RankableArticle{ID: 2, Tags: []string{"security"}}, FinalScore: 1},
+	}
+	runner := NewRunner(&mockHNClient{}, &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithArticleCount(5), WithPinnedTags("security"))
+
+	// The pinned article was filtered out before selection
+	selected := runner.includePinned(ranked, ranked[:1])
+	if len(selected) != 2 || selected[0].ID != 1 || selected[1].ID != 2 {
+		t.Errorf("selected %v, want [1 2]", selected)
+	}
+}
+
+func sentIDs(sender *mockArticleSender) []int64 {
+	ids := make([]int64, len(sender.sentArticles))
+	for i, a := range sender.sentArticles {
+		ids[i] = a.ID
+	}
+	return ids
 }
 
 func TestRunDigestNoChatID(t *testing.T) {
@@ -379,3 +2477,149 @@ func TestProcessedArticle(t *testing.T) {
 		t.Error("expected 2 tags")
 	}
 }
+
+func TestRunDigestSpacesSends(t *testing.T) {
+	sender := &mockArticleSender{}
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithSendDelay(200*time.Millisecond, 100*time.Millisecond),
+	)
+
+	var waits []time.Duration
+	var sentBeforeWait []int
+	runner.wait = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		sentBeforeWait = append(sentBeforeWait, len(sender.sentArticles))
+		return nil
+	}
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(sender.sentArticles) != 3 {
+		t.Fatalf("sent %d articles, want 3", len(sender.sentArticles))
+	}
+	// One pause between each pair of sends and none after the last
+	if len(waits) != 2 {
+		t.Fatalf("waits = %v, want 2", waits)
+	}
+	for i, d := range waits {
+		if d < 200*time.Millisecond || d > 300*time.Millisecond {
+			t.Errorf("wait %d = %v, want between 200ms and 300ms", i, d)
+		}
+		if sentBeforeWait[i] != i+1 {
+			t.Errorf("wait %d happened after %d sends, want %d", i, sentBeforeWait[i], i+1)
+		}
+	}
+}
+
+func TestRunDigestSendDelayCancellable(t *testing.T) {
+	sender := &mockArticleSender{}
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithSendDelay(time.Hour, 0),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := runner.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run error = %v, want context.DeadlineExceeded", err)
+	}
+	if len(sender.sentArticles) != 1 {
+		t.Errorf("sent %d articles before cancellation, want 1", len(sender.sentArticles))
+	}
+}
+
+func TestRunDigestLogsRankingAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(prev)
+
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 2.0
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Article 1": {Summary: "Summary 1", Tags: []string{"go"}},
+		},
+	}
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, summarizer, storage, &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(2),
+		WithDecayRate(0),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	type rankedRecord struct {
+		Msg     string `json:"msg"`
+		Article struct {
+			Rank        int      `json:"rank"`
+			ID          int64    `json:"id"`
+			HNScore     int      `json:"hn_score"`
+			HNComponent float64  `json:"hn_component"`
+			MatchedTags []string `json:"matched_tags"`
+			TagScore    float64  `json:"tag_score"`
+			FinalScore  float64  `json:"final_score"`
+		} `json:"article"`
+	}
+	var records []rankedRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec rankedRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if rec.Msg == "ranked candidate" {
+			records = append(records, rec)
+		}
+	}
+
+	// Every candidate is logged, not just those sent, in rank order
+	if len(records) != 3 {
+		t.Fatalf("ranked candidate records = %d, want 3", len(records))
+	}
+	for i, rec := range records {
+		if rec.Article.Rank != i+1 {
+			t.Errorf("record %d rank = %d, want %d", i, rec.Article.Rank, i+1)
+		}
+		if i > 0 && rec.Article.FinalScore > records[i-1].Article.FinalScore {
+			t.Errorf("records not in score order: %v after %v", rec.Article.FinalScore, records[i-1].Article.FinalScore)
+		}
+	}
+
+	top := records[0].Article
+	if top.ID != 1 || top.HNScore != 100 || top.TagScore != 2.0 || top.HNComponent == 0 || top.FinalScore == 0 {
+		t.Errorf("top record = %+v, want article 1 with its score components", top)
+	}
+	if len(top.MatchedTags) != 1 || top.MatchedTags[0] != "go=2.00" {
+		t.Errorf("matched tags = %v, want [go=2.00]", top.MatchedTags)
+	}
+}
+
+func TestRunDigestRankingNotLoggedAtInfo(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	defer slog.SetDefault(prev)
+
+	runner := NewRunner(
+		newBatchFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(1),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "ranked candidate") {
+		t.Error("ranking should not be logged at info level")
+	}
+}
@@ -0,0 +1,40 @@
+package digest
+
+// RunStats summarizes a finished digest run.
+type RunStats struct {
+	ChatID     int64   `json:"chat_id"`
+	Trigger    Trigger `json:"trigger"`
+	Candidates int     `json:"candidates"` // Articles scraped and summarized, or reused
+	Selected   int     `json:"selected"`   // Articles picked to send
+	Sent       int     `json:"sent"`       // Articles delivered
+}
+
+// DeliveryObserver is notified of digest deliveries, so that they can be
+// forwarded elsewhere, such as to a log pipeline or a dashboard. Its
+// methods are called synchronously from the run and should return
+// quickly.
+type DeliveryObserver interface {
+	// OnArticleSent is called after each article is delivered, with the
+	// ID of the Telegram message it was sent as.
+	OnArticleSent(article *ArticleToSend, messageID int64)
+	// OnRunComplete is called once at the end of each run that finished
+	// without error, including runs that had nothing to send.
+	OnRunComplete(stats RunStats)
+}
+
+// WithDeliveryObserver sets the observer notified of deliveries. By
+// default deliveries are not reported.
+func WithDeliveryObserver(observer DeliveryObserver) Option {
+	return func(r *Runner) {
+		if observer != nil {
+			r.observer = observer
+		}
+	}
+}
+
+// noopObserver is the default DeliveryObserver.
+type noopObserver struct{}
+
+func (noopObserver) OnArticleSent(article *ArticleToSend, messageID int64) {}
+
+func (noopObserver) OnRunComplete(stats RunStats) {}
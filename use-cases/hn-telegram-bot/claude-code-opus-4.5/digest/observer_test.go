@@ -0,0 +1,99 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type recordingObserver struct {
+	sent       []*ArticleToSend
+	messageIDs []int64
+	runs       []RunStats
+}
+
+func (o *recordingObserver) OnArticleSent(article *ArticleToSend, messageID int64) {
+	o.sent = append(o.sent, article)
+	o.messageIDs = append(o.messageIDs, messageID)
+}
+
+func (o *recordingObserver) OnRunComplete(stats RunStats) {
+	o.runs = append(o.runs, stats)
+}
+
+func observedHNClient(n int) *mockHNClient {
+	hnClient := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= int64(n); id++ {
+		hnClient.topStories = append(hnClient.topStories, id)
+		hnClient.items[id] = &HNItem{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id), Score: 100}
+	}
+	return hnClient
+}
+
+func TestDeliveryObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	sender := &mockArticleSender{}
+	runner := NewRunner(
+		observedHNClient(5), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithTrigger(TriggerOnDemand),
+		WithDeliveryObserver(observer),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(observer.sent) != len(sender.sentArticles) || len(observer.sent) != 3 {
+		t.Fatalf("observed %d sent articles, want one per sent article (%d)", len(observer.sent), len(sender.sentArticles))
+	}
+	for i, a := range observer.sent {
+		if a.ID != sender.sentArticles[i].ID {
+			t.Errorf("observed article %d = %d, want %d", i, a.ID, sender.sentArticles[i].ID)
+		}
+		if observer.messageIDs[i] != int64(i+1) {
+			t.Errorf("observed message ID %d = %d, want %d", i, observer.messageIDs[i], i+1)
+		}
+	}
+
+	want := RunStats{ChatID: 12345, Trigger: TriggerOnDemand, Candidates: 5, Selected: 3, Sent: 3}
+	if len(observer.runs) != 1 || observer.runs[0] != want {
+		t.Errorf("observed runs = %+v, want one %+v", observer.runs, want)
+	}
+}
+
+func TestDeliveryObserverEmptyRun(t *testing.T) {
+	observer := &recordingObserver{}
+	runner := NewRunner(
+		observedHNClient(0), &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithDeliveryObserver(observer),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(observer.sent) != 0 {
+		t.Errorf("observed %d sent articles, want none", len(observer.sent))
+	}
+	if len(observer.runs) != 1 || observer.runs[0].Sent != 0 {
+		t.Errorf("observed runs = %+v, want one with nothing sent", observer.runs)
+	}
+}
+
+func TestDeliveryObserverFailedRun(t *testing.T) {
+	observer := &recordingObserver{}
+	hnClient := &mockHNClient{fetchError: errors.New("hn unavailable")}
+	runner := NewRunner(
+		hnClient, &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithDeliveryObserver(observer),
+	)
+	if err := runner.Run(context.Background()); err == nil {
+		t.Fatal("expected Run to fail")
+	}
+	if len(observer.runs) != 0 {
+		t.Errorf("observed runs = %+v, want none for a failed run", observer.runs)
+	}
+}
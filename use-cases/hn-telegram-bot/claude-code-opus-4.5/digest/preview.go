@@ -0,0 +1,53 @@
+package digest
+
+import "context"
+
+// PreviewArticle is a ranked story shown by a preview.
+type PreviewArticle struct {
+	ID      int64
+	Title   string
+	HNScore int
+	Tags    []string
+	Score   float64
+}
+
+// Preview ranks the current top stories without scraping, summarizing or
+// sending them. Only articles summarized in earlier runs have tags, so new
+// stories are ranked on HN score and domain alone.
+func (r *Runner) Preview(ctx context.Context) ([]PreviewArticle, error) {
+	ids, err := r.candidateIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := r.fetchItems(ctx, ids)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	items = r.filterByAge(items)
+
+	itemsByID := make(map[int64]*HNItem, len(items))
+	for _, item := range items {
+		itemsByID[item.ID] = item
+	}
+
+	all := r.rankItems(ctx, items)
+	ranked := all
+	if len(ranked) > r.articleCount {
+		ranked = ranked[:r.articleCount]
+	}
+	ranked = r.includePinned(all, ranked)
+
+	preview := make([]PreviewArticle, len(ranked))
+	for i, ra := range ranked {
+		item := itemsByID[ra.ID]
+		preview[i] = PreviewArticle{
+			ID:      item.ID,
+			Title:   item.Title,
+			HNScore: item.Score,
+			Tags:    ra.Tags,
+			Score:   ra.FinalScore,
+		}
+	}
+	return preview, nil
+}
@@ -0,0 +1,58 @@
+package digest
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	hnClient := &mockHNClient{
+		topStories: []int64{1, 2, 3},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Go Article", URL: "https://example.com/1", Score: 100},
+			2: {ID: 2, Title: "Popular Article", URL: "https://example.com/2", Score: 150},
+			3: {ID: 3, Title: "Sent Article", URL: "https://example.com/3", Score: 900},
+		},
+	}
+
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 3.0
+	storage.articles[1] = &StoredArticle{ID: 1, Tags: []string{"go"}} // Summarized earlier
+	storage.recentlySent[12345] = []int64{3}
+
+	scraper := &mockScraper{}
+	summarizer := &mockSummarizer{}
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(hnClient, scraper, summarizer, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(5),
+	)
+	preview, err := runner.Preview(context.Background())
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	if len(preview) != 2 {
+		t.Fatalf("got %d preview articles, want 2 (recently sent excluded)", len(preview))
+	}
+	if preview[0].ID != 1 || preview[0].Title != "Go Article" {
+		t.Errorf("first article = %+v, want the stored Go article ranked by its tags", preview[0])
+	}
+	if len(preview[0].Tags) != 1 || preview[0].Tags[0] != "go" {
+		t.Errorf("first article tags = %v, want [go]", preview[0].Tags)
+	}
+	if len(preview[1].Tags) != 0 {
+		t.Errorf("unsummarized article tags = %v, want none", preview[1].Tags)
+	}
+
+	if len(scraper.scraped) != 0 || len(summarizer.contents) != 0 {
+		t.Error("preview should not scrape or summarize")
+	}
+	if sender.attempts != 0 {
+		t.Errorf("send attempts = %d, want 0", sender.attempts)
+	}
+	if w := storage.tagWeights["go"]; w != 3.0 {
+		t.Errorf("tag weight = %f, want 3.0 (preview should not apply decay)", w)
+	}
+}
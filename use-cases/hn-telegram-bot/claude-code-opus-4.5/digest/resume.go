@@ -0,0 +1,134 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// maxPendingAge is how old an interrupted digest can be and still be
+// resumed. Older articles are stale news by then, so they are dropped.
+const maxPendingAge = 12 * time.Hour
+
+// PendingBatch is the articles of a digest, saved before they are sent so
+// that a run interrupted midway can be resumed.
+type PendingBatch struct {
+	ID        string
+	CreatedAt time.Time
+	Articles  []*ArticleToSend
+}
+
+// BatchStore persists each chat's pending batch.
+type BatchStore interface {
+	// SavePendingBatch records the batch a chat is being sent, replacing
+	// any earlier batch of the chat.
+	SavePendingBatch(ctx context.Context, chatID int64, batch *PendingBatch) error
+	// GetPendingBatch returns the batch a chat was last being sent, or nil
+	// if it has none.
+	GetPendingBatch(ctx context.Context, chatID int64) (*PendingBatch, error)
+	// DeletePendingBatch forgets a sent batch, unless a later batch has
+	// replaced it.
+	DeletePendingBatch(ctx context.Context, chatID int64, batchID string) error
+}
+
+// WithBatchStore saves each digest's articles before sending them, so that
+// Resume can send those an interrupted run didn't. By default batches are
+// not saved.
+func WithBatchStore(store BatchStore) Option {
+	return func(r *Runner) {
+		r.batches = store
+	}
+}
+
+// savePendingBatch saves the articles about to be sent and returns the
+// batch's ID, or "" if they weren't saved. A failure to save only costs
+// the ability to resume, so it is logged.
+func (r *Runner) savePendingBatch(ctx context.Context, articles []*ArticleToSend) string {
+	if r.batches == nil || len(articles) == 0 {
+		return ""
+	}
+	now := r.now()
+	batch := &PendingBatch{
+		ID:        fmt.Sprintf("%d-%d", r.chatID, now.UnixNano()),
+		CreatedAt: now,
+		Articles:  articles,
+	}
+	if err := r.batches.SavePendingBatch(ctx, r.chatID, batch); err != nil {
+		slog.Warn("failed to save pending digest, it can't be resumed if interrupted", "error", err)
+		return ""
+	}
+	return batch.ID
+}
+
+// deletePendingBatch forgets a batch that was sent.
+func (r *Runner) deletePendingBatch(ctx context.Context, batchID string) {
+	if batchID == "" {
+		return
+	}
+	if err := r.batches.DeletePendingBatch(ctx, r.chatID, batchID); err != nil {
+		slog.Warn("failed to delete pending digest", "batch_id", batchID, "error", err)
+	}
+}
+
+// Resume sends the articles of the chat's pending batch that an
+// interrupted run didn't send, and returns how many it sent. Batches older
+// than maxPendingAge are dropped instead. Resumed articles are sent without
+// the section headers, intro or outro of the original run.
+func (r *Runner) Resume(ctx context.Context) (int, error) {
+	if r.batches == nil {
+		return 0, nil
+	}
+	batch, err := r.batches.GetPendingBatch(ctx, r.chatID)
+	if err != nil {
+		return 0, fmt.Errorf("get pending batch: %w", err)
+	}
+	if batch == nil {
+		return 0, nil
+	}
+	if age := r.now().Sub(batch.CreatedAt); age > maxPendingAge {
+		slog.Info("dropping stale pending digest", "batch_id", batch.ID, "age", age)
+		r.deletePendingBatch(ctx, batch.ID)
+		return 0, nil
+	}
+
+	var remaining []*ArticleToSend
+	for _, a := range batch.Articles {
+		sent, err := r.recentlySent(ctx, a.ID)
+		if err != nil {
+			return 0, fmt.Errorf("check sent article %d: %w", a.ID, err)
+		}
+		if !sent {
+			remaining = append(remaining, a)
+		}
+	}
+	slog.Info("resuming interrupted digest", "batch_id", batch.ID,
+		"remaining", len(remaining), "articles", len(batch.Articles))
+
+	sent := 0
+	for i, toSend := range remaining {
+		if err := ctx.Err(); err != nil {
+			return sent, fmt.Errorf("send articles: %w", err)
+		}
+		if i > 0 {
+			if err := r.wait(ctx, r.sendPause()); err != nil {
+				return sent, err
+			}
+		}
+		ok, err := r.deliver(ctx, toSend)
+		if err != nil {
+			// The chat is gone, so there is nothing left to resume
+			r.deletePendingBatch(ctx, batch.ID)
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return sent, fmt.Errorf("send articles: %w", err)
+	}
+	r.deletePendingBatch(ctx, batch.ID)
+	slog.Info("resumed digest sent", "batch_id", batch.ID, "sent", sent)
+	return sent, nil
+}
@@ -0,0 +1,179 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+type mockBatchStore struct {
+	batches map[int64]*PendingBatch
+}
+
+func newMockBatchStore() *mockBatchStore {
+	return &mockBatchStore{batches: make(map[int64]*PendingBatch)}
+}
+
+func (m *mockBatchStore) SavePendingBatch(ctx context.Context, chatID int64, batch *PendingBatch) error {
+	m.batches[chatID] = batch
+	return nil
+}
+
+func (m *mockBatchStore) GetPendingBatch(ctx context.Context, chatID int64) (*PendingBatch, error) {
+	return m.batches[chatID], nil
+}
+
+func (m *mockBatchStore) DeletePendingBatch(ctx context.Context, chatID int64, batchID string) error {
+	if b, ok := m.batches[chatID]; ok && b.ID == batchID {
+		delete(m.batches, chatID)
+	}
+	return nil
+}
+
+// crashingSender stops the run after sending a number of articles, like
+// the process dying midway through a digest.
+type crashingSender struct {
+	mockArticleSender
+	sendsLeft int
+	crash     context.CancelFunc
+}
+
+func (s *crashingSender) SendArticle(ctx context.Context, chatID int64, article *ArticleToSend) (int64, error) {
+	if s.sendsLeft == 0 {
+		s.crash()
+		return 0, ctx.Err()
+	}
+	s.sendsLeft--
+	return s.mockArticleSender.SendArticle(ctx, chatID, article)
+}
+
+func newFiveStoryFixture() *mockHNClient {
+	client := &mockHNClient{items: make(map[int64]*HNItem)}
+	for id := int64(1); id <= 5; id++ {
+		client.topStories = append(client.topStories, id)
+		client.items[id] = &HNItem{
+			ID:    id,
+			Title: fmt.Sprintf("Article %d", id),
+			URL:   fmt.Sprintf("https://example.com/%d", id),
+			Score: int(600 - 100*id),
+		}
+	}
+	return client
+}
+
+func TestResumeAfterCrash(t *testing.T) {
+	storage := newMockStorage()
+	batches := newMockBatchStore()
+
+	ctx, crash := context.WithCancel(context.Background())
+	defer crash()
+	crashing := &crashingSender{sendsLeft: 2, crash: crash}
+	runner := NewRunner(newFiveStoryFixture(), &mockScraper{}, &mockSummarizer{}, storage, crashing,
+		WithChatID(12345),
+		WithArticleCount(5),
+		WithBatchStore(batches),
+	)
+	if err := runner.Run(ctx); err == nil {
+		t.Fatal("expected the interrupted run to fail")
+	}
+	if ids := sentIDs(&crashing.mockArticleSender); !slices.Equal(ids, []int64{1, 2}) {
+		t.Fatalf("sent %v before the crash, want [1 2]", ids)
+	}
+	if batches.batches[12345] == nil {
+		t.Fatal("interrupted run left no pending batch")
+	}
+
+	// After a restart, a new runner sends the remainder
+	sender := &mockArticleSender{}
+	runner = NewRunner(newFiveStoryFixture(), &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithBatchStore(batches),
+	)
+	sent, err := runner.Resume(context.Background())
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if sent != 3 {
+		t.Errorf("Resume sent %d, want 3", sent)
+	}
+	if ids := sentIDs(sender); !slices.Equal(ids, []int64{3, 4, 5}) {
+		t.Errorf("resumed %v, want [3 4 5]", ids)
+	}
+	if !slices.Equal(storage.sentArticleIDs, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("marked sent %v, want all five once", storage.sentArticleIDs)
+	}
+	if len(batches.batches) != 0 {
+		t.Errorf("pending batches = %v, want none once resumed", batches.batches)
+	}
+
+	// Nothing is left to resume
+	if sent, err := runner.Resume(context.Background()); err != nil || sent != 0 {
+		t.Errorf("second Resume = %d, %v, want nothing sent", sent, err)
+	}
+}
+
+func TestRunDigestClearsSentBatch(t *testing.T) {
+	batches := newMockBatchStore()
+	runner := NewRunner(newFiveStoryFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), &mockArticleSender{},
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithBatchStore(batches),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(batches.batches) != 0 {
+		t.Errorf("pending batches = %v, want none after a complete run", batches.batches)
+	}
+}
+
+func TestResumeDropsStaleBatch(t *testing.T) {
+	batches := newMockBatchStore()
+	batches.batches[12345] = &PendingBatch{
+		ID:        "old",
+		CreatedAt: time.Now().Add(-maxPendingAge - time.Hour),
+		Articles:  []*ArticleToSend{{ID: 1, Title: "Article 1"}},
+	}
+	sender := &mockArticleSender{}
+	runner := NewRunner(newFiveStoryFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithBatchStore(batches),
+	)
+
+	if sent, err := runner.Resume(context.Background()); err != nil || sent != 0 {
+		t.Errorf("Resume = %d, %v, want nothing sent", sent, err)
+	}
+	if len(sender.sentArticles) != 0 || len(batches.batches) != 0 {
+		t.Errorf("sent %d articles and kept %v, want the stale batch dropped", len(sender.sentArticles), batches.batches)
+	}
+}
+
+func TestResumeIgnoresSendsToOtherChats(t *testing.T) {
+	storage := newMockStorage()
+	batches := newMockBatchStore()
+	batches.batches[12345] = &PendingBatch{
+		ID:        "batch",
+		CreatedAt: time.Now(),
+		Articles:  []*ArticleToSend{{ID: 1, Title: "Article 1"}, {ID: 2, Title: "Article 2"}},
+	}
+	// Article 1 already reached this chat, article 2 only another one
+	storage.recentlySent[12345] = []int64{1}
+	storage.recentlySent[999] = []int64{2}
+
+	sender := &mockArticleSender{}
+	runner := NewRunner(newFiveStoryFixture(), &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithBatchStore(batches),
+	)
+	if _, err := runner.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if ids := sentIDs(sender); !slices.Equal(ids, []int64{2}) {
+		t.Errorf("resumed %v, want [2]", ids)
+	}
+}
@@ -0,0 +1,95 @@
+package digest
+
+import (
+	"math/rand"
+
+	"hn-telegram-bot/ranker"
+)
+
+// minSampleWeight is the sampling weight given to articles whose score is
+// zero or negative, so that they keep a small chance of being picked.
+const minSampleWeight = 1e-6
+
+// Selector picks the articles to send from the ranked candidates.
+type Selector interface {
+	// Select returns up to count of the candidates, which are in rank
+	// order, keeping that order.
+	Select(candidates []ranker.RankedArticle, count int) []ranker.RankedArticle
+}
+
+// NewSelector returns the selector for a selection mode, TopN for an
+// unknown one.
+func NewSelector(mode SelectionMode) Selector {
+	if mode == SelectSampled {
+		return &Sampled{}
+	}
+	return TopN{}
+}
+
+// TopN selects the highest-ranked articles. It is the default selector.
+type TopN struct{}
+
+// Select returns the first count candidates.
+func (TopN) Select(candidates []ranker.RankedArticle, count int) []ranker.RankedArticle {
+	if len(candidates) <= count {
+		return candidates
+	}
+	return candidates[:count]
+}
+
+// Sampled draws articles at random with probability proportional to their
+// score, so lower-ranked topics occasionally get through.
+type Sampled struct {
+	// Rand is the random source, so that tests can seed it. If nil, the
+	// global source is used.
+	Rand *rand.Rand
+}
+
+// Select draws count of the candidates without replacement: each draw
+// picks one of the remaining candidates with probability proportional to
+// its score.
+func (s *Sampled) Select(candidates []ranker.RankedArticle, count int) []ranker.RankedArticle {
+	if len(candidates) <= count {
+		return candidates
+	}
+
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	for i, a := range candidates {
+		weights[i] = max(a.FinalScore, minSampleWeight)
+		total += weights[i]
+	}
+
+	picked := make([]bool, len(candidates))
+	for range count {
+		target := s.float64() * total
+		chosen := -1
+		for i, w := range weights {
+			if picked[i] {
+				continue
+			}
+			chosen = i
+			if target < w {
+				break
+			}
+			target -= w
+		}
+		picked[chosen] = true
+		total -= weights[chosen]
+	}
+
+	selected := make([]ranker.RankedArticle, 0, count)
+	for i, a := range candidates {
+		if picked[i] {
+			selected = append(selected, a)
+		}
+	}
+	return selected
+}
+
+func (s *Sampled) float64() float64 {
+	if s.Rand != nil {
+		return s.Rand.Float64()
+	}
+	return rand.Float64()
+}
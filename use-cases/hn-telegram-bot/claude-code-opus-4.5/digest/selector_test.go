@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"hn-telegram-bot/ranker"
+)
+
+func sampledRanking() []ranker.RankedArticle {
+	scores := []float64{10, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	ranked := make([]ranker.RankedArticle, len(scores))
+	for i, s := range scores {
+		ranked[i] = ranker.RankedArticle{RankableArticle: ranker.RankableArticle{ID: int64(i + 1)}, FinalScore: s}
+	}
+	return ranked
+}
+
+func TestTopNSelector(t *testing.T) {
+	selected := TopN{}.Select(sampledRanking(), 3)
+	if len(selected) != 3 || selected[0].ID != 1 || selected[1].ID != 2 || selected[2].ID != 3 {
+		t.Errorf("selected %v, want the top 3", selected)
+	}
+
+	if selected := (TopN{}).Select(sampledRanking()[:2], 3); len(selected) != 2 {
+		t.Errorf("selected %d of 2 candidates, want both", len(selected))
+	}
+}
+
+func TestNewSelector(t *testing.T) {
+	if _, ok := NewSelector(SelectTopN).(TopN); !ok {
+		t.Error("topn mode should select the top N")
+	}
+	if _, ok := NewSelector(SelectSampled).(*Sampled); !ok {
+		t.Error("sampled mode should sample")
+	}
+}
+
+func TestSampledSelectorIsDeterministicWithSeed(t *testing.T) {
+	selectIDs := func() []int64 {
+		selector := &Sampled{Rand: rand.New(rand.NewSource(42))}
+		var ids []int64
+		for _, a := range selector.Select(sampledRanking(), 4) {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	first, second := selectIDs(), selectIDs()
+	if len(first) != 4 {
+		t.Fatalf("selected %v, want 4 articles", first)
+	}
+	if !slices.Equal(first, second) {
+		t.Errorf("selections with the same seed differ: %v vs %v", first, second)
+	}
+	if !slices.IsSorted(first) {
+		t.Errorf("selected %v, want rank order", first)
+	}
+}
+
+func TestSampledSelectorFavorsHighScores(t *testing.T) {
+	selector := &Sampled{Rand: rand.New(rand.NewSource(1))}
+
+	const trials = 2000
+	counts := make(map[int64]int)
+	for range trials {
+		counts[selector.Select(sampledRanking(), 1)[0].ID]++
+	}
+
+	// Article 1 has 10 of the 19 total score, so it should win about half
+	// the draws; each of the others about 1 in 19
+	if got := float64(counts[1]) / trials; got < 0.45 || got > 0.6 {
+		t.Errorf("top article picked %.2f of the time, want about 0.53", got)
+	}
+	for id := int64(2); id <= 10; id++ {
+		if counts[id] == 0 || counts[id] >= counts[1] {
+			t.Errorf("article %d picked %d times, want occasionally but less than the top article (%d)", id, counts[id], counts[1])
+		}
+	}
+}
+
+// lastSelector picks the lowest-ranked candidates.
+type lastSelector struct {
+	calls int
+}
+
+func (s *lastSelector) Select(candidates []ranker.RankedArticle, count int) []ranker.RankedArticle {
+	s.calls++
+	return candidates[max(0, len(candidates)-count):]
+}
+
+func TestRunDigestUsesSelector(t *testing.T) {
+	selector := &lastSelector{}
+	sender := &mockArticleSender{}
+	runner := NewRunner(newBatchFixture(), &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(1),
+		WithSelector(selector),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if selector.calls != 1 {
+		t.Errorf("selector called %d times, want once", selector.calls)
+	}
+	// Article 1 has the lowest HN score, so it ranks last
+	if ids := sentIDs(sender); !slices.Equal(ids, []int64{1}) {
+		t.Errorf("sent %v, want the selector's pick [1]", ids)
+	}
+}
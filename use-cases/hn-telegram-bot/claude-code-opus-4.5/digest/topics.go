@@ -0,0 +1,61 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+
+	"hn-telegram-bot/ranker"
+)
+
+// OtherTopic is the section of articles that match no learned tag.
+const OtherTopic = "Other"
+
+// sectionMarkers set topic sections apart, in section order. The Other
+// section always uses otherMarker.
+var sectionMarkers = []string{"🟦", "🟩", "🟨", "🟧", "🟪", "🟥"}
+
+const otherMarker = "⬜"
+
+// TopicSection is a group of ranked articles sharing a dominant tag.
+type TopicSection struct {
+	Tag      string
+	Score    float64 // Summed final score of the section's articles
+	Articles []ranker.RankedArticle
+}
+
+// GroupByTopic sections ranked articles by their dominant tag: the learned
+// tag with the highest weight, or OtherTopic if none matched. Sections are
+// ordered by their summed score, and keep their articles in rank order.
+func GroupByTopic(ranked []ranker.RankedArticle) []TopicSection {
+	var sections []TopicSection
+	index := make(map[string]int)
+	for _, a := range ranked {
+		tag := OtherTopic
+		if len(a.MatchedTags) > 0 {
+			tag = a.MatchedTags[0].Tag
+		}
+		i, ok := index[tag]
+		if !ok {
+			i = len(sections)
+			index[tag] = i
+			sections = append(sections, TopicSection{Tag: tag})
+		}
+		sections[i].Score += a.FinalScore
+		sections[i].Articles = append(sections[i].Articles, a)
+	}
+
+	// Sections with equal scores keep the order of their best article
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Score > sections[j].Score
+	})
+	return sections
+}
+
+// sectionHeader returns the header announcing the section at index.
+func sectionHeader(index int, tag string) string {
+	marker := otherMarker
+	if tag != OtherTopic {
+		marker = sectionMarkers[index%len(sectionMarkers)]
+	}
+	return fmt.Sprintf("%s %s", marker, tag)
+}
@@ -0,0 +1,84 @@
+package digest
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hn-telegram-bot/ranker"
+)
+
+func rankedWithTags(id int64, score float64, tags ...string) ranker.RankedArticle {
+	a := ranker.RankedArticle{FinalScore: score}
+	a.ID = id
+	for i, tag := range tags {
+		a.MatchedTags = append(a.MatchedTags, ranker.TagContribution{Tag: tag, Weight: float64(len(tags) - i)})
+	}
+	return a
+}
+
+func TestGroupByTopic(t *testing.T) {
+	ranked := []ranker.RankedArticle{
+		rankedWithTags(1, 5.0, "go", "ai"),
+		rankedWithTags(2, 4.0, "ai"),
+		rankedWithTags(3, 3.5, "ai", "go"),
+		rankedWithTags(4, 3.0),
+		rankedWithTags(5, 2.0, "go"),
+		rankedWithTags(6, 1.0),
+	}
+
+	sections := GroupByTopic(ranked)
+
+	want := []struct {
+		tag   string
+		score float64
+		ids   []int64
+	}{
+		{"ai", 7.5, []int64{2, 3}},
+		{"go", 7.0, []int64{1, 5}},
+		{OtherTopic, 4.0, []int64{4, 6}},
+	}
+	if len(sections) != len(want) {
+		t.Fatalf("got %d sections, want %d", len(sections), len(want))
+	}
+	for i, w := range want {
+		s := sections[i]
+		var ids []int64
+		for _, a := range s.Articles {
+			ids = append(ids, a.ID)
+		}
+		if s.Tag != w.tag || s.Score != w.score || !slices.Equal(ids, w.ids) {
+			t.Errorf("section %d = %s (%.1f) %v, want %s (%.1f) %v", i, s.Tag, s.Score, ids, w.tag, w.score, w.ids)
+		}
+	}
+}
+
+func TestRunDigestTopicSections(t *testing.T) {
+	summarizer := &mockSummarizer{
+		results: map[string]*SummaryResult{
+			"Article 1": {Summary: "Summary 1", Tags: []string{"rust"}},
+			"Article 2": {Summary: "Summary 2", Tags: []string{"go"}},
+			"Article 3": {Summary: "Summary 3", Tags: []string{"go"}},
+		},
+	}
+	storage := newMockStorage()
+	storage.tagWeights["go"] = 2.0
+	storage.tagWeights["rust"] = 1.0
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(newBatchFixture(), &mockScraper{}, summarizer, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(3),
+		WithTopicSections(true),
+	)
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if want := []string{"🟦 go", "🟩 rust"}; !slices.Equal(sender.notices, want) {
+		t.Errorf("section headers = %q, want %q", sender.notices, want)
+	}
+	if ids := sentIDs(sender); !slices.Equal(ids, []int64{3, 2, 1}) {
+		t.Errorf("sent %v, want the go articles before the rust one", ids)
+	}
+}
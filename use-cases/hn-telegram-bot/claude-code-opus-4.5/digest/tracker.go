@@ -0,0 +1,53 @@
+package digest
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker tracks in-flight digest runs so that shutdown can wait for them
+// to finish instead of aborting a digest midway through sending.
+type Tracker struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// Start registers a new run. It returns false once shutdown has begun,
+// in which case the caller must not run the digest.
+func (t *Tracker) Start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+// Done marks a run started with Start as finished.
+func (t *Tracker) Done() {
+	t.wg.Done()
+}
+
+// Shutdown stops accepting new runs and waits up to grace for in-flight
+// runs to finish. It reports whether all runs finished in time.
+func (t *Tracker) Shutdown(grace time.Duration) bool {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(grace):
+		return false
+	}
+}
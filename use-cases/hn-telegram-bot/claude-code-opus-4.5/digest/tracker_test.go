@@ -0,0 +1,66 @@
+package digest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTrackerWaitsForInFlightRun(t *testing.T) {
+	tracker := &Tracker{}
+
+	// The polling context is cancelled on shutdown, but the run uses its own
+	pollCtx, cancelPoll := context.WithCancel(context.Background())
+
+	var finished atomic.Bool
+	started := make(chan struct{})
+	go func() {
+		if !tracker.Start() {
+			t.Error("Start should succeed before shutdown")
+			close(started)
+			return
+		}
+		defer tracker.Done()
+		close(started)
+
+		time.Sleep(100 * time.Millisecond) // Simulated slow send
+		finished.Store(true)
+	}()
+
+	<-started
+	cancelPoll()
+	<-pollCtx.Done()
+
+	if !tracker.Shutdown(2 * time.Second) {
+		t.Fatal("Shutdown should report the run finished within the grace period")
+	}
+	if !finished.Load() {
+		t.Error("in-flight run should have been allowed to finish")
+	}
+}
+
+func TestTrackerGracePeriodExceeded(t *testing.T) {
+	tracker := &Tracker{}
+	tracker.Start()
+	defer tracker.Done()
+
+	start := time.Now()
+	if tracker.Shutdown(50 * time.Millisecond) {
+		t.Fatal("Shutdown should report a run still in flight")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown waited %v, should give up after the grace period", elapsed)
+	}
+}
+
+func TestTrackerRejectsRunsAfterShutdown(t *testing.T) {
+	tracker := &Tracker{}
+
+	if !tracker.Shutdown(time.Second) {
+		t.Fatal("Shutdown with no runs should succeed immediately")
+	}
+	if tracker.Start() {
+		t.Error("Start should fail after shutdown")
+	}
+}
@@ -0,0 +1,102 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SourceSinceLast identifies articles fetched as new since the previous
+// digest, in WithSinceLastDigest mode.
+const SourceSinceLast = "since_last"
+
+// lastDigestSetting stores when the last digest that sent articles started,
+// in WithSinceLastDigest mode.
+const lastDigestSetting = "last_digest_at"
+
+// defaultDigestWindow is how far back the first digest in
+// WithSinceLastDigest mode looks, with no previous digest to start from.
+const defaultDigestWindow = 24 * time.Hour
+
+// lateRiserWindow is how long before the previous digest a story may have
+// been posted and still be fetched in WithSinceLastDigest mode, so that a
+// story that reached minScore only after that digest isn't missed. Stories
+// already sent are dropped as usual.
+const lateRiserWindow = 12 * time.Hour
+
+// WindowedHNClient finds stories posted within a time window.
+type WindowedHNClient interface {
+	// GetStoriesSince returns up to limit IDs of stories posted after
+	// since with at least minScore points, highest scored first.
+	GetStoriesSince(ctx context.Context, since time.Time, minScore, limit int) ([]int64, error)
+}
+
+// WithSinceLastDigest fetches the stories posted since the previous digest,
+// or shortly before it, with at least minScore points, instead of the
+// current top stories, so that a digest covers what is new since the last
+// one. The first digest looks back a day. A nil client disables it.
+func WithSinceLastDigest(client WindowedHNClient, minScore int) Option {
+	return func(r *Runner) {
+		r.windowed = client
+		r.windowMinScore = minScore
+	}
+}
+
+// fetchStoryIDs fetches up to limit candidate story IDs: the stories new
+// since the last digest in WithSinceLastDigest mode, the top stories
+// otherwise.
+func (r *Runner) fetchStoryIDs(ctx context.Context, limit int) ([]int64, error) {
+	if r.windowed == nil {
+		ids, err := r.hnClient.GetTopStories(ctx, limit)
+		if err != nil {
+			return nil, fmt.Errorf("fetch top stories: %w", err)
+		}
+		return ids, nil
+	}
+
+	since := r.now().Add(-defaultDigestWindow)
+	if last, ok := r.lastDigestTime(ctx); ok {
+		since = last.Add(-lateRiserWindow)
+	}
+	ids, err := r.windowed.GetStoriesSince(ctx, since, r.windowMinScore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("fetch stories since %s: %w", since.Format(time.RFC3339), err)
+	}
+	slog.Info("fetched stories since last digest", "since", since, "min_score", r.windowMinScore)
+	return ids, nil
+}
+
+// lastDigestTime returns when the last digest started, and false if none
+// is recorded.
+func (r *Runner) lastDigestTime(ctx context.Context) (time.Time, bool) {
+	value, err := r.storage.GetSetting(ctx, lastDigestSetting)
+	if err != nil {
+		return time.Time{}, false
+	}
+	last, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		slog.Warn("invalid last digest time, looking back a day", "value", value, "error", err)
+		return time.Time{}, false
+	}
+	return last, true
+}
+
+// recordDigestTime records startedAt as the start of the next digest's
+// window, in WithSinceLastDigest mode.
+func (r *Runner) recordDigestTime(ctx context.Context, startedAt time.Time) {
+	if r.windowed == nil {
+		return
+	}
+	if err := r.storage.SetSetting(ctx, lastDigestSetting, startedAt.UTC().Format(time.RFC3339)); err != nil {
+		slog.Warn("failed to record digest time", "error", err)
+	}
+}
+
+// source returns the story source of the articles this run fetches.
+func (r *Runner) source() string {
+	if r.windowed != nil {
+		return SourceSinceLast
+	}
+	return SourceTop
+}
@@ -0,0 +1,106 @@
+package digest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mockWindowedClient searches the items of a mockHNClient by posting time
+// and score.
+type mockWindowedClient struct {
+	*mockHNClient
+	since []time.Time
+}
+
+func (m *mockWindowedClient) GetStoriesSince(ctx context.Context, since time.Time, minScore, limit int) ([]int64, error) {
+	m.since = append(m.since, since)
+	var ids []int64
+	for _, id := range slices.Sorted(maps.Keys(m.items)) {
+		if item := m.items[id]; item.Time.After(since) && item.Score >= minScore {
+			ids = append(ids, id)
+		}
+	}
+	return ids[:min(len(ids), limit)], nil
+}
+
+func newWindowFixture(last time.Time) *mockWindowedClient {
+	client := &mockHNClient{
+		topStories: []int64{1},
+		items: map[int64]*HNItem{
+			1: {ID: 1, Title: "Rose after the last digest", URL: "https://example.com/1", Score: 500, Time: last.Add(-time.Hour)},
+			2: {ID: 2, Title: "New and popular", URL: "https://example.com/2", Score: 200, Time: last.Add(time.Hour)},
+			3: {ID: 3, Title: "New but unnoticed", URL: "https://example.com/3", Score: 10, Time: last.Add(2 * time.Hour)},
+			4: {ID: 4, Title: "New this morning", URL: "https://example.com/4", Score: 80, Time: last.Add(22 * time.Hour)},
+			5: {ID: 5, Title: "Before the window", URL: "https://example.com/5", Score: 500, Time: last.Add(-lateRiserWindow - time.Hour)},
+		},
+	}
+	return &mockWindowedClient{mockHNClient: client}
+}
+
+func TestRunDigestSinceLastDigest(t *testing.T) {
+	last := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := last.Add(24 * time.Hour)
+	client := newWindowFixture(last)
+	storage := newMockStorage()
+	storage.settings[lastDigestSetting] = last.Format(time.RFC3339)
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(client.mockHNClient, &mockScraper{}, &mockSummarizer{}, storage, sender,
+		WithChatID(12345),
+		WithArticleCount(5),
+		WithSinceLastDigest(client, 50),
+	)
+	runner.now = func() time.Time { return now }
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if want := last.Add(-lateRiserWindow); len(client.since) != 1 || !client.since[0].Equal(want) {
+		t.Errorf("searched since %v, want %v", client.since, want)
+	}
+	var sent []int64
+	for _, a := range sender.sentArticles {
+		sent = append(sent, a.ID)
+	}
+	slices.Sort(sent)
+	// Story 1 was posted just before the last digest, but may only have
+	// reached enough points since
+	if !slices.Equal(sent, []int64{1, 2, 4}) {
+		t.Errorf("sent %v, want the stories new since the last digest with enough points [1 2 4]", sent)
+	}
+	for _, source := range storage.sentSources {
+		if source != SourceSinceLast {
+			t.Errorf("sent source = %q, want %q", source, SourceSinceLast)
+		}
+	}
+	if got := storage.settings[lastDigestSetting]; got != now.Format(time.RFC3339) {
+		t.Errorf("recorded last digest time = %q, want %q", got, now.Format(time.RFC3339))
+	}
+}
+
+func TestRunDigestSinceLastDigestFirstRun(t *testing.T) {
+	last := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := last.Add(30 * time.Hour)
+	client := newWindowFixture(last)
+	sender := &mockArticleSender{}
+
+	runner := NewRunner(client.mockHNClient, &mockScraper{}, &mockSummarizer{}, newMockStorage(), sender,
+		WithChatID(12345),
+		WithArticleCount(5),
+		WithSinceLastDigest(client, 50),
+	)
+	runner.now = func() time.Time { return now }
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if want := now.Add(-defaultDigestWindow); len(client.since) != 1 || !client.since[0].Equal(want) {
+		t.Errorf("searched since %v, want a day back %v", client.since, want)
+	}
+	if len(sender.sentArticles) != 1 || sender.sentArticles[0].ID != 4 {
+		t.Errorf("sent %d articles, want only story 4 from the last day", len(sender.sentArticles))
+	}
+}
@@ -0,0 +1,104 @@
+// Package health serves liveness and readiness probes over HTTP, for
+// container orchestrators, and optionally an authenticated endpoint that
+// triggers a digest.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// checkTimeout bounds each readiness check, so that a hung dependency fails
+// the probe instead of stalling it.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is ready, returning nil if it is.
+type Check func(ctx context.Context) error
+
+// Handler serves /healthz and /readyz, and /trigger-digest if enabled.
+type Handler struct {
+	mux        *http.ServeMux
+	checks     map[string]Check
+	heartbeat  *Heartbeat // Nil unless liveness follows a heartbeat
+	maxBeatAge time.Duration
+}
+
+// response is the JSON body of the probes, and of failed triggers.
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// NewHandler creates a handler whose readiness endpoint runs the given
+// checks, keyed by component name.
+func NewHandler(checks map[string]Check, opts ...Option) *Handler {
+	h := &Handler{mux: http.NewServeMux(), checks: checks}
+	h.mux.HandleFunc("GET /healthz", h.live)
+	h.mux.HandleFunc("GET /readyz", h.ready)
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// ready runs every check and reports 503 if any of them fails.
+func (h *Handler) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	resp := response{Status: "ok", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+	for _, name := range names {
+		if err := h.checks[name](ctx); err != nil {
+			slog.Warn("readiness check failed", "check", name, "error", err)
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = "ok"
+	}
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Start listens on addr and serves handler in the background. Listening
+// happens before Start returns, so a bad or busy address is reported
+// straight away. The returned server's Addr is the address listened on.
+func Start(addr string, handler http.Handler) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			slog.Error("health server stopped", "error", err)
+		}
+	}()
+	return srv, nil
+}
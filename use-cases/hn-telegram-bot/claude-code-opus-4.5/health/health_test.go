@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hn-telegram-bot/storage"
+)
+
+func newTestDB(t *testing.T) *storage.DB {
+	t.Helper()
+	db, err := storage.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func get(t *testing.T, h http.Handler, path string) (int, response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var resp response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode %s response: %v", path, err)
+	}
+	return rec.Code, resp
+}
+
+func TestHealthy(t *testing.T) {
+	db := newTestDB(t)
+	h := NewHandler(map[string]Check{
+		"database": db.Ping,
+		"telegram": func(ctx context.Context) error { return nil },
+	})
+
+	if code, resp := get(t, h, "/healthz"); code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("/healthz = %d %+v, want 200 ok", code, resp)
+	}
+
+	code, resp := get(t, h, "/readyz")
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("/readyz = %d %+v, want 200 ok", code, resp)
+	}
+	if resp.Checks["database"] != "ok" || resp.Checks["telegram"] != "ok" {
+		t.Errorf("checks = %v, want all ok", resp.Checks)
+	}
+}
+
+func TestLiveFailsWithStaleHeartbeat(t *testing.T) {
+	heartbeat := NewHeartbeat()
+	h := NewHandler(nil, WithHeartbeat(heartbeat, 20*time.Millisecond))
+
+	if code, resp := get(t, h, "/healthz"); code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("/healthz = %d %+v, want 200 ok with a fresh heartbeat", code, resp)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	code, resp := get(t, h, "/healthz")
+	if code != http.StatusServiceUnavailable || resp.Status != "stale" || resp.Error == "" {
+		t.Errorf("/healthz = %d %+v, want 503 stale", code, resp)
+	}
+
+	heartbeat.Beat()
+	if code, resp := get(t, h, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d %+v, want 200 once the heartbeat beats again", code, resp)
+	}
+}
+
+func TestReadyFailsWithClosedStore(t *testing.T) {
+	db := newTestDB(t)
+	db.Close()
+	h := NewHandler(map[string]Check{
+		"database": db.Ping,
+		"telegram": func(ctx context.Context) error { return nil },
+	})
+
+	code, resp := get(t, h, "/readyz")
+	if code != http.StatusServiceUnavailable || resp.Status != "unavailable" {
+		t.Errorf("/readyz = %d %+v, want 503 unavailable", code, resp)
+	}
+	if resp.Checks["database"] == "ok" || resp.Checks["telegram"] != "ok" {
+		t.Errorf("checks = %v, want only the database failing", resp.Checks)
+	}
+
+	// Liveness doesn't depend on the store
+	if code, _ := get(t, h, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", code)
+	}
+}
+
+func TestReadyFailsUntilTelegramInitialized(t *testing.T) {
+	h := NewHandler(map[string]Check{
+		"telegram": func(ctx context.Context) error { return errors.New("not initialized") },
+	})
+
+	code, resp := get(t, h, "/readyz")
+	if code != http.StatusServiceUnavailable || resp.Checks["telegram"] != "not initialized" {
+		t.Errorf("/readyz = %d %+v, want 503 with the telegram error", code, resp)
+	}
+}
+
+func TestStart(t *testing.T) {
+	srv, err := Start("127.0.0.1:0", NewHandler(nil))
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + srv.Addr + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+
+	if _, err := Start(srv.Addr, NewHandler(nil)); err == nil {
+		t.Error("expected error for an address already in use")
+	}
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat records when a long-running loop last made progress. It is
+// safe for concurrent use.
+type Heartbeat struct {
+	last atomic.Int64 // Unix nanoseconds
+}
+
+// NewHeartbeat creates a heartbeat that last beat now, so that a loop gets
+// the same allowance to start as it has between beats.
+func NewHeartbeat() *Heartbeat {
+	b := &Heartbeat{}
+	b.Beat()
+	return b
+}
+
+// Beat records that the loop made progress.
+func (b *Heartbeat) Beat() {
+	b.last.Store(time.Now().UnixNano())
+}
+
+// Last returns when the loop last made progress.
+func (b *Heartbeat) Last() time.Time {
+	return time.Unix(0, b.last.Load())
+}
+
+// WithHeartbeat fails /healthz with 503 once heartbeat hasn't beaten for
+// maxAge, so that an orchestrator restarts a process whose loop is stuck
+// even though it still serves requests. A nil heartbeat leaves /healthz
+// always succeeding.
+func WithHeartbeat(heartbeat *Heartbeat, maxAge time.Duration) Option {
+	return func(h *Handler) {
+		if heartbeat == nil {
+			return
+		}
+		h.heartbeat, h.maxBeatAge = heartbeat, maxAge
+	}
+}
+
+// live reports that the process is up and, if it has a heartbeat, that
+// the heartbeat is recent.
+func (h *Handler) live(w http.ResponseWriter, r *http.Request) {
+	if h.heartbeat != nil {
+		if age := time.Since(h.heartbeat.Last()); age > h.maxBeatAge {
+			msg := fmt.Sprintf("no heartbeat for %s", age.Round(time.Second))
+			writeJSON(w, http.StatusServiceUnavailable, response{Status: "stale", Error: msg})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, response{Status: "ok"})
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// TriggerHeader carries the shared secret that authorizes a request to
+// POST /trigger-digest.
+const TriggerHeader = "X-Trigger-Secret"
+
+// TriggerFunc runs a digest and returns its stats, which are encoded as
+// the JSON response.
+type TriggerFunc func(ctx context.Context) (any, error)
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithTrigger serves POST /trigger-digest, which runs trigger for requests
+// carrying secret in TriggerHeader and rejects all others. An empty secret
+// leaves the endpoint disabled, so that it is never open to anyone. Only
+// one triggered digest runs at a time; a request made while one is running
+// gets 409 Conflict.
+func WithTrigger(secret string, trigger TriggerFunc) Option {
+	return func(h *Handler) {
+		if secret == "" || trigger == nil {
+			return
+		}
+		var running sync.Mutex
+		h.mux.HandleFunc("POST /trigger-digest", func(w http.ResponseWriter, r *http.Request) {
+			h.triggerDigest(w, r, secret, &running, trigger)
+		})
+	}
+}
+
+// triggerDigest runs a digest once the request is authenticated, unless
+// running is held by a digest already underway. The run is synchronous, so
+// the response reports how it went.
+func (h *Handler) triggerDigest(w http.ResponseWriter, r *http.Request, secret string, running *sync.Mutex, trigger TriggerFunc) {
+	given := r.Header.Get(TriggerHeader)
+	if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+		slog.Warn("rejected digest trigger", "remote_addr", r.RemoteAddr)
+		writeJSON(w, http.StatusUnauthorized, response{Status: "unauthorized"})
+		return
+	}
+
+	if !running.TryLock() {
+		slog.Info("digest trigger rejected: a digest is already running", "remote_addr", r.RemoteAddr)
+		writeJSON(w, http.StatusConflict, response{Status: "busy", Error: "a digest is already running"})
+		return
+	}
+	defer running.Unlock()
+
+	slog.Info("digest triggered over HTTP", "remote_addr", r.RemoteAddr)
+	stats, err := trigger(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, response{Status: "error", Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,140 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeStats struct {
+	Sent int `json:"sent"`
+}
+
+func post(h http.Handler, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/trigger-digest", nil)
+	if secret != "" {
+		req.Header.Set(TriggerHeader, secret)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTriggerDigest(t *testing.T) {
+	runs := 0
+	h := NewHandler(nil, WithTrigger("s3cret", func(ctx context.Context) (any, error) {
+		runs++
+		return fakeStats{Sent: 3}, nil
+	}))
+
+	rec := post(h, "s3cret")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if runs != 1 {
+		t.Errorf("digest ran %d times, want once", runs)
+	}
+	var stats fakeStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if stats.Sent != 3 {
+		t.Errorf("stats = %+v, want the run's stats", stats)
+	}
+}
+
+func TestTriggerDigestRejectsUnauthenticated(t *testing.T) {
+	runs := 0
+	h := NewHandler(nil, WithTrigger("s3cret", func(ctx context.Context) (any, error) {
+		runs++
+		return fakeStats{}, nil
+	}))
+
+	for _, secret := range []string{"", "wrong", "s3cret "} {
+		if rec := post(h, secret); rec.Code != http.StatusUnauthorized {
+			t.Errorf("secret %q: status = %d, want 401", secret, rec.Code)
+		}
+	}
+	if runs != 0 {
+		t.Errorf("digest ran %d times, want never", runs)
+	}
+
+	// GET isn't a trigger, even with the secret
+	req := httptest.NewRequest(http.MethodGet, "/trigger-digest", nil)
+	req.Header.Set(TriggerHeader, "s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed || runs != 0 {
+		t.Errorf("GET status = %d with %d runs, want 405 and no run", rec.Code, runs)
+	}
+}
+
+func TestTriggerDigestDisabledWithoutSecret(t *testing.T) {
+	h := NewHandler(nil, WithTrigger("", func(ctx context.Context) (any, error) {
+		t.Error("digest ran without a secret configured")
+		return nil, nil
+	}))
+
+	if rec := post(h, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestTriggerDigestReportsFailure(t *testing.T) {
+	h := NewHandler(nil, WithTrigger("s3cret", func(ctx context.Context) (any, error) {
+		return nil, errors.New("no chat_id set")
+	}))
+
+	rec := post(h, "s3cret")
+
+	var resp response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError || resp.Error != "no chat_id set" {
+		t.Errorf("response = %d %+v, want 500 with the error", rec.Code, resp)
+	}
+}
+
+func TestTriggerDigestRejectsConcurrentRun(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runs := 0
+	h := NewHandler(nil, WithTrigger("s3cret", func(ctx context.Context) (any, error) {
+		runs++
+		close(started)
+		<-release
+		return fakeStats{Sent: 1}, nil
+	}))
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post(h, "s3cret") }()
+	<-started
+
+	var resp response
+	rec := post(h, "s3cret")
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if rec.Code != http.StatusConflict || resp.Status != "busy" {
+		t.Errorf("concurrent trigger = %d %+v, want 409 busy", rec.Code, resp)
+	}
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Errorf("first trigger status = %d, want 200", rec.Code)
+	}
+	if runs != 1 {
+		t.Errorf("digest ran %d times, want once", runs)
+	}
+
+	// Once the run is over, the next trigger runs again
+	started = make(chan struct{})
+	if rec := post(h, "s3cret"); rec.Code != http.StatusOK || runs != 2 {
+		t.Errorf("trigger after the run = %d with %d runs, want 200 and a second run", rec.Code, runs)
+	}
+}
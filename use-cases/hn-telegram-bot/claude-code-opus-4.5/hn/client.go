@@ -5,36 +5,63 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-const defaultBaseURL = "https://hacker-news.firebaseio.com"
+const (
+	defaultBaseURL   = "https://hacker-news.firebaseio.com"
+	defaultSearchURL = "https://hn.algolia.com"
+
+	// maxSearchHits is the most hits the search API returns per request.
+	maxSearchHits = 1000
+)
 
 // Item represents a Hacker News item (story, comment, etc.).
 type Item struct {
-	ID          int64  `json:"id"`
-	Title       string `json:"title"`
-	URL         string `json:"url"`
-	Score       int    `json:"score"`
-	Descendants int    `json:"descendants"`
-	By          string `json:"by"`
-	Time        int64  `json:"time"`
-	Type        string `json:"type"`
+	ID          int64   `json:"id"`
+	Title       string  `json:"title"`
+	URL         string  `json:"url"`
+	Text        string  `json:"text"`
+	Score       int     `json:"score"`
+	Descendants int     `json:"descendants"`
+	By          string  `json:"by"`
+	Time        int64   `json:"time"`
+	Type        string  `json:"type"`
+	Kids        []int64 `json:"kids"` // Direct replies, in HN's ranked order
 }
 
 // Client provides access to the Hacker News API.
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+	searchURL  string
+	maxStories int
 }
 
 // Option configures a Client.
 type Option func(*Client)
 
-// WithBaseURL sets a custom base URL (for testing).
+// WithBaseURL sets a custom base URL, such as a mirror or a test server.
+// An empty URL keeps the default.
 func WithBaseURL(url string) Option {
 	return func(c *Client) {
-		c.baseURL = url
+		if url != "" {
+			c.baseURL = strings.TrimRight(url, "/")
+		}
+	}
+}
+
+// WithSearchURL sets a custom base URL for the HN Search API used by
+// GetStoriesSince. An empty URL keeps the default.
+func WithSearchURL(url string) Option {
+	return func(c *Client) {
+		if url != "" {
+			c.searchURL = strings.TrimRight(url, "/")
+		}
 	}
 }
 
@@ -45,11 +72,34 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithMaxStories caps the story IDs GetTopStories and GetStoriesSince
+// return at n, whatever limit they are asked for. Zero means no cap.
+func WithMaxStories(n int) Option {
+	return func(c *Client) {
+		c.maxStories = n
+	}
+}
+
+// WithHTTPClient makes requests to the HN and Algolia APIs through a copy
+// of client, for instance one routed through a proxy. The API calls are
+// small, so a client without a timeout gets the 30-second default, or the
+// one set with WithTimeout, rather than waiting indefinitely.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		hc := *client
+		if hc.Timeout == 0 {
+			hc.Timeout = c.httpClient.Timeout
+		}
+		c.httpClient = &hc
+	}
+}
+
 // NewClient creates a new HN API client.
 func NewClient(opts ...Option) *Client {
 	c := &Client{
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		baseURL:    defaultBaseURL,
+		searchURL:  defaultSearchURL,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -57,8 +107,14 @@ func NewClient(opts ...Option) *Client {
 	return c
 }
 
-// GetTopStories returns the top N story IDs.
+// GetTopStories returns the top N story IDs, or all of them (up to 500)
+// if limit is zero, capped by WithMaxStories. Only as much of the list as
+// is needed is read.
 func (c *Client) GetTopStories(ctx context.Context, limit int) ([]int64, error) {
+	if c.maxStories > 0 && (limit <= 0 || limit > c.maxStories) {
+		limit = c.maxStories
+	}
+
 	url := c.baseURL + "/v0/topstories.json"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -76,17 +132,107 @@ func (c *Client) GetTopStories(ctx context.Context, limit int) ([]int64, error)
 		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
 
-	var ids []int64
-	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+	// Decode the array one ID at a time, so that reading stops once there
+	// are enough of them
+	dec := json.NewDecoder(resp.Body)
+	if tok, err := dec.Token(); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
+	} else if tok != json.Delim('[') {
+		return nil, fmt.Errorf("decode response: got %v, want an array of IDs", tok)
+	}
+	var ids []int64
+	for dec.More() && (limit <= 0 || len(ids) < limit) {
+		var id int64
+		if err := dec.Decode(&id); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetStoriesSince returns the IDs of stories posted after since with at
+// least minScore points, highest scored first, from the HN Search API. The
+// whole window is read, up to the 1000 hits the API returns, before the
+// highest scored are kept, so that stories posted early in the window
+// aren't cut off by newer ones. At most limit IDs are returned, or 1000 if
+// limit is zero, capped by WithMaxStories.
+func (c *Client) GetStoriesSince(ctx context.Context, since time.Time, minScore, limit int) ([]int64, error) {
+	if limit <= 0 || limit > maxSearchHits {
+		limit = maxSearchHits
+	}
+	if c.maxStories > 0 {
+		limit = min(limit, c.maxStories)
 	}
 
-	if limit > 0 && len(ids) > limit {
-		ids = ids[:limit]
+	var hits []searchHit
+	for page := 0; len(hits) < maxSearchHits; page++ {
+		result, err := c.searchPage(ctx, since, minScore, page)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, result.Hits...)
+		if page+1 >= result.NbPages || len(result.Hits) == 0 {
+			break
+		}
+	}
+	slices.SortStableFunc(hits, func(a, b searchHit) int { return b.Points - a.Points })
+
+	ids := make([]int64, 0, min(len(hits), limit))
+	for _, hit := range hits[:min(len(hits), limit)] {
+		id, err := strconv.ParseInt(hit.ObjectID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("decode response: invalid story ID %q", hit.ObjectID)
+		}
+		ids = append(ids, id)
 	}
 	return ids, nil
 }
 
+// searchHit is a story found by the HN Search API.
+type searchHit struct {
+	ObjectID string `json:"objectID"`
+	Points   int    `json:"points"`
+}
+
+// searchResult is one page of HN Search API results.
+type searchResult struct {
+	Hits    []searchHit `json:"hits"`
+	NbPages int         `json:"nbPages"`
+}
+
+// searchPage fetches one page of the stories posted after since with at
+// least minScore points, newest first.
+func (c *Client) searchPage(ctx context.Context, since time.Time, minScore, page int) (*searchResult, error) {
+	query := url.Values{}
+	query.Set("tags", "story")
+	query.Set("numericFilters", fmt.Sprintf("created_at_i>%d,points>=%d", since.Unix(), minScore))
+	query.Set("hitsPerPage", strconv.Itoa(maxSearchHits))
+	query.Set("page", strconv.Itoa(page))
+	searchURL := c.searchURL + "/api/v1/search_by_date?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search stories: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var result searchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &result, nil
+}
+
 // GetItem retrieves an item by ID.
 func (c *Client) GetItem(ctx context.Context, id int64) (*Item, error) {
 	url := fmt.Sprintf("%s/v0/item/%d.json", c.baseURL, id)
@@ -3,6 +3,8 @@ package hn
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -56,6 +58,140 @@ func TestGetTopStoriesLimitExceedsAvailable(t *testing.T) {
 	}
 }
 
+// countingTransport counts the bytes read from response bodies.
+type countingTransport struct {
+	read int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, read: &c.read}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	read *int
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.read += n
+	return n, err
+}
+
+func TestGetTopStoriesReadsOnlyNeededPrefix(t *testing.T) {
+	// A full topstories list of 500 IDs
+	all := make([]int64, 500)
+	for i := range all {
+		all[i] = 40000000 + int64(i)
+	}
+	body, _ := json.Marshal(all)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := NewClient(WithBaseURL(server.URL), WithHTTPClient(&http.Client{Transport: transport}))
+
+	ids, err := client.GetTopStories(context.Background(), 6)
+	if err != nil {
+		t.Fatalf("GetTopStories failed: %v", err)
+	}
+
+	if len(ids) != 6 || ids[0] != all[0] || ids[5] != all[5] {
+		t.Errorf("ids = %v, want the first 6", ids)
+	}
+	if transport.read >= len(body)/2 {
+		t.Errorf("read %d of %d bytes, want only the prefix holding 6 IDs", transport.read, len(body))
+	}
+}
+
+func TestGetTopStoriesMaxStories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]int64{1, 2, 3, 4, 5})
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithMaxStories(2))
+	ctx := context.Background()
+
+	for _, limit := range []int{0, 4} {
+		ids, err := client.GetTopStories(ctx, limit)
+		if err != nil {
+			t.Fatalf("GetTopStories(%d) failed: %v", limit, err)
+		}
+		if len(ids) != 2 {
+			t.Errorf("GetTopStories(%d) = %v, want 2 IDs", limit, ids)
+		}
+	}
+	if ids, _ := client.GetTopStories(ctx, 1); len(ids) != 1 {
+		t.Errorf("GetTopStories(1) = %v, want 1 ID", ids)
+	}
+}
+
+func TestGetStoriesSince(t *testing.T) {
+	since := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/search_by_date" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("tags") != "story" {
+			t.Errorf("tags = %q, want story", q.Get("tags"))
+		}
+		if want := fmt.Sprintf("created_at_i>%d,points>=50", since.Unix()); q.Get("numericFilters") != want {
+			t.Errorf("numericFilters = %q, want %q", q.Get("numericFilters"), want)
+		}
+		// The whole window is read, however few stories are wanted
+		if q.Get("hitsPerPage") != "1000" {
+			t.Errorf("hitsPerPage = %q, want 1000", q.Get("hitsPerPage"))
+		}
+		// Newest first: the best story was posted early in the window
+		w.Write([]byte(`{"hits":[{"objectID":"300","points":80},{"objectID":"200","points":120},{"objectID":"100","points":400}],"nbHits":3,"nbPages":1}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithSearchURL(server.URL), WithMaxStories(2))
+	ids, err := client.GetStoriesSince(context.Background(), since, 50, 10)
+	if err != nil {
+		t.Fatalf("GetStoriesSince failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 100 || ids[1] != 200 {
+		t.Errorf("GetStoriesSince = %v, want the highest scored [100 200]", ids)
+	}
+}
+
+func TestGetStoriesSincePages(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if page == "0" {
+			w.Write([]byte(`{"hits":[{"objectID":"300","points":10}],"nbPages":2}`))
+			return
+		}
+		w.Write([]byte(`{"hits":[{"objectID":"200","points":0}],"nbPages":2}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithSearchURL(server.URL))
+	ids, err := client.GetStoriesSince(context.Background(), time.Now().Add(-time.Hour), 0, 0)
+	if err != nil {
+		t.Fatalf("GetStoriesSince failed: %v", err)
+	}
+	if len(pages) != 2 || pages[0] != "0" || pages[1] != "1" {
+		t.Errorf("requested pages %v, want [0 1]", pages)
+	}
+	if len(ids) != 2 || ids[0] != 300 || ids[1] != 200 {
+		t.Errorf("GetStoriesSince = %v, want [300 200]", ids)
+	}
+}
+
 func TestGetItem(t *testing.T) {
 	item := Item{
 		ID:          12345,
@@ -66,6 +202,7 @@ func TestGetItem(t *testing.T) {
 		By:          "testuser",
 		Time:        1609459200,
 		Type:        "story",
+		Kids:        []int64{12350, 12346},
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +239,29 @@ func TestGetItem(t *testing.T) {
 	if result.By != item.By {
 		t.Errorf("By = %q, want %q", result.By, item.By)
 	}
+	if len(result.Kids) != 2 || result.Kids[0] != 12350 || result.Kids[1] != 12346 {
+		t.Errorf("Kids = %v, want %v in order", result.Kids, item.Kids)
+	}
+}
+
+func TestGetItemText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 1, "title": "Ask HN: Question?", "text": "What do you <i>think</i>?", "type": "story"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+
+	item, err := client.GetItem(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetItem failed: %v", err)
+	}
+	if item.URL != "" {
+		t.Errorf("URL = %q, want empty", item.URL)
+	}
+	if item.Text != "What do you <i>think</i>?" {
+		t.Errorf("Text = %q, want item text", item.Text)
+	}
 }
 
 func TestGetItemNotFound(t *testing.T) {
@@ -166,6 +326,45 @@ func TestInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestClientAgainstMirror(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v0/topstories.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[101, 102]`))
+	})
+	mux.HandleFunc("/v0/item/101.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 101, "title": "Mirrored Story", "url": "https://example.com", "score": 42, "type": "story"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Trailing slash should not produce double slashes in endpoint paths
+	client := NewClient(WithBaseURL(server.URL + "/"))
+	ctx := context.Background()
+
+	ids, err := client.GetTopStories(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetTopStories failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 101 {
+		t.Fatalf("ids = %v, want [101 102]", ids)
+	}
+
+	item, err := client.GetItem(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("GetItem failed: %v", err)
+	}
+	if item.Title != "Mirrored Story" || item.Score != 42 {
+		t.Errorf("item = %+v, want mirrored story with score 42", item)
+	}
+}
+
+func TestEmptyBaseURLKeepsDefault(t *testing.T) {
+	client := NewClient(WithBaseURL(""))
+	if client.baseURL != defaultBaseURL {
+		t.Errorf("baseURL = %q, want %q", client.baseURL, defaultBaseURL)
+	}
+}
+
 func TestDefaultClient(t *testing.T) {
 	client := NewClient()
 	if client.baseURL != "https://hacker-news.firebaseio.com" {
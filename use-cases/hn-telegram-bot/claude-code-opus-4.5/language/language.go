@@ -0,0 +1,90 @@
+// Package language guesses the primary language of article text.
+//
+// Detection counts each language's most common short words, which is
+// cheap and reliable on the several paragraphs a scraped article provides,
+// but not on a single sentence.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxWords bounds how much of a long article is examined.
+	maxWords = 2000
+	// minHits is how many common words must match before a language is
+	// reported at all.
+	minHits = 5
+)
+
+// commonWords lists frequent function words for each supported language,
+// keyed by ISO 639-1 code.
+var commonWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "it", "for", "was", "with", "on", "are", "this",
+		"be", "by", "have", "from", "or", "not", "but", "which", "you", "they", "we", "their", "has",
+		"were", "been", "will", "would", "can", "an", "at"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "un", "du", "que", "qui", "dans", "pour",
+		"pas", "sur", "au", "avec", "ce", "il", "sont", "par", "plus", "ne", "nous", "vous", "mais",
+		"ou", "aux", "cette", "été", "leur"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich",
+		"auf", "für", "dem", "des", "auch", "es", "im", "wird", "sind", "wir", "ich", "sie", "aber",
+		"oder", "werden", "noch", "nach", "bei"},
+	"es": {"el", "los", "las", "y", "es", "una", "del", "que", "en", "por", "para", "con", "no", "se",
+		"su", "lo", "al", "como", "más", "pero", "sus", "ya", "fue", "este", "esta", "son", "también"},
+	"it": {"il", "lo", "gli", "di", "che", "è", "non", "per", "una", "del", "della", "con", "sono",
+		"nel", "alla", "anche", "come", "più", "ma", "questo", "essere", "ha", "dei", "delle"},
+	"pt": {"o", "os", "as", "e", "do", "da", "dos", "das", "que", "não", "um", "uma", "em", "para",
+		"com", "por", "se", "mais", "como", "mas", "foi", "ao", "ele", "é", "são", "também", "na", "no"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "in", "voor", "met",
+		"zijn", "die", "er", "aan", "ook", "als", "bij", "maar", "worden", "wordt", "nog", "naar", "om"},
+}
+
+// wordLanguages maps each common word to the languages it belongs to.
+var wordLanguages = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range commonWords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// Supported reports whether Detect can return the given ISO 639-1 code.
+func Supported(code string) bool {
+	_, ok := commonWords[code]
+	return ok
+}
+
+// Detect returns the ISO 639-1 code of the text's primary language, or ""
+// if the text is too short or too ambiguous to tell.
+func Detect(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) > maxWords {
+		words = words[:maxWords]
+	}
+
+	hits := make(map[string]int)
+	for _, w := range words {
+		for _, lang := range wordLanguages[w] {
+			hits[lang]++
+		}
+	}
+
+	best, bestHits, runnerUp := "", 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > bestHits:
+			best, bestHits, runnerUp = lang, n, bestHits
+		case n > runnerUp:
+			runnerUp = n
+		}
+	}
+	if bestHits < minHits || bestHits == runnerUp {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,57 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "english",
+			text: "The new release of the compiler is faster than the previous one, and it was built with " +
+				"a focus on memory usage. Users have reported that their builds are now much quicker.",
+			want: "en",
+		},
+		{
+			name: "french",
+			text: "Le nouveau compilateur est plus rapide que la version précédente, et il a été conçu pour " +
+				"réduire la mémoire utilisée. Les utilisateurs disent que leurs projets sont plus rapides avec cette version.",
+			want: "fr",
+		},
+		{
+			name: "german",
+			text: "Der neue Compiler ist schneller als die alte Version, und er wird mit weniger Speicher " +
+				"gebaut. Die Nutzer sagen, dass sich die Builds auch bei großen Projekten nicht mehr verzögern.",
+			want: "de",
+		},
+		{
+			name: "too short",
+			text: "Show HN: a tiny job queue",
+			want: "",
+		},
+		{
+			name: "empty",
+			text: "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.text); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupported(t *testing.T) {
+	if !Supported("en") || !Supported("fr") {
+		t.Error("en and fr should be supported")
+	}
+	if Supported("xx") || Supported("EN") {
+		t.Error("only lowercase ISO 639-1 codes of known languages should be supported")
+	}
+}
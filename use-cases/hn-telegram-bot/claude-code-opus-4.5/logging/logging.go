@@ -0,0 +1,107 @@
+// Package logging builds the application's slog logger from its logging
+// configuration.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Options selects how and where logs are written.
+type Options struct {
+	Format string // "json" or "text"; JSON if empty
+	Output string // "stdout", "stderr" or "file:/path"; stdout if empty
+	Level  string // "debug", "info", "warn" or "error"; info if empty
+}
+
+// filePrefix marks an Output that names a file to append logs to.
+const filePrefix = "file:"
+
+// New returns a logger configured by opts, along with a function that
+// closes its output. Closing is a no-op unless logs go to a file.
+func New(opts Options) (*slog.Logger, func() error, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, closeFn, err := openOutput(opts.Output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handler, err := NewHandler(w, opts.Format, level)
+	if err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+	return slog.New(handler), closeFn, nil
+}
+
+// NewHandler returns a JSON or text handler writing to w at the given
+// level.
+func NewHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "", "json":
+		return slog.NewJSONHandler(w, handlerOpts), nil
+	case "text":
+		return slog.NewTextHandler(w, handlerOpts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q: want json or text", format)
+	}
+}
+
+// ParseLevel parses a log level name, case-insensitively.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q: want debug, info, warn or error", s)
+	}
+}
+
+// ValidateOutput checks that s names a supported log output.
+func ValidateOutput(s string) error {
+	switch {
+	case s == "", s == "stdout", s == "stderr":
+		return nil
+	case strings.HasPrefix(s, filePrefix) && strings.TrimPrefix(s, filePrefix) != "":
+		return nil
+	default:
+		return fmt.Errorf("unknown log output %q: want stdout, stderr or file:/path", s)
+	}
+}
+
+// openOutput returns the writer for a log output and a function that
+// closes it.
+func openOutput(output string) (io.Writer, func() error, error) {
+	if err := ValidateOutput(output); err != nil {
+		return nil, nil, err
+	}
+
+	noop := func() error { return nil }
+	switch output {
+	case "", "stdout":
+		return os.Stdout, noop, nil
+	case "stderr":
+		return os.Stderr, noop, nil
+	}
+
+	path := strings.TrimPrefix(output, filePrefix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open log file: %w", err)
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewHandlerText(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := NewHandler(&buf, "text", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	if _, ok := handler.(*slog.TextHandler); !ok {
+		t.Fatalf("handler = %T, want *slog.TextHandler", handler)
+	}
+
+	slog.New(handler).Info("hello", "key", "value")
+	if got := buf.String(); !strings.Contains(got, "msg=hello key=value") {
+		t.Errorf("output = %q, want text key=value pairs", got)
+	}
+}
+
+func TestNewHandlerJSON(t *testing.T) {
+	for _, format := range []string{"", "json"} {
+		handler, err := NewHandler(&bytes.Buffer{}, format, slog.LevelInfo)
+		if err != nil {
+			t.Fatalf("NewHandler(%q) failed: %v", format, err)
+		}
+		if _, ok := handler.(*slog.JSONHandler); !ok {
+			t.Errorf("NewHandler(%q) = %T, want *slog.JSONHandler", format, handler)
+		}
+	}
+
+	if _, err := NewHandler(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestNewHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	handler, _ := NewHandler(&buf, "text", slog.LevelWarn)
+	logger := slog.New(handler)
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "kept") {
+		t.Errorf("output = %q, want only the warning", got)
+	}
+}
+
+func TestNewFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	logger, closeLog, err := New(Options{Format: "text", Output: "file:" + path, Level: "debug"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	logger.Debug("to file")
+	if err := closeLog(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "msg=\"to file\"") {
+		t.Errorf("log file = %q, want the debug message", data)
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	tests := []Options{
+		{Output: "syslog"},
+		{Output: "file:"},
+		{Level: "verbose"},
+		{Format: "xml"},
+	}
+	for _, opts := range tests {
+		if _, _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) succeeded, want error", opts)
+		}
+	}
+}
@@ -3,33 +3,47 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"golang.org/x/net/http/httpproxy"
 
 	"hn-telegram-bot/bot"
 	"hn-telegram-bot/config"
 	"hn-telegram-bot/digest"
+	"hn-telegram-bot/health"
 	"hn-telegram-bot/hn"
+	"hn-telegram-bot/logging"
+	"hn-telegram-bot/offline"
 	"hn-telegram-bot/ranker"
 	"hn-telegram-bot/scheduler"
 	"hn-telegram-bot/scraper"
+	"hn-telegram-bot/settings"
 	"hn-telegram-bot/storage"
 	"hn-telegram-bot/summarizer"
 )
 
 func main() {
-	// Set up structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	once := flag.Bool("once", false, "run a single digest and exit, for cron-style deployments")
+	flag.Parse()
+
+	// Log JSON to stdout until the configured logger is set up
+	logger, _, _ := logging.New(logging.Options{})
 	slog.SetDefault(logger)
 
 	slog.Info("starting HN Telegram Bot")
@@ -41,10 +55,28 @@ func main() {
 		slog.Error("failed to load config", "path", configPath, "error", err)
 		os.Exit(1)
 	}
+
+	logger, closeLog, err := logging.New(logging.Options{Format: cfg.LogFormat, Output: cfg.LogOutput, Level: cfg.LogLevel})
+	if err != nil {
+		slog.Error("failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	slog.SetDefault(logger)
 	slog.Info("config loaded", "path", configPath)
 
+	footer, err := bot.ParseFooterTemplate(cfg.FooterTemplate)
+	if err != nil {
+		slog.Error("invalid footer_template", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize database
-	db, err := storage.NewDB(cfg.DBPath)
+	var dbOpts []storage.Option
+	if cfg.CreateDBDir {
+		dbOpts = append(dbOpts, storage.WithCreateDir())
+	}
+	db, err := storage.NewDB(cfg.DBPath, dbOpts...)
 	if err != nil {
 		slog.Error("failed to initialize database", "path", cfg.DBPath, "error", err)
 		os.Exit(1)
@@ -52,28 +84,107 @@ func main() {
 	defer db.Close()
 	slog.Info("database initialized", "path", cfg.DBPath)
 
-	// Initialize Telegram bot
-	tgBot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
-	if err != nil {
-		slog.Error("failed to initialize Telegram bot", "error", err)
+	if err := db.SetTagAliases(context.Background(), cfg.TagAliases); err != nil {
+		slog.Error("failed to apply tag aliases", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("telegram bot initialized", "username", tgBot.Self.UserName)
 
-	// Initialize components
-	hnClient := hn.NewClient(
-		hn.WithTimeout(time.Duration(cfg.FetchTimeoutSecs) * time.Second),
-	)
-	articleScraper := scraper.NewScraper(
-		scraper.WithTimeout(time.Duration(cfg.FetchTimeoutSecs) * time.Second),
-	)
-	articleSummarizer := summarizer.NewSummarizer(
-		cfg.GeminiAPIKey,
-		summarizer.WithModel(cfg.GeminiModel),
+	// Serve health probes from the start, so that readiness reflects the
+	// Telegram bot still initializing. The trigger endpoint works once the
+	// app is ready.
+	var botReady atomic.Bool
+	var readyApp atomic.Pointer[App]
+	var heartbeat *health.Heartbeat // Beaten by the poll loop, nil in offline mode
+	if !cfg.Offline {
+		heartbeat = health.NewHeartbeat()
+	}
+	if cfg.HealthAddr != "" {
+		srv, err := health.Start(cfg.HealthAddr, health.NewHandler(map[string]health.Check{
+			"database": db.Ping,
+			"telegram": func(ctx context.Context) error {
+				if !botReady.Load() {
+					return errors.New("bot not initialized")
+				}
+				return nil
+			},
+		}, health.WithTrigger(cfg.TriggerSecret, func(ctx context.Context) (any, error) {
+			app := readyApp.Load()
+			if app == nil {
+				return nil, errors.New("bot not initialized")
+			}
+			return app.RunDigest(ctx)
+		}), health.WithHeartbeat(heartbeat, pollStaleAfter)))
+		if err != nil {
+			slog.Error("failed to start health server", "error", err)
+			os.Exit(1)
+		}
+		defer srv.Close()
+		slog.Info("health server listening", "addr", srv.Addr)
+	}
+
+	if len(cfg.SeedTags) > 0 {
+		if n, err := db.SeedTagWeights(context.Background(), cfg.SeedTags, seedTagWeight); err != nil {
+			slog.Error("failed to seed tag weights", "error", err)
+		} else if n > 0 {
+			slog.Info("seeded tag weights", "count", n, "weight", seedTagWeight)
+		}
+	}
+
+	// Initialize components, or local fakes in offline mode
+	var (
+		sender            bot.MessageSender
+		hnClient          digest.HNClient
+		articleScraper    digest.Scraper
+		articleSummarizer summarizerClient
+		botName           string
 	)
+	if cfg.Offline {
+		slog.Info("offline mode: using canned stories and writing messages to stdout")
+		sender = offline.NewSender(os.Stdout)
+		hnClient = offline.NewHNClient(offlineStoryCount)
+		articleScraper = offline.Scraper{}
+		articleSummarizer = offline.Summarizer{}
+		botReady.Store(true)
+	} else {
+		tgBot, err := bot.NewBotAPI(cfg.TelegramToken, cfg.TelegramAPIBase)
+		if err != nil {
+			slog.Error("failed to initialize Telegram bot", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("telegram bot initialized", "username", tgBot.Self.UserName)
+		botName = tgBot.Self.UserName
+
+		sender = bot.NewTelegramSender(tgBot, bot.WithUnsubscriber(db))
+		httpClient := newHTTPClient(cfg)
+		hnClient = &hnClientAdapter{hn.NewClient(
+			hn.WithHTTPClient(httpClient),
+			hn.WithBaseURL(cfg.HNBaseURL),
+			hn.WithSearchURL(cfg.HNSearchURL),
+			hn.WithTimeout(time.Duration(cfg.FetchTimeoutSecs)*time.Second),
+			hn.WithMaxStories(cfg.MaxStories),
+		)}
+		pageScraper := scraper.NewScraper(
+			scraper.WithHTTPClient(httpClient),
+			scraper.WithTimeout(time.Duration(cfg.FetchTimeoutSecs)*time.Second),
+		)
+		articleScraper = &scraperAdapter{pageScraper}
+		articleSummarizer = &summarizerAdapter{summarizer.NewSummarizer(
+			cfg.GeminiAPIKey,
+			summarizer.WithAPIKeys(cfg.GeminiAPIKeys...),
+			summarizer.WithHTTPClient(httpClient),
+			summarizer.WithTimeout(cfg.GeminiReqTimeout),
+			summarizer.WithRateLimit(cfg.SummarizerRPM),
+			summarizer.WithModel(cfg.GeminiModel),
+			summarizer.WithFallbackModel(cfg.GeminiFallbackModel),
+			summarizer.WithSummaryLength(cfg.SummaryMinLength, cfg.SummaryMaxLength),
+			summarizer.WithRefusalPatterns(cfg.RefusalPatterns),
+			summarizer.WithTagWeighting(summarizer.TagWeighting(cfg.TagWeighting)),
+		)}
+		botReady.Store(true)
+	}
 
 	// Initialize scheduler
-	sched, err := scheduler.NewScheduler(cfg.Timezone)
+	sched, err := scheduler.NewScheduler(cfg.Timezone, scheduler.WithJitter(cfg.ScheduleJitter))
 	if err != nil {
 		slog.Error("failed to initialize scheduler", "timezone", cfg.Timezone, "error", err)
 		os.Exit(1)
@@ -83,26 +194,92 @@ func main() {
 	app := &App{
 		cfg:        cfg,
 		db:         db,
-		tgBot:      tgBot,
+		sender:     sender,
 		hnClient:   hnClient,
 		scraper:    articleScraper,
 		summarizer: articleSummarizer,
 		scheduler:  sched,
+		digests:    &digest.Tracker{},
+		botName:    botName,
+		footer:     footer,
 	}
 
+	botStore := &botStorageAdapter{db}
+	app.settings = settings.New(botStore, settings.Defaults{DigestTime: cfg.DigestTime, ArticleCount: cfg.ArticleCount})
+	app.commands = bot.NewCommandHandler(
+		&messageSenderAdapter{app},
+		app.settings,
+		sched,
+		botStore,
+		botStore,
+		bot.WithDigestTrigger(app),
+		bot.WithStatusProviders(sched, db),
+		bot.WithDomainStats(botStore),
+		bot.WithSourceStats(botStore),
+		bot.WithTagHistory(botStore),
+		bot.WithSentArticles(botStore),
+		bot.WithPreferenceResetter(botStore),
+		bot.WithSubscriptions(db),
+		bot.WithSubscribers(db),
+		bot.WithPreviewer(app),
+		bot.WithExpander(botStore, app),
+		bot.WithURLTester(app),
+		bot.WithLikeUndoer(botStore),
+		bot.WithLikeBoost(cfg.TagBoostOnLike, bot.BoostCurve(cfg.TagBoostCurve), botStore),
+		bot.WithConfig(bot.HandlerConfig{
+			ChatID:          cfg.ChatID,
+			DigestTime:      cfg.DigestTime,
+			ArticleCount:    cfg.ArticleCount,
+			Timezone:        cfg.Timezone,
+			Model:           cfg.GeminiModel,
+			PinnedTags:      cfg.PinnedTags,
+			QuietHoursStart: cfg.QuietHoursStart,
+			QuietHoursEnd:   cfg.QuietHoursEnd,
+			MinTagWeight:    cfg.MinTagWeight,
+			AdminChatIDs:    cfg.AdminChatIDs,
+		}),
+	)
+	app.reactions = bot.NewReactionHandler(botStore, botStore, botStore, cfg.TagBoostOnLike,
+		bot.WithLikeEmojis(cfg.LikeEmojis),
+		bot.WithDislikeEmojis(db, cfg.DislikeEmojis),
+		bot.WithDomainBooster(botStore),
+		bot.WithLikeRecorder(db),
+		bot.WithBoostCurve(bot.BoostCurve(cfg.TagBoostCurve), botStore),
+	)
+
 	// Initialize chat ID from config or database
 	if cfg.ChatID != 0 {
 		app.chatID = cfg.ChatID
-	} else if chatIDStr, err := db.GetSetting(context.Background(), "chat_id"); err == nil {
+	} else if chatIDStr, err := app.settings.GetSetting(context.Background(), "chat_id"); err == nil {
 		if id, err := strconv.ParseInt(chatIDStr, 10, 64); err == nil {
 			app.chatID = id
 		}
 	}
+	if app.chatID == 0 && cfg.Offline {
+		app.chatID = offlineChatID
+	}
+
+	// Sent state used to be tracked on articles without a chat; attribute
+	// it to the configured chat so dedup and reactions keep working
+	if app.chatID != 0 {
+		if n, err := db.MigrateSentArticles(context.Background(), app.chatID); err != nil {
+			slog.Error("failed to migrate sent articles", "error", err)
+		} else if n > 0 {
+			slog.Info("migrated sent articles", "chat_id", app.chatID, "count", n)
+		}
+	}
 
 	// Set up context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Digests get their own context so that an in-flight run can finish
+	// sending during the shutdown grace period
+	digestCtx, cancelDigests := context.WithCancel(context.Background())
+	defer cancelDigests()
+	app.digestCtx = digestCtx
+	readyApp.Store(app)
+
 	// Handle shutdown signals
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -113,129 +290,107 @@ func main() {
 		cancel()
 	}()
 
-	// Schedule daily digest
-	digestTime := cfg.DigestTime
-	if storedTime, err := db.GetSetting(ctx, "digest_time"); err == nil {
-		digestTime = storedTime
+	// A one-shot run finishes an interrupted digest before its own, and
+	// needs neither the schedule nor polling
+	if *once {
+		app.resumeDigest(digestCtx)
+		code := app.runOnce(ctx)
+		db.Close()
+		os.Exit(code)
 	}
 
+	// Schedule daily digest
+	digestTime := app.settings.DigestTime(ctx)
+
 	if err := sched.Schedule(digestTime, func() {
-		app.runDigest(context.Background())
+		app.runDigest(digestCtx, digest.TriggerScheduled)
 	}); err != nil {
 		slog.Error("failed to schedule digest", "error", err)
 		os.Exit(1)
 	}
 	sched.Start()
 	defer sched.Stop()
-	slog.Info("digest scheduled", "time", digestTime, "timezone", cfg.Timezone)
-
-	// Run the bot
-	slog.Info("starting bot polling")
-	app.run(ctx)
+	slog.Info("digest scheduled", "time", digestTime, "timezone", cfg.Timezone,
+		"jitter_offset", sched.Offset(), "next_run", sched.NextRun())
+
+	// Finish an interrupted digest in the background, so that sending it
+	// doesn't hold up answering commands
+	go app.resumeDigest(digestCtx)
+
+	// Run the bot. Offline mode has no Telegram updates to poll, so it runs
+	// one digest straight away and then only the schedule until shutdown.
+	if cfg.Offline {
+		app.runDigest(digestCtx, digest.TriggerOnDemand)
+		<-ctx.Done()
+	} else {
+		slog.Info("starting bot polling")
+		poller := bot.NewPoller(cfg.TelegramToken,
+			bot.WithAPIBaseURL(cfg.TelegramAPIBase),
+			bot.WithUpdateTypes(updateTypes(cfg)...),
+			bot.WithHeartbeat(heartbeat.Beat),
+		)
+		poller.Run(ctx, app.handleUpdate)
+	}
 	slog.Info("bot stopped")
-}
 
-// App holds all application dependencies.
-type App struct {
-	cfg        *config.Config
-	db         *storage.DB
-	tgBot      *tgbotapi.BotAPI
-	hnClient   *hn.Client
-	scraper    *scraper.Scraper
-	summarizer *summarizer.Summarizer
-	scheduler  *scheduler.Scheduler
-	chatID     int64
-	mu         sync.RWMutex
-}
-
-func (a *App) run(ctx context.Context) {
-	// Use manual getUpdates to support message reactions
-	offset := 0
-	timeout := 30
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		updates, err := a.getUpdates(ctx, offset, timeout)
-		if err != nil {
-			if ctx.Err() != nil {
-				return
-			}
-			slog.Warn("failed to get updates", "error", err)
-			time.Sleep(time.Second)
-			continue
-		}
-
-		for _, update := range updates {
-			offset = update.UpdateID + 1
-			a.handleUpdate(ctx, &update)
-		}
+	// Stop starting new digests and let any in-flight one finish
+	sched.Stop()
+	grace := time.Duration(cfg.ShutdownGraceSecs) * time.Second
+	slog.Info("waiting for in-flight digest", "grace_period", grace)
+	if !app.digests.Shutdown(grace) {
+		slog.Warn("in-flight digest did not finish within grace period")
 	}
+	cancelDigests()
 }
 
-// Update represents a Telegram update with reaction support.
-type Update struct {
-	UpdateID        int              `json:"update_id"`
-	Message         *tgbotapi.Message `json:"message"`
-	MessageReaction *MessageReaction `json:"message_reaction"`
-}
+const (
+	// seedTagWeight is the starting weight of configured seed tags, roughly
+	// what a tag reaches after five likes.
+	seedTagWeight = 2.0
 
-// MessageReaction represents a reaction update from Telegram.
-type MessageReaction struct {
-	Chat        Chat              `json:"chat"`
-	MessageID   int               `json:"message_id"`
-	Date        int               `json:"date"`
-	OldReaction []ReactionType    `json:"old_reaction"`
-	NewReaction []ReactionType    `json:"new_reaction"`
-}
+	// offlineStoryCount is how many canned stories offline mode serves.
+	offlineStoryCount = 100
+	// offlineChatID is used in offline mode when no chat_id is configured.
+	offlineChatID = 1
 
-// Chat represents a Telegram chat.
-type Chat struct {
-	ID int64 `json:"id"`
-}
+	// pollStaleAfter is how long the poll loop may go without coming round
+	// before /healthz fails. A pass takes at most a long poll and a retry's
+	// backoff, each about a minute, plus handling the updates received.
+	pollStaleAfter = 5 * time.Minute
+)
 
-// ReactionType represents a reaction emoji.
-type ReactionType struct {
-	Type  string `json:"type"`
-	Emoji string `json:"emoji"`
+// summarizerClient summarizes articles one at a time or in batches, and
+// HN discussions. It also writes the longer summaries /expand sends.
+type summarizerClient interface {
+	digest.Summarizer
+	digest.BatchSummarizer
+	digest.DiscussionSummarizer
+	SummarizeExpanded(ctx context.Context, title, content string) (*digest.SummaryResult, error)
 }
 
-func (a *App) getUpdates(ctx context.Context, offset, timeout int) ([]Update, error) {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=%d&allowed_updates=%s",
-		a.cfg.TelegramToken, offset, timeout, `["message","message_reaction"]`)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	client := &http.Client{Timeout: time.Duration(timeout+10) * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		OK     bool     `json:"ok"`
-		Result []Update `json:"result"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	if !result.OK {
-		return nil, fmt.Errorf("telegram API returned not OK")
-	}
-
-	return result.Result, nil
+// App holds all application dependencies.
+type App struct {
+	cfg        *config.Config
+	db         *storage.DB
+	sender     bot.MessageSender
+	hnClient   digest.HNClient
+	scraper    digest.Scraper
+	summarizer summarizerClient
+	scheduler  *scheduler.Scheduler
+	commands   *bot.CommandHandler
+	reactions  *bot.ReactionHandler
+	digests    *digest.Tracker
+	digestCtx  context.Context
+	botName    string // Bot's username, empty in offline mode
+	chatID     int64
+	queued     *time.Timer    // Digest queued by QueueDigest, if any
+	slow       sync.WaitGroup // Commands handled by runSlow
+	settings   *settings.Settings
+	footer     *bot.FooterTemplate
+	mu         sync.RWMutex
 }
 
-func (a *App) handleUpdate(ctx context.Context, update *Update) {
+func (a *App) handleUpdate(ctx context.Context, update *bot.Update) {
 	if update.Message != nil {
 		a.handleMessage(ctx, update.Message)
 	}
@@ -249,276 +404,468 @@ func (a *App) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
 		return
 	}
 
-	text := strings.TrimSpace(msg.Text)
+	text, ok := commandText(strings.TrimSpace(msg.Text), a.botName)
 	chatID := msg.Chat.ID
+	if !ok {
+		slog.Debug("ignoring command for another bot", "chat_id", chatID, "text", msg.Text)
+		return
+	}
 
 	slog.Info("received message", "chat_id", chatID, "text", text)
 
+	var err error
 	switch {
 	case text == "/start":
-		a.handleStartCommand(ctx, chatID)
+		err = a.commands.HandleStart(ctx, chatID)
+	case text == "/subscribe":
+		a.setChatID(chatID)
+		err = a.commands.HandleSubscribe(ctx, chatID)
+	case text == "/unsubscribe":
+		err = a.commands.HandleUnsubscribe(ctx, chatID)
 	case text == "/fetch":
-		a.handleFetchCommand(ctx, chatID)
-	case text == "/stats":
-		a.handleStatsCommand(ctx, chatID)
+		a.setChatID(chatID)
+		err = a.commands.HandleFetch(ctx, chatID)
+	case text == "/preview":
+		err = a.commands.HandlePreview(ctx, chatID)
+	case text == "/stats sources":
+		err = a.commands.HandleSourceStats(ctx, chatID)
+	case text == "/stats" || strings.HasPrefix(text, "/stats "):
+		err = a.commands.HandleStats(ctx, chatID, strings.TrimPrefix(text, "/stats"))
+	case text == "/status":
+		err = a.commands.HandleStatus(ctx, chatID)
+	case text == "/history" || strings.HasPrefix(text, "/history "):
+		err = a.commands.HandleHistory(ctx, chatID, strings.TrimPrefix(text, "/history"))
+	case text == "/articles" || strings.HasPrefix(text, "/articles "):
+		err = a.commands.HandleArticles(ctx, chatID, strings.TrimPrefix(text, "/articles"))
+	case text == "/undo":
+		err = a.commands.HandleUndo(ctx, chatID)
+	case text == "/expand":
+		var replyToID int64
+		if msg.ReplyToMessage != nil {
+			replyToID = int64(msg.ReplyToMessage.MessageID)
+		}
+		a.runSlow(ctx, chatID, text, func(ctx context.Context) error {
+			return a.commands.HandleExpand(ctx, chatID, replyToID)
+		})
+	case text == "/test" || strings.HasPrefix(text, "/test "):
+		a.runSlow(ctx, chatID, text, func(ctx context.Context) error {
+			return a.commands.HandleTest(ctx, chatID, strings.TrimPrefix(text, "/test"))
+		})
+	case text == "/simulate" || strings.HasPrefix(text, "/simulate "):
+		err = a.commands.HandleSimulate(ctx, chatID, strings.TrimPrefix(text, "/simulate"))
+	case text == "/broadcast" || strings.HasPrefix(text, "/broadcast "):
+		err = a.commands.HandleBroadcast(ctx, chatID, strings.TrimPrefix(text, "/broadcast"))
+	case text == "/reset" || strings.HasPrefix(text, "/reset "):
+		err = a.commands.HandleReset(ctx, chatID, strings.TrimPrefix(text, "/reset"))
+	case text == "/lists" || strings.HasPrefix(text, "/lists "):
+		err = a.commands.HandleLists(ctx, chatID, strings.TrimPrefix(text, "/lists"))
 	case strings.HasPrefix(text, "/settings"):
-		args := strings.TrimPrefix(text, "/settings")
-		a.handleSettingsCommand(ctx, chatID, strings.TrimSpace(args))
+		err = a.commands.HandleSettings(ctx, chatID, strings.TrimPrefix(text, "/settings"))
 	}
+	a.reportCommandError(ctx, chatID, text, err)
 }
 
-func (a *App) handleStartCommand(ctx context.Context, chatID int64) {
-	// Save chat ID
-	a.mu.Lock()
-	a.chatID = chatID
-	a.mu.Unlock()
+// runSlow handles a command that scrapes and summarizes in the background,
+// so that it doesn't hold up the poll loop. It gets as long as a digest
+// gives one article to be scraped and summarized.
+func (a *App) runSlow(ctx context.Context, chatID int64, text string, handle func(ctx context.Context) error) {
+	a.slow.Go(func() {
+		cmdCtx, cancel := context.WithTimeout(ctx, a.cfg.ScrapeTimeout+a.cfg.SummaryTimeout)
+		defer cancel()
+		a.reportCommandError(ctx, chatID, text, handle(cmdCtx))
+	})
+}
 
-	if err := a.db.SetSetting(ctx, "chat_id", strconv.FormatInt(chatID, 10)); err != nil {
-		slog.Warn("failed to save chat_id", "error", err)
+// reportCommandError logs a command's failure, if any, and tells the chat.
+func (a *App) reportCommandError(ctx context.Context, chatID int64, text string, err error) {
+	switch {
+	case err == nil:
+	case storage.IsBusy(err):
+		slog.Warn("database busy, command not handled", "chat_id", chatID, "text", text, "error", err)
+		a.sendMessage(ctx, chatID, "⏳ Temporary error: the database is busy. Please try again in a moment.", bot.ParseModeNone)
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.Warn("command timed out", "chat_id", chatID, "text", text, "error", err)
+		a.sendMessage(ctx, chatID, "⌛ That took too long. Please try again later.", bot.ParseModeNone)
+	default:
+		slog.Warn("failed to handle command", "chat_id", chatID, "text", text, "error", err)
+		a.sendMessage(ctx, chatID, "Something went wrong. Please try again later.", bot.ParseModeNone)
 	}
+}
 
-	msg := "Welcome to the HN Digest Bot! 🗞️\n\n" +
-		"Commands:\n" +
-		"/fetch - Get your personalized digest now\n" +
-		"/settings - View or update digest settings\n" +
-		"/stats - View your interests and stats\n\n" +
-		"React with 👍 to articles you like to train your preferences!"
+// commandText strips the "@botname" Telegram appends to commands in group
+// chats ("/settings@mybot time 09:00"), so that they dispatch like plain
+// commands. It reports false for a command addressed to a different bot.
+func commandText(text, botName string) (string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return text, true
+	}
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	cmd, target, found := strings.Cut(text[:end], "@")
+	if !found {
+		return text, true
+	}
+	if botName != "" && !strings.EqualFold(target, botName) {
+		return "", false
+	}
+	return cmd + text[end:], true
+}
 
-	a.sendMessage(ctx, chatID, msg, false)
+func (a *App) handleReaction(ctx context.Context, reaction *bot.MessageReaction) {
+	chatID := reaction.Chat.ID
+	msgID := int64(reaction.MessageID)
+	for _, emoji := range reaction.AddedEmojis() {
+		slog.Info("received reaction", "chat_id", chatID, "message_id", msgID, "emoji", emoji)
+		if err := a.reactions.HandleReaction(ctx, chatID, msgID, emoji); err != nil {
+			slog.Warn("failed to handle reaction", "chat_id", chatID, "message_id", msgID, "emoji", emoji, "error", err)
+		}
+	}
 }
 
-func (a *App) handleFetchCommand(ctx context.Context, chatID int64) {
+func (a *App) setChatID(chatID int64) {
 	a.mu.Lock()
 	a.chatID = chatID
 	a.mu.Unlock()
-
-	go a.runDigest(ctx)
 }
 
-func (a *App) handleStatsCommand(ctx context.Context, chatID int64) {
-	likeCount, err := a.db.GetLikeCount(ctx)
-	if err != nil {
-		slog.Warn("failed to get like count", "error", err)
-		a.sendMessage(ctx, chatID, "Failed to retrieve stats.", false)
-		return
-	}
+// TriggerDigest starts an on-demand digest run in the background. The run
+// uses the app's digest context rather than ctx, so it isn't aborted when
+// the triggering request's context is cancelled on shutdown.
+func (a *App) TriggerDigest(ctx context.Context) error {
+	go a.runDigest(a.digestCtx, digest.TriggerOnDemand)
+	return nil
+}
 
-	if likeCount == 0 {
-		a.sendMessage(ctx, chatID, "No likes yet! React with 👍 to articles to train your preferences.", false)
-		return
-	}
+// TriggerScheduledDigest starts a scheduled digest run in the background,
+// like TriggerDigest.
+func (a *App) TriggerScheduledDigest(ctx context.Context) error {
+	go a.runDigest(a.digestCtx, digest.TriggerScheduled)
+	return nil
+}
 
-	topTags, err := a.db.GetTopTags(ctx, 10)
-	if err != nil {
-		slog.Warn("failed to get top tags", "error", err)
-		a.sendMessage(ctx, chatID, "Failed to retrieve stats.", false)
-		return
-	}
+// QueueDigest starts an on-demand digest run in the background at the
+// given time, like TriggerDigest. Only one digest is queued at a time, so
+// queueing another replaces it.
+func (a *App) QueueDigest(ctx context.Context, at time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	var sb strings.Builder
-	sb.WriteString("📊 Your Interests:\n\n")
-	for i, tag := range topTags {
-		sb.WriteString(fmt.Sprintf("%d. %s (%.2f)\n", i+1, tag.Tag, tag.Weight))
+	if a.queued != nil {
+		a.queued.Stop()
 	}
-	sb.WriteString(fmt.Sprintf("\nTotal articles liked: %d", likeCount))
-
-	a.sendMessage(ctx, chatID, sb.String(), false)
+	a.queued = time.AfterFunc(time.Until(at), func() {
+		a.runDigest(a.digestCtx, digest.TriggerOnDemand)
+	})
+	slog.Info("digest queued", "at", at)
+	return nil
 }
 
-func (a *App) handleSettingsCommand(ctx context.Context, chatID int64, args string) {
-	if args == "" {
-		// Display current settings
-		digestTime := a.cfg.DigestTime
-		if storedTime, err := a.db.GetSetting(ctx, "digest_time"); err == nil {
-			digestTime = storedTime
-		}
+// RunDigest runs a digest for the HTTP trigger endpoint and returns its
+// stats once it has finished. Like TriggerDigest, the run uses the app's
+// digest context, so it completes even if the request is abandoned. A
+// skipped run reports no stats.
+func (a *App) RunDigest(ctx context.Context) (digest.RunStats, error) {
+	recorder := &statsRecorder{}
+	err := a.runDigest(a.digestCtx, digest.TriggerHTTP, digest.WithDeliveryObserver(recorder))
+	return recorder.stats, err
+}
 
-		articleCount := a.cfg.ArticleCount
-		if storedCount, err := a.db.GetSetting(ctx, "article_count"); err == nil {
-			if n, err := strconv.Atoi(storedCount); err == nil {
-				articleCount = n
-			}
-		}
+// statsRecorder keeps the stats of the run it observes.
+type statsRecorder struct {
+	stats digest.RunStats
+}
 
-		msg := fmt.Sprintf("Current Settings:\n\n"+
-			"📅 Digest Time: %s\n"+
-			"📰 Articles per Digest: %d\n\n"+
-			"Update with:\n"+
-			"/settings time HH:MM\n"+
-			"/settings count N", digestTime, articleCount)
+func (s *statsRecorder) OnArticleSent(article *digest.ArticleToSend, messageID int64) {}
 
-		a.sendMessage(ctx, chatID, msg, false)
-		return
-	}
+func (s *statsRecorder) OnRunComplete(stats digest.RunStats) {
+	s.stats = stats
+}
 
-	parts := strings.SplitN(args, " ", 2)
-	if len(parts) < 2 {
-		a.sendMessage(ctx, chatID, "Usage:\n/settings time HH:MM\n/settings count N", false)
-		return
+// runDigest runs a digest for the current chat, logging and returning any
+// failure. Skipping an unsubscribed chat or a run during shutdown is not a
+// failure.
+func (a *App) runDigest(ctx context.Context, trigger digest.Trigger, opts ...digest.Option) error {
+	if !a.digests.Start() {
+		slog.Info("shutting down, skipping digest")
+		return nil
 	}
+	defer a.digests.Done()
 
-	subCmd := strings.ToLower(parts[0])
-	value := strings.TrimSpace(parts[1])
-
-	switch subCmd {
-	case "time":
-		if !isValidTime(value) {
-			a.sendMessage(ctx, chatID, "Invalid time format. Use HH:MM (e.g., 09:00, 18:30)", false)
-			return
-		}
-
-		if err := a.db.SetSetting(ctx, "digest_time", value); err != nil {
-			slog.Warn("failed to save digest_time", "error", err)
-			a.sendMessage(ctx, chatID, "Failed to update settings.", false)
-			return
-		}
-
-		// Update scheduler
-		if err := a.scheduler.Schedule(value, func() {
-			a.runDigest(context.Background())
-		}); err != nil {
-			slog.Warn("failed to reschedule digest", "error", err)
-		}
-
-		a.sendMessage(ctx, chatID, fmt.Sprintf("✅ Digest time updated to %s", value), false)
+	a.mu.RLock()
+	chatID := a.chatID
+	a.mu.RUnlock()
 
-	case "count":
-		count, err := strconv.Atoi(value)
-		if err != nil || count < 1 || count > 100 {
-			a.sendMessage(ctx, chatID, "Invalid count. Must be a number between 1 and 100.", false)
-			return
-		}
+	if chatID == 0 {
+		slog.Warn("cannot run digest: no chat_id set")
+		return errors.New("no chat_id set")
+	}
 
-		if err := a.db.SetSetting(ctx, "article_count", value); err != nil {
-			slog.Warn("failed to save article_count", "error", err)
-			a.sendMessage(ctx, chatID, "Failed to update settings.", false)
-			return
+	// An unsubscribed chat only stops getting digests it didn't ask for, so
+	// /fetch still works
+	if trigger != digest.TriggerOnDemand {
+		if unsubscribed, err := a.db.IsChatUnsubscribed(ctx, chatID); err != nil {
+			slog.Warn("failed to check chat subscription", "chat_id", chatID, "error", err)
+		} else if unsubscribed {
+			slog.Debug("skipping digest for unsubscribed chat", "chat_id", chatID)
+			return nil
 		}
+	}
 
-		a.sendMessage(ctx, chatID, fmt.Sprintf("✅ Article count updated to %d", count), false)
-
-	default:
-		a.sendMessage(ctx, chatID, "Usage:\n/settings time HH:MM\n/settings count N", false)
+	runner := a.newRunner(ctx, chatID, append([]digest.Option{digest.WithTrigger(trigger)}, opts...)...)
+	err := runner.Run(ctx)
+	if errors.Is(err, digest.ErrChatUnavailable) {
+		slog.Info("digest stopped: chat is unavailable", "chat_id", chatID)
+	} else if err != nil {
+		slog.Error("digest run failed", "error", err)
 	}
+	return err
 }
 
-func (a *App) handleReaction(ctx context.Context, reaction *MessageReaction) {
-	// Check for new thumbs-up reaction
-	var hasThumbsUp bool
-	for _, r := range reaction.NewReaction {
-		if r.Emoji == "👍" {
-			hasThumbsUp = true
-			break
-		}
+// resumeDigest sends what remains of a digest the last process was
+// interrupted in the middle of sending, if any, unless the chat has
+// unsubscribed since.
+func (a *App) resumeDigest(ctx context.Context) {
+	if !a.digests.Start() {
+		return
 	}
+	defer a.digests.Done()
 
-	// Check if it was already there
-	for _, r := range reaction.OldReaction {
-		if r.Emoji == "👍" {
-			hasThumbsUp = false // It was already there, not a new reaction
-			break
-		}
+	a.mu.RLock()
+	chatID := a.chatID
+	a.mu.RUnlock()
+	if chatID == 0 {
+		return
 	}
 
-	if !hasThumbsUp {
+	if unsubscribed, err := a.db.IsChatUnsubscribed(ctx, chatID); err != nil {
+		slog.Warn("failed to check chat subscription", "chat_id", chatID, "error", err)
+	} else if unsubscribed {
+		slog.Info("not resuming interrupted digest: chat is unsubscribed", "chat_id", chatID)
 		return
 	}
 
-	msgID := int64(reaction.MessageID)
-	slog.Info("received thumbs-up reaction", "message_id", msgID)
-
-	// Look up article
-	article, err := a.db.GetArticleByMessageID(ctx, msgID)
+	sent, err := a.newRunner(ctx, chatID).Resume(ctx)
 	if err != nil {
-		if err != storage.ErrNotFound {
-			slog.Warn("failed to lookup article by message ID", "message_id", msgID, "error", err)
-		}
-		return // Not an article message or error
+		slog.Error("failed to resume interrupted digest", "sent", sent, "error", err)
+	} else if sent > 0 {
+		slog.Info("resumed interrupted digest", "sent", sent)
 	}
+}
 
-	// Check if already liked
-	liked, err := a.db.IsArticleLiked(ctx, article.ID)
-	if err != nil {
-		slog.Warn("failed to check if article liked", "article_id", article.ID, "error", err)
-		return
+// runOnce runs a single digest synchronously and returns the process exit
+// code: 0 on success and 1 on failure.
+func (a *App) runOnce(ctx context.Context) int {
+	if err := a.runDigest(ctx, digest.TriggerScheduled); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// newRunner creates a digest runner for chatID using the current settings,
+// followed by any extra options.
+func (a *App) newRunner(ctx context.Context, chatID int64, opts ...digest.Option) *digest.Runner {
+	articleCount := a.settings.ArticleCount(ctx)
+
+	explain := false
+	if v, err := a.settings.GetSetting(ctx, "explain"); err == nil {
+		explain = v == "on"
 	}
-	if liked {
-		return // Already liked, idempotent
+
+	pinned := a.cfg.PinnedTags
+	if v, err := a.settings.GetSetting(ctx, "pinned_tags"); err == nil && v != "" {
+		pinned = append(slices.Clone(pinned), strings.Split(v, ",")...)
 	}
 
-	// Record like
-	if err := a.db.LikeArticle(ctx, article.ID); err != nil {
-		slog.Warn("failed to record like", "article_id", article.ID, "error", err)
-		return
+	photos := false
+	if v, err := a.settings.GetSetting(ctx, "format"); err == nil {
+		photos = v == bot.FormatPhoto
 	}
 
-	// Boost tags
-	for _, tag := range article.Tags {
-		if err := a.db.BoostTagWeight(ctx, tag, a.cfg.TagBoostOnLike); err != nil {
-			slog.Warn("failed to boost tag", "tag", tag, "error", err)
-		}
+	var discussion digest.DiscussionSummarizer
+	if a.cfg.DiscussionSummary {
+		discussion = a.summarizer
 	}
 
-	slog.Info("processed like", "article_id", article.ID, "tags", article.Tags)
+	// The offline scraper has no pages to read canonical URLs or images from
+	pages, _ := a.scraper.(digest.PageScraper)
+
+	// Nor can the offline HN client search stories by date
+	var windowed digest.WindowedHNClient
+	if a.cfg.StoryWindow == "since_last_digest" {
+		windowed, _ = a.hnClient.(digest.WindowedHNClient)
+	}
+
+	opts = append([]digest.Option{
+		digest.WithChatID(chatID),
+		digest.WithArticleCount(articleCount),
+		digest.WithMinArticles(a.cfg.MinArticles),
+		digest.WithCandidateMultiplier(a.cfg.CandidateMultiplier),
+		digest.WithRankBeforeSummarize(a.cfg.RankBeforeSummarize),
+		digest.WithDecayRate(a.cfg.TagDecayRate),
+		digest.WithDecayMode(digest.DecayMode(a.cfg.DecayMode)),
+		digest.WithSelector(digest.NewSelector(digest.SelectionMode(a.cfg.SelectionMode))),
+		digest.WithMinTagWeight(a.cfg.MinTagWeight),
+		digest.WithExplain(explain),
+		digest.WithMinTagScore(a.cfg.MinTagScore, a.cfg.MinTagScoreLikes),
+		digest.WithDomainWeightFactor(a.cfg.DomainWeightFactor),
+		digest.WithTagCountConfidence(a.cfg.TagCountConfidence),
+		digest.WithMaxRankedTags(a.cfg.MaxRankedTags),
+		digest.WithBatchSummarizer(a.summarizer, a.cfg.SummaryBatchSize),
+		digest.WithHNConcurrency(a.cfg.HNConcurrency),
+		digest.WithScrapeConcurrency(a.cfg.ScrapeConcurrency),
+		digest.WithSendDelay(a.cfg.SendDelay, a.cfg.SendJitter),
+		digest.WithTimeouts(digest.Timeouts{
+			Item:      a.cfg.ItemTimeout,
+			Scrape:    a.cfg.ScrapeTimeout,
+			Summarize: a.cfg.SummaryTimeout,
+			Send:      a.cfg.SendTimeout,
+		}),
+		digest.WithMaxRunDuration(a.cfg.MaxRunDuration),
+		digest.WithMaxArticleAge(a.cfg.MaxArticleAge),
+		digest.WithAllowedLanguages(a.cfg.AllowedLanguages...),
+		digest.WithEmptyNotice(a.cfg.NotifyEmptyDigest),
+		digest.WithIntroOutro(a.cfg.DigestIntro, a.cfg.DigestOutro),
+		digest.WithPinnedTags(pinned...),
+		digest.WithTagAliases(a.cfg.TagAliases),
+		digest.WithDiscussionSummary(discussion),
+		digest.WithPageScraper(pages),
+		digest.WithSinceLastDigest(windowed, a.cfg.WindowMinScore),
+		digest.WithTopicSections(a.cfg.GroupByTopic),
+	}, opts...)
+
+	store := &storageAdapter{db: a.db, settings: a.settings}
+	opts = append(opts, digest.WithBatchStore(store), digest.WithSentFilter(a.db))
+	return digest.NewRunner(
+		a.hnClient,
+		a.scraper,
+		a.summarizer,
+		store,
+		&articleSenderAdapter{app: a, photos: photos},
+		opts...,
+	)
 }
 
-func (a *App) runDigest(ctx context.Context) {
+// Preview ranks the next digest's articles without sending them.
+func (a *App) Preview(ctx context.Context) ([]bot.PreviewItem, error) {
 	a.mu.RLock()
 	chatID := a.chatID
 	a.mu.RUnlock()
 
-	if chatID == 0 {
-		slog.Warn("cannot run digest: no chat_id set")
-		return
+	articles, err := a.newRunner(ctx, chatID).Preview(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	// Get article count from settings
-	articleCount := a.cfg.ArticleCount
-	if storedCount, err := a.db.GetSetting(ctx, "article_count"); err == nil {
-		if n, err := strconv.Atoi(storedCount); err == nil {
-			articleCount = n
+	items := make([]bot.PreviewItem, len(articles))
+	for i, article := range articles {
+		items[i] = bot.PreviewItem{
+			Title:   article.Title,
+			HNScore: article.HNScore,
+			Score:   article.Score,
+			Tags:    article.Tags,
 		}
 	}
+	return items, nil
+}
 
-	// Create digest runner
-	runner := digest.NewRunner(
-		&hnClientAdapter{a.hnClient},
-		&scraperAdapter{a.scraper},
-		&summarizerAdapter{a.summarizer},
-		&storageAdapter{a.db},
-		&articleSenderAdapter{a},
-		digest.WithChatID(chatID),
-		digest.WithArticleCount(articleCount),
-		digest.WithDecayRate(a.cfg.TagDecayRate),
-		digest.WithMinTagWeight(a.cfg.MinTagWeight),
-	)
+// ExpandArticle re-scrapes an article and summarizes it at greater length.
+// Text posts, which link to their own HN discussion, are summarized from
+// their HN text instead.
+func (a *App) ExpandArticle(ctx context.Context, article *bot.ArticleInfo) (string, error) {
+	var content string
+	if article.URL == fmt.Sprintf("https://news.ycombinator.com/item?id=%d", article.ID) {
+		item, err := a.hnClient.GetItem(ctx, article.ID)
+		if err != nil {
+			return "", fmt.Errorf("get item: %w", err)
+		}
+		content = item.Text
+	} else {
+		text, err := a.scraper.Scrape(ctx, article.URL)
+		if err != nil {
+			return "", fmt.Errorf("scrape: %w", err)
+		}
+		content = text
+	}
+	if content == "" {
+		content = article.Title
+	}
 
-	if err := runner.Run(ctx); err != nil {
-		slog.Error("digest run failed", "error", err)
+	result, err := a.summarizer.SummarizeExpanded(ctx, article.Title, content)
+	if err != nil {
+		return "", fmt.Errorf("summarize: %w", err)
 	}
+	return result.Summary, nil
 }
 
-func (a *App) sendMessage(ctx context.Context, chatID int64, text string, html bool) (int64, error) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if html {
-		msg.ParseMode = tgbotapi.ModeHTML
+// TestURL scrapes and summarizes a URL the way a digest would, for /test.
+// Nothing is stored, so the result neither counts as sent nor teaches the
+// ranker anything.
+func (a *App) TestURL(ctx context.Context, url string) (*bot.ArticleForDisplay, error) {
+	content, err := a.scraper.Scrape(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("scrape: %w", err)
+	}
+	if content == "" {
+		return nil, errors.New("scrape: no content found")
 	}
 
-	sent, err := a.tgBot.Send(msg)
+	// The page title isn't scraped, so the URL stands in for it
+	result, err := a.summarizer.Summarize(ctx, url, content)
 	if err != nil {
-		slog.Warn("failed to send message", "chat_id", chatID, "error", err)
-		return 0, err
+		return nil, fmt.Errorf("summarize: %w", err)
+	}
+	return &bot.ArticleForDisplay{
+		Title:       url,
+		Summary:     result.Summary,
+		URL:         url,
+		Explanation: "tags: " + strings.Join(result.Tags, ", "),
+		WordCount:   len(strings.Fields(content)),
+		Footer:      a.footer,
+	}, nil
+}
+
+func (a *App) sendMessage(ctx context.Context, chatID int64, text string, mode bot.ParseMode) (int64, error) {
+	return a.sender.SendMessage(ctx, chatID, text, mode)
+}
+
+// updateTypes returns the Telegram update types the enabled features need.
+func updateTypes(cfg *config.Config) []string {
+	types := []string{bot.UpdateMessage}
+	if !cfg.DisableReactions {
+		types = append(types, bot.UpdateMessageReaction)
 	}
-	return int64(sent.MessageID), nil
+	return types
 }
 
-func isValidTime(s string) bool {
-	if len(s) != 5 || s[2] != ':' {
-		return false
+// newHTTPClient builds the client shared by the HN client, scraper and
+// summarizer. Proxies from the config take precedence over the
+// HTTP_PROXY/HTTPS_PROXY environment variables; NO_PROXY always applies.
+// Its transport keeps enough idle connections per host for parallel
+// fetches to reuse them instead of dialing and handshaking again. Each
+// component sets its own timeout.
+func newHTTPClient(cfg *config.Config) *http.Client {
+	proxyCfg := httpproxy.FromEnvironment()
+	if cfg.HTTPProxy != "" {
+		proxyCfg.HTTPProxy = cfg.HTTPProxy
 	}
-	hour, err1 := strconv.Atoi(s[:2])
-	minute, err2 := strconv.Atoi(s[3:])
-	return err1 == nil && err2 == nil && hour >= 0 && hour <= 23 && minute >= 0 && minute <= 59
+	if cfg.HTTPSProxy != "" {
+		proxyCfg.HTTPSProxy = cfg.HTTPSProxy
+	}
+	proxyFunc := proxyCfg.ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, cfg.MaxIdleConnsPerHost)
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	return &http.Client{Transport: transport}
 }
 
 // Adapter types to bridge between our interfaces and the digest package interfaces
@@ -531,18 +878,29 @@ func (h *hnClientAdapter) GetTopStories(ctx context.Context, limit int) ([]int64
 	return h.client.GetTopStories(ctx, limit)
 }
 
+func (h *hnClientAdapter) GetStoriesSince(ctx context.Context, since time.Time, minScore, limit int) ([]int64, error) {
+	return h.client.GetStoriesSince(ctx, since, minScore, limit)
+}
+
 func (h *hnClientAdapter) GetItem(ctx context.Context, id int64) (*digest.HNItem, error) {
 	item, err := h.client.GetItem(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return &digest.HNItem{
+	hnItem := &digest.HNItem{
 		ID:          item.ID,
 		Title:       item.Title,
 		URL:         item.URL,
+		Text:        item.Text,
 		Score:       item.Score,
 		Descendants: item.Descendants,
-	}, nil
+		By:          item.By,
+		Kids:        item.Kids,
+	}
+	if item.Time != 0 {
+		hnItem.Time = time.Unix(item.Time, 0)
+	}
+	return hnItem, nil
 }
 
 type scraperAdapter struct {
@@ -553,6 +911,18 @@ func (s *scraperAdapter) Scrape(ctx context.Context, url string) (string, error)
 	return s.scraper.Scrape(ctx, url)
 }
 
+func (s *scraperAdapter) ScrapePage(ctx context.Context, url string) (*digest.ScrapedPage, error) {
+	page, err := s.scraper.ScrapePage(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &digest.ScrapedPage{Content: page.Content, Canonical: page.Canonical, ImageURL: page.ImageURL}, nil
+}
+
+func (s *scraperAdapter) CanonicalURL(url string) string {
+	return scraper.CanonicalURL(url)
+}
+
 type summarizerAdapter struct {
 	summarizer *summarizer.Summarizer
 }
@@ -565,62 +935,405 @@ func (s *summarizerAdapter) Summarize(ctx context.Context, title, content string
 	return &digest.SummaryResult{
 		Summary: result.Summary,
 		Tags:    result.Tags,
+		Model:   result.Model,
 	}, nil
 }
 
+func (s *summarizerAdapter) SummarizeBatch(ctx context.Context, inputs []digest.SummaryInput) ([]digest.SummaryResult, error) {
+	batch := make([]summarizer.Input, len(inputs))
+	for i, in := range inputs {
+		batch[i] = summarizer.Input{Title: in.Title, Content: in.Content}
+	}
+
+	results, err := s.summarizer.SummarizeBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]digest.SummaryResult, len(results))
+	for i, r := range results {
+		summaries[i] = digest.SummaryResult{Summary: r.Summary, Tags: r.Tags, Model: r.Model, Err: r.Err}
+	}
+	return summaries, nil
+}
+
+func (s *summarizerAdapter) SummarizeExpanded(ctx context.Context, title, content string) (*digest.SummaryResult, error) {
+	result, err := s.summarizer.SummarizeExpanded(ctx, title, content)
+	if err != nil {
+		return nil, err
+	}
+	return &digest.SummaryResult{Summary: result.Summary, Tags: result.Tags, Model: result.Model}, nil
+}
+
+func (s *summarizerAdapter) SummarizeDiscussion(ctx context.Context, comments []string) (string, error) {
+	return s.summarizer.SummarizeDiscussion(ctx, comments)
+}
+
 type storageAdapter struct {
-	db *storage.DB
+	db       *storage.DB
+	settings *settings.Settings
+}
+
+func (s *storageAdapter) WasSent(ctx context.Context, chatID, articleID int64) (bool, time.Time, error) {
+	return s.db.WasSent(ctx, chatID, articleID)
 }
 
-func (s *storageAdapter) GetRecentlySentArticleIDs(ctx context.Context, within time.Duration) ([]int64, error) {
-	return s.db.GetRecentlySentArticleIDs(ctx, within)
+func (s *storageAdapter) GetRecentlySentArticleIDs(ctx context.Context, chatID int64, within time.Duration) ([]int64, error) {
+	return s.db.GetRecentlySentArticleIDs(ctx, chatID, within)
 }
 
 func (s *storageAdapter) GetAllTagWeights(ctx context.Context) (map[string]float64, error) {
 	return s.db.GetAllTagWeights(ctx)
 }
 
-func (s *storageAdapter) ApplyTagDecay(ctx context.Context, decayRate, minWeight float64) error {
-	return s.db.ApplyTagDecay(ctx, decayRate, minWeight)
+func (s *storageAdapter) ApplyDecay(ctx context.Context, decayRate, minWeight float64) error {
+	return s.db.ApplyDecay(ctx, decayRate, minWeight)
+}
+
+func (s *storageAdapter) GetAllTagCounts(ctx context.Context) (map[string]int, error) {
+	return s.db.GetAllTagCounts(ctx)
+}
+
+func (s *storageAdapter) GetAllDomainWeights(ctx context.Context) (map[string]float64, error) {
+	return s.db.GetAllDomainWeights(ctx)
+}
+
+func (s *storageAdapter) GetArticleTags(ctx context.Context, articleID int64) ([]string, error) {
+	article, err := s.db.GetArticle(ctx, articleID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return article.Tags, nil
+}
+
+func (s *storageAdapter) GetFirstScore(ctx context.Context, articleID int64) (int, time.Time, error) {
+	article, err := s.db.GetArticle(ctx, articleID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return article.FirstScore, article.FirstSeenAt, nil
+}
+
+func (s *storageAdapter) GetSummaryByContentHash(ctx context.Context, articleID int64, hash string) (*digest.SummaryResult, error) {
+	article, err := s.db.GetArticleByContentHash(ctx, articleID, hash)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &digest.SummaryResult{Summary: article.Summary, Tags: article.Tags, Model: article.SummaryModel}, nil
+}
+
+func (s *storageAdapter) GetSeenArticle(ctx context.Context, articleID int64, within time.Duration) (*digest.StoredArticle, error) {
+	article, err := s.db.GetSeenArticle(ctx, articleID, within)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &digest.StoredArticle{
+		ID:           article.ID,
+		Title:        article.Title,
+		URL:          article.URL,
+		Summary:      article.Summary,
+		SummaryModel: article.SummaryModel,
+		ContentHash:  article.ContentHash,
+		Tags:         article.Tags,
+		HNScore:      article.HNScore,
+		FetchedAt:    article.FetchedAt,
+		ImageURL:     article.ImageURL,
+	}, nil
+}
+
+func (s *storageAdapter) MarkArticleSeen(ctx context.Context, articleID int64) error {
+	return s.db.MarkArticleSeen(ctx, articleID)
+}
+
+func (s *storageAdapter) GetLikeCount(ctx context.Context) (int, error) {
+	return s.db.GetLikeCount(ctx)
 }
 
 func (s *storageAdapter) SaveArticle(ctx context.Context, article *digest.StoredArticle) error {
 	return s.db.SaveArticle(ctx, &storage.Article{
-		ID:            article.ID,
-		Title:         article.Title,
-		URL:           article.URL,
-		Summary:       article.Summary,
-		Tags:          article.Tags,
-		HNScore:       article.HNScore,
-		FetchedAt:     article.FetchedAt,
-		SentAt:        article.SentAt,
-		TelegramMsgID: article.TelegramMsgID,
+		ID:           article.ID,
+		Title:        article.Title,
+		URL:          article.URL,
+		Summary:      article.Summary,
+		SummaryModel: article.SummaryModel,
+		ContentHash:  article.ContentHash,
+		Tags:         article.Tags,
+		HNScore:      article.HNScore,
+		FetchedAt:    article.FetchedAt,
+		ImageURL:     article.ImageURL,
 	})
 }
 
-func (s *storageAdapter) MarkArticleSent(ctx context.Context, articleID int64, telegramMsgID int64) error {
-	return s.db.MarkArticleSent(ctx, articleID, telegramMsgID)
+func (s *storageAdapter) MarkArticleSent(ctx context.Context, articleID, chatID, telegramMsgID int64, source string) error {
+	return s.db.MarkArticleSent(ctx, articleID, chatID, telegramMsgID, source)
 }
 
 func (s *storageAdapter) GetSetting(ctx context.Context, key string) (string, error) {
-	return s.db.GetSetting(ctx, key)
+	return s.settings.GetSetting(ctx, key)
+}
+
+func (s *storageAdapter) SetSetting(ctx context.Context, key, value string) error {
+	return s.settings.SetSetting(ctx, key, value)
+}
+
+func (s *storageAdapter) RecordFailedArticle(ctx context.Context, articleID int64, stage, errMsg string) error {
+	return s.db.RecordFailedArticle(ctx, articleID, stage, errMsg)
+}
+
+func (s *storageAdapter) GetFailedArticleIDs(ctx context.Context, maxAttempts int, within time.Duration) ([]int64, error) {
+	return s.db.GetFailedArticleIDs(ctx, maxAttempts, within)
+}
+
+func (s *storageAdapter) ClearFailedArticle(ctx context.Context, articleID int64) error {
+	return s.db.ClearFailedArticle(ctx, articleID)
+}
+
+func (s *storageAdapter) SavePendingBatch(ctx context.Context, chatID int64, batch *digest.PendingBatch) error {
+	articles, err := json.Marshal(batch.Articles)
+	if err != nil {
+		return fmt.Errorf("encode articles: %w", err)
+	}
+	return s.db.SavePendingBatch(ctx, &storage.PendingBatch{
+		ID:        batch.ID,
+		ChatID:    chatID,
+		Articles:  articles,
+		CreatedAt: batch.CreatedAt,
+	})
+}
+
+func (s *storageAdapter) GetPendingBatch(ctx context.Context, chatID int64) (*digest.PendingBatch, error) {
+	stored, err := s.db.GetPendingBatch(ctx, chatID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	batch := &digest.PendingBatch{ID: stored.ID, CreatedAt: stored.CreatedAt}
+	if err := json.Unmarshal(stored.Articles, &batch.Articles); err != nil {
+		return nil, fmt.Errorf("decode articles: %w", err)
+	}
+	return batch, nil
+}
+
+func (s *storageAdapter) DeletePendingBatch(ctx context.Context, chatID int64, batchID string) error {
+	return s.db.DeletePendingBatch(ctx, chatID, batchID)
 }
 
 type articleSenderAdapter struct {
-	app *App
+	app    *App
+	photos bool // Send articles as photos where the page has an image
 }
 
 func (a *articleSenderAdapter) SendArticle(ctx context.Context, chatID int64, article *digest.ArticleToSend) (int64, error) {
-	msg := bot.FormatArticleMessage(&bot.ArticleForDisplay{
-		ID:       article.ID,
-		Title:    article.Title,
-		Summary:  article.Summary,
-		HNScore:  article.HNScore,
-		Comments: article.Comments,
-		URL:      article.URL,
-	})
-	return a.app.sendMessage(ctx, chatID, msg, true)
+	display := &bot.ArticleForDisplay{
+		ID:          article.ID,
+		Title:       article.Title,
+		Summary:     article.Summary,
+		HNScore:     article.HNScore,
+		Comments:    article.Comments,
+		URL:         article.URL,
+		Explanation: article.Explanation,
+		Discussion:  article.Discussion,
+		WordCount:   article.WordCount,
+		PostedAt:    article.PostedAt,
+		Source:      article.Source,
+		By:          article.By,
+		FirstScore:  article.FirstScore,
+		FirstSeenAt: article.FirstSeenAt,
+		Footer:      a.app.footer,
+		MaxLength:   a.app.cfg.MaxMessageLength,
+	}
+	if a.photos {
+		display.ImageURL = article.ImageURL
+	}
+	msgID, err := bot.SendArticle(ctx, a.app.sender, chatID, display)
+	if errors.Is(err, bot.ErrChatUnavailable) {
+		return 0, fmt.Errorf("%w: %v", digest.ErrChatUnavailable, err)
+	}
+	return msgID, err
+}
+
+func (a *articleSenderAdapter) SendNotice(ctx context.Context, chatID int64, text string) error {
+	_, err := a.app.sendMessage(ctx, chatID, text, bot.ParseModeNone)
+	if errors.Is(err, bot.ErrChatUnavailable) {
+		return fmt.Errorf("%w: %v", digest.ErrChatUnavailable, err)
+	}
+	return err
+}
+
+// Adapter types to bridge between storage and the bot package interfaces
+
+type messageSenderAdapter struct {
+	app *App
+}
+
+func (m *messageSenderAdapter) SendMessage(ctx context.Context, chatID int64, text string, mode bot.ParseMode) (int64, error) {
+	return m.app.sendMessage(ctx, chatID, text, mode)
+}
+
+type botStorageAdapter struct {
+	db *storage.DB
+}
+
+func (s *botStorageAdapter) GetSetting(ctx context.Context, key string) (string, error) {
+	value, err := s.db.GetSetting(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", bot.ErrSettingNotFound
+	}
+	return value, err
+}
+
+func (s *botStorageAdapter) SetSetting(ctx context.Context, key, value string) error {
+	return s.db.SetSetting(ctx, key, value)
+}
+
+func (s *botStorageAdapter) IsArticleLiked(ctx context.Context, articleID int64) (bool, error) {
+	return s.db.IsArticleLiked(ctx, articleID)
+}
+
+func (s *botStorageAdapter) LikeArticle(ctx context.Context, articleID, chatID int64) error {
+	return s.db.LikeArticle(ctx, articleID, chatID)
+}
+
+func (s *botStorageAdapter) GetLikeCount(ctx context.Context) (int, error) {
+	return s.db.GetLikeCount(ctx)
+}
+
+func (s *botStorageAdapter) GetLikeCountSince(ctx context.Context, since time.Time) (int, error) {
+	return s.db.GetLikeCountSince(ctx, since)
+}
+
+func (s *botStorageAdapter) GetSentCountsBySource(ctx context.Context, chatID int64, within time.Duration) ([]bot.SourceStat, error) {
+	counts, err := s.db.GetSentCountsBySource(ctx, chatID, within)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]bot.SourceStat, len(counts))
+	for i, c := range counts {
+		stats[i] = bot.SourceStat{Source: c.Source, Count: c.Count}
+	}
+	return stats, nil
+}
+
+func (s *botStorageAdapter) BoostTagWeights(ctx context.Context, boosts map[string]float64) error {
+	return s.db.BoostTagWeights(ctx, boosts)
+}
+
+func (s *botStorageAdapter) GetTagCount(ctx context.Context, tag string) (int, error) {
+	return s.db.GetTagCount(ctx, tag)
 }
 
-// Ensure ranker package is used (it's used internally by digest)
-var _ = ranker.NewRanker
+func (s *botStorageAdapter) GetTagWeightsPaged(ctx context.Context, limit, offset int) ([]bot.TagStat, error) {
+	tags, err := s.db.GetTagWeightsPaged(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]bot.TagStat, len(tags))
+	for i, t := range tags {
+		stats[i] = bot.TagStat{Tag: t.Tag, Weight: t.Weight}
+	}
+	return stats, nil
+}
+
+func (s *botStorageAdapter) GetTagWeightHistory(ctx context.Context, tag string, since time.Time) ([]bot.TagWeightPoint, error) {
+	history, err := s.db.GetTagWeightHistory(ctx, tag, since)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]bot.TagWeightPoint, len(history))
+	for i, p := range history {
+		points[i] = bot.TagWeightPoint{Weight: p.Weight, RecordedAt: p.RecordedAt}
+	}
+	return points, nil
+}
+
+func (s *botStorageAdapter) RecentSentArticles(ctx context.Context, chatID int64, limit int) ([]bot.SentArticle, error) {
+	sent, err := s.db.RecentSentArticles(ctx, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]bot.SentArticle, len(sent))
+	for i, a := range sent {
+		articles[i] = bot.SentArticle{Title: a.Title, URL: a.URL, SentAt: a.SentAt}
+	}
+	return articles, nil
+}
+
+func (s *botStorageAdapter) ClearPreferences(ctx context.Context) (*bot.ResetSummary, error) {
+	cleared, err := s.db.ClearPreferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &bot.ResetSummary{
+		Tags:     int(cleared.Tags),
+		Domains:  int(cleared.Domains),
+		Likes:    int(cleared.Likes),
+		Dislikes: int(cleared.Dislikes),
+	}, nil
+}
+
+func (s *botStorageAdapter) BoostDomainWeight(ctx context.Context, domain string, boost float64) error {
+	return s.db.BoostDomainWeight(ctx, domain, boost)
+}
+
+func (s *botStorageAdapter) GetTopDomains(ctx context.Context, limit int) ([]bot.DomainStat, error) {
+	domains, err := s.db.GetTopDomains(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]bot.DomainStat, len(domains))
+	for i, d := range domains {
+		stats[i] = bot.DomainStat{Domain: d.Domain, Weight: d.Weight}
+	}
+	return stats, nil
+}
+
+func (s *botStorageAdapter) UndoLastLike(ctx context.Context, chatID int64, minWeight float64) (*bot.ArticleInfo, error) {
+	article, err := s.db.UndoLastLike(ctx, chatID, minWeight)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, bot.ErrNoLikeToUndo
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bot.ArticleInfo{
+		ID:     article.ID,
+		Title:  article.Title,
+		URL:    article.URL,
+		Tags:   article.Tags,
+		Domain: ranker.Domain(article.URL),
+	}, nil
+}
+
+func (s *botStorageAdapter) AddArticleMessage(ctx context.Context, articleID, chatID, msgID int64) error {
+	return s.db.AddArticleMessage(ctx, articleID, chatID, msgID)
+}
+
+func (s *botStorageAdapter) GetArticleByMessageID(ctx context.Context, chatID, msgID int64) (*bot.ArticleInfo, error) {
+	article, err := s.db.GetArticleByMessageID(ctx, chatID, msgID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, bot.ErrArticleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bot.ArticleInfo{
+		ID:     article.ID,
+		Title:  article.Title,
+		URL:    article.URL,
+		Tags:   article.Tags,
+		Domain: ranker.Domain(article.URL),
+	}, nil
+}
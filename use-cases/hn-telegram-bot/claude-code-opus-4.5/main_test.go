@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"hn-telegram-bot/bot"
+	"hn-telegram-bot/config"
+	"hn-telegram-bot/digest"
+	"hn-telegram-bot/hn"
+	"hn-telegram-bot/offline"
+	"hn-telegram-bot/scheduler"
+	"hn-telegram-bot/scraper"
+	"hn-telegram-bot/settings"
+	"hn-telegram-bot/storage"
+)
+
+func TestOfflineDigest(t *testing.T) {
+	app, sender := newOfflineApp(t)
+	db := app.db
+
+	app.runDigest(context.Background(), digest.TriggerScheduled)
+
+	if n := sender.Sent(); n != 5 {
+		t.Errorf("sent %d messages, want 5", n)
+	}
+	if n, err := db.GetSentArticleCount(context.Background()); err != nil || n != 5 {
+		t.Errorf("sent article count = %d (err %v), want 5", n, err)
+	}
+}
+
+type failingHNClient struct{}
+
+func (failingHNClient) GetTopStories(ctx context.Context, limit int) ([]int64, error) {
+	return nil, errors.New("hn unavailable")
+}
+
+func (failingHNClient) GetItem(ctx context.Context, id int64) (*digest.HNItem, error) {
+	return nil, errors.New("hn unavailable")
+}
+
+func TestRunOnce(t *testing.T) {
+	app, sender := newOfflineApp(t)
+	if code := app.runOnce(context.Background()); code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	if n := sender.Sent(); n != 5 {
+		t.Errorf("sent %d messages, want 5", n)
+	}
+
+	app, sender = newOfflineApp(t)
+	app.hnClient = failingHNClient{}
+	if code := app.runOnce(context.Background()); code != 1 {
+		t.Errorf("exit code when HN is unavailable = %d, want 1", code)
+	}
+	if n := sender.Sent(); n != 0 {
+		t.Errorf("sent %d messages after a failed run, want 0", n)
+	}
+
+	app, _ = newOfflineApp(t)
+	app.chatID = 0
+	if code := app.runOnce(context.Background()); code != 1 {
+		t.Errorf("exit code without a chat = %d, want 1", code)
+	}
+}
+
+// newOfflineApp returns an app wired to the offline fakes and a fresh
+// database, set up to send five articles.
+func TestEmptyDigestNotice(t *testing.T) {
+	tests := []struct {
+		trigger digest.Trigger
+		want    int
+	}{
+		{digest.TriggerOnDemand, 1},
+		{digest.TriggerScheduled, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.trigger), func(t *testing.T) {
+			app, sender := newOfflineApp(t)
+			app.hnClient = offline.NewHNClient(0)
+
+			if err := app.runDigest(context.Background(), tt.trigger); err != nil {
+				t.Fatalf("runDigest failed: %v", err)
+			}
+			if n := sender.Sent(); n != tt.want {
+				t.Errorf("sent %d messages, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribedChatSkipsScheduledDigest(t *testing.T) {
+	tests := []struct {
+		trigger digest.Trigger
+		want    int
+	}{
+		{digest.TriggerScheduled, 0},
+		{digest.TriggerHTTP, 0},
+		{digest.TriggerOnDemand, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.trigger), func(t *testing.T) {
+			app, sender := newOfflineApp(t)
+			ctx := context.Background()
+			if err := app.db.UnsubscribeChat(ctx, offlineChatID, "unsubscribed with /unsubscribe"); err != nil {
+				t.Fatalf("UnsubscribeChat failed: %v", err)
+			}
+
+			if err := app.runDigest(ctx, tt.trigger); err != nil {
+				t.Fatalf("runDigest failed: %v", err)
+			}
+			if n := sender.Sent(); n != tt.want {
+				t.Errorf("sent %d messages, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestResumeDigestSkipsUnsubscribedChat(t *testing.T) {
+	for _, unsubscribed := range []bool{false, true} {
+		app, sender := newOfflineApp(t)
+		ctx := context.Background()
+		store := &storageAdapter{db: app.db, settings: app.settings}
+		batch := &digest.PendingBatch{
+			ID:        "interrupted",
+			CreatedAt: time.Now(),
+			Articles:  []*digest.ArticleToSend{{ID: 1, Title: "Unsent", URL: "https://example.com/1"}},
+		}
+		if err := store.SavePendingBatch(ctx, offlineChatID, batch); err != nil {
+			t.Fatalf("SavePendingBatch failed: %v", err)
+		}
+		if unsubscribed {
+			if err := app.db.UnsubscribeChat(ctx, offlineChatID, "unsubscribed with /unsubscribe"); err != nil {
+				t.Fatalf("UnsubscribeChat failed: %v", err)
+			}
+		}
+
+		app.resumeDigest(ctx)
+
+		want := 1
+		if unsubscribed {
+			want = 0
+		}
+		if n := sender.Sent(); n != want {
+			t.Errorf("unsubscribed %v: sent %d messages, want %d", unsubscribed, n, want)
+		}
+	}
+}
+
+func TestReactionOnExpandedSummaryLikesOnce(t *testing.T) {
+	app, sender := newOfflineApp(t)
+	db := app.db
+	ctx := context.Background()
+	botStore := &botStorageAdapter{db}
+	sched, err := scheduler.NewScheduler("UTC")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	app.commands = bot.NewCommandHandler(&messageSenderAdapter{app}, botStore, sched, botStore, botStore,
+		bot.WithExpander(botStore, app))
+
+	article := &storage.Article{ID: 1, Title: "Test", URL: "https://example.com/1", Tags: []string{"go"}, FetchedAt: time.Now()}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+	if err := db.MarkArticleSent(ctx, 1, offlineChatID, 10, "top"); err != nil {
+		t.Fatalf("MarkArticleSent failed: %v", err)
+	}
+
+	app.handleMessage(ctx, &tgbotapi.Message{
+		Text:           "/expand",
+		Chat:           &tgbotapi.Chat{ID: offlineChatID},
+		ReplyToMessage: &tgbotapi.Message{MessageID: 10},
+	})
+	app.slow.Wait()
+	if sender.Sent() != 1 {
+		t.Fatalf("sent %d messages, want the expanded summary", sender.Sent())
+	}
+	expanded := int64(sender.Sent())
+
+	reactions := bot.NewReactionHandler(botStore, botStore, botStore, 1.0)
+	for _, msgID := range []int64{expanded, 10} {
+		if err := reactions.HandleReaction(ctx, offlineChatID, msgID, "👍"); err != nil {
+			t.Fatalf("message %d: HandleReaction failed: %v", msgID, err)
+		}
+	}
+
+	if n, err := db.GetLikeCount(ctx); err != nil || n != 1 {
+		t.Errorf("like count = %d (err %v), want 1", n, err)
+	}
+	if w, err := db.GetTagWeight(ctx, "go"); err != nil || w != 2.0 {
+		t.Errorf("go weight = %f (err %v), want one boost to 2.0", w, err)
+	}
+}
+
+func newOfflineApp(t *testing.T) (*App, *offline.Sender) {
+	t.Helper()
+	db, err := storage.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sender := offline.NewSender(io.Discard)
+	app := &App{
+		cfg: &config.Config{
+			ArticleCount:      5,
+			TagDecayRate:      0.02,
+			DecayMode:         string(digest.DecayPerRun),
+			MinTagWeight:      0.1,
+			HNConcurrency:     8,
+			ScrapeConcurrency: 4,
+			ScrapeTimeout:     30 * time.Second,
+			SummaryTimeout:    2 * time.Minute,
+			Offline:           true,
+		},
+		db:         db,
+		sender:     sender,
+		hnClient:   offline.NewHNClient(offlineStoryCount),
+		scraper:    offline.Scraper{},
+		summarizer: offline.Summarizer{},
+		digests:    &digest.Tracker{},
+		chatID:     offlineChatID,
+		settings:   settings.New(&botStorageAdapter{db}, settings.Defaults{ArticleCount: 5}),
+	}
+	return app, sender
+}
+
+func TestHTTPClientUsesConfiguredProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+
+	// A forward proxy receives the absolute target URL and answers in
+	// place of the real hosts, which don't exist
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+
+		switch r.URL.Host {
+		case "hn.invalid":
+			fmt.Fprint(w, "[1, 2, 3]")
+		default:
+			fmt.Fprint(w, "<html><body><article><p>Proxied article content that is long enough to keep.</p></article></body></html>")
+		}
+	}))
+	defer proxy.Close()
+
+	httpClient := newHTTPClient(&config.Config{HTTPProxy: proxy.URL})
+	ctx := context.Background()
+
+	ids, err := hn.NewClient(hn.WithHTTPClient(httpClient), hn.WithBaseURL("http://hn.invalid")).GetTopStories(ctx, 3)
+	if err != nil {
+		t.Fatalf("GetTopStories through proxy failed: %v", err)
+	}
+	if len(ids) != 3 {
+		t.Errorf("story IDs = %v, want 3", ids)
+	}
+
+	if _, err := scraper.NewScraper(scraper.WithHTTPClient(httpClient)).Scrape(ctx, "http://example.invalid/post"); err != nil {
+		t.Fatalf("Scrape through proxy failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"http://hn.invalid/v0/topstories.json", "http://example.invalid/post"}
+	if len(proxied) != len(want) {
+		t.Fatalf("proxied requests = %v, want %v", proxied, want)
+	}
+	for i := range want {
+		if proxied[i] != want[i] {
+			t.Errorf("proxied request %d = %q, want %q", i, proxied[i], want[i])
+		}
+	}
+}
+
+// newConnCountingServer starts a server that answers every request with
+// body, after waiting for inFlight requests to arrive together if set,
+// and counts the connections clients open to it.
+func newConnCountingServer(t *testing.T, body string, inFlight *sync.WaitGroup) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inFlight != nil {
+			inFlight.Done()
+			inFlight.Wait()
+		}
+		fmt.Fprint(w, body)
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+func TestHTTPClientReusesConnections(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	server, conns := newConnCountingServer(t, `{"id": 1, "title": "Story"}`, nil)
+
+	// The HN client and the scraper copy the shared client, keeping its
+	// transport
+	httpClient := newHTTPClient(&config.Config{})
+	hnClient := hn.NewClient(hn.WithHTTPClient(httpClient), hn.WithBaseURL(server.URL))
+	pageScraper := scraper.NewScraper(scraper.WithHTTPClient(httpClient))
+	ctx := context.Background()
+	for range 3 {
+		if _, err := hnClient.GetItem(ctx, 1); err != nil {
+			t.Fatalf("GetItem failed: %v", err)
+		}
+		pageScraper.Scrape(ctx, server.URL+"/post")
+	}
+
+	if n := conns.Load(); n != 1 {
+		t.Errorf("opened %d connections for 6 sequential requests, want 1", n)
+	}
+}
+
+func TestHTTPClientKeepsIdleConnectionsPerHost(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	const parallel = 8
+	var inFlight sync.WaitGroup
+	server, conns := newConnCountingServer(t, "ok", &inFlight)
+
+	// Each burst holds parallel connections open at once, so the second
+	// only reuses them all if the first left them idle
+	httpClient := newHTTPClient(&config.Config{MaxIdleConnsPerHost: parallel})
+	for range 2 {
+		inFlight.Add(parallel)
+		var done sync.WaitGroup
+		for range parallel {
+			done.Go(func() {
+				resp, err := httpClient.Get(server.URL)
+				if err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			})
+		}
+		done.Wait()
+	}
+
+	if n := conns.Load(); n != parallel {
+		t.Errorf("opened %d connections for two bursts of %d requests, want %d", n, parallel, parallel)
+	}
+}
+
+func TestUpdateTypes(t *testing.T) {
+	if got := updateTypes(&config.Config{}); len(got) != 2 || got[1] != bot.UpdateMessageReaction {
+		t.Errorf("updateTypes = %v, want messages and reactions", got)
+	}
+	if got := updateTypes(&config.Config{DisableReactions: true}); len(got) != 1 || got[0] != bot.UpdateMessage {
+		t.Errorf("updateTypes with reactions disabled = %v, want messages only", got)
+	}
+}
+
+func TestCommandText(t *testing.T) {
+	tests := []struct {
+		text    string
+		botName string
+		want    string
+		wantOK  bool
+	}{
+		{"/start", "mybot", "/start", true},
+		{"/start@mybot", "mybot", "/start", true},
+		{"/start@MyBot", "mybot", "/start", true},
+		{"/settings@mybot time 09:00", "mybot", "/settings time 09:00", true},
+		{"/start@otherbot", "mybot", "", false},
+		{"/start@mybot", "", "/start", true},
+		{"hello @mybot", "mybot", "hello @mybot", true},
+	}
+	for _, tt := range tests {
+		got, ok := commandText(tt.text, tt.botName)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("commandText(%q, %q) = %q, %v, want %q, %v", tt.text, tt.botName, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestGroupCommandsDispatch(t *testing.T) {
+	app, sender := newOfflineApp(t)
+	app.botName = "mybot"
+	sched, err := scheduler.NewScheduler("UTC")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	botStore := &botStorageAdapter{app.db}
+	app.commands = bot.NewCommandHandler(&messageSenderAdapter{app}, botStore, sched, botStore, botStore)
+	ctx := context.Background()
+
+	const groupID = -1001234567890
+	message := func(text string) *tgbotapi.Message {
+		return &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{ID: groupID, Type: "supergroup"}}
+	}
+
+	app.handleMessage(ctx, message("/subscribe@mybot"))
+	if app.chatID != groupID {
+		t.Errorf("chat ID = %d, want the group's %d", app.chatID, groupID)
+	}
+	if v, err := app.db.GetSetting(ctx, "chat_id"); err != nil || v != "-1001234567890" {
+		t.Errorf("stored chat_id = %q (err %v), want the group's", v, err)
+	}
+
+	app.handleMessage(ctx, message("/settings@mybot time 07:30"))
+	if v, err := app.db.GetSetting(ctx, "digest_time"); err != nil || v != "07:30" {
+		t.Errorf("digest_time = %q (err %v), want 07:30", v, err)
+	}
+
+	before := sender.Sent()
+	app.handleMessage(ctx, message("/stats"))
+	if n := sender.Sent() - before; n != 1 {
+		t.Errorf("/stats sent %d messages, want 1", n)
+	}
+
+	before = sender.Sent()
+	app.handleMessage(ctx, message("/stats@otherbot"))
+	if n := sender.Sent() - before; n != 0 {
+		t.Errorf("/stats for another bot sent %d messages, want 0", n)
+	}
+}
+
+// lockDatabase holds an exclusive lock on the database at path from
+// another connection, so that reads fail as busy until unlock is called.
+func lockDatabase(t *testing.T, path string) (unlock func()) {
+	t.Helper()
+	other, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open second connection: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("get connection: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("lock database: %v", err)
+	}
+
+	var once sync.Once
+	unlock = func() {
+		once.Do(func() {
+			conn.ExecContext(context.Background(), "COMMIT")
+			conn.Close()
+		})
+	}
+	t.Cleanup(unlock)
+	return unlock
+}
+
+// newCommandApp returns an offline app with a database at a known path
+// and command handling set up, and the buffer its replies are written to.
+// The database gives up on locks quickly, so that busy tests stay fast.
+func newCommandApp(t *testing.T) (*App, string, *bytes.Buffer) {
+	t.Helper()
+	app, _ := newOfflineApp(t)
+	path := filepath.Join(t.TempDir(), "commands.db")
+	db, err := storage.NewDB(path, storage.WithBusyTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	app.db = db
+	app.settings = settings.New(&botStorageAdapter{db}, settings.Defaults{ArticleCount: 5})
+
+	var out bytes.Buffer
+	app.sender = offline.NewSender(&out)
+	sched, err := scheduler.NewScheduler("UTC")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	botStore := &botStorageAdapter{db}
+	app.commands = bot.NewCommandHandler(&messageSenderAdapter{app}, botStore, sched, botStore, botStore)
+	return app, path, &out
+}
+
+func TestCommandWaitsWhileDatabaseBusy(t *testing.T) {
+	app, path, out := newCommandApp(t)
+	unlock := lockDatabase(t, path)
+	time.AfterFunc(60*time.Millisecond, unlock)
+
+	app.handleMessage(context.Background(), &tgbotapi.Message{Text: "/stats", Chat: &tgbotapi.Chat{ID: offlineChatID}})
+
+	// With no likes yet, /stats replies with a hint rather than stats
+	if got := out.String(); !strings.Contains(got, "No likes yet") {
+		t.Errorf("reply = %q, want the /stats reply after the lock was released", got)
+	}
+}
+
+func TestCommandRepliesWhenDatabaseStaysBusy(t *testing.T) {
+	app, path, out := newCommandApp(t)
+	lockDatabase(t, path)
+
+	app.handleMessage(context.Background(), &tgbotapi.Message{Text: "/stats", Chat: &tgbotapi.Chat{ID: offlineChatID}})
+
+	if got := out.String(); !strings.Contains(got, "Temporary error") {
+		t.Errorf("reply = %q, want the temporary error notice", got)
+	}
+}
+
+// countingScraper and countingSummarizer count the pipeline steps /test runs.
+type countingScraper struct {
+	offline.Scraper
+	scraped []string
+}
+
+func (s *countingScraper) Scrape(ctx context.Context, url string) (string, error) {
+	s.scraped = append(s.scraped, url)
+	return s.Scraper.Scrape(ctx, url)
+}
+
+type countingSummarizer struct {
+	offline.Summarizer
+	summarized int
+}
+
+func (s *countingSummarizer) Summarize(ctx context.Context, title, content string) (*digest.SummaryResult, error) {
+	s.summarized++
+	return s.Summarizer.Summarize(ctx, title, content)
+}
+
+func TestTestCommandStoresNothing(t *testing.T) {
+	app, _, out := newCommandApp(t)
+	scraper := &countingScraper{}
+	summarizer := &countingSummarizer{}
+	app.scraper = scraper
+	app.summarizer = summarizer
+	sched, err := scheduler.NewScheduler("UTC")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	botStore := &botStorageAdapter{app.db}
+	app.commands = bot.NewCommandHandler(&messageSenderAdapter{app}, botStore, sched, botStore, botStore,
+		bot.WithURLTester(app),
+		bot.WithConfig(bot.HandlerConfig{AdminChatIDs: []int64{offlineChatID}}))
+
+	ctx := context.Background()
+	app.handleMessage(ctx, &tgbotapi.Message{Text: "/test https://example.com/post", Chat: &tgbotapi.Chat{ID: offlineChatID}})
+	app.slow.Wait()
+
+	if len(scraper.scraped) != 1 || scraper.scraped[0] != "https://example.com/post" {
+		t.Errorf("scraped %v, want the URL once", scraper.scraped)
+	}
+	if summarizer.summarized != 1 {
+		t.Errorf("summarized %d times, want once", summarizer.summarized)
+	}
+	if got := out.String(); !strings.Contains(got, "Offline summary of https://example.com/post") {
+		t.Errorf("reply = %q, want the summary", got)
+	}
+
+	if n, err := app.db.GetSentArticleCount(ctx); err != nil || n != 0 {
+		t.Errorf("sent articles = %d (err %v), want none", n, err)
+	}
+	if weights, err := app.db.GetAllTagWeights(ctx); err != nil || len(weights) != 0 {
+		t.Errorf("tag weights = %v (err %v), want none", weights, err)
+	}
+	if article, err := app.db.GetArticleByMessageID(ctx, offlineChatID, 1); err == nil {
+		t.Errorf("stored article %+v, want none", article)
+	}
+}
+
+// stalledScraper never finishes a scrape before its context is done.
+type stalledScraper struct {
+	offline.Scraper
+}
+
+func (stalledScraper) Scrape(ctx context.Context, url string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestExpandRunsInBackgroundWithDeadline(t *testing.T) {
+	app, _, out := newCommandApp(t)
+	app.scraper = stalledScraper{}
+	app.cfg.ScrapeTimeout = 50 * time.Millisecond
+	app.cfg.SummaryTimeout = 0
+	ctx := context.Background()
+	if err := app.db.SaveArticle(ctx, &storage.Article{ID: 1, Title: "Slow", URL: "https://example.com/slow", FetchedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+	if err := app.db.MarkArticleSent(ctx, 1, offlineChatID, 10, "top"); err != nil {
+		t.Fatalf("MarkArticleSent failed: %v", err)
+	}
+	sched, err := scheduler.NewScheduler("UTC")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	botStore := &botStorageAdapter{app.db}
+	app.commands = bot.NewCommandHandler(&messageSenderAdapter{app}, botStore, sched, botStore, botStore,
+		bot.WithExpander(botStore, app))
+
+	start := time.Now()
+	app.handleMessage(ctx, &tgbotapi.Message{
+		Text:           "/expand",
+		Chat:           &tgbotapi.Chat{ID: offlineChatID},
+		ReplyToMessage: &tgbotapi.Message{MessageID: 10},
+	})
+	if elapsed := time.Since(start); elapsed >= app.cfg.ScrapeTimeout {
+		t.Errorf("handleMessage blocked for %s, want it to return before the scrape ends", elapsed)
+	}
+
+	app.slow.Wait()
+	if got := out.String(); !strings.Contains(got, "took too long") {
+		t.Errorf("reply = %q, want the timeout notice", got)
+	}
+}
@@ -0,0 +1,169 @@
+// Package offline provides fakes for Hacker News, scraping, summarization
+// and Telegram so the digest pipeline can run locally without network
+// access or credentials.
+package offline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"hn-telegram-bot/bot"
+	"hn-telegram-bot/digest"
+)
+
+const loremIpsum = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod " +
+	"tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis " +
+	"nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat."
+
+// stories are the canned story titles, each paired with the tags the fake
+// summarizer assigns to it.
+var stories = []struct {
+	title string
+	tags  []string
+}{
+	{"Go 1.25 released with faster garbage collection", []string{"go", "performance"}},
+	{"Show HN: A tiny SQLite-backed job queue", []string{"sqlite", "databases", "show-hn"}},
+	{"Rust in the Linux kernel: one year later", []string{"rust", "linux"}},
+	{"Why we moved off Kubernetes", []string{"kubernetes", "devops"}},
+	{"Ask HN: How do you organize personal notes?", []string{"ask-hn", "productivity"}},
+	{"Understanding CPU caches with simple benchmarks", []string{"performance", "hardware"}},
+	{"The hidden cost of microservices", []string{"architecture", "devops"}},
+	{"Postgres query planning explained", []string{"postgres", "databases"}},
+	{"Writing a Lisp interpreter in a weekend", []string{"lisp", "compilers"}},
+	{"A visual guide to TLS 1.3", []string{"security", "networking"}},
+}
+
+// HNClient serves a fixed list of stories.
+type HNClient struct {
+	items []*digest.HNItem
+}
+
+// NewHNClient creates a client serving n canned stories, cycling through
+// the built-in titles.
+func NewHNClient(n int) *HNClient {
+	items := make([]*digest.HNItem, n)
+	for i := range items {
+		id := int64(i + 1)
+		items[i] = &digest.HNItem{
+			ID:          id,
+			Title:       storyTitle(id),
+			URL:         fmt.Sprintf("https://example.com/offline/%d", id),
+			Score:       500 - (i*37)%450,
+			Descendants: (i * 13) % 200,
+		}
+	}
+	return &HNClient{items: items}
+}
+
+// GetTopStories returns up to limit story IDs in rank order.
+func (c *HNClient) GetTopStories(ctx context.Context, limit int) ([]int64, error) {
+	n := min(limit, len(c.items))
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = c.items[i].ID
+	}
+	return ids, nil
+}
+
+// GetItem returns the story with the given ID.
+func (c *HNClient) GetItem(ctx context.Context, id int64) (*digest.HNItem, error) {
+	if id < 1 || int(id) > len(c.items) {
+		return nil, fmt.Errorf("item %d not found", id)
+	}
+	item := *c.items[id-1]
+	return &item, nil
+}
+
+// Scraper returns placeholder content for every URL.
+type Scraper struct{}
+
+// Scrape returns lorem ipsum text.
+func (Scraper) Scrape(ctx context.Context, url string) (string, error) {
+	return loremIpsum, nil
+}
+
+// Summarizer returns a deterministic summary built from the title.
+type Summarizer struct{}
+
+// Summarize returns a templated summary and the tags of the matching canned
+// story, if any.
+func (Summarizer) Summarize(ctx context.Context, title, content string) (*digest.SummaryResult, error) {
+	return &digest.SummaryResult{
+		Summary: fmt.Sprintf("Offline summary of %s. %d characters of content were read.", title, len(content)),
+		Tags:    storyTags(title),
+		Model:   "offline",
+	}, nil
+}
+
+// SummarizeBatch summarizes each input in order.
+func (s Summarizer) SummarizeBatch(ctx context.Context, inputs []digest.SummaryInput) ([]digest.SummaryResult, error) {
+	results := make([]digest.SummaryResult, len(inputs))
+	for i, in := range inputs {
+		result, _ := s.Summarize(ctx, in.Title, in.Content)
+		results[i] = *result
+	}
+	return results, nil
+}
+
+// SummarizeExpanded returns a longer templated summary.
+func (s Summarizer) SummarizeExpanded(ctx context.Context, title, content string) (*digest.SummaryResult, error) {
+	result, _ := s.Summarize(ctx, title, content)
+	result.Summary = fmt.Sprintf("Expanded offline summary of %s, with more detail. %d characters of content were read.", title, len(content))
+	return result, nil
+}
+
+// SummarizeDiscussion returns a templated take on the comments.
+func (Summarizer) SummarizeDiscussion(ctx context.Context, comments []string) (string, error) {
+	return fmt.Sprintf("Offline take on %d comments.", len(comments)), nil
+}
+
+// Sender writes messages to a writer instead of Telegram.
+type Sender struct {
+	w      io.Writer
+	mu     sync.Mutex
+	lastID int64
+}
+
+// NewSender creates a sender that writes each message to w.
+func NewSender(w io.Writer) *Sender {
+	return &Sender{w: w}
+}
+
+// SendMessage writes the message and returns a sequential message ID.
+func (s *Sender) SendMessage(ctx context.Context, chatID int64, text string, mode bot.ParseMode) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	if _, err := fmt.Fprintf(s.w, "--- message %d to chat %d ---\n%s\n\n", s.lastID, chatID, text); err != nil {
+		return 0, err
+	}
+	return s.lastID, nil
+}
+
+// Sent returns the number of messages written so far.
+func (s *Sender) Sent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.lastID)
+}
+
+func storyTitle(id int64) string {
+	title := stories[int(id-1)%len(stories)].title
+	if round := int(id-1) / len(stories); round > 0 {
+		title = fmt.Sprintf("%s (part %d)", title, round+1)
+	}
+	return title
+}
+
+func storyTags(title string) []string {
+	for _, s := range stories {
+		if strings.HasPrefix(title, s.title) {
+			return s.tags
+		}
+	}
+	return nil
+}
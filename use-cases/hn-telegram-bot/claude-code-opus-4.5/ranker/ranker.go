@@ -1,8 +1,12 @@
 package ranker
 
 import (
+	"cmp"
 	"math"
+	"net/url"
+	"slices"
 	"sort"
+	"strings"
 )
 
 // RankableArticle contains the data needed for ranking.
@@ -10,6 +14,15 @@ type RankableArticle struct {
 	ID      int64
 	Tags    []string
 	HNScore int
+	Domain  string
+}
+
+// TagContribution is a learned tag weight that contributed to an article's
+// score. Weight is what the tag added to the tag score: its learned weight,
+// scaled by its confidence if tag counts are set.
+type TagContribution struct {
+	Tag    string
+	Weight float64
 }
 
 // RankedArticle contains an article with its computed scores.
@@ -17,24 +30,88 @@ type RankedArticle struct {
 	RankableArticle
 	TagScore         float64
 	HNScoreComponent float64
+	DomainScore      float64
 	FinalScore       float64
+	MatchedTags      []TagContribution // Learned tags, highest weight first
+}
+
+// MatchedScore returns the summed weight of the article's learned tags.
+func (a RankedArticle) MatchedScore() float64 {
+	var score float64
+	for _, m := range a.MatchedTags {
+		score += m.Weight
+	}
+	return score
 }
 
 // Ranker scores and ranks articles based on learned preferences.
 type Ranker struct {
-	tagWeight float64
-	hnWeight  float64
+	tagWeight     float64
+	hnWeight      float64
+	domainFactor  float64
+	domainWeights map[string]float64
+	aliases       map[string]string
+	tagCounts     map[string]int
+	maxTags       int
+}
+
+// Option configures a Ranker.
+type Option func(*Ranker)
+
+// WithDomainWeights blends learned domain weights into the final score,
+// scaled by factor.
+func WithDomainWeights(weights map[string]float64, factor float64) Option {
+	return func(r *Ranker) {
+		r.domainWeights = weights
+		r.domainFactor = factor
+	}
+}
+
+// WithTagAliases matches an article's tags by their canonical tag, aliases
+// mapping each alias to its canonical tag, so that an article tagged
+// "golang" scores with the weight learned for "go". Ranked articles carry
+// their canonical tags.
+func WithTagAliases(aliases map[string]string) Option {
+	return func(r *Ranker) {
+		r.aliases = aliases
+	}
+}
+
+// WithTagCounts scales each learned tag weight by a confidence multiplier
+// of 1 + ln(1 + count), counts holding how many times each tag has been
+// reinforced by likes, so that a tag liked many times outweighs one liked
+// once at the same weight. A nil map leaves weights unscaled.
+func WithTagCounts(counts map[string]int) Option {
+	return func(r *Ranker) {
+		r.tagCounts = counts
+	}
+}
+
+// WithMaxTags only counts an article's n highest scoring tags towards its
+// tag score and matched tags, so that an article given many tags can't
+// outrank others on the number of its tags alone. Ranked articles keep all
+// their tags. Zero counts every tag.
+func WithMaxTags(n int) Option {
+	return func(r *Ranker) {
+		r.maxTags = n
+	}
 }
 
 // NewRanker creates a ranker with the given weighting factors.
-func NewRanker(tagWeight, hnWeight float64) *Ranker {
-	return &Ranker{
+func NewRanker(tagWeight, hnWeight float64, opts ...Option) *Ranker {
+	r := &Ranker{
 		tagWeight: tagWeight,
 		hnWeight:  hnWeight,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-// Rank scores and sorts articles by their computed final score.
+// Rank scores and sorts articles by their computed final score. Articles
+// with equal scores keep their input order, so the same candidates always
+// rank the same way.
 func (r *Ranker) Rank(articles []RankableArticle, weights map[string]float64) []RankedArticle {
 	if len(articles) == 0 {
 		return nil
@@ -46,38 +123,126 @@ func (r *Ranker) Rank(articles []RankableArticle, weights map[string]float64) []
 
 	ranked := make([]RankedArticle, len(articles))
 	for i, article := range articles {
-		tagScore := r.calculateTagScore(article.Tags, weights)
+		article.Tags = r.canonicalTags(article.Tags)
+		scored := r.scoredTags(article.Tags, weights)
+		tagScore := r.calculateTagScore(scored, weights)
 		hnScore := r.calculateHNScore(article.HNScore)
-		finalScore := tagScore*r.tagWeight + hnScore*r.hnWeight
+		domainScore := r.calculateDomainScore(article.Domain)
+		finalScore := tagScore*r.tagWeight + hnScore*r.hnWeight + domainScore*r.domainFactor
 
 		ranked[i] = RankedArticle{
 			RankableArticle:  article,
 			TagScore:         tagScore,
 			HNScoreComponent: hnScore,
+			DomainScore:      domainScore,
 			FinalScore:       finalScore,
+			MatchedTags:      r.matchedTags(scored, weights),
 		}
 	}
 
-	sort.Slice(ranked, func(i, j int) bool {
+	sort.SliceStable(ranked, func(i, j int) bool {
 		return ranked[i].FinalScore > ranked[j].FinalScore
 	})
 
 	return ranked
 }
 
+// canonicalTags lowercases and trims tags, the form weights and aliases
+// are stored in, and replaces aliases by their canonical tag, keeping the
+// first of any tags that then repeat.
+func (r *Ranker) canonicalTags(tags []string) []string {
+	canonical := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if c, ok := r.aliases[tag]; ok {
+			tag = c
+		}
+		if !slices.Contains(canonical, tag) {
+			canonical = append(canonical, tag)
+		}
+	}
+	return canonical
+}
+
+// scoredTags returns the tags that count towards an article's score: all
+// of them, or the maxTags highest scoring ones.
+func (r *Ranker) scoredTags(tags []string, weights map[string]float64) []string {
+	if r.maxTags <= 0 || len(tags) <= r.maxTags {
+		return tags
+	}
+	sorted := slices.Clone(tags)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		return cmp.Compare(r.tagScore(b, weights), r.tagScore(a, weights))
+	})
+	return sorted[:r.maxTags]
+}
+
 func (r *Ranker) calculateTagScore(tags []string, weights map[string]float64) float64 {
 	var score float64
 	for _, tag := range tags {
-		if w, ok := weights[tag]; ok {
-			score += w
-		} else {
-			score += 1.0 // Default weight for unknown tags
-		}
+		score += r.tagScore(tag, weights)
 	}
 	return score
 }
 
+// tagScore returns what a tag adds to an article's tag score.
+func (r *Ranker) tagScore(tag string, weights map[string]float64) float64 {
+	if w, ok := weights[tag]; ok {
+		return w * r.confidence(tag)
+	}
+	return 1.0 // Default weight for unknown tags
+}
+
+// confidence returns the multiplier of a learned tag's weight, 1 unless
+// tag counts are set.
+func (r *Ranker) confidence(tag string) float64 {
+	if r.tagCounts == nil {
+		return 1
+	}
+	return 1 + math.Log1p(float64(r.tagCounts[tag]))
+}
+
+// matchedTags returns the article's tags that have a learned weight, with
+// the weight they were scored with, by weight and then by tag, the same
+// order as storage's TagWeightsOrdered.
+func (r *Ranker) matchedTags(tags []string, weights map[string]float64) []TagContribution {
+	var matched []TagContribution
+	for _, tag := range tags {
+		if _, ok := weights[tag]; ok {
+			matched = append(matched, TagContribution{Tag: tag, Weight: r.tagScore(tag, weights)})
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Weight != matched[j].Weight {
+			return matched[i].Weight > matched[j].Weight
+		}
+		return matched[i].Tag < matched[j].Tag
+	})
+	return matched
+}
+
+func (r *Ranker) calculateDomainScore(domain string) float64 {
+	if w, ok := r.domainWeights[domain]; ok {
+		return w
+	}
+	return 1.0 // Default weight for unknown domains
+}
+
 func (r *Ranker) calculateHNScore(score int) float64 {
 	// log10(score + 1) to handle score of 0
 	return math.Log10(float64(score) + 1)
 }
+
+// Domain returns the normalized host of an article URL for domain learning,
+// or "" if it has none. HN discussion links are not treated as a domain.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host == "news.ycombinator.com" {
+		return ""
+	}
+	return host
+}
@@ -1,6 +1,7 @@
 package ranker
 
 import (
+	"fmt"
 	"math"
 	"testing"
 )
@@ -39,6 +40,191 @@ func TestRankArticles(t *testing.T) {
 	}
 }
 
+func TestRankSurfacesMatchedTags(t *testing.T) {
+	weights := map[string]float64{
+		"go":      2.1,
+		"testing": 1.3,
+		"rust":    1.8,
+	}
+
+	articles := []RankableArticle{
+		{ID: 1, Tags: []string{"testing", "go", "tooling"}, HNScore: 100},
+	}
+
+	r := NewRanker(0.7, 0.3)
+	ranked := r.Rank(articles, weights)
+
+	matched := ranked[0].MatchedTags
+	if len(matched) != 2 {
+		t.Fatalf("got %d matched tags, want 2: %v", len(matched), matched)
+	}
+
+	// Sorted by weight descending; unknown "tooling" is not a match
+	if matched[0].Tag != "go" || matched[0].Weight != 2.1 {
+		t.Errorf("matched[0] = %+v, want go (2.1)", matched[0])
+	}
+	if matched[1].Tag != "testing" || matched[1].Weight != 1.3 {
+		t.Errorf("matched[1] = %+v, want testing (1.3)", matched[1])
+	}
+}
+
+func TestRankTagAliases(t *testing.T) {
+	weights := map[string]float64{"go": 2.0, "ml": 1.5}
+	aliases := map[string]string{"golang": "go", "machine-learning": "ml"}
+
+	articles := []RankableArticle{
+		{ID: 1, Tags: []string{"golang", "go", "machine-learning"}, HNScore: 100},
+	}
+
+	r := NewRanker(0.7, 0.3, WithTagAliases(aliases))
+	ranked := r.Rank(articles, weights)
+
+	// "golang" and "go" count once, as go
+	if want := 3.5; ranked[0].TagScore != want {
+		t.Errorf("TagScore = %f, want %f", ranked[0].TagScore, want)
+	}
+	if got := ranked[0].Tags; len(got) != 2 || got[0] != "go" || got[1] != "ml" {
+		t.Errorf("Tags = %v, want the canonical [go ml]", got)
+	}
+	if articles[0].Tags[0] != "golang" {
+		t.Errorf("input tags changed to %v", articles[0].Tags)
+	}
+}
+
+func TestRankMatchesTagsCaseInsensitively(t *testing.T) {
+	weights := map[string]float64{"go": 2.0, "ml": 1.5}
+	aliases := map[string]string{"machine-learning": "ml"}
+	articles := []RankableArticle{
+		{ID: 1, Tags: []string{"Go", " Machine-Learning "}, HNScore: 100},
+	}
+
+	ranked := NewRanker(0.7, 0.3, WithTagAliases(aliases)).Rank(articles, weights)
+
+	// "Go" ranks with the stored go weight, not the unknown tag default
+	if want := 3.5; ranked[0].TagScore != want {
+		t.Errorf("TagScore = %f, want %f", ranked[0].TagScore, want)
+	}
+	if got := ranked[0].MatchedTags; len(got) != 2 || got[0].Tag != "go" || got[1].Tag != "ml" {
+		t.Errorf("MatchedTags = %v, want go and ml", got)
+	}
+}
+
+func TestRankTagCounts(t *testing.T) {
+	weights := map[string]float64{"rust": 2.0, "go": 2.0}
+	counts := map[string]int{"rust": 1, "go": 20}
+	articles := []RankableArticle{
+		{ID: 1, Tags: []string{"rust"}, HNScore: 100},
+		{ID: 2, Tags: []string{"go"}, HNScore: 100},
+	}
+
+	// Equal weights tie, keeping input order
+	if ranked := NewRanker(0.7, 0.3).Rank(articles, weights); ranked[0].ID != 1 {
+		t.Errorf("without counts, first = %d, want 1", ranked[0].ID)
+	}
+
+	ranked := NewRanker(0.7, 0.3, WithTagCounts(counts)).Rank(articles, weights)
+	if ranked[0].ID != 2 {
+		t.Errorf("with counts, first = %d, want the often liked tag's article 2", ranked[0].ID)
+	}
+	want := 2.0 * (1 + math.Log(21))
+	if math.Abs(ranked[0].TagScore-want) > 1e-9 {
+		t.Errorf("TagScore = %f, want %f", ranked[0].TagScore, want)
+	}
+	if got := ranked[0].MatchedTags[0].Weight; math.Abs(got-want) > 1e-9 {
+		t.Errorf("matched weight = %f, want the scaled weight %f", got, want)
+	}
+	if got := ranked[0].MatchedScore(); math.Abs(got-ranked[0].TagScore) > 1e-9 {
+		t.Errorf("MatchedScore = %f, want the tag score %f", got, ranked[0].TagScore)
+	}
+}
+
+func TestRankMaxTags(t *testing.T) {
+	weights := map[string]float64{"go": 2.5, "rust": 2.5, "databases": 2.5}
+	overTagged := []string{"go"}
+	for i := range 14 {
+		overTagged = append(overTagged, fmt.Sprintf("tag%d", i))
+	}
+	articles := []RankableArticle{
+		{ID: 1, Tags: overTagged, HNScore: 100},
+		{ID: 2, Tags: []string{"go", "rust", "databases"}, HNScore: 100},
+	}
+
+	// Uncapped, fourteen unknown tags outweigh three liked ones
+	if ranked := NewRanker(0.7, 0.3).Rank(articles, weights); ranked[0].ID != 1 {
+		t.Errorf("without a cap, first = %d, want the over-tagged article 1", ranked[0].ID)
+	}
+
+	ranked := NewRanker(0.7, 0.3, WithMaxTags(5)).Rank(articles, weights)
+	if ranked[0].ID != 2 {
+		t.Errorf("with a cap, first = %d, want the well-matched article 2", ranked[0].ID)
+	}
+	byID := map[int64]RankedArticle{ranked[0].ID: ranked[0], ranked[1].ID: ranked[1]}
+	if got, want := byID[1].TagScore, 2.5+4*1.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("over-tagged TagScore = %f, want %f from its 5 best tags", got, want)
+	}
+	if got, want := byID[2].TagScore, 3*2.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("TagScore = %f, want %f, unaffected under the cap", got, want)
+	}
+	if len(byID[1].Tags) != 15 {
+		t.Errorf("ranked article kept %d tags, want all 15", len(byID[1].Tags))
+	}
+	if len(byID[1].MatchedTags) != 1 || byID[1].MatchedTags[0].Tag != "go" {
+		t.Errorf("matched tags = %v, want [go]", byID[1].MatchedTags)
+	}
+}
+
+func TestMatchedScore(t *testing.T) {
+	a := RankedArticle{MatchedTags: []TagContribution{{Tag: "go", Weight: 2.0}, {Tag: "testing", Weight: 0.5}}}
+	if got := a.MatchedScore(); got != 2.5 {
+		t.Errorf("MatchedScore = %f, want 2.5", got)
+	}
+
+	if got := (RankedArticle{}).MatchedScore(); got != 0 {
+		t.Errorf("MatchedScore with no matches = %f, want 0", got)
+	}
+}
+
+func TestRankDomainInfluence(t *testing.T) {
+	articles := []RankableArticle{
+		{ID: 1, Tags: []string{"go"}, HNScore: 100, Domain: "example.com"},
+		{ID: 2, Tags: []string{"go"}, HNScore: 100, Domain: "favorite.dev"},
+	}
+	domains := map[string]float64{"favorite.dev": 3.0}
+
+	// Without domain weights the articles tie; with them the favorite wins
+	r := NewRanker(0.7, 0.3, WithDomainWeights(domains, 0.5))
+	ranked := r.Rank(articles, nil)
+
+	if ranked[0].ID != 2 {
+		t.Errorf("first article = %d, want 2 (preferred domain)", ranked[0].ID)
+	}
+	if ranked[0].DomainScore != 3.0 {
+		t.Errorf("DomainScore = %f, want 3.0", ranked[0].DomainScore)
+	}
+	if diff := ranked[0].FinalScore - ranked[1].FinalScore; math.Abs(diff-1.0) > 0.0001 {
+		t.Errorf("score difference = %f, want 1.0 (0.5 * (3.0 - 1.0))", diff)
+	}
+}
+
+func TestDomain(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.Example.com/path", "example.com"},
+		{"https://blog.rust-lang.org/2024/post", "blog.rust-lang.org"},
+		{"https://news.ycombinator.com/item?id=1", ""},
+		{"", ""},
+		{"::not a url", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Domain(tt.url); got != tt.want {
+			t.Errorf("Domain(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
 func TestRankArticlesEmpty(t *testing.T) {
 	r := NewRanker(0.7, 0.3)
 	ranked := r.Rank(nil, nil)
@@ -127,6 +313,31 @@ func TestMultipleArticlesWithSameScore(t *testing.T) {
 	}
 }
 
+func TestRankIsDeterministicForTies(t *testing.T) {
+	articles := []RankableArticle{
+		{ID: 3, Tags: []string{"rust", "go", "zig"}, HNScore: 10},
+		{ID: 1, Tags: []string{"zig", "go", "rust"}, HNScore: 10},
+		{ID: 2, Tags: []string{"go", "rust", "zig"}, HNScore: 10},
+	}
+	weights := map[string]float64{"go": 1.5, "rust": 1.5, "zig": 1.5}
+
+	r := NewRanker(0.7, 0.3)
+	for range 10 {
+		ranked := r.Rank(articles, weights)
+		for i, want := range []int64{3, 1, 2} {
+			if ranked[i].ID != want {
+				t.Fatalf("rank %d = article %d, want %d (input order)", i+1, ranked[i].ID, want)
+			}
+		}
+		for _, a := range ranked {
+			tags := []string{a.MatchedTags[0].Tag, a.MatchedTags[1].Tag, a.MatchedTags[2].Tag}
+			if tags[0] != "go" || tags[1] != "rust" || tags[2] != "zig" {
+				t.Fatalf("article %d matched tags = %v, want alphabetical among equal weights", a.ID, tags)
+			}
+		}
+	}
+}
+
 func TestCustomWeights(t *testing.T) {
 	articles := []RankableArticle{
 		{ID: 1, Tags: []string{"tag"}, HNScore: 99}, // tag=1.0, hn=2.0
@@ -0,0 +1,38 @@
+package scheduler
+
+import "time"
+
+// Clock provides the current time and timers, so that tests can control
+// time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by a Clock.
+type Timer interface {
+	// C returns the channel the fire time is delivered on.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting whether it was
+	// still pending.
+	Stop() bool
+}
+
+// realClock is the system clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.fireDue()
+	return t
+}
+
+// Advance moves the clock forward by d, firing timers that come due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fireDue()
+}
+
+// pending returns the number of timers that have neither fired nor been
+// stopped.
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.done {
+			n++
+		}
+	}
+	return n
+}
+
+// waitForTimer waits until a timer is pending, i.e. the scheduler is
+// waiting for its next run.
+func (c *fakeClock) waitForTimer(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.pending() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler never started waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fireDue fires timers whose time has come. The caller must hold c.mu.
+func (c *fakeClock) fireDue() {
+	for _, t := range c.timers {
+		if !t.done && !t.when.After(c.now) {
+			t.done = true
+			t.c <- t.when
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+	done  bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasPending := !t.done
+	t.done = true
+	return wasPending
+}
@@ -2,61 +2,106 @@ package scheduler
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
+	"log/slog"
+	"math/rand"
 	"sync"
 	"time"
 
-	"github.com/robfig/cron/v3"
+	"hn-telegram-bot/config"
 )
 
-var timeRegex = regexp.MustCompile(`^([01][0-9]|2[0-3]):([0-5][0-9])$`)
-
-// Scheduler manages cron-based job scheduling with timezone support.
+// Scheduler runs a job once a day at a fixed local time in its timezone,
+// optionally delayed by a random offset picked once per scheduler. A run
+// that comes due while the previous one is still going is skipped.
 type Scheduler struct {
-	cron     *cron.Cron
 	location *time.Location
-	mu       sync.Mutex
-	entryID  cron.EntryID
-	started  bool
+	clock    Clock
+	jitter   time.Duration // Upper bound of offset
+	offset   time.Duration // Delay added to the scheduled time every day
+
+	mu      sync.Mutex
+	job     func()
+	hour    int
+	minute  int
+	next    time.Time // Zero until a job is scheduled and started
+	started bool
+	running bool // A run of the job hasn't returned yet
+	wake    chan struct{}
+	stop    chan struct{}
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock sets the clock used to tell the time and wait, for testing.
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithJitter delays the job by a random offset of up to max, so that many
+// instances scheduled for the same time don't all fire at once. The offset
+// is picked when the scheduler is created and kept for its lifetime. max
+// must be less than a day.
+func WithJitter(max time.Duration) Option {
+	return func(s *Scheduler) {
+		s.jitter = max
+	}
 }
 
 // NewScheduler creates a new scheduler for the given timezone.
-func NewScheduler(timezone string) (*Scheduler, error) {
+func NewScheduler(timezone string, opts ...Option) (*Scheduler, error) {
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
 		return nil, fmt.Errorf("load timezone %q: %w", timezone, err)
 	}
 
-	return &Scheduler{
-		cron:     cron.New(cron.WithLocation(loc)),
+	s := &Scheduler{
 		location: loc,
-	}, nil
+		clock:    realClock{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.jitter >= 24*time.Hour {
+		return nil, fmt.Errorf("schedule jitter must be less than a day, got %v", s.jitter)
+	}
+	if s.jitter > 0 {
+		s.offset = time.Duration(rand.Int63n(int64(s.jitter) + 1)).Truncate(time.Second)
+	}
+	return s, nil
+}
+
+// Offset returns the delay added to the scheduled time, picked within the
+// jitter window when the scheduler was created.
+func (s *Scheduler) Offset() time.Duration {
+	return s.offset
 }
 
-// Schedule sets up a daily job at the specified time (HH:MM format).
+// Schedule sets up a daily job at the specified time (H:MM or HH:MM format).
+// Calling it again is the same as calling Reschedule.
 func (s *Scheduler) Schedule(timeStr string, fn func()) error {
-	hour, minute, err := parseTime(timeStr)
+	return s.Reschedule(timeStr, fn)
+}
+
+// Reschedule replaces the scheduled job and its time in one step, so that
+// there is only ever one daily job, however often it is called. A run
+// pending for the old time is cancelled.
+func (s *Scheduler) Reschedule(timeStr string, fn func()) error {
+	hour, minute, err := config.ParseDigestTime(timeStr)
 	if err != nil {
 		return err
 	}
 
-	spec := buildCronSpec(hour, minute)
-
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Remove existing job if any
-	if s.entryID != 0 {
-		s.cron.Remove(s.entryID)
-	}
-
-	entryID, err := s.cron.AddFunc(spec, fn)
-	if err != nil {
-		return fmt.Errorf("add cron job: %w", err)
+	s.job, s.hour, s.minute = fn, hour, minute
+	if s.started {
+		s.next = nextRun(s.clock.Now(), hour, minute, s.offset, s.location)
+		s.signal()
 	}
-	s.entryID = entryID
-
 	return nil
 }
 
@@ -65,36 +110,140 @@ func (s *Scheduler) Start() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.started {
-		s.cron.Start()
-		s.started = true
+	if s.started {
+		return
 	}
+	s.started = true
+	if s.job != nil {
+		s.next = nextRun(s.clock.Now(), s.hour, s.minute, s.offset, s.location)
+	}
+	s.wake = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	go s.run(s.wake, s.stop)
 }
 
-// Stop halts the scheduler.
+// Stop halts the scheduler. A job that is already running is not
+// interrupted.
 func (s *Scheduler) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.started {
-		s.cron.Stop()
+		close(s.stop)
 		s.started = false
+		s.next = time.Time{}
 	}
 }
 
-func parseTime(timeStr string) (int, int, error) {
-	matches := timeRegex.FindStringSubmatch(timeStr)
-	if len(matches) != 3 {
-		return 0, 0, fmt.Errorf("invalid time format: %q (expected HH:MM)", timeStr)
+// NextRun returns the next time the scheduled job will fire, or the zero
+// time if no job is scheduled or the scheduler has not been started.
+func (s *Scheduler) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// signal wakes the run loop to pick up a new schedule. The caller must
+// hold s.mu.
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run waits for each scheduled instant and starts the job, until stop is
+// closed.
+func (s *Scheduler) run(wake, stop <-chan struct{}) {
+	for {
+		s.mu.Lock()
+		next := s.next
+		s.mu.Unlock()
+
+		var fire <-chan time.Time
+		var timer Timer
+		if !next.IsZero() {
+			timer = s.clock.NewTimer(next.Sub(s.clock.Now()))
+			fire = timer.C()
+		}
+
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-fire:
+			s.mu.Lock()
+			// A stop or reschedule may have raced with the timer
+			select {
+			case <-stop:
+				s.mu.Unlock()
+				return
+			default:
+			}
+			if !s.next.Equal(next) {
+				s.mu.Unlock()
+				continue
+			}
+			// Counting from now rather than from the missed instant means
+			// that days missed while suspended fire once, not back to back
+			now := s.clock.Now()
+			if now.Before(next) {
+				now = next
+			}
+			s.next = nextRun(now, s.hour, s.minute, s.offset, s.location)
+			if s.running {
+				s.mu.Unlock()
+				slog.Warn("previous scheduled run still going, skipping this one", "next", s.next)
+				continue
+			}
+			s.running = true
+			job := s.job
+			s.mu.Unlock()
+			go s.runJob(job)
+		}
 	}
+}
 
-	hour, _ := strconv.Atoi(matches[1])
-	minute, _ := strconv.Atoi(matches[2])
+// runJob runs job and records that it has returned.
+func (s *Scheduler) runJob(job func()) {
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+	job()
+}
 
-	return hour, minute, nil
+// nextRun returns the first hour:minute in loc, delayed by offset, strictly
+// after t. On days when that time doesn't exist because clocks move
+// forward, it falls on the equivalent time after the shift. offset must be
+// less than a day.
+func nextRun(t time.Time, hour, minute int, offset time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	// An offset may carry yesterday's run past midnight, into today
+	for day := t.Day() - 1; ; day++ {
+		next := dailyAt(t.Year(), t.Month(), day, hour, minute, loc).Add(offset)
+		if next.After(t) {
+			return next
+		}
+	}
 }
 
-func buildCronSpec(hour, minute int) string {
-	// Cron format: minute hour day month weekday
-	return fmt.Sprintf("%d %d * * *", minute, hour)
+// dailyAt returns hour:minute on the given day in loc. time.Date may
+// resolve a wall time skipped by a DST shift to before the shift, so such
+// times are moved forward by the size of the gap.
+func dailyAt(year int, month time.Month, day, hour, minute int, loc *time.Location) time.Time {
+	at := time.Date(year, month, day, hour, minute, 0, 0, loc)
+	want := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	got := time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), 0, 0, time.UTC)
+	if gap := want.Sub(got); gap > 0 {
+		at = at.Add(gap)
+	}
+	return at
 }
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"testing"
+	"time"
 )
 
 func TestNewScheduler(t *testing.T) {
@@ -27,8 +28,7 @@ func TestScheduleAndStop(t *testing.T) {
 	s, _ := NewScheduler("UTC")
 	defer s.Stop()
 
-	// Simply test that we can schedule and start without errors
-	// Testing actual cron execution timing is unreliable in unit tests
+	// Firing is covered by the fake clock tests below
 	err := s.Schedule("12:00", func() {})
 	if err != nil {
 		t.Fatalf("Schedule failed: %v", err)
@@ -36,10 +36,18 @@ func TestScheduleAndStop(t *testing.T) {
 
 	s.Start()
 
-	// Verify scheduler is running (entries should exist)
-	entries := s.cron.Entries()
-	if len(entries) != 1 {
-		t.Errorf("expected 1 cron entry, got %d", len(entries))
+	// Verify the job is scheduled
+	if s.NextRun().IsZero() {
+		t.Error("expected a scheduled run")
+	}
+}
+
+func TestScheduleSingleDigitHour(t *testing.T) {
+	s, _ := NewScheduler("UTC")
+	defer s.Stop()
+
+	if err := s.Schedule("9:00", func() {}); err != nil {
+		t.Fatalf("Schedule(\"9:00\") failed: %v", err)
 	}
 }
 
@@ -51,7 +59,6 @@ func TestScheduleInvalidTime(t *testing.T) {
 		"invalid",
 		"25:00",
 		"12:60",
-		"9:00",   // Missing leading zero
 		"12:0",   // Missing leading zero
 	}
 
@@ -63,61 +70,6 @@ func TestScheduleInvalidTime(t *testing.T) {
 	}
 }
 
-func TestParseTime(t *testing.T) {
-	tests := []struct {
-		input   string
-		hour    int
-		minute  int
-		wantErr bool
-	}{
-		{"09:00", 9, 0, false},
-		{"00:00", 0, 0, false},
-		{"23:59", 23, 59, false},
-		{"12:30", 12, 30, false},
-		{"25:00", 0, 0, true},
-		{"12:60", 0, 0, true},
-		{"invalid", 0, 0, true},
-	}
-
-	for _, tt := range tests {
-		hour, minute, err := parseTime(tt.input)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("parseTime(%q) should return error", tt.input)
-			}
-		} else {
-			if err != nil {
-				t.Errorf("parseTime(%q) unexpected error: %v", tt.input, err)
-			}
-			if hour != tt.hour || minute != tt.minute {
-				t.Errorf("parseTime(%q) = (%d, %d), want (%d, %d)",
-					tt.input, hour, minute, tt.hour, tt.minute)
-			}
-		}
-	}
-}
-
-func TestBuildCronSpec(t *testing.T) {
-	tests := []struct {
-		hour     int
-		minute   int
-		expected string
-	}{
-		{9, 0, "0 9 * * *"},
-		{0, 0, "0 0 * * *"},
-		{23, 59, "59 23 * * *"},
-		{12, 30, "30 12 * * *"},
-	}
-
-	for _, tt := range tests {
-		spec := buildCronSpec(tt.hour, tt.minute)
-		if spec != tt.expected {
-			t.Errorf("buildCronSpec(%d, %d) = %q, want %q",
-				tt.hour, tt.minute, spec, tt.expected)
-		}
-	}
-}
-
 func TestReschedule(t *testing.T) {
 	s, _ := NewScheduler("UTC")
 	defer s.Stop()
@@ -128,24 +80,43 @@ func TestReschedule(t *testing.T) {
 	if err := s.Schedule("12:00", fn); err != nil {
 		t.Fatalf("initial Schedule failed: %v", err)
 	}
-
-	// Verify one entry
-	if len(s.cron.Entries()) != 1 {
-		t.Error("expected 1 entry after initial schedule")
+	s.Start()
+	if next := s.NextRun(); next.Hour() != 12 {
+		t.Errorf("NextRun = %v, want 12:00", next)
 	}
 
-	// Reschedule to different time
+	// Reschedule to different time, replacing the old one
 	if err := s.Schedule("14:00", fn); err != nil {
 		t.Fatalf("reschedule failed: %v", err)
 	}
+	if next := s.NextRun(); next.Hour() != 14 || next.Minute() != 0 {
+		t.Errorf("NextRun after reschedule = %v, want 14:00", next)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	s, _ := NewScheduler("UTC")
+	defer s.Stop()
 
-	// Still should have only one entry (old one removed)
-	if len(s.cron.Entries()) != 1 {
-		t.Error("expected 1 entry after reschedule")
+	if !s.NextRun().IsZero() {
+		t.Error("NextRun should be zero before scheduling")
 	}
 
-	// Verify we can still start
+	if err := s.Schedule("12:00", func() {}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
 	s.Start()
+
+	next := s.NextRun()
+	if next.IsZero() {
+		t.Fatal("NextRun should be set after scheduling and starting")
+	}
+	if next.Hour() != 12 || next.Minute() != 0 {
+		t.Errorf("NextRun = %v, want 12:00", next)
+	}
+	if !next.After(time.Now()) {
+		t.Errorf("NextRun = %v, should be in the future", next)
+	}
 }
 
 func TestMultipleStartStop(t *testing.T) {
@@ -161,3 +132,249 @@ func TestMultipleStartStop(t *testing.T) {
 	s.Stop()
 	s.Stop()
 }
+
+func TestNextRunComputation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		hour, minute int
+		offset       time.Duration
+		loc          *time.Location
+		want         time.Time
+	}{
+		{"later today", time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC), 9, 0, 0, time.UTC,
+			time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)},
+		{"already passed", time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC), 9, 0, 0, time.UTC,
+			time.Date(2026, 1, 11, 9, 0, 0, 0, time.UTC)},
+		{"exactly now", time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC), 9, 0, 0, time.UTC,
+			time.Date(2026, 1, 11, 9, 0, 0, 0, time.UTC)},
+		{"end of month", time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC), 9, 0, 0, time.UTC,
+			time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"in timezone", time.Date(2026, 1, 10, 13, 0, 0, 0, time.UTC), 9, 0, 0, newYork,
+			time.Date(2026, 1, 10, 9, 0, 0, 0, newYork)},
+		{"passed in timezone", time.Date(2026, 1, 10, 15, 0, 0, 0, time.UTC), 9, 0, 0, newYork,
+			time.Date(2026, 1, 11, 9, 0, 0, 0, newYork)},
+		{"across DST start", time.Date(2026, 3, 7, 12, 0, 0, 0, newYork), 9, 0, 0, newYork,
+			time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC)},
+		{"skipped by DST start", time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), 2, 30, 0, newYork,
+			time.Date(2026, 3, 8, 3, 30, 0, 0, newYork)},
+		{"with offset", time.Date(2026, 1, 10, 9, 10, 0, 0, time.UTC), 9, 0, 20 * time.Minute, time.UTC,
+			time.Date(2026, 1, 10, 9, 20, 0, 0, time.UTC)},
+		{"offset past midnight", time.Date(2026, 1, 11, 0, 5, 0, 0, time.UTC), 23, 50, 20 * time.Minute, time.UTC,
+			time.Date(2026, 1, 11, 0, 10, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextRun(tt.now, tt.hour, tt.minute, tt.offset, tt.loc); !got.Equal(tt.want) {
+				t.Errorf("nextRun = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newFakeScheduler creates a started scheduler on a fake clock, running a
+// job at 12:00 UTC that reports each run on the returned channel.
+func newFakeScheduler(t *testing.T, now time.Time) (*Scheduler, *fakeClock, chan struct{}) {
+	t.Helper()
+	clock := newFakeClock(now)
+	s, err := NewScheduler("UTC", WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+
+	runs := make(chan struct{}, 10)
+	if err := s.Schedule("12:00", func() { runs <- struct{}{} }); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	s.Start()
+	clock.waitForTimer(t)
+	return s, clock, runs
+}
+
+func expectRuns(t *testing.T, runs chan struct{}, want int) {
+	t.Helper()
+	for i := range want {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("job ran %d times, want %d", i, want)
+		}
+	}
+	select {
+	case <-runs:
+		t.Fatalf("job ran more than %d times", want)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestJobFiresOnceAtScheduledTime(t *testing.T) {
+	s, clock, runs := newFakeScheduler(t, time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+
+	// Not yet due
+	clock.Advance(59 * time.Minute)
+	expectRuns(t, runs, 0)
+
+	// Crossing 12:00 runs the job once, and the next run moves to tomorrow
+	clock.Advance(2 * time.Minute)
+	expectRuns(t, runs, 1)
+	clock.waitForTimer(t)
+	if want := time.Date(2026, 1, 11, 12, 0, 0, 0, time.UTC); !s.NextRun().Equal(want) {
+		t.Errorf("NextRun = %v, want %v", s.NextRun(), want)
+	}
+
+	clock.Advance(time.Hour)
+	expectRuns(t, runs, 0)
+
+	clock.Advance(24 * time.Hour)
+	expectRuns(t, runs, 1)
+}
+
+func TestMissedRunsFireOnce(t *testing.T) {
+	s, clock, runs := newFakeScheduler(t, time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+
+	// Three days pass at once, as after a suspend
+	clock.Advance(3*24*time.Hour + 2*time.Hour)
+	expectRuns(t, runs, 1)
+	clock.waitForTimer(t)
+	if want := time.Date(2026, 1, 14, 12, 0, 0, 0, time.UTC); !s.NextRun().Equal(want) {
+		t.Errorf("NextRun = %v, want %v, the next one after now", s.NextRun(), want)
+	}
+}
+
+func TestRunSkippedWhileJobRunning(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+	s, err := NewScheduler("UTC", WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+
+	runs := make(chan struct{}, 10)
+	release := make(chan struct{})
+	if err := s.Schedule("12:00", func() {
+		runs <- struct{}{}
+		<-release
+	}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	s.Start()
+	clock.waitForTimer(t)
+
+	clock.Advance(2 * time.Hour)
+	expectRuns(t, runs, 1)
+
+	// The first run is still going a day later, so the second is skipped
+	clock.waitForTimer(t)
+	clock.Advance(24 * time.Hour)
+	expectRuns(t, runs, 0)
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for s.isRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("job never returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.waitForTimer(t)
+	clock.Advance(24 * time.Hour)
+	expectRuns(t, runs, 1)
+}
+
+func (s *Scheduler) isRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+func TestJitterDelaysRunWithinWindow(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC))
+	s, err := NewScheduler("UTC", WithClock(clock), WithJitter(30*time.Minute))
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	defer s.Stop()
+
+	if err := s.Schedule("09:00", func() {}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	s.Start()
+
+	configured := time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)
+	next := s.NextRun()
+	if next.Before(configured) || next.After(configured.Add(30*time.Minute)) {
+		t.Errorf("NextRun = %v, want within 30m after %v", next, configured)
+	}
+	if want := configured.Add(s.Offset()); !next.Equal(want) {
+		t.Errorf("NextRun = %v, want configured time plus offset %v", next, want)
+	}
+
+	// The offset is kept when the time changes
+	if err := s.Reschedule("10:00", func() {}); err != nil {
+		t.Fatalf("Reschedule failed: %v", err)
+	}
+	if want := configured.Add(time.Hour + s.Offset()); !s.NextRun().Equal(want) {
+		t.Errorf("NextRun after reschedule = %v, want %v", s.NextRun(), want)
+	}
+}
+
+func TestJitterMustBeUnderADay(t *testing.T) {
+	if _, err := NewScheduler("UTC", WithJitter(24*time.Hour)); err == nil {
+		t.Error("expected error for a day of jitter")
+	}
+}
+
+func TestRescheduleMovesPendingRun(t *testing.T) {
+	s, clock, runs := newFakeScheduler(t, time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+
+	if err := s.Schedule("13:00", func() { runs <- struct{}{} }); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	// The old time passes without a run
+	clock.Advance(90 * time.Minute)
+	expectRuns(t, runs, 0)
+
+	clock.waitForTimer(t)
+	clock.Advance(time.Hour)
+	expectRuns(t, runs, 1)
+}
+
+func TestStopPreventsRun(t *testing.T) {
+	s, clock, runs := newFakeScheduler(t, time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+
+	s.Stop()
+	if !s.NextRun().IsZero() {
+		t.Errorf("NextRun after Stop = %v, want zero", s.NextRun())
+	}
+
+	clock.Advance(2 * time.Hour)
+	expectRuns(t, runs, 0)
+}
+
+func TestRescheduleKeepsSingleJob(t *testing.T) {
+	s, clock, runs := newFakeScheduler(t, time.Date(2026, 1, 10, 11, 0, 0, 0, time.UTC))
+
+	for _, at := range []string{"12:30", "13:00", "13:30"} {
+		if err := s.Reschedule(at, func() { runs <- struct{}{} }); err != nil {
+			t.Fatalf("Reschedule(%s) failed: %v", at, err)
+		}
+	}
+	if want := time.Date(2026, 1, 10, 13, 30, 0, 0, time.UTC); !s.NextRun().Equal(want) {
+		t.Errorf("NextRun = %v, want %v", s.NextRun(), want)
+	}
+
+	// Step through the afternoon: only the latest time fires
+	for range 6 {
+		clock.waitForTimer(t)
+		clock.Advance(30 * time.Minute)
+	}
+	expectRuns(t, runs, 1)
+}
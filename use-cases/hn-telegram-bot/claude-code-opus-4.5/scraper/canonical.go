@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// trackingParams are query parameters that only identify where a click
+// came from, removed by CanonicalURL along with any utm_ parameter.
+var trackingParams = []string{"fbclid", "gclid", "amp", "outputType"}
+
+// CanonicalURL returns the URL of the page rawURL shows, with AMP and
+// tracking variants collapsed: Google AMP cache and viewer URLs point to
+// the page they cache, "amp." hosts and "/amp" path segments are removed,
+// and tracking parameters and fragments are dropped. URLs that can't be
+// parsed are returned as is.
+func CanonicalURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if cached, ok := ampCacheSource(u); ok {
+		u = cached
+	}
+
+	// Only drop "amp." when a registrable domain is left, so that amp.dev
+	// keeps its host while amp.example.com becomes example.com.
+	if rest, ok := strings.CutPrefix(strings.ToLower(u.Hostname()), "amp."); ok && strings.Contains(rest, ".") {
+		u.Host = u.Host[len("amp."):]
+	}
+	switch {
+	case strings.HasPrefix(u.Path, "/amp/"):
+		u.Path = strings.TrimPrefix(u.Path, "/amp")
+	case strings.HasSuffix(u.Path, "/amp") || strings.HasSuffix(u.Path, "/amp/"):
+		u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/amp")
+	case strings.HasSuffix(u.Path, ".amp"):
+		u.Path = strings.TrimSuffix(u.Path, ".amp")
+	}
+	u.RawPath = ""
+
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			if strings.HasPrefix(key, "utm_") {
+				q.Del(key)
+			}
+		}
+		for _, key := range trackingParams {
+			q.Del(key)
+		}
+		u.RawQuery = q.Encode()
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// ampCacheSource returns the page a Google AMP cache or viewer URL serves,
+// such as https://example.com/a for
+// https://example-com.cdn.ampproject.org/c/s/example.com/a or
+// https://www.google.com/amp/s/example.com/a.
+func ampCacheSource(u *url.URL) (*url.URL, bool) {
+	host := strings.ToLower(u.Hostname())
+	var rest string
+	switch {
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		// Content is served under /c/, images under /i/ and videos under /v/
+		prefix, after, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		if !ok || (prefix != "c" && prefix != "i" && prefix != "v") {
+			return nil, false
+		}
+		rest = after
+	case host == "google.com" || host == "www.google.com":
+		if !strings.HasPrefix(u.Path, "/amp/") {
+			return nil, false
+		}
+		rest = strings.TrimPrefix(u.Path, "/amp/")
+	default:
+		return nil, false
+	}
+
+	scheme := "http"
+	if after, ok := strings.CutPrefix(rest, "s/"); ok {
+		scheme, rest = "https", after
+	}
+	source, err := url.Parse(scheme + "://" + rest)
+	if err != nil || source.Host == "" {
+		return nil, false
+	}
+	source.RawQuery = u.RawQuery
+	return source, true
+}
+
+// ExtractCanonicalURL reads HTML from r and returns the href of its
+// <link rel="canonical"> tag resolved against base, or "" if there is
+// none. Only http and https URLs are returned. Parsing stops at the end of
+// the document head.
+func ExtractCanonicalURL(r io.Reader, base *url.URL) (string, error) {
+	return findInHead(r, func(tok html.Token) string {
+		if tok.Data != "link" {
+			return ""
+		}
+		var rel, href string
+		for _, attr := range tok.Attr {
+			switch attr.Key {
+			case "rel":
+				rel = strings.ToLower(attr.Val)
+			case "href":
+				href = strings.TrimSpace(attr.Val)
+			}
+		}
+		if rel != "canonical" || href == "" {
+			return ""
+		}
+		return resolveHTTP(base, href)
+	})
+}
@@ -0,0 +1,95 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/amp/article", "https://example.com/article"},
+		{"https://example.com/article/amp/", "https://example.com/article"},
+		{"https://example.com/article.amp", "https://example.com/article"},
+		{"https://amp.example.com/article", "https://example.com/article"},
+		{"https://AMP.example.com/article", "https://example.com/article"},
+		{"https://amp.dev/about", "https://amp.dev/about"},
+		{"https://example-com.cdn.ampproject.org/c/s/example.com/article", "https://example.com/article"},
+		{"https://www.google.com/amp/s/example.com/amp/article", "https://example.com/article"},
+		{"https://www.google.com/amp/example.com/article", "http://example.com/article"},
+		{"https://example.com/article?utm_source=hn&id=7&fbclid=x#top", "https://example.com/article?id=7"},
+		{"https://example.com/amplify/article", "https://example.com/amplify/article"},
+		{"https://www.google.com/search?q=amp", "https://www.google.com/search?q=amp"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalURL(tt.url); got != tt.want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestExtractCanonicalURL(t *testing.T) {
+	base, _ := url.Parse("https://example.com/amp/article")
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"absolute", `<head><link rel="canonical" href="https://example.com/article"></head>`, "https://example.com/article"},
+		{"relative", `<head><link rel="Canonical" href="/article"/></head>`, "https://example.com/article"},
+		{"other rel", `<head><link rel="amphtml" href="/amp/article"></head>`, ""},
+		{"in body", `<head></head><body><link rel="canonical" href="/article"></body>`, ""},
+	}
+	for _, tt := range tests {
+		got, err := ExtractCanonicalURL(strings.NewReader(tt.html), base)
+		if err != nil {
+			t.Fatalf("%s: ExtractCanonicalURL failed: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScrapePageHonorsLinkTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head>
+<link rel="canonical" href="https://example.com/2024/story?utm_medium=amp">
+</head><body><article><p>The main content of the AMP page.</p></article></body></html>`))
+	}))
+	defer server.Close()
+
+	page, err := NewScraper().ScrapePage(context.Background(), server.URL+"/amp/story")
+	if err != nil {
+		t.Fatalf("ScrapePage failed: %v", err)
+	}
+	if !strings.Contains(page.Content, "main content") {
+		t.Errorf("content = %q, want the page's text", page.Content)
+	}
+	if page.Canonical != "https://example.com/2024/story" {
+		t.Errorf("canonical = %q, want the link tag's URL", page.Canonical)
+	}
+}
+
+func TestScrapePageWithoutLinkTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body><p>Some content here.</p></body></html>`))
+	}))
+	defer server.Close()
+
+	page, err := NewScraper().ScrapePage(context.Background(), server.URL+"/story/amp")
+	if err != nil {
+		t.Fatalf("ScrapePage failed: %v", err)
+	}
+	if page.Canonical != server.URL+"/story" {
+		t.Errorf("canonical = %q, want %q", page.Canonical, server.URL+"/story")
+	}
+}
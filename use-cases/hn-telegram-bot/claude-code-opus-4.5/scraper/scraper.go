@@ -1,18 +1,27 @@
 package scraper
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+
+	"hn-telegram-bot/util"
 )
 
 const defaultMaxContentLen = 4000
 
+// maxPageSize bounds how much of a page is read. Pages are cut off beyond
+// it, which leaves their head and opening text to parse.
+const maxPageSize = 5 << 20
+
 // Scraper extracts readable content from web pages.
 type Scraper struct {
 	httpClient    *http.Client
@@ -36,6 +45,20 @@ func WithMaxContentLength(n int) Option {
 	}
 }
 
+// WithHTTPClient fetches article pages through a copy of client, for
+// instance one routed through a proxy. A client without a timeout gets the
+// scraper's 10-second default, or the one set with WithTimeout, so that a
+// site that never answers can't stall a digest.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Scraper) {
+		hc := *client
+		if hc.Timeout == 0 {
+			hc.Timeout = s.httpClient.Timeout
+		}
+		s.httpClient = &hc
+	}
+}
+
 // NewScraper creates a new content scraper.
 func NewScraper(opts ...Option) *Scraper {
 	s := &Scraper{
@@ -48,17 +71,74 @@ func NewScraper(opts ...Option) *Scraper {
 	return s
 }
 
+// Page is what ScrapePage reads from a page.
+type Page struct {
+	// Content is the page's readable text.
+	Content string
+	// Canonical is the URL its <link rel="canonical"> tag declares, or
+	// else the scraped URL, in either case passed through CanonicalURL.
+	Canonical string
+	// ImageURL is the absolute URL of the page's main image, as declared
+	// by its og:image meta tag, or "" if the page declares none.
+	ImageURL string
+}
+
 // Scrape extracts readable text content from a URL.
 func (s *Scraper) Scrape(ctx context.Context, rawURL string) (string, error) {
+	page, err := s.ScrapePage(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return page.Content, nil
+}
+
+// ScrapePage extracts readable text content from a URL, along with the
+// canonical URL and main image the page declares, fetching it once.
+func (s *Scraper) ScrapePage(ctx context.Context, rawURL string) (*Page, error) {
+	parsedURL, body, err := s.fetch(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(body, maxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("read page: %w", err)
+	}
+
+	article, err := readability.FromReader(bytes.NewReader(raw), parsedURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse content: %w", err)
+	}
+
+	page := &Page{Content: strings.TrimSpace(article.TextContent)}
+
+	// Truncate if necessary, without splitting a character
+	page.Content = util.Truncate(page.Content, s.maxContentLen)
+
+	canonical, _ := ExtractCanonicalURL(bytes.NewReader(raw), parsedURL)
+	if canonical == "" {
+		canonical = rawURL
+	}
+	page.Canonical = CanonicalURL(canonical)
+
+	// A missing image is no reason to drop the content
+	page.ImageURL, _ = ExtractImageURL(bytes.NewReader(raw), parsedURL)
+	return page, nil
+}
+
+// fetch validates rawURL and returns the body of the page it points to.
+// The caller must close the body.
+func (s *Scraper) fetch(ctx context.Context, rawURL string) (*url.URL, io.ReadCloser, error) {
 	// Validate URL
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		return "", fmt.Errorf("invalid URL: %s", rawURL)
+		return nil, nil, fmt.Errorf("invalid URL: %s", rawURL)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
+		return nil, nil, fmt.Errorf("create request: %w", err)
 	}
 
 	// Set a user agent to avoid being blocked
@@ -66,25 +146,73 @@ func (s *Scraper) Scrape(ctx context.Context, rawURL string) (string, error) {
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetch URL: %w", err)
+		return nil, nil, fmt.Errorf("fetch URL: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status: %d", resp.StatusCode)
-	}
-
-	article, err := readability.FromReader(resp.Body, parsedURL)
-	if err != nil {
-		return "", fmt.Errorf("parse content: %w", err)
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
+	return parsedURL, resp.Body, nil
+}
 
-	content := strings.TrimSpace(article.TextContent)
+// ExtractImageURL reads HTML from r and returns the og:image URL resolved
+// against base, or "" if there is none. Only http and https images are
+// returned. Parsing stops at the end of the document head.
+func ExtractImageURL(r io.Reader, base *url.URL) (string, error) {
+	return findInHead(r, func(tok html.Token) string {
+		if tok.Data != "meta" {
+			return ""
+		}
+		var property, content string
+		for _, attr := range tok.Attr {
+			switch attr.Key {
+			case "property":
+				property = strings.ToLower(attr.Val)
+			case "content":
+				content = strings.TrimSpace(attr.Val)
+			}
+		}
+		if (property != "og:image" && property != "og:image:url") || content == "" {
+			return ""
+		}
+		return resolveHTTP(base, content)
+	})
+}
 
-	// Truncate if necessary
-	if len(content) > s.maxContentLen {
-		content = content[:s.maxContentLen]
+// findInHead reads HTML from r and returns the first non-empty result of
+// match over the start tags of the document head, or "" if there is none.
+func findInHead(r io.Reader, match func(tok html.Token) string) (string, error) {
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return "", nil
+			}
+			return "", fmt.Errorf("parse HTML: %w", z.Err())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "body" {
+				return "", nil
+			}
+			if found := match(tok); found != "" {
+				return found, nil
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return "", nil
+			}
+		}
 	}
+}
 
-	return content, nil
+// resolveHTTP resolves ref against base, returning "" unless the result is
+// an http or https URL.
+func resolveHTTP(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
 }
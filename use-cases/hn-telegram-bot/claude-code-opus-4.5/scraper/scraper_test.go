@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -161,3 +162,79 @@ func TestDefaultScraper(t *testing.T) {
 		t.Errorf("default maxContentLen = %d, want 4000", s.maxContentLen)
 	}
 }
+
+func TestExtractImageURL(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"absolute", `<html><head><meta property="og:image" content="https://cdn.example.com/a.png"></head></html>`,
+			"https://cdn.example.com/a.png"},
+		{"relative", `<head><meta property="og:image" content="/img/a.png" /></head>`,
+			"https://example.com/img/a.png"},
+		{"url property", `<head><meta property="og:image:url" content="https://example.com/b.jpg"></head>`,
+			"https://example.com/b.jpg"},
+		{"none", `<html><head><title>No image</title></head><body><img src="/a.png"></body></html>`, ""},
+		{"empty content", `<head><meta property="og:image" content=" "></head>`, ""},
+		{"non-http scheme", `<head><meta property="og:image" content="data:image/png;base64,AAAA"></head>`, ""},
+		{"outside head", `<head></head><body><meta property="og:image" content="/a.png"></body>`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractImageURL(strings.NewReader(tt.html), base)
+			if err != nil {
+				t.Fatalf("ExtractImageURL failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractImageURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapePageStopsReadingLargePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="canonical" href="https://example.com/big"></head><body><p>`))
+		// Far more than is read; writes fail once the scraper stops reading
+		chunk := []byte(strings.Repeat("endless text ", 1000))
+		for range 10 * maxPageSize / len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	page, err := NewScraper(WithTimeout(5*time.Second)).ScrapePage(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("ScrapePage failed: %v", err)
+	}
+	if page.Canonical != "https://example.com/big" {
+		t.Errorf("canonical = %q, want the link tag's URL", page.Canonical)
+	}
+	if !strings.HasPrefix(page.Content, "endless text") {
+		t.Errorf("content = %.40q..., want the start of the page's text", page.Content)
+	}
+}
+
+func TestScrapePageImageURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:image" content="/cover.jpg"></head><body>Hi</body></html>`))
+	}))
+	defer server.Close()
+
+	s := NewScraper(WithTimeout(5 * time.Second))
+	page, err := s.ScrapePage(context.Background(), server.URL+"/article")
+	if err != nil {
+		t.Fatalf("ScrapePage failed: %v", err)
+	}
+	if want := server.URL + "/cover.jpg"; page.ImageURL != want {
+		t.Errorf("ImageURL = %q, want %q", page.ImageURL, want)
+	}
+}
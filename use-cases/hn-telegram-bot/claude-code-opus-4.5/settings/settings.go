@@ -0,0 +1,102 @@
+// Package settings holds the settings users change with /settings, shared
+// between the command handlers that update them and the scheduled digest
+// that reads them.
+package settings
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Keys of the settings read by the scheduled digest.
+const (
+	KeyDigestTime   = "digest_time"
+	KeyArticleCount = "article_count"
+)
+
+// Store persists settings.
+type Store interface {
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+}
+
+// Defaults are the configured values of settings that haven't been set.
+type Defaults struct {
+	DigestTime   string
+	ArticleCount int
+}
+
+// Settings caches persisted settings in memory. It is safe for concurrent
+// use: a setting is persisted and cached under a single lock, so readers
+// never see a value the store doesn't hold.
+type Settings struct {
+	store    Store
+	defaults Defaults
+
+	mu    sync.RWMutex
+	cache map[string]string
+}
+
+// New returns settings kept in store, with defaults for those not set.
+func New(store Store, defaults Defaults) *Settings {
+	return &Settings{
+		store:    store,
+		defaults: defaults,
+		cache:    make(map[string]string),
+	}
+}
+
+// GetSetting returns the value of key, reading it from the store the first
+// time. Errors from the store, including its error for a setting that
+// isn't set, are returned as is.
+func (s *Settings) GetSetting(ctx context.Context, key string) (string, error) {
+	s.mu.RLock()
+	value, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok {
+		return value, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another caller may have loaded or set it meanwhile
+	if value, ok := s.cache[key]; ok {
+		return value, nil
+	}
+	value, err := s.store.GetSetting(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	s.cache[key] = value
+	return value, nil
+}
+
+// SetSetting persists value for key and caches it.
+func (s *Settings) SetSetting(ctx context.Context, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.SetSetting(ctx, key, value); err != nil {
+		return err
+	}
+	s.cache[key] = value
+	return nil
+}
+
+// DigestTime returns the time of the daily digest, in HH:MM.
+func (s *Settings) DigestTime(ctx context.Context) string {
+	if v, err := s.GetSetting(ctx, KeyDigestTime); err == nil && v != "" {
+		return v
+	}
+	return s.defaults.DigestTime
+}
+
+// ArticleCount returns the number of articles per digest.
+func (s *Settings) ArticleCount(ctx context.Context) int {
+	if v, err := s.GetSetting(ctx, KeyArticleCount); err == nil {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return s.defaults.ArticleCount
+}
@@ -0,0 +1,106 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var errNotFound = errors.New("setting not found")
+
+// mapStore is an unsynchronized store, so that the race detector flags any
+// access Settings doesn't serialize.
+type mapStore struct {
+	values map[string]string
+	gets   int
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{values: make(map[string]string)}
+}
+
+func (m *mapStore) GetSetting(ctx context.Context, key string) (string, error) {
+	m.gets++
+	if v, ok := m.values[key]; ok {
+		return v, nil
+	}
+	return "", errNotFound
+}
+
+func (m *mapStore) SetSetting(ctx context.Context, key, value string) error {
+	m.values[key] = value
+	return nil
+}
+
+func TestSettingsDefaults(t *testing.T) {
+	s := New(newMapStore(), Defaults{DigestTime: "09:00", ArticleCount: 30})
+	ctx := context.Background()
+
+	if got := s.DigestTime(ctx); got != "09:00" {
+		t.Errorf("DigestTime = %q, want default 09:00", got)
+	}
+	if got := s.ArticleCount(ctx); got != 30 {
+		t.Errorf("ArticleCount = %d, want default 30", got)
+	}
+	if _, err := s.GetSetting(ctx, "explain"); !errors.Is(err, errNotFound) {
+		t.Errorf("GetSetting of unset key error = %v, want the store's error", err)
+	}
+}
+
+func TestSettingsSetPersistsAndCaches(t *testing.T) {
+	store := newMapStore()
+	store.values[KeyArticleCount] = "12"
+	s := New(store, Defaults{DigestTime: "09:00", ArticleCount: 30})
+	ctx := context.Background()
+
+	if got := s.ArticleCount(ctx); got != 12 {
+		t.Errorf("ArticleCount = %d, want stored 12", got)
+	}
+	s.ArticleCount(ctx)
+	if store.gets != 1 {
+		t.Errorf("store read %d times, want once", store.gets)
+	}
+
+	if err := s.SetSetting(ctx, KeyDigestTime, "07:30"); err != nil {
+		t.Fatalf("SetSetting failed: %v", err)
+	}
+	if store.values[KeyDigestTime] != "07:30" {
+		t.Errorf("stored digest_time = %q, want 07:30", store.values[KeyDigestTime])
+	}
+	if got := s.DigestTime(ctx); got != "07:30" {
+		t.Errorf("DigestTime = %q, want 07:30", got)
+	}
+}
+
+// TestSettingsConcurrentAccess updates settings from one goroutine per
+// /settings command while the scheduled digest reads them. Run with -race.
+func TestSettingsConcurrentAccess(t *testing.T) {
+	s := New(newMapStore(), Defaults{DigestTime: "09:00", ArticleCount: 30})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				s.SetSetting(ctx, KeyArticleCount, strconv.Itoa(i*50+j+1))
+				s.SetSetting(ctx, KeyDigestTime, "07:30")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if n := s.ArticleCount(ctx); n < 1 {
+					t.Errorf("ArticleCount = %d", n)
+				}
+				if got := s.DigestTime(ctx); got != "09:00" && got != "07:30" {
+					t.Errorf("DigestTime = %q", got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultBusyTimeout is how long a statement waits for another connection
+// to release the database before failing as busy. Locks taken by writes
+// are held for milliseconds, so this only runs out if something is stuck.
+const defaultBusyTimeout = 5 * time.Second
+
+// SQLite result codes for a database or table that another connection
+// holds locked.
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// WithBusyTimeout sets how long every statement, read or write, waits for
+// the database to be released by another connection before failing with
+// an error IsBusy recognizes. It defaults to 5 seconds.
+func WithBusyTimeout(d time.Duration) Option {
+	return func(db *DB) {
+		db.busyTimeout = d
+	}
+}
+
+// IsBusy reports whether err is SQLite reporting the database or a table
+// still locked by another connection once the busy timeout ran out. Such
+// errors are transient, so the operation can be tried again shortly.
+func IsBusy(err error) bool {
+	var coded interface{ Code() int }
+	if !errors.As(err, &coded) {
+		return false
+	}
+	// Extended result codes keep the primary code in the low byte
+	code := coded.Code() & 0xff
+	return code == sqliteBusy || code == sqliteLocked
+}
+
+// dataSourceName returns the DSN opening the database at path, which may
+// already carry query parameters, with a busy timeout. The driver runs the
+// pragma on every connection it opens, so that it applies across the whole
+// pool.
+func dataSourceName(path string, busyTimeout time.Duration) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)", path, sep, busyTimeout.Milliseconds())
+}
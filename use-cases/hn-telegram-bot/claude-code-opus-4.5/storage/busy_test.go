@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lockDB takes an exclusive lock on the database at path from another
+// connection, returning a function that releases it.
+func lockDB(t *testing.T, path string) func() {
+	t.Helper()
+	other, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open second connection: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("get connection: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatalf("lock database: %v", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "COMMIT")
+		conn.Close()
+	}
+}
+
+func TestReadWaitsWhileLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	db.SetSetting(ctx, "digest_time", "09:00")
+
+	unlock := lockDB(t, path)
+	time.AfterFunc(50*time.Millisecond, unlock)
+
+	value, err := db.GetSetting(ctx, "digest_time")
+	if err != nil || value != "09:00" {
+		t.Errorf("GetSetting = %q, %v; want 09:00 once the lock is released", value, err)
+	}
+}
+
+func TestWriteWaitsWhileLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	unlock := lockDB(t, path)
+	time.AfterFunc(50*time.Millisecond, unlock)
+
+	if err := db.DislikeArticle(ctx, 1); err != nil {
+		t.Fatalf("DislikeArticle = %v, want success once the lock is released", err)
+	}
+	if disliked, err := db.IsArticleDisliked(ctx, 1); err != nil || !disliked {
+		t.Errorf("IsArticleDisliked = %v, %v; want true", disliked, err)
+	}
+}
+
+func TestBusyOnceTimeoutRunsOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path, WithBusyTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+
+	unlock := lockDB(t, path)
+	defer unlock()
+
+	if _, err := db.GetLikeCount(context.Background()); !IsBusy(err) {
+		t.Errorf("GetLikeCount error = %v, want a busy error", err)
+	}
+}
+
+func TestIsBusyIgnoresOtherErrors(t *testing.T) {
+	if IsBusy(errors.New("no such table")) || IsBusy(nil) {
+		t.Error("IsBusy = true for an error that isn't SQLite being busy")
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minSeenFilterCapacity is the fewest sent articles the seen filter is
+	// sized for, so that a new database has room to grow.
+	minSeenFilterCapacity = 10000
+	// seenFilterBitsPerID and seenFilterHashes give a false positive rate
+	// of about 1% while the filter holds no more IDs than it is sized for.
+	seenFilterBitsPerID = 10
+	seenFilterHashes    = 7
+)
+
+// seenFilter is a bloom filter of the IDs of sent articles. It never
+// reports a sent article as unsent, but may report an unsent article as
+// sent, more often once it holds more IDs than it was sized for, so the
+// database stays the source of truth for possible hits.
+type seenFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+}
+
+// newSeenFilter returns an empty filter sized for capacity IDs.
+func newSeenFilter(capacity int) *seenFilter {
+	words := (capacity*seenFilterBitsPerID + 63) / 64
+	return &seenFilter{bits: make([]uint64, max(words, 1))}
+}
+
+// add records that an article was sent.
+func (f *seenFilter) add(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.each(id, func(word int, mask uint64) bool {
+		f.bits[word] |= mask
+		return true
+	})
+}
+
+// mayContain reports whether an article may have been sent. False means
+// it definitely wasn't.
+func (f *seenFilter) mayContain(id int64) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.each(id, func(word int, mask uint64) bool {
+		return f.bits[word]&mask != 0
+	})
+}
+
+// each calls fn with the word and bit of each of the ID's hash positions,
+// stopping early, and returning false, if fn does.
+func (f *seenFilter) each(id int64, fn func(word int, mask uint64) bool) bool {
+	// Double hashing derives every position from two hashes of the ID
+	h1 := splitmix64(uint64(id))
+	h2 := splitmix64(h1) | 1
+	n := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < seenFilterHashes; i++ {
+		bit := (h1 + i*h2) % n
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitmix64 scrambles x, so that nearby IDs land on unrelated bits.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// loadSeenFilter builds the seen filter from every article ever sent,
+// sized for twice as many so that it stays accurate as more are sent.
+func (db *DB) loadSeenFilter(ctx context.Context) error {
+	var count int
+	if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(DISTINCT article_id) FROM sent_articles`).Scan(&count); err != nil {
+		return err
+	}
+	filter := newSeenFilter(max(2*count, minSeenFilterCapacity))
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT DISTINCT article_id FROM sent_articles`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		filter.add(id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	db.seen = filter
+	return nil
+}
+
+// UnsentArticleIDs returns the IDs, in order, of the articles not sent to
+// a chat within the given duration. Only the articles the seen filter
+// reports as possibly sent are looked up, so it is cheaper than
+// GetRecentlySentArticleIDs for a large or long-running database.
+func (db *DB) UnsentArticleIDs(ctx context.Context, chatID int64, ids []int64, within time.Duration) ([]int64, error) {
+	var maybeSent []any
+	for _, id := range ids {
+		if db.seen.mayContain(id) {
+			maybeSent = append(maybeSent, id)
+		}
+	}
+	if len(maybeSent) == 0 {
+		return ids, nil
+	}
+
+	cutoff := time.Now().Add(-within)
+	query := fmt.Sprintf(`SELECT DISTINCT article_id FROM sent_articles WHERE chat_id = ? AND sent_at > ? AND article_id IN (%s)`,
+		strings.TrimSuffix(strings.Repeat("?,", len(maybeSent)), ","))
+	rows, err := db.conn.QueryContext(ctx, query, append([]any{chatID, cutoff}, maybeSent...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sent := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		sent[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unsent []int64
+	for _, id := range ids {
+		if !sent[id] {
+			unsent = append(unsent, id)
+		}
+	}
+	return unsent, nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSeenFilterNoFalseNegatives(t *testing.T) {
+	// Overfilling the filter raises its false positive rate, but must never
+	// lose an ID
+	for _, capacity := range []int{1000, 100} {
+		filter := newSeenFilter(capacity)
+		for id := int64(1); id <= 1000; id++ {
+			filter.add(id * 7919)
+		}
+		for id := int64(1); id <= 1000; id++ {
+			if !filter.mayContain(id * 7919) {
+				t.Fatalf("capacity %d: added ID %d reported unseen", capacity, id*7919)
+			}
+		}
+	}
+}
+
+func TestSeenFilterFalsePositiveRate(t *testing.T) {
+	filter := newSeenFilter(1000)
+	for id := int64(1); id <= 1000; id++ {
+		filter.add(id)
+	}
+
+	hits := 0
+	for id := int64(1_000_001); id <= 1_010_000; id++ {
+		if filter.mayContain(id) {
+			hits++
+		}
+	}
+	// About 1% is expected; allow some slack
+	if rate := float64(hits) / 10000; rate > 0.03 {
+		t.Errorf("false positive rate = %.3f, want about 0.01", rate)
+	}
+}
+
+func TestUnsentArticleIDs(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2, 3, 4} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if db.seen.mayContain(id) {
+			t.Fatalf("article %d not sent yet, but the filter reports it seen", id)
+		}
+	}
+
+	// Article 2 was sent to another chat, and article 3 too long ago to
+	// count as recent
+	sends := []struct{ articleID, chatID, msgID int64 }{{1, 100, 10}, {2, 200, 11}, {3, 100, 12}}
+	for _, s := range sends {
+		if err := db.MarkArticleSent(ctx, s.articleID, s.chatID, s.msgID, "top"); err != nil {
+			t.Fatalf("MarkArticleSent failed: %v", err)
+		}
+	}
+	if _, err := db.conn.Exec(`UPDATE sent_articles SET sent_at = ? WHERE article_id = 3`, time.Now().Add(-10*24*time.Hour)); err != nil {
+		t.Fatalf("backdate sent article: %v", err)
+	}
+
+	for _, s := range sends {
+		if !db.seen.mayContain(s.articleID) {
+			t.Errorf("article %d was sent, but the filter reports it unseen", s.articleID)
+		}
+	}
+
+	got, err := db.UnsentArticleIDs(ctx, 100, []int64{4, 3, 2, 1}, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("UnsentArticleIDs failed: %v", err)
+	}
+	if want := []int64{4, 3, 2}; !slices.Equal(got, want) {
+		t.Errorf("UnsentArticleIDs = %v, want %v", got, want)
+	}
+}
+
+func TestUnsentArticleIDsAfterMigration(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Article 1 was sent before deliveries were tracked per chat, so it
+	// isn't in sent_articles when the filter is loaded
+	for _, id := range []int64{1, 2} {
+		article := &Article{ID: id, Title: "Legacy", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+	if _, err := db.conn.ExecContext(ctx, `UPDATE articles SET sent_at = ?, telegram_msg_id = 500 WHERE id = 1`, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("seed legacy row: %v", err)
+	}
+
+	if _, err := db.MigrateSentArticles(ctx, 100); err != nil {
+		t.Fatalf("MigrateSentArticles failed: %v", err)
+	}
+
+	got, err := db.UnsentArticleIDs(ctx, 100, []int64{1, 2}, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("UnsentArticleIDs failed: %v", err)
+	}
+	if want := []int64{2}; !slices.Equal(got, want) {
+		t.Errorf("UnsentArticleIDs after migration = %v, want %v", got, want)
+	}
+}
+
+func TestSeenFilterLoadedOnStartup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	for id := int64(1); id <= 50; id++ {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+		if err := db.MarkArticleSent(ctx, id, 100, id, "top"); err != nil {
+			t.Fatalf("MarkArticleSent failed: %v", err)
+		}
+	}
+	db.Close()
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+
+	for id := int64(1); id <= 50; id++ {
+		if !db.seen.mayContain(id) {
+			t.Fatalf("article %d was sent before the restart, but the filter reports it unseen", id)
+		}
+	}
+	got, err := db.UnsentArticleIDs(ctx, 100, []int64{1, 25, 50, 51}, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("UnsentArticleIDs failed: %v", err)
+	}
+	if want := []int64{51}; !slices.Equal(got, want) {
+		t.Errorf("UnsentArticleIDs = %v, want %v", got, want)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -14,17 +17,23 @@ import (
 // ErrNotFound is returned when a record is not found.
 var ErrNotFound = errors.New("not found")
 
-// Article represents a Hacker News article with metadata.
+// Article represents a Hacker News article with metadata. Delivery to
+// chats is tracked separately in sent_articles.
 type Article struct {
-	ID            int64
-	Title         string
-	URL           string
-	Summary       string
-	Tags          []string
-	HNScore       int
-	FetchedAt     time.Time
-	SentAt        *time.Time
-	TelegramMsgID *int64
+	ID           int64
+	Title        string
+	URL          string
+	Summary      string
+	SummaryModel string // Model that produced Summary, empty if unknown
+	ContentHash  string // Hash of the content Summary was made from, empty if unknown
+	Tags         []string
+	HNScore      int
+	FetchedAt    time.Time
+	ImageURL     string // Main image of the article's page, empty if none
+	// FirstScore is HNScore as it was when the article was first saved, at
+	// FirstSeenAt. SaveArticle never changes them.
+	FirstScore  int
+	FirstSeenAt time.Time
 }
 
 // TagWeight represents a tag's learned preference weight.
@@ -34,23 +43,84 @@ type TagWeight struct {
 	Count  int
 }
 
+// TagWeightPoint is a tag's weight at a point in time.
+type TagWeightPoint struct {
+	Weight     float64
+	RecordedAt time.Time
+}
+
+// DomainWeight represents a domain's learned preference weight.
+type DomainWeight struct {
+	Domain string
+	Weight float64
+	Count  int
+}
+
+// PendingBatch is the articles of a digest whose sending began but may
+// not have finished. Articles is opaque to storage.
+type PendingBatch struct {
+	ID        string
+	ChatID    int64
+	Articles  []byte
+	CreatedAt time.Time
+}
+
+// ClearedPreferences counts the rows removed by ClearPreferences.
+type ClearedPreferences struct {
+	Tags     int64
+	Domains  int64
+	Likes    int64
+	Dislikes int64
+}
+
 // DB wraps the SQLite database connection and provides storage operations.
 type DB struct {
-	conn *sql.DB
+	conn        *sql.DB
+	aliases     map[string]string // Canonical tag of each alias, set by SetTagAliases
+	createDir   bool              // Create the database file's directory if missing
+	busyTimeout time.Duration     // How long statements wait for a locked database
+	seen        *seenFilter       // Articles ever sent, kept up to date by MarkArticleSent
+}
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithCreateDir creates the directory of the database file, and any
+// missing parents, before opening it. Without it, NewDB fails if the
+// directory doesn't exist.
+func WithCreateDir() Option {
+	return func(db *DB) {
+		db.createDir = true
+	}
 }
 
 // NewDB creates a new database connection and initializes the schema.
-func NewDB(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path)
+func NewDB(path string, opts ...Option) (*DB, error) {
+	db := &DB{busyTimeout: defaultBusyTimeout}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	if db.createDir {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create database directory: %w", err)
+		}
+	}
+
+	conn, err := sql.Open("sqlite", dataSourceName(path, db.busyTimeout))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	db.conn = conn
 	if err := db.initSchema(); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("init schema: %w", err)
 	}
+	if err := db.loadSeenFilter(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("load seen articles: %w", err)
+	}
 
 	return db, nil
 }
@@ -60,6 +130,36 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
+// Ping checks that the database is still reachable.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
+
+// execer runs a statement on either the database or a transaction, so
+// that a statement can be shared by standalone and compound operations.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// withTx runs fn in a transaction, committing it if fn returns nil and
+// rolling it back otherwise, so that an operation made of several
+// statements never leaves partial writes behind.
+func (db *DB) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) initSchema() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS articles (
@@ -67,19 +167,61 @@ func (db *DB) initSchema() error {
 		title TEXT NOT NULL,
 		url TEXT NOT NULL,
 		summary TEXT,
+		summary_model TEXT NOT NULL DEFAULT '',
+		content_hash TEXT NOT NULL DEFAULT '',
 		tags TEXT NOT NULL DEFAULT '[]',
 		hn_score INTEGER DEFAULT 0,
 		fetched_at DATETIME NOT NULL,
+		-- Legacy single-chat sent state, superseded by sent_articles
 		sent_at DATETIME,
 		telegram_msg_id INTEGER
 	);
 
-	CREATE INDEX IF NOT EXISTS idx_articles_sent_at ON articles(sent_at);
-	CREATE INDEX IF NOT EXISTS idx_articles_telegram_msg_id ON articles(telegram_msg_id);
+	CREATE TABLE IF NOT EXISTS sent_articles (
+		article_id INTEGER NOT NULL REFERENCES articles(id),
+		chat_id INTEGER NOT NULL,
+		sent_at DATETIME NOT NULL,
+		message_id INTEGER NOT NULL,
+		source TEXT NOT NULL DEFAULT 'top',
+		PRIMARY KEY (chat_id, message_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sent_articles_chat_sent_at ON sent_articles(chat_id, sent_at);
+	CREATE INDEX IF NOT EXISTS idx_sent_articles_article_sent_at ON sent_articles(article_id, sent_at);
+
+	-- Further messages belonging to a sent article, such as a separate
+	-- discussion link, so that reactions to them count for the article
+	CREATE TABLE IF NOT EXISTS article_messages (
+		article_id INTEGER NOT NULL REFERENCES articles(id),
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
 
 	CREATE TABLE IF NOT EXISTS likes (
 		article_id INTEGER PRIMARY KEY REFERENCES articles(id),
-		liked_at DATETIME NOT NULL
+		liked_at DATETIME NOT NULL,
+		chat_id INTEGER NOT NULL DEFAULT 0 -- 0 if unknown
+	);
+
+	-- Boosts applied for each like, so that the like can be undone
+	CREATE TABLE IF NOT EXISTS like_boosts (
+		article_id INTEGER NOT NULL REFERENCES likes(article_id),
+		kind TEXT NOT NULL, -- 'tag' or 'domain'
+		name TEXT NOT NULL,
+		boost REAL NOT NULL,
+		PRIMARY KEY (article_id, kind, name)
+	);
+
+	-- Each chat's most recent like that /undo can take back
+	CREATE TABLE IF NOT EXISTS last_likes (
+		chat_id INTEGER PRIMARY KEY,
+		article_id INTEGER NOT NULL REFERENCES likes(article_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS dislikes (
+		article_id INTEGER PRIMARY KEY REFERENCES articles(id),
+		disliked_at DATETIME NOT NULL
 	);
 
 	CREATE TABLE IF NOT EXISTS tag_weights (
@@ -88,17 +230,242 @@ func (db *DB) initSchema() error {
 		count INTEGER NOT NULL DEFAULT 0
 	);
 
+	CREATE TABLE IF NOT EXISTS tag_weight_history (
+		tag TEXT NOT NULL,
+		weight REAL NOT NULL,
+		recorded_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_tag_weight_history_tag_recorded_at
+		ON tag_weight_history(tag, recorded_at);
+
+	CREATE TABLE IF NOT EXISTS domain_weights (
+		domain TEXT PRIMARY KEY,
+		weight REAL NOT NULL DEFAULT 1.0,
+		count INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS unsubscribed_chats (
+		chat_id INTEGER PRIMARY KEY,
+		reason TEXT NOT NULL DEFAULT '',
+		unsubscribed_at DATETIME NOT NULL
+	);
+
 	CREATE TABLE IF NOT EXISTS settings (
 		key TEXT PRIMARY KEY,
 		value TEXT NOT NULL
 	);
+
+	CREATE TABLE IF NOT EXISTS failed_articles (
+		article_id INTEGER PRIMARY KEY,
+		stage TEXT NOT NULL,
+		error TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 1,
+		failed_at DATETIME NOT NULL
+	);
+
+	-- The digest each chat is being sent, kept until it has been sent so
+	-- that an interrupted run can be resumed
+	CREATE TABLE IF NOT EXISTS pending_batches (
+		chat_id INTEGER PRIMARY KEY,
+		batch_id TEXT NOT NULL,
+		articles BLOB NOT NULL,
+		created_at DATETIME NOT NULL
+	);
 	`
 
-	_, err := db.conn.Exec(schema)
+	if _, err := db.conn.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after a table was first created
+	columns := []struct{ table, column, definition string }{
+		{"sent_articles", "source", "TEXT NOT NULL DEFAULT 'top'"},
+		{"articles", "summary_model", "TEXT NOT NULL DEFAULT ''"},
+		{"articles", "content_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"articles", "seen_at", "DATETIME"},
+		{"articles", "first_score", "INTEGER"},
+		{"articles", "first_seen_at", "DATETIME"},
+		{"articles", "image_url", "TEXT NOT NULL DEFAULT ''"},
+		{"likes", "chat_id", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := db.addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	// Indexes on added columns can only be created once the columns exist
+	if _, err := db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_articles_content_hash ON articles(content_hash)`); err != nil {
+		return fmt.Errorf("create content hash index: %w", err)
+	}
+	if _, err := db.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_likes_liked_at ON likes(liked_at)`); err != nil {
+		return fmt.Errorf("create liked_at index: %w", err)
+	}
+
+	// Likes recorded before chat_id existed belong to the chat the article
+	// was first sent to
+	backfill := `
+	UPDATE likes SET chat_id = (
+		SELECT chat_id FROM sent_articles s WHERE s.article_id = likes.article_id
+		ORDER BY s.sent_at LIMIT 1
+	)
+	WHERE chat_id = 0 AND EXISTS (SELECT 1 FROM sent_articles s WHERE s.article_id = likes.article_id)
+	`
+	if _, err := db.conn.Exec(backfill); err != nil {
+		return fmt.Errorf("backfill like chats: %w", err)
+	}
+
+	if err := db.mergeTags(context.Background()); err != nil {
+		return fmt.Errorf("merge tag case: %w", err)
+	}
+	return nil
+}
+
+// SetTagAliases makes every tag written or looked up from now on resolve
+// through aliases, a map from alias to canonical tag, so that "golang"
+// shares the weight of "go". Weights already stored under an alias are
+// merged into its canonical tag. It must be called before db is used
+// concurrently.
+func (db *DB) SetTagAliases(ctx context.Context, aliases map[string]string) error {
+	db.aliases = make(map[string]string, len(aliases))
+	for alias, tag := range aliases {
+		db.aliases[normalizeTag(alias)] = normalizeTag(tag)
+	}
+	if err := db.mergeTags(ctx); err != nil {
+		return fmt.Errorf("merge tag aliases: %w", err)
+	}
+	return nil
+}
+
+// mergeTags folds tag weights stored under a tag that isn't canonical,
+// such as those stored by older versions under differing case ("Go",
+// "go", "GO") or under an alias, into the canonical tag's row, summing
+// their weights and counts. It does nothing once all tags are canonical.
+func (db *DB) mergeTags(ctx context.Context) error {
+	rows, err := db.conn.QueryContext(ctx, `SELECT tag, weight, count FROM tag_weights`)
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]TagWeight)
+	var stale []string
+	for rows.Next() {
+		var tw TagWeight
+		if err := rows.Scan(&tw.Tag, &tw.Weight, &tw.Count); err != nil {
+			rows.Close()
+			return err
+		}
+		norm := db.canonicalTag(tw.Tag)
+		if norm != tw.Tag {
+			stale = append(stale, tw.Tag)
+		}
+		m := merged[norm]
+		m.Tag = norm
+		m.Weight += tw.Weight
+		m.Count += tw.Count
+		merged[norm] = m
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// Like boosts move too, so that undoing an earlier like takes back
+	// the boost from the canonical tag
+	mergeBoosts := `
+	INSERT INTO like_boosts (article_id, kind, name, boost)
+	SELECT article_id, kind, ?, boost FROM like_boosts WHERE kind = ? AND name = ?
+	ON CONFLICT(article_id, kind, name) DO UPDATE SET boost = boost + excluded.boost
+	`
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		for _, tag := range stale {
+			norm := db.canonicalTag(tag)
+			if _, err := tx.Exec(`DELETE FROM tag_weights WHERE tag IN (?, ?)`, tag, norm); err != nil {
+				return fmt.Errorf("delete tag %q: %w", tag, err)
+			}
+			m := merged[norm]
+			if _, err := tx.Exec(`INSERT INTO tag_weights (tag, weight, count) VALUES (?, ?, ?)`, m.Tag, m.Weight, m.Count); err != nil {
+				return fmt.Errorf("insert merged tag %q: %w", norm, err)
+			}
+			if _, err := tx.Exec(`UPDATE tag_weight_history SET tag = ? WHERE tag = ?`, norm, tag); err != nil {
+				return fmt.Errorf("rename tag history %q: %w", tag, err)
+			}
+			if _, err := tx.Exec(mergeBoosts, norm, boostKindTag, tag); err != nil {
+				return fmt.Errorf("merge like boosts of %q: %w", tag, err)
+			}
+			if _, err := tx.Exec(`DELETE FROM like_boosts WHERE kind = ? AND name = ?`, boostKindTag, tag); err != nil {
+				return fmt.Errorf("delete like boosts of %q: %w", tag, err)
+			}
+		}
+		return nil
+	})
+}
+
+// normalizeTag returns the normalized form of a tag, so that "Go" and "go"
+// share a weight.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// canonicalTag returns the tag a weight is stored under: the normalized
+// tag, or the tag it is an alias of.
+func (db *DB) canonicalTag(tag string) string {
+	tag = normalizeTag(tag)
+	if canonical, ok := db.aliases[tag]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// canonicalBoosts sums boosts by canonical tag, so that an article tagged
+// with both a tag and its alias boosts the tag once.
+func (db *DB) canonicalBoosts(boosts map[string]float64) map[string]float64 {
+	merged := make(map[string]float64, len(boosts))
+	for tag, boost := range boosts {
+		merged[db.canonicalTag(tag)] += boost
+	}
+	return merged
+}
+
+// addColumnIfMissing adds a column to an existing table created by an
+// older version of the schema.
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
-// SaveArticle inserts or updates an article.
+// SaveArticle inserts or updates an article. The score and time it was
+// first saved with are kept, so that later saves can be compared with them;
+// articles saved before they were recorded keep their last saved score.
 func (db *DB) SaveArticle(ctx context.Context, article *Article) error {
 	tagsJSON, err := json.Marshal(article.Tags)
 	if err != nil {
@@ -106,17 +473,20 @@ func (db *DB) SaveArticle(ctx context.Context, article *Article) error {
 	}
 
 	query := `
-	INSERT INTO articles (id, title, url, summary, tags, hn_score, fetched_at, sent_at, telegram_msg_id)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO articles (id, title, url, summary, summary_model, content_hash, tags, hn_score, fetched_at, first_score, first_seen_at, image_url)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(id) DO UPDATE SET
+		first_score = COALESCE(articles.first_score, articles.hn_score),
+		first_seen_at = COALESCE(articles.first_seen_at, articles.fetched_at),
 		title = excluded.title,
 		url = excluded.url,
 		summary = excluded.summary,
+		summary_model = excluded.summary_model,
+		content_hash = excluded.content_hash,
 		tags = excluded.tags,
 		hn_score = excluded.hn_score,
 		fetched_at = excluded.fetched_at,
-		sent_at = excluded.sent_at,
-		telegram_msg_id = excluded.telegram_msg_id
+		image_url = excluded.image_url
 	`
 
 	_, err = db.conn.ExecContext(ctx, query,
@@ -124,11 +494,14 @@ func (db *DB) SaveArticle(ctx context.Context, article *Article) error {
 		article.Title,
 		article.URL,
 		article.Summary,
+		article.SummaryModel,
+		article.ContentHash,
 		string(tagsJSON),
 		article.HNScore,
 		article.FetchedAt,
-		article.SentAt,
-		article.TelegramMsgID,
+		article.HNScore,
+		article.FetchedAt,
+		article.ImageURL,
 	)
 	return err
 }
@@ -136,72 +509,95 @@ func (db *DB) SaveArticle(ctx context.Context, article *Article) error {
 // GetArticle retrieves an article by HN ID.
 func (db *DB) GetArticle(ctx context.Context, id int64) (*Article, error) {
 	query := `
-	SELECT id, title, url, summary, tags, hn_score, fetched_at, sent_at, telegram_msg_id
+	SELECT id, title, url, summary, summary_model, content_hash, tags, hn_score, fetched_at, first_score, first_seen_at, image_url
 	FROM articles WHERE id = ?
 	`
+	return scanArticle(db.conn.QueryRowContext(ctx, query, id))
+}
 
-	article := &Article{}
-	var tagsJSON string
-	var sentAt sql.NullTime
-	var telegramMsgID sql.NullInt64
-
-	err := db.conn.QueryRowContext(ctx, query, id).Scan(
-		&article.ID,
-		&article.Title,
-		&article.URL,
-		&article.Summary,
-		&tagsJSON,
-		&article.HNScore,
-		&article.FetchedAt,
-		&sentAt,
-		&telegramMsgID,
-	)
-	if err == sql.ErrNoRows {
+// GetArticleByContentHash retrieves an article if it was summarized from
+// content with the given hash, so that its unchanged content need not be
+// summarized again. Other articles never match, since pages such as
+// cookie walls and paywall stubs hash the same for unrelated stories.
+func (db *DB) GetArticleByContentHash(ctx context.Context, id int64, hash string) (*Article, error) {
+	if hash == "" {
 		return nil, ErrNotFound
 	}
-	if err != nil {
-		return nil, err
-	}
+	query := `
+	SELECT id, title, url, summary, summary_model, content_hash, tags, hn_score, fetched_at, first_score, first_seen_at, image_url
+	FROM articles WHERE id = ? AND content_hash = ?
+	`
+	return scanArticle(db.conn.QueryRowContext(ctx, query, id, hash))
+}
 
-	if err := json.Unmarshal([]byte(tagsJSON), &article.Tags); err != nil {
-		return nil, fmt.Errorf("unmarshal tags: %w", err)
-	}
-	if article.Tags == nil {
-		article.Tags = []string{}
-	}
+// MarkArticleSeen records that a stored article was summarized for a digest
+// now, whether or not it was sent.
+func (db *DB) MarkArticleSeen(ctx context.Context, id int64) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE articles SET seen_at = ? WHERE id = ?`, time.Now(), id)
+	return err
+}
 
-	if sentAt.Valid {
-		article.SentAt = &sentAt.Time
-	}
-	if telegramMsgID.Valid {
-		article.TelegramMsgID = &telegramMsgID.Int64
-	}
+// GetSeenArticle retrieves an article marked seen within the given
+// duration, returning ErrNotFound if it wasn't.
+func (db *DB) GetSeenArticle(ctx context.Context, id int64, within time.Duration) (*Article, error) {
+	query := `
+	SELECT id, title, url, summary, summary_model, content_hash, tags, hn_score, fetched_at, first_score, first_seen_at, image_url
+	FROM articles WHERE id = ? AND seen_at > ?
+	`
+	return scanArticle(db.conn.QueryRowContext(ctx, query, id, time.Now().Add(-within)))
+}
 
-	return article, nil
+// GetArticleByMessageID retrieves the article sent to a chat as the given
+// Telegram message, or as one of the further messages added with
+// AddArticleMessage.
+func (db *DB) GetArticleByMessageID(ctx context.Context, chatID, msgID int64) (*Article, error) {
+	query := `
+	SELECT a.id, a.title, a.url, a.summary, a.summary_model, a.content_hash, a.tags, a.hn_score, a.fetched_at, a.first_score, a.first_seen_at, a.image_url
+	FROM articles a
+	WHERE a.id = (
+		SELECT article_id FROM sent_articles WHERE chat_id = ? AND message_id = ?
+		UNION ALL
+		SELECT article_id FROM article_messages WHERE chat_id = ? AND message_id = ?
+		LIMIT 1
+	)
+	`
+	return scanArticle(db.conn.QueryRowContext(ctx, query, chatID, msgID, chatID, msgID))
 }
 
-// GetArticleByMessageID retrieves an article by its Telegram message ID.
-func (db *DB) GetArticleByMessageID(ctx context.Context, msgID int64) (*Article, error) {
+// AddArticleMessage associates a further message in chatID with an article
+// sent there, so that GetArticleByMessageID finds the article from either
+// message. Unlike MarkArticleSent, it doesn't count as another delivery.
+func (db *DB) AddArticleMessage(ctx context.Context, articleID, chatID, msgID int64) error {
 	query := `
-	SELECT id, title, url, summary, tags, hn_score, fetched_at, sent_at, telegram_msg_id
-	FROM articles WHERE telegram_msg_id = ?
+	INSERT INTO article_messages (article_id, chat_id, message_id)
+	VALUES (?, ?, ?)
+	ON CONFLICT(chat_id, message_id) DO UPDATE SET article_id = excluded.article_id
 	`
+	_, err := db.conn.ExecContext(ctx, query, articleID, chatID, msgID)
+	return err
+}
 
+func scanArticle(row *sql.Row) (*Article, error) {
 	article := &Article{}
-	var tagsJSON string
-	var sentAt sql.NullTime
-	var telegramMsgID sql.NullInt64
+	var (
+		tagsJSON    string
+		firstScore  sql.NullInt64
+		firstSeenAt sql.NullTime
+	)
 
-	err := db.conn.QueryRowContext(ctx, query, msgID).Scan(
+	err := row.Scan(
 		&article.ID,
 		&article.Title,
 		&article.URL,
 		&article.Summary,
+		&article.SummaryModel,
+		&article.ContentHash,
 		&tagsJSON,
 		&article.HNScore,
 		&article.FetchedAt,
-		&sentAt,
-		&telegramMsgID,
+		&firstScore,
+		&firstSeenAt,
+		&article.ImageURL,
 	)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -210,6 +606,15 @@ func (db *DB) GetArticleByMessageID(ctx context.Context, msgID int64) (*Article,
 		return nil, err
 	}
 
+	// Articles saved once before first scores were recorded have none
+	article.FirstScore, article.FirstSeenAt = article.HNScore, article.FetchedAt
+	if firstScore.Valid {
+		article.FirstScore = int(firstScore.Int64)
+	}
+	if firstSeenAt.Valid {
+		article.FirstSeenAt = firstSeenAt.Time
+	}
+
 	if err := json.Unmarshal([]byte(tagsJSON), &article.Tags); err != nil {
 		return nil, fmt.Errorf("unmarshal tags: %w", err)
 	}
@@ -217,22 +622,16 @@ func (db *DB) GetArticleByMessageID(ctx context.Context, msgID int64) (*Article,
 		article.Tags = []string{}
 	}
 
-	if sentAt.Valid {
-		article.SentAt = &sentAt.Time
-	}
-	if telegramMsgID.Valid {
-		article.TelegramMsgID = &telegramMsgID.Int64
-	}
-
 	return article, nil
 }
 
-// GetRecentlySentArticleIDs returns IDs of articles sent within the given duration.
-func (db *DB) GetRecentlySentArticleIDs(ctx context.Context, within time.Duration) ([]int64, error) {
+// GetRecentlySentArticleIDs returns IDs of articles sent to a chat within
+// the given duration.
+func (db *DB) GetRecentlySentArticleIDs(ctx context.Context, chatID int64, within time.Duration) ([]int64, error) {
 	cutoff := time.Now().Add(-within)
-	query := `SELECT id FROM articles WHERE sent_at > ?`
+	query := `SELECT DISTINCT article_id FROM sent_articles WHERE chat_id = ? AND sent_at > ?`
 
-	rows, err := db.conn.QueryContext(ctx, query, cutoff)
+	rows, err := db.conn.QueryContext(ctx, query, chatID, cutoff)
 	if err != nil {
 		return nil, err
 	}
@@ -249,11 +648,164 @@ func (db *DB) GetRecentlySentArticleIDs(ctx context.Context, within time.Duratio
 	return ids, rows.Err()
 }
 
-// MarkArticleSent updates an article with sent timestamp and message ID.
-func (db *DB) MarkArticleSent(ctx context.Context, articleID int64, telegramMsgID int64) error {
-	query := `UPDATE articles SET sent_at = ?, telegram_msg_id = ? WHERE id = ?`
-	_, err := db.conn.ExecContext(ctx, query, time.Now(), telegramMsgID, articleID)
-	return err
+// WasSent reports whether an article was sent to a chat, and when it was
+// last sent there. It looks up the one article, so it is cheaper than
+// GetRecentlySentArticleIDs for spot checks.
+func (db *DB) WasSent(ctx context.Context, chatID, articleID int64) (bool, time.Time, error) {
+	query := `SELECT sent_at FROM sent_articles WHERE chat_id = ? AND article_id = ? ORDER BY sent_at DESC LIMIT 1`
+
+	var sentAt time.Time
+	err := db.conn.QueryRowContext(ctx, query, chatID, articleID).Scan(&sentAt)
+	if err == sql.ErrNoRows {
+		return false, time.Time{}, nil
+	}
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	return true, sentAt, nil
+}
+
+// MarkArticleSent records that an article fetched from source was sent to
+// a chat as the given Telegram message.
+func (db *DB) MarkArticleSent(ctx context.Context, articleID, chatID, telegramMsgID int64, source string) error {
+	query := `
+	INSERT INTO sent_articles (article_id, chat_id, sent_at, message_id, source)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(chat_id, message_id) DO UPDATE SET
+		article_id = excluded.article_id,
+		sent_at = excluded.sent_at,
+		source = excluded.source
+	`
+	if _, err := db.conn.ExecContext(ctx, query, articleID, chatID, time.Now(), telegramMsgID, source); err != nil {
+		return err
+	}
+	db.seen.add(articleID)
+	return nil
+}
+
+// SourceCount is the number of articles sent from a story source.
+type SourceCount struct {
+	Source string
+	Count  int
+}
+
+// GetSentCountsBySource tallies articles sent to a chat within the given
+// duration, grouped by the source they were fetched from.
+func (db *DB) GetSentCountsBySource(ctx context.Context, chatID int64, within time.Duration) ([]SourceCount, error) {
+	cutoff := time.Now().Add(-within)
+	query := `
+	SELECT source, COUNT(*) FROM sent_articles
+	WHERE chat_id = ? AND sent_at > ?
+	GROUP BY source ORDER BY COUNT(*) DESC, source
+	`
+	rows, err := db.conn.QueryContext(ctx, query, chatID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []SourceCount
+	for rows.Next() {
+		var sc SourceCount
+		if err := rows.Scan(&sc.Source, &sc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, sc)
+	}
+	return counts, rows.Err()
+}
+
+// SentArticle is an article as it was sent to a chat.
+type SentArticle struct {
+	ID     int64
+	Title  string
+	URL    string
+	SentAt time.Time
+}
+
+// RecentSentArticles returns the last limit articles sent to a chat, most
+// recent first.
+func (db *DB) RecentSentArticles(ctx context.Context, chatID int64, limit int) ([]SentArticle, error) {
+	query := `
+	SELECT a.id, a.title, a.url, s.sent_at
+	FROM sent_articles s
+	JOIN articles a ON a.id = s.article_id
+	WHERE s.chat_id = ?
+	ORDER BY s.sent_at DESC
+	LIMIT ?
+	`
+	rows, err := db.conn.QueryContext(ctx, query, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var articles []SentArticle
+	for rows.Next() {
+		var a SentArticle
+		if err := rows.Scan(&a.ID, &a.Title, &a.URL, &a.SentAt); err != nil {
+			return nil, err
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
+
+// GetSentArticleCount returns the number of distinct articles that have been sent.
+func (db *DB) GetSentArticleCount(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(DISTINCT article_id) FROM sent_articles`
+	var count int
+	err := db.conn.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+// sentArticlesMigratedKey marks that legacy sent state has been copied
+// into sent_articles.
+const sentArticlesMigratedKey = "sent_articles_migrated"
+
+// MigrateSentArticles copies sent state recorded on the articles table,
+// from before deliveries were tracked per chat, into sent_articles under
+// chatID. It runs once; later calls are no-ops. It returns the number of
+// deliveries copied.
+func (db *DB) MigrateSentArticles(ctx context.Context, chatID int64) (int64, error) {
+	var copied int64
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var done string
+		err := tx.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, sentArticlesMigratedKey).Scan(&done)
+		if err == nil {
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO sent_articles (article_id, chat_id, sent_at, message_id)
+		SELECT id, ?, sent_at, telegram_msg_id FROM articles
+		WHERE sent_at IS NOT NULL AND telegram_msg_id IS NOT NULL
+		`, chatID)
+		if err != nil {
+			return fmt.Errorf("copy sent state: %w", err)
+		}
+		if copied, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO settings (key, value) VALUES (?, '1')`, sentArticlesMigratedKey); err != nil {
+			return fmt.Errorf("mark migrated: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// The seen filter was built before the copied deliveries existed
+	if copied > 0 {
+		if err := db.loadSeenFilter(ctx); err != nil {
+			return copied, fmt.Errorf("reload seen articles: %w", err)
+		}
+	}
+	return copied, nil
 }
 
 // IsArticleLiked checks if an article has been liked.
@@ -270,9 +822,151 @@ func (db *DB) IsArticleLiked(ctx context.Context, articleID int64) (bool, error)
 	return true, nil
 }
 
-// LikeArticle records a like for an article (idempotent).
-func (db *DB) LikeArticle(ctx context.Context, articleID int64) error {
-	query := `INSERT OR IGNORE INTO likes (article_id, liked_at) VALUES (?, ?)`
+// LikeArticle records a like for an article from a chat (idempotent).
+func (db *DB) LikeArticle(ctx context.Context, articleID, chatID int64) error {
+	query := `INSERT OR IGNORE INTO likes (article_id, liked_at, chat_id) VALUES (?, ?, ?)`
+	_, err := db.conn.ExecContext(ctx, query, articleID, time.Now(), chatID)
+	return err
+}
+
+// Kinds of boost recorded for a like.
+const (
+	boostKindTag    = "tag"
+	boostKindDomain = "domain"
+)
+
+// RecordLike records a like for an article from a chat, applies its tag
+// and domain boosts, and records them so that UndoLastLike can take them
+// back, all in one transaction. The like becomes the chat's last like. An
+// article that is already liked is left as it is.
+func (db *DB) RecordLike(ctx context.Context, articleID, chatID int64, tagBoosts, domainBoosts map[string]float64) error {
+	query := `INSERT INTO like_boosts (article_id, kind, name, boost) VALUES (?, ?, ?, ?)`
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO likes (article_id, liked_at, chat_id) VALUES (?, ?, ?)`, articleID, time.Now(), chatID)
+		if err != nil {
+			return fmt.Errorf("record like: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+
+		tags := db.canonicalBoosts(tagBoosts)
+		for tag, boost := range tags {
+			if err := boostTag(ctx, tx, tag, boost); err != nil {
+				return fmt.Errorf("boost tag %q: %w", tag, err)
+			}
+		}
+		for domain, boost := range domainBoosts {
+			if err := boostDomain(ctx, tx, domain, boost); err != nil {
+				return fmt.Errorf("boost domain %q: %w", domain, err)
+			}
+		}
+		for kind, boosts := range map[string]map[string]float64{boostKindTag: tags, boostKindDomain: domainBoosts} {
+			for name, boost := range boosts {
+				if _, err := tx.ExecContext(ctx, query, articleID, kind, name, boost); err != nil {
+					return fmt.Errorf("record %s boost %q: %w", kind, name, err)
+				}
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO last_likes (chat_id, article_id) VALUES (?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET article_id = excluded.article_id
+		`, chatID, articleID)
+		return err
+	})
+}
+
+// UndoLastLike removes a chat's last like and takes back the boosts
+// recorded for it, without lowering any weight below minWeight. It returns
+// the article that was unliked, or ErrNotFound if the chat has no like to
+// undo. Only the last like can be undone, so undoing twice in a row finds
+// nothing the second time.
+func (db *DB) UndoLastLike(ctx context.Context, chatID int64, minWeight float64) (*Article, error) {
+	var article *Article
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var articleID int64
+		err := tx.QueryRowContext(ctx, `SELECT article_id FROM last_likes WHERE chat_id = ?`, chatID).Scan(&articleID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("find last like: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM last_likes WHERE chat_id = ?`, chatID); err != nil {
+			return fmt.Errorf("clear last like: %w", err)
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT kind, name, boost FROM like_boosts WHERE article_id = ?`, articleID)
+		if err != nil {
+			return fmt.Errorf("get like boosts: %w", err)
+		}
+		type boost struct {
+			kind, name string
+			amount     float64
+		}
+		var boosts []boost
+		for rows.Next() {
+			var b boost
+			if err := rows.Scan(&b.kind, &b.name, &b.amount); err != nil {
+				rows.Close()
+				return err
+			}
+			boosts = append(boosts, b)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, b := range boosts {
+			table, column := "tag_weights", "tag"
+			if b.kind == boostKindDomain {
+				table, column = "domain_weights", "domain"
+			}
+			// Weights already below the floor are left where they are
+			query := `UPDATE ` + table + ` SET weight = MAX(weight - ?, MIN(weight, ?)), count = MAX(count - 1, 0) WHERE ` + column + ` = ?`
+			if _, err := tx.ExecContext(ctx, query, b.amount, minWeight, b.name); err != nil {
+				return fmt.Errorf("take back %s boost %q: %w", b.kind, b.name, err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM like_boosts WHERE article_id = ?`, articleID); err != nil {
+			return fmt.Errorf("delete like boosts: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM likes WHERE article_id = ?`, articleID); err != nil {
+			return fmt.Errorf("delete like: %w", err)
+		}
+
+		article, err = scanArticle(tx.QueryRowContext(ctx, `
+		SELECT id, title, url, summary, summary_model, content_hash, tags, hn_score, fetched_at, first_score, first_seen_at, image_url
+		FROM articles WHERE id = ?
+		`, articleID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+// IsArticleDisliked checks if an article has been disliked.
+func (db *DB) IsArticleDisliked(ctx context.Context, articleID int64) (bool, error) {
+	query := `SELECT 1 FROM dislikes WHERE article_id = ?`
+	var dummy int
+	err := db.conn.QueryRowContext(ctx, query, articleID).Scan(&dummy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DislikeArticle records a dislike for an article (idempotent).
+func (db *DB) DislikeArticle(ctx context.Context, articleID int64) error {
+	query := `INSERT OR IGNORE INTO dislikes (article_id, disliked_at) VALUES (?, ?)`
 	_, err := db.conn.ExecContext(ctx, query, articleID, time.Now())
 	return err
 }
@@ -285,17 +979,38 @@ func (db *DB) GetLikeCount(ctx context.Context) (int, error) {
 	return count, err
 }
 
+// GetLikeCountSince returns the number of articles liked since the given
+// time.
+func (db *DB) GetLikeCountSince(ctx context.Context, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM likes WHERE liked_at >= ?`
+	var count int
+	err := db.conn.QueryRowContext(ctx, query, since).Scan(&count)
+	return count, err
+}
+
 // GetTagWeight returns the weight for a tag, or 1.0 if not found.
 func (db *DB) GetTagWeight(ctx context.Context, tag string) (float64, error) {
 	query := `SELECT weight FROM tag_weights WHERE tag = ?`
 	var weight float64
-	err := db.conn.QueryRowContext(ctx, query, tag).Scan(&weight)
+	err := db.conn.QueryRowContext(ctx, query, db.canonicalTag(tag)).Scan(&weight)
 	if err == sql.ErrNoRows {
 		return 1.0, nil
 	}
 	return weight, err
 }
 
+// GetTagCount returns how many times a tag has been boosted, or 0 if it
+// has no weight yet.
+func (db *DB) GetTagCount(ctx context.Context, tag string) (int, error) {
+	query := `SELECT count FROM tag_weights WHERE tag = ?`
+	var count int
+	err := db.conn.QueryRowContext(ctx, query, db.canonicalTag(tag)).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return count, err
+}
+
 // GetAllTagWeights returns all tag weights as a map.
 func (db *DB) GetAllTagWeights(ctx context.Context) (map[string]float64, error) {
 	query := `SELECT tag, weight FROM tag_weights`
@@ -317,8 +1032,55 @@ func (db *DB) GetAllTagWeights(ctx context.Context) (map[string]float64, error)
 	return weights, rows.Err()
 }
 
-// BoostTagWeight increases a tag's weight by the given amount.
+// GetAllTagCounts returns how many times each tag has been boosted, as a
+// map.
+func (db *DB) GetAllTagCounts(ctx context.Context) (map[string]int, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT tag, count FROM tag_weights`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, err
+		}
+		counts[tag] = count
+	}
+	return counts, rows.Err()
+}
+
+// TagWeightsOrdered returns all tag weights, highest weight first and
+// alphabetically among equal weights, so that callers iterating them see
+// the same order every time.
+func (db *DB) TagWeightsOrdered(ctx context.Context) ([]TagWeight, error) {
+	return db.queryTagWeights(ctx, `SELECT tag, weight, count FROM tag_weights ORDER BY weight DESC, tag ASC`)
+}
+
+// BoostTagWeight increases a tag's weight by the given amount. Tags are
+// case-insensitive.
 func (db *DB) BoostTagWeight(ctx context.Context, tag string, boost float64) error {
+	return boostTag(ctx, db.conn, db.canonicalTag(tag), boost)
+}
+
+// BoostTagWeights increases the weight of each tag by its amount in a
+// single transaction, so that either all of the tags are boosted or none.
+func (db *DB) BoostTagWeights(ctx context.Context, boosts map[string]float64) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		for tag, boost := range db.canonicalBoosts(boosts) {
+			if err := boostTag(ctx, tx, tag, boost); err != nil {
+				return fmt.Errorf("boost tag %q: %w", tag, err)
+			}
+		}
+		return nil
+	})
+}
+
+// boostTag boosts the weight of tag, which must be canonical.
+func boostTag(ctx context.Context, ex execer, tag string, boost float64) error {
 	query := `
 	INSERT INTO tag_weights (tag, weight, count)
 	VALUES (?, 1.0 + ?, 1)
@@ -326,23 +1088,87 @@ func (db *DB) BoostTagWeight(ctx context.Context, tag string, boost float64) err
 		weight = weight + ?,
 		count = count + 1
 	`
-	_, err := db.conn.ExecContext(ctx, query, tag, boost, boost)
+	_, err := ex.ExecContext(ctx, query, tag, boost, boost)
 	return err
 }
 
 // ApplyTagDecay reduces all tag weights by decay rate with a minimum floor.
 func (db *DB) ApplyTagDecay(ctx context.Context, decayRate, minWeight float64) error {
-	query := `
-	UPDATE tag_weights SET weight = MAX(weight * (1.0 - ?), ?)
-	`
-	_, err := db.conn.ExecContext(ctx, query, decayRate, minWeight)
+	return decayWeights(ctx, db.conn, "tag_weights", decayRate, minWeight)
+}
+
+// ApplyDecay decays tag and domain weights and then records a snapshot of
+// the decayed tag weights, all in one transaction. If any step fails,
+// none of the weights change.
+func (db *DB) ApplyDecay(ctx context.Context, decayRate, minWeight float64) error {
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if err := decayWeights(ctx, tx, "tag_weights", decayRate, minWeight); err != nil {
+			return fmt.Errorf("decay tags: %w", err)
+		}
+		if err := decayWeights(ctx, tx, "domain_weights", decayRate, minWeight); err != nil {
+			return fmt.Errorf("decay domains: %w", err)
+		}
+		if err := snapshotTagWeights(ctx, tx); err != nil {
+			return fmt.Errorf("snapshot tag weights: %w", err)
+		}
+		return nil
+	})
+}
+
+// decayWeights reduces every weight in table, which must be tag_weights or
+// domain_weights, by decay rate with a minimum floor.
+func decayWeights(ctx context.Context, ex execer, table string, decayRate, minWeight float64) error {
+	query := `UPDATE ` + table + ` SET weight = MAX(weight * (1.0 - ?), ?)`
+	_, err := ex.ExecContext(ctx, query, decayRate, minWeight)
 	return err
 }
 
+// SeedTagWeights gives each tag the given starting weight, but only when
+// no tag weights exist yet, so learned preferences are never overwritten.
+// It returns the number of tags seeded.
+func (db *DB) SeedTagWeights(ctx context.Context, tags []string, weight float64) (int, error) {
+	seeded := 0
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var existing int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM tag_weights`).Scan(&existing); err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		for _, tag := range tags {
+			res, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO tag_weights (tag, weight, count) VALUES (?, ?, 0)`, db.canonicalTag(tag), weight)
+			if err != nil {
+				return fmt.Errorf("seed tag %q: %w", tag, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			seeded += int(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seeded, nil
+}
+
 // GetTopTags returns the top N tags by weight.
 func (db *DB) GetTopTags(ctx context.Context, limit int) ([]TagWeight, error) {
-	query := `SELECT tag, weight, count FROM tag_weights ORDER BY weight DESC LIMIT ?`
-	rows, err := db.conn.QueryContext(ctx, query, limit)
+	return db.GetTagWeightsPaged(ctx, limit, 0)
+}
+
+// GetTagWeightsPaged returns up to limit tags by weight, skipping the
+// first offset.
+func (db *DB) GetTagWeightsPaged(ctx context.Context, limit, offset int) ([]TagWeight, error) {
+	return db.queryTagWeights(ctx, `SELECT tag, weight, count FROM tag_weights ORDER BY weight DESC, tag ASC LIMIT ? OFFSET ?`, limit, offset)
+}
+
+func (db *DB) queryTagWeights(ctx context.Context, query string, args ...any) ([]TagWeight, error) {
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -359,6 +1185,290 @@ func (db *DB) GetTopTags(ctx context.Context, limit int) ([]TagWeight, error) {
 	return tags, rows.Err()
 }
 
+// SnapshotTagWeights records the current weight of every tag in the
+// tag weight history.
+func (db *DB) SnapshotTagWeights(ctx context.Context) error {
+	return snapshotTagWeights(ctx, db.conn)
+}
+
+func snapshotTagWeights(ctx context.Context, ex execer) error {
+	query := `
+	INSERT INTO tag_weight_history (tag, weight, recorded_at)
+	SELECT tag, weight, ? FROM tag_weights
+	`
+	_, err := ex.ExecContext(ctx, query, time.Now())
+	return err
+}
+
+// GetTagWeightHistory returns a tag's recorded weights since the given
+// time, oldest first.
+func (db *DB) GetTagWeightHistory(ctx context.Context, tag string, since time.Time) ([]TagWeightPoint, error) {
+	query := `
+	SELECT weight, recorded_at FROM tag_weight_history
+	WHERE tag = ? AND recorded_at >= ?
+	ORDER BY recorded_at
+	`
+	rows, err := db.conn.QueryContext(ctx, query, db.canonicalTag(tag), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []TagWeightPoint
+	for rows.Next() {
+		var p TagWeightPoint
+		if err := rows.Scan(&p.Weight, &p.RecordedAt); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// GetAllDomainWeights returns all domain weights as a map.
+func (db *DB) GetAllDomainWeights(ctx context.Context) (map[string]float64, error) {
+	query := `SELECT domain, weight FROM domain_weights`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := make(map[string]float64)
+	for rows.Next() {
+		var domain string
+		var weight float64
+		if err := rows.Scan(&domain, &weight); err != nil {
+			return nil, err
+		}
+		weights[domain] = weight
+	}
+	return weights, rows.Err()
+}
+
+// BoostDomainWeight increases a domain's weight by the given amount.
+func (db *DB) BoostDomainWeight(ctx context.Context, domain string, boost float64) error {
+	return boostDomain(ctx, db.conn, domain, boost)
+}
+
+// boostDomain boosts the weight of domain.
+func boostDomain(ctx context.Context, ex execer, domain string, boost float64) error {
+	query := `
+	INSERT INTO domain_weights (domain, weight, count)
+	VALUES (?, 1.0 + ?, 1)
+	ON CONFLICT(domain) DO UPDATE SET
+		weight = weight + ?,
+		count = count + 1
+	`
+	_, err := ex.ExecContext(ctx, query, domain, boost, boost)
+	return err
+}
+
+// ApplyDomainDecay reduces all domain weights by decay rate with a minimum floor.
+func (db *DB) ApplyDomainDecay(ctx context.Context, decayRate, minWeight float64) error {
+	return decayWeights(ctx, db.conn, "domain_weights", decayRate, minWeight)
+}
+
+// GetTopDomains returns the top N domains by weight.
+func (db *DB) GetTopDomains(ctx context.Context, limit int) ([]DomainWeight, error) {
+	query := `SELECT domain, weight, count FROM domain_weights ORDER BY weight DESC LIMIT ?`
+	rows, err := db.conn.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []DomainWeight
+	for rows.Next() {
+		var dw DomainWeight
+		if err := rows.Scan(&dw.Domain, &dw.Weight, &dw.Count); err != nil {
+			return nil, err
+		}
+		domains = append(domains, dw)
+	}
+	return domains, rows.Err()
+}
+
+// ClearPreferences deletes all learned preferences (tag and domain weights,
+// likes and dislikes) in a single transaction. Articles, sent history,
+// tag weight history and settings are kept.
+func (db *DB) ClearPreferences(ctx context.Context) (*ClearedPreferences, error) {
+	cleared := &ClearedPreferences{}
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		tables := []struct {
+			name  string
+			count *int64
+		}{
+			{"tag_weights", &cleared.Tags},
+			{"domain_weights", &cleared.Domains},
+			{"likes", &cleared.Likes},
+			{"dislikes", &cleared.Dislikes},
+		}
+		// Boosts and last likes go with the likes, but aren't counted
+		for _, table := range []string{"like_boosts", "last_likes"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+				return fmt.Errorf("clear %s: %w", table, err)
+			}
+		}
+		for _, t := range tables {
+			res, err := tx.ExecContext(ctx, "DELETE FROM "+t.name)
+			if err != nil {
+				return fmt.Errorf("clear %s: %w", t.name, err)
+			}
+			if *t.count, err = res.RowsAffected(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cleared, nil
+}
+
+// UnsubscribeChat marks a chat as no longer receiving digests.
+func (db *DB) UnsubscribeChat(ctx context.Context, chatID int64, reason string) error {
+	query := `
+	INSERT INTO unsubscribed_chats (chat_id, reason, unsubscribed_at) VALUES (?, ?, ?)
+	ON CONFLICT(chat_id) DO UPDATE SET reason = excluded.reason, unsubscribed_at = excluded.unsubscribed_at
+	`
+	_, err := db.conn.ExecContext(ctx, query, chatID, reason, time.Now())
+	return err
+}
+
+// ResubscribeChat clears a chat's unsubscribed state.
+func (db *DB) ResubscribeChat(ctx context.Context, chatID int64) error {
+	query := `DELETE FROM unsubscribed_chats WHERE chat_id = ?`
+	_, err := db.conn.ExecContext(ctx, query, chatID)
+	return err
+}
+
+// GetSubscribedChatIDs returns the chats that receive digests: the chat
+// set with /subscribe and every chat articles were sent to, except those that
+// unsubscribed.
+func (db *DB) GetSubscribedChatIDs(ctx context.Context) ([]int64, error) {
+	query := `
+	SELECT chat_id FROM (
+		SELECT chat_id FROM sent_articles
+		UNION
+		SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'chat_id'
+	)
+	WHERE chat_id != 0 AND chat_id NOT IN (SELECT chat_id FROM unsubscribed_chats)
+	ORDER BY chat_id
+	`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsChatUnsubscribed checks if a chat has been unsubscribed.
+func (db *DB) IsChatUnsubscribed(ctx context.Context, chatID int64) (bool, error) {
+	query := `SELECT 1 FROM unsubscribed_chats WHERE chat_id = ?`
+	var dummy int
+	err := db.conn.QueryRowContext(ctx, query, chatID).Scan(&dummy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RecordFailedArticle records that processing an article failed at the
+// given stage, counting the attempt.
+func (db *DB) RecordFailedArticle(ctx context.Context, articleID int64, stage, errMsg string) error {
+	query := `
+	INSERT INTO failed_articles (article_id, stage, error, attempts, failed_at) VALUES (?, ?, ?, 1, ?)
+	ON CONFLICT(article_id) DO UPDATE SET
+		stage = excluded.stage,
+		error = excluded.error,
+		attempts = attempts + 1,
+		failed_at = excluded.failed_at
+	`
+	_, err := db.conn.ExecContext(ctx, query, articleID, stage, errMsg, time.Now())
+	return err
+}
+
+// GetFailedArticleIDs returns the articles that last failed within the
+// given duration and have failed fewer than maxAttempts times, oldest
+// failure first. Articles that used up their attempts stay recorded but
+// aren't returned.
+func (db *DB) GetFailedArticleIDs(ctx context.Context, maxAttempts int, within time.Duration) ([]int64, error) {
+	query := `SELECT article_id FROM failed_articles WHERE attempts < ? AND failed_at > ? ORDER BY failed_at, article_id`
+	rows, err := db.conn.QueryContext(ctx, query, maxAttempts, time.Now().Add(-within))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ClearFailedArticle forgets an article's failures, once it has been
+// processed successfully.
+func (db *DB) ClearFailedArticle(ctx context.Context, articleID int64) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM failed_articles WHERE article_id = ?`, articleID)
+	return err
+}
+
+// SavePendingBatch records the batch a chat is being sent, replacing any
+// earlier batch of the chat.
+func (db *DB) SavePendingBatch(ctx context.Context, batch *PendingBatch) error {
+	query := `
+	INSERT INTO pending_batches (chat_id, batch_id, articles, created_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(chat_id) DO UPDATE SET
+		batch_id = excluded.batch_id,
+		articles = excluded.articles,
+		created_at = excluded.created_at
+	`
+	_, err := db.conn.ExecContext(ctx, query, batch.ChatID, batch.ID, batch.Articles, batch.CreatedAt)
+	return err
+}
+
+// GetPendingBatch returns the batch a chat was last being sent, or
+// ErrNotFound if it has none.
+func (db *DB) GetPendingBatch(ctx context.Context, chatID int64) (*PendingBatch, error) {
+	query := `SELECT batch_id, articles, created_at FROM pending_batches WHERE chat_id = ?`
+	batch := &PendingBatch{ChatID: chatID}
+	err := db.conn.QueryRowContext(ctx, query, chatID).Scan(&batch.ID, &batch.Articles, &batch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// DeletePendingBatch forgets a chat's pending batch once it has been sent,
+// unless a later batch has replaced it.
+func (db *DB) DeletePendingBatch(ctx context.Context, chatID int64, batchID string) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM pending_batches WHERE chat_id = ? AND batch_id = ?`, chatID, batchID)
+	return err
+}
+
 // GetSetting retrieves a setting value by key.
 func (db *DB) GetSetting(ctx context.Context, key string) (string, error) {
 	query := `SELECT value FROM settings WHERE key = ?`
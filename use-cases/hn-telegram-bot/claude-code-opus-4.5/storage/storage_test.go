@@ -3,7 +3,12 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -38,6 +43,35 @@ func TestNewDB(t *testing.T) {
 	}
 }
 
+func TestNewDBMissingDir(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data", "nested", "bot.db")
+
+	if db, err := NewDB(dbPath); err == nil {
+		db.Close()
+		t.Fatal("NewDB succeeded without the database's directory, want an error")
+	}
+	if _, err := os.Stat(filepath.Dir(dbPath)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory created without WithCreateDir: %v", err)
+	}
+}
+
+func TestNewDBCreateDir(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data", "nested", "bot.db")
+
+	db, err := NewDB(dbPath, WithCreateDir())
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.conn.ExecContext(context.Background(), "SELECT 1 FROM articles LIMIT 1"); err != nil {
+		t.Errorf("articles table not created: %v", err)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("database file not created: %v", err)
+	}
+}
+
 func TestArticleCRUD(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
@@ -84,39 +118,230 @@ func TestArticleCRUD(t *testing.T) {
 	}
 }
 
-func TestArticleByMessageID(t *testing.T) {
+func TestArticleSummaryModel(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 	ctx := context.Background()
 
-	// Save article with message ID
 	article := &Article{
-		ID:              12345,
-		Title:           "Test Article",
-		URL:             "https://example.com/test",
-		Tags:            []string{"go"},
-		FetchedAt:       time.Now(),
-		SentAt:          ptrTime(time.Now()),
-		TelegramMsgID:   ptrInt64(789),
+		ID:           1,
+		Title:        "Test",
+		URL:          "https://example.com",
+		Summary:      "Summary",
+		SummaryModel: "gemini-2.0-flash-lite",
+		Tags:         []string{},
+		FetchedAt:    time.Now(),
 	}
 	if err := db.SaveArticle(ctx, article); err != nil {
 		t.Fatalf("SaveArticle failed: %v", err)
 	}
 
-	// Find by message ID
-	retrieved, err := db.GetArticleByMessageID(ctx, 789)
+	retrieved, err := db.GetArticle(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetArticle failed: %v", err)
+	}
+	if retrieved.SummaryModel != "gemini-2.0-flash-lite" {
+		t.Errorf("SummaryModel = %q, want %q", retrieved.SummaryModel, "gemini-2.0-flash-lite")
+	}
+
+	// Re-summarizing with another model replaces it
+	article.SummaryModel = "gemini-2.5-flash"
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+	insertSent(t, db, 1, 100, 7, time.Now())
+	retrieved, err = db.GetArticleByMessageID(ctx, 100, 7)
 	if err != nil {
 		t.Fatalf("GetArticleByMessageID failed: %v", err)
 	}
-	if retrieved.ID != 12345 {
-		t.Errorf("ID = %d, want %d", retrieved.ID, 12345)
+	if retrieved.SummaryModel != "gemini-2.5-flash" {
+		t.Errorf("SummaryModel = %q, want %q", retrieved.SummaryModel, "gemini-2.5-flash")
 	}
+}
 
-	// Non-existent message ID
-	_, err = db.GetArticleByMessageID(ctx, 999)
-	if err != ErrNotFound {
+func TestArticleImageURL(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	article := &Article{
+		ID:        1,
+		Title:     "Test",
+		URL:       "https://example.com",
+		Summary:   "Summary",
+		Tags:      []string{},
+		FetchedAt: time.Now(),
+		ImageURL:  "https://example.com/cover.jpg",
+	}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	insertSent(t, db, 1, 100, 7, time.Now())
+	retrieved, err := db.GetArticleByMessageID(ctx, 100, 7)
+	if err != nil {
+		t.Fatalf("GetArticleByMessageID failed: %v", err)
+	}
+	if retrieved.ImageURL != article.ImageURL {
+		t.Errorf("ImageURL = %q, want %q", retrieved.ImageURL, article.ImageURL)
+	}
+}
+
+func TestGetArticleByContentHash(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.GetArticleByContentHash(ctx, 1, "abc"); err != ErrNotFound {
+		t.Errorf("unknown hash error = %v, want ErrNotFound", err)
+	}
+
+	now := time.Now()
+	articles := []*Article{
+		{ID: 1, Title: "Story", URL: "https://example.com/1", Summary: "Story summary", ContentHash: "abc", Tags: []string{"go"}, FetchedAt: now},
+		{ID: 2, Title: "Paywalled", URL: "https://example.com/2", Summary: "Paywall summary", ContentHash: "stub", Tags: []string{"news"}, FetchedAt: now},
+		{ID: 3, Title: "Unhashed", URL: "https://example.com/3", Summary: "Other", Tags: []string{}, FetchedAt: now},
+	}
+	for _, a := range articles {
+		if err := db.SaveArticle(ctx, a); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+
+	got, err := db.GetArticleByContentHash(ctx, 1, "abc")
+	if err != nil {
+		t.Fatalf("GetArticleByContentHash failed: %v", err)
+	}
+	if got.ID != 1 || got.Summary != "Story summary" || got.ContentHash != "abc" {
+		t.Errorf("article = %+v, want article 1", got)
+	}
+
+	// Another article with the same content, such as the same paywall
+	// stub, doesn't match
+	if _, err := db.GetArticleByContentHash(ctx, 4, "stub"); err != ErrNotFound {
+		t.Errorf("other article's hash error = %v, want ErrNotFound", err)
+	}
+	if _, err := db.GetArticleByContentHash(ctx, 1, "stub"); err != ErrNotFound {
+		t.Errorf("changed content error = %v, want ErrNotFound", err)
+	}
+
+	// Articles saved without a hash never match
+	if _, err := db.GetArticleByContentHash(ctx, 3, ""); err != ErrNotFound {
+		t.Errorf("empty hash error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSeenArticles(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	article := &Article{ID: 1, Title: "Test", URL: "https://example.com", Summary: "Summary", Tags: []string{"go"}, FetchedAt: time.Now()}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	// Stored but never marked seen
+	if _, err := db.GetSeenArticle(ctx, 1, time.Hour); err != ErrNotFound {
+		t.Errorf("unseen article: expected ErrNotFound, got: %v", err)
+	}
+
+	if err := db.MarkArticleSeen(ctx, 1); err != nil {
+		t.Fatalf("MarkArticleSeen failed: %v", err)
+	}
+	seen, err := db.GetSeenArticle(ctx, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("GetSeenArticle failed: %v", err)
+	}
+	if seen.Summary != "Summary" || len(seen.Tags) != 1 || seen.Tags[0] != "go" {
+		t.Errorf("seen article = %+v, want the stored summary and tags", seen)
+	}
+
+	// Outside the window
+	if _, err := db.GetSeenArticle(ctx, 1, -time.Second); err != ErrNotFound {
+		t.Errorf("expired: expected ErrNotFound, got: %v", err)
+	}
+}
+
+func TestAddArticleMessage(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	article := &Article{ID: 1, Title: "Test", URL: "https://example.com", Tags: []string{"go"}, FetchedAt: time.Now()}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+	if err := db.MarkArticleSent(ctx, 1, 100, 10, "top"); err != nil {
+		t.Fatalf("MarkArticleSent failed: %v", err)
+	}
+	if err := db.AddArticleMessage(ctx, 1, 100, 11); err != nil {
+		t.Fatalf("AddArticleMessage failed: %v", err)
+	}
+
+	for _, msgID := range []int64{10, 11} {
+		retrieved, err := db.GetArticleByMessageID(ctx, 100, msgID)
+		if err != nil {
+			t.Fatalf("message %d: GetArticleByMessageID failed: %v", msgID, err)
+		}
+		if retrieved.ID != 1 {
+			t.Errorf("message %d: ID = %d, want 1", msgID, retrieved.ID)
+		}
+	}
+
+	// The extra message is not another delivery
+	if n, err := db.GetSentArticleCount(ctx); err != nil || n != 1 {
+		t.Errorf("sent article count = %d (err %v), want 1", n, err)
+	}
+	counts, err := db.GetSentCountsBySource(ctx, 100, time.Hour)
+	if err != nil || len(counts) != 1 || counts[0].Count != 1 {
+		t.Errorf("sent counts = %v (err %v), want one top delivery", counts, err)
+	}
+
+	if _, err := db.GetArticleByMessageID(ctx, 200, 11); err != ErrNotFound {
+		t.Errorf("other chat: expected ErrNotFound, got: %v", err)
+	}
+}
+
+func TestArticleByMessageID(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{"go"}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+
+	// Message IDs are only unique within a chat
+	db.MarkArticleSent(ctx, 1, 100, 789, "top")
+	db.MarkArticleSent(ctx, 2, 200, 789, "top")
+
+	retrieved, err := db.GetArticleByMessageID(ctx, 100, 789)
+	if err != nil {
+		t.Fatalf("GetArticleByMessageID failed: %v", err)
+	}
+	if retrieved.ID != 1 {
+		t.Errorf("chat 100: ID = %d, want 1", retrieved.ID)
+	}
+
+	retrieved, err = db.GetArticleByMessageID(ctx, 200, 789)
+	if err != nil {
+		t.Fatalf("GetArticleByMessageID failed: %v", err)
+	}
+	if retrieved.ID != 2 {
+		t.Errorf("chat 200: ID = %d, want 2", retrieved.ID)
+	}
+
+	// Non-existent message ID, and a known message ID in another chat
+	if _, err := db.GetArticleByMessageID(ctx, 100, 999); err != ErrNotFound {
 		t.Errorf("expected ErrNotFound, got: %v", err)
 	}
+	if _, err := db.GetArticleByMessageID(ctx, 300, 789); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for other chat, got: %v", err)
+	}
 }
 
 func TestGetRecentlySentArticleIDs(t *testing.T) {
@@ -125,204 +350,1185 @@ func TestGetRecentlySentArticleIDs(t *testing.T) {
 	ctx := context.Background()
 
 	now := time.Now()
+	for _, id := range []int64{1, 2, 3} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: now}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
 
-	// Article sent 3 days ago (within range)
-	article1 := &Article{
-		ID:        1,
-		Title:     "Recent",
-		URL:       "https://example.com/1",
-		Tags:      []string{},
-		FetchedAt: now,
-		SentAt:    ptrTime(now.Add(-3 * 24 * time.Hour)),
-	}
-	// Article sent 10 days ago (outside range)
-	article2 := &Article{
-		ID:        2,
-		Title:     "Old",
-		URL:       "https://example.com/2",
-		Tags:      []string{},
-		FetchedAt: now,
-		SentAt:    ptrTime(now.Add(-10 * 24 * time.Hour)),
-	}
-	// Article not sent yet
-	article3 := &Article{
-		ID:        3,
-		Title:     "Not Sent",
-		URL:       "https://example.com/3",
-		Tags:      []string{},
-		FetchedAt: now,
+	// Article 1 sent 3 days ago (within range), article 2 sent 10 days
+	// ago (outside range), article 3 not sent yet
+	insertSent(t, db, 1, 100, 10, now.Add(-3*24*time.Hour))
+	insertSent(t, db, 2, 100, 11, now.Add(-10*24*time.Hour))
+
+	ids, err := db.GetRecentlySentArticleIDs(ctx, 100, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetRecentlySentArticleIDs failed: %v", err)
 	}
 
-	for _, a := range []*Article{article1, article2, article3} {
-		if err := db.SaveArticle(ctx, a); err != nil {
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("got IDs %v, want [1]", ids)
+	}
+}
+
+func TestWasSent(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	for _, id := range []int64{1, 2} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: now}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+
+	// Article 1 was sent to two chats; article 2 was never sent
+	insertSent(t, db, 1, 100, 10, now.Add(-3*24*time.Hour))
+	insertSent(t, db, 1, 200, 11, now.Add(-time.Hour))
+
+	for _, chatID := range []int64{100, 200} {
+		want := now.Add(-3 * 24 * time.Hour)
+		if chatID == 200 {
+			want = now.Add(-time.Hour)
+		}
+		sent, sentAt, err := db.WasSent(ctx, chatID, 1)
+		if err != nil {
+			t.Fatalf("WasSent failed: %v", err)
+		}
+		if !sent || !sentAt.Equal(want) {
+			t.Errorf("WasSent(%d, 1) = %v, %v, want true, %v", chatID, sent, sentAt, want)
+		}
+	}
+
+	// Sends to other chats don't count
+	sent, sentAt, err := db.WasSent(ctx, 300, 1)
+	if err != nil {
+		t.Fatalf("WasSent failed: %v", err)
+	}
+	if sent || !sentAt.IsZero() {
+		t.Errorf("WasSent(300, 1) = %v, %v, want false and a zero time", sent, sentAt)
+	}
+
+	sent, sentAt, err = db.WasSent(ctx, 100, 2)
+	if err != nil {
+		t.Fatalf("WasSent failed: %v", err)
+	}
+	if sent || !sentAt.IsZero() {
+		t.Errorf("WasSent(100, 2) = %v, %v, want false and a zero time", sent, sentAt)
+	}
+}
+
+func TestGetRecentlySentArticleIDsPerChat(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
 			t.Fatalf("SaveArticle failed: %v", err)
 		}
 	}
+	db.MarkArticleSent(ctx, 1, 100, 10, "top")
+	db.MarkArticleSent(ctx, 2, 200, 10, "top")
 
-	ids, err := db.GetRecentlySentArticleIDs(ctx, 7*24*time.Hour)
+	ids, err := db.GetRecentlySentArticleIDs(ctx, 100, time.Hour)
 	if err != nil {
 		t.Fatalf("GetRecentlySentArticleIDs failed: %v", err)
 	}
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("chat 100: got IDs %v, want [1]", ids)
+	}
+
+	// A chat that was never sent anything has nothing to dedup
+	ids, _ = db.GetRecentlySentArticleIDs(ctx, 300, time.Hour)
+	if len(ids) != 0 {
+		t.Errorf("chat 300: got IDs %v, want none", ids)
+	}
+}
+
+func TestSaveArticlePreservesSentState(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	article := &Article{ID: 1, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+	if err := db.MarkArticleSent(ctx, 1, 100, 456, "top"); err != nil {
+		t.Fatalf("MarkArticleSent failed: %v", err)
+	}
+
+	// Re-saving (as the digest does before sending) keeps deliveries
+	article.Title = "Updated"
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	retrieved, err := db.GetArticleByMessageID(ctx, 100, 456)
+	if err != nil {
+		t.Fatalf("GetArticleByMessageID failed: %v", err)
+	}
+	if retrieved.Title != "Updated" {
+		t.Errorf("Title = %q, want 'Updated'", retrieved.Title)
+	}
+}
+
+func TestSaveArticleKeepsFirstScore(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	firstSeen := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	article := &Article{ID: 1, Title: "Test", URL: "https://example.com", Tags: []string{}, HNScore: 100, FetchedAt: firstSeen}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	// The story re-trends with a higher score
+	article.HNScore = 220
+	article.FetchedAt = time.Now()
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	retrieved, err := db.GetArticle(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetArticle failed: %v", err)
+	}
+	if retrieved.HNScore != 220 {
+		t.Errorf("HNScore = %d, want 220", retrieved.HNScore)
+	}
+	if retrieved.FirstScore != 100 || !retrieved.FirstSeenAt.Equal(firstSeen) {
+		t.Errorf("first score = %d at %v, want 100 at %v", retrieved.FirstScore, retrieved.FirstSeenAt, firstSeen)
+	}
+	if delta := retrieved.HNScore - retrieved.FirstScore; delta != 120 {
+		t.Errorf("score delta = %d, want 120", delta)
+	}
+}
+
+func TestGetSentArticleCount(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2, 3} {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+	db.MarkArticleSent(ctx, 1, 100, 100, "top")
+	db.MarkArticleSent(ctx, 2, 100, 101, "top")
+	db.MarkArticleSent(ctx, 2, 200, 101, "top") // Same article in another chat
+
+	count, err := db.GetSentArticleCount(ctx)
+	if err != nil {
+		t.Fatalf("GetSentArticleCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("sent count = %d, want 2", count)
+	}
+}
+
+func TestMigrateSentArticles(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Simulate rows written before per-chat tracking
+	sentAt := time.Now().Add(-time.Hour)
+	for _, id := range []int64{1, 2} {
+		article := &Article{ID: id, Title: "Legacy", URL: "https://example.com", Tags: []string{}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+	_, err := db.conn.ExecContext(ctx, `UPDATE articles SET sent_at = ?, telegram_msg_id = 500 WHERE id = 1`, sentAt)
+	if err != nil {
+		t.Fatalf("seed legacy row: %v", err)
+	}
+
+	copied, err := db.MigrateSentArticles(ctx, 100)
+	if err != nil {
+		t.Fatalf("MigrateSentArticles failed: %v", err)
+	}
+	if copied != 1 {
+		t.Errorf("copied = %d, want 1", copied)
+	}
+
+	if retrieved, err := db.GetArticleByMessageID(ctx, 100, 500); err != nil || retrieved.ID != 1 {
+		t.Errorf("GetArticleByMessageID after migration = %v, %v; want article 1", retrieved, err)
+	}
+	ids, _ := db.GetRecentlySentArticleIDs(ctx, 100, 24*time.Hour)
+	if len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("recently sent after migration = %v, want [1]", ids)
+	}
+
+	// Running again, even for another chat, is a no-op
+	copied, err = db.MigrateSentArticles(ctx, 200)
+	if err != nil {
+		t.Fatalf("second MigrateSentArticles failed: %v", err)
+	}
+	if copied != 0 {
+		t.Errorf("second migration copied = %d, want 0", copied)
+	}
+}
+
+func TestGetSentCountsBySource(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	for id := int64(1); id <= 6; id++ {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{}, FetchedAt: now}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+
+	insertSent(t, db, 1, 100, 1, now.Add(-time.Hour), "top")
+	insertSent(t, db, 2, 100, 2, now.Add(-time.Hour), "top")
+	insertSent(t, db, 3, 100, 3, now.Add(-time.Hour), "best")
+	insertSent(t, db, 4, 100, 4, now.Add(-40*24*time.Hour), "search") // Outside window
+	insertSent(t, db, 5, 200, 5, now.Add(-time.Hour), "search")       // Other chat
+	db.MarkArticleSent(ctx, 6, 100, 6, "top")
+
+	counts, err := db.GetSentCountsBySource(ctx, 100, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetSentCountsBySource failed: %v", err)
+	}
+
+	want := []SourceCount{{Source: "top", Count: 3}, {Source: "best", Count: 1}}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %+v, want %+v", counts, want)
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Errorf("counts[%d] = %+v, want %+v", i, counts[i], want[i])
+		}
+	}
+}
+
+func TestRecentSentArticles(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	for id := int64(1); id <= 4; id++ {
+		article := &Article{ID: id, Title: fmt.Sprintf("Article %d", id), URL: fmt.Sprintf("https://example.com/%d", id), Tags: []string{}, FetchedAt: now}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+
+	insertSent(t, db, 1, 100, 1, now.Add(-3*time.Hour))
+	insertSent(t, db, 2, 100, 2, now.Add(-time.Hour))
+	insertSent(t, db, 3, 100, 3, now.Add(-2*time.Hour))
+	insertSent(t, db, 4, 200, 4, now) // Other chat
+
+	articles, err := db.RecentSentArticles(ctx, 100, 10)
+	if err != nil {
+		t.Fatalf("RecentSentArticles failed: %v", err)
+	}
+	wantIDs := []int64{2, 3, 1}
+	if len(articles) != len(wantIDs) {
+		t.Fatalf("articles = %+v, want IDs %v", articles, wantIDs)
+	}
+	for i, id := range wantIDs {
+		if articles[i].ID != id {
+			t.Errorf("articles[%d].ID = %d, want %d", i, articles[i].ID, id)
+		}
+	}
+	if articles[0].Title != "Article 2" || articles[0].URL != "https://example.com/2" {
+		t.Errorf("articles[0] = %+v, want Article 2 with its URL", articles[0])
+	}
+
+	limited, err := db.RecentSentArticles(ctx, 100, 2)
+	if err != nil {
+		t.Fatalf("RecentSentArticles with limit failed: %v", err)
+	}
+	if len(limited) != 2 || limited[0].ID != 2 || limited[1].ID != 3 {
+		t.Errorf("limited = %+v, want the 2 most recent", limited)
+	}
+}
+
+func TestColumnMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create sent_articles as it was before the source column existed
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	db.conn.Exec(`DROP TABLE sent_articles`)
+	db.conn.Exec(`CREATE TABLE sent_articles (
+		article_id INTEGER NOT NULL, chat_id INTEGER NOT NULL,
+		sent_at DATETIME NOT NULL, message_id INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, message_id))`)
+	db.conn.Exec(`INSERT INTO sent_articles VALUES (1, 100, ?, 10)`, time.Now())
+
+	// And articles as it was before the summary_model column existed
+	db.conn.Exec(`DROP TABLE articles`)
+	db.conn.Exec(`CREATE TABLE articles (
+		id INTEGER PRIMARY KEY, title TEXT NOT NULL, url TEXT NOT NULL,
+		summary TEXT, tags TEXT NOT NULL DEFAULT '[]', hn_score INTEGER DEFAULT 0,
+		fetched_at DATETIME NOT NULL, sent_at DATETIME, telegram_msg_id INTEGER)`)
+	db.conn.Exec(`INSERT INTO articles (id, title, url, summary, fetched_at) VALUES (1, 'Old', 'https://example.com', 'Old summary', ?)`, time.Now())
+	db.Close()
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB on old schema failed: %v", err)
+	}
+	defer db.Close()
+
+	counts, err := db.GetSentCountsBySource(context.Background(), 100, time.Hour)
+	if err != nil {
+		t.Fatalf("GetSentCountsBySource failed: %v", err)
+	}
+	if len(counts) != 1 || counts[0].Source != "top" {
+		t.Errorf("counts = %+v, want existing rows attributed to 'top'", counts)
+	}
+
+	article, err := db.GetArticle(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetArticle on migrated articles failed: %v", err)
+	}
+	if article.SummaryModel != "" {
+		t.Errorf("SummaryModel = %q, want empty for existing rows", article.SummaryModel)
+	}
+}
+
+func insertSent(t *testing.T, db *DB, articleID, chatID, msgID int64, sentAt time.Time, source ...string) {
+	t.Helper()
+	src := "top"
+	if len(source) > 0 {
+		src = source[0]
+	}
+	query := `INSERT INTO sent_articles (article_id, chat_id, sent_at, message_id, source) VALUES (?, ?, ?, ?, ?)`
+	if _, err := db.conn.Exec(query, articleID, chatID, sentAt, msgID, src); err != nil {
+		t.Fatalf("insert sent article: %v", err)
+	}
+}
+
+func TestLikeOperations(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Save article first
+	article := &Article{
+		ID:        12345,
+		Title:     "Test",
+		URL:       "https://example.com",
+		Tags:      []string{"go"},
+		FetchedAt: time.Now(),
+	}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	// Check not liked
+	liked, err := db.IsArticleLiked(ctx, 12345)
+	if err != nil {
+		t.Fatalf("IsArticleLiked failed: %v", err)
+	}
+	if liked {
+		t.Error("article should not be liked initially")
+	}
+
+	// Like article
+	if err := db.LikeArticle(ctx, 12345, 100); err != nil {
+		t.Fatalf("LikeArticle failed: %v", err)
+	}
+
+	// Check liked
+	liked, err = db.IsArticleLiked(ctx, 12345)
+	if err != nil {
+		t.Fatalf("IsArticleLiked failed: %v", err)
+	}
+	if !liked {
+		t.Error("article should be liked")
+	}
+
+	// Like again (idempotent)
+	if err := db.LikeArticle(ctx, 12345, 100); err != nil {
+		t.Fatalf("LikeArticle (duplicate) failed: %v", err)
+	}
+
+	// Count likes
+	count, err := db.GetLikeCount(ctx)
+	if err != nil {
+		t.Fatalf("GetLikeCount failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("like count = %d, want 1", count)
+	}
+}
+
+func TestGetLikeCountSince(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, like := range []struct {
+		articleID int64
+		likedAt   time.Time
+	}{
+		{1, now.Add(-time.Hour)},
+		{2, now.Add(-6 * 24 * time.Hour)},
+		{3, now.Add(-8 * 24 * time.Hour)},
+		{4, now.Add(-30 * 24 * time.Hour)},
+	} {
+		if _, err := db.conn.Exec(`INSERT INTO likes (article_id, liked_at, chat_id) VALUES (?, ?, 100)`, like.articleID, like.likedAt); err != nil {
+			t.Fatalf("insert like: %v", err)
+		}
+	}
+	if err := db.LikeArticle(ctx, 5, 100); err != nil {
+		t.Fatalf("LikeArticle failed: %v", err)
+	}
+
+	tests := []struct {
+		since time.Time
+		want  int
+	}{
+		{now.Add(-7 * 24 * time.Hour), 3},
+		{now.Add(-2 * time.Hour), 2},
+		{now.Add(-365 * 24 * time.Hour), 5},
+		{now.Add(time.Hour), 0},
+	}
+	for _, tt := range tests {
+		count, err := db.GetLikeCountSince(ctx, tt.since)
+		if err != nil {
+			t.Fatalf("GetLikeCountSince failed: %v", err)
+		}
+		if count != tt.want {
+			t.Errorf("GetLikeCountSince(%v ago) = %d, want %d", now.Sub(tt.since).Round(time.Hour), count, tt.want)
+		}
+	}
+}
+
+func TestLikeChatMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Create likes as it was before the chat_id column existed
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	db.conn.Exec(`DROP TABLE likes`)
+	db.conn.Exec(`CREATE TABLE likes (article_id INTEGER PRIMARY KEY, liked_at DATETIME NOT NULL)`)
+	db.conn.Exec(`INSERT INTO likes VALUES (1, ?), (2, ?)`, time.Now(), time.Now())
+	insertSent(t, db, 1, 100, 10, time.Now())
+	db.Close()
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB on old schema failed: %v", err)
+	}
+	defer db.Close()
+
+	chats := make(map[int64]int64)
+	rows, err := db.conn.Query(`SELECT article_id, chat_id FROM likes`)
+	if err != nil {
+		t.Fatalf("query likes: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var articleID, chatID int64
+		if err := rows.Scan(&articleID, &chatID); err != nil {
+			t.Fatalf("scan like: %v", err)
+		}
+		chats[articleID] = chatID
+	}
+
+	// The chat is taken from where the article was sent, or left unknown
+	if chats[1] != 100 || chats[2] != 0 {
+		t.Errorf("like chats = %v, want article 1 in chat 100 and article 2 unknown", chats)
+	}
+	if count, err := db.GetLikeCountSince(context.Background(), time.Now().Add(-time.Hour)); err != nil || count != 2 {
+		t.Errorf("GetLikeCountSince = %d, %v; want existing likes counted", count, err)
+	}
+}
+
+func TestDislikeOperations(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	article := &Article{ID: 12345, Title: "Test", URL: "https://example.com", Tags: []string{"go"}, FetchedAt: time.Now()}
+	if err := db.SaveArticle(ctx, article); err != nil {
+		t.Fatalf("SaveArticle failed: %v", err)
+	}
+
+	disliked, err := db.IsArticleDisliked(ctx, 12345)
+	if err != nil {
+		t.Fatalf("IsArticleDisliked failed: %v", err)
+	}
+	if disliked {
+		t.Error("article should not be disliked initially")
+	}
+
+	// Dislike twice (idempotent)
+	for i := 0; i < 2; i++ {
+		if err := db.DislikeArticle(ctx, 12345); err != nil {
+			t.Fatalf("DislikeArticle failed: %v", err)
+		}
+	}
+
+	disliked, _ = db.IsArticleDisliked(ctx, 12345)
+	if !disliked {
+		t.Error("article should be disliked")
+	}
+
+	// Dislikes are not counted as likes
+	count, _ := db.GetLikeCount(ctx)
+	if count != 0 {
+		t.Errorf("like count = %d, want 0", count)
+	}
+}
+
+func TestUndoLastLike(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		db.SaveArticle(ctx, &Article{ID: id, Title: fmt.Sprintf("Article %d", id), URL: "https://example.com", FetchedAt: time.Now()})
+	}
+	db.BoostTagWeights(ctx, map[string]float64{"go": 0.5})
+
+	// Chat 100 likes article 1, then article 2
+	if err := db.RecordLike(ctx, 1, 100, map[string]float64{"go": 0.2}, nil); err != nil {
+		t.Fatalf("RecordLike failed: %v", err)
+	}
+	if err := db.RecordLike(ctx, 2, 100, map[string]float64{"go": 0.2, "rust": 0.2}, map[string]float64{"example.com": 0.2}); err != nil {
+		t.Fatalf("RecordLike failed: %v", err)
+	}
+	// Liking an article again changes nothing
+	if err := db.RecordLike(ctx, 2, 100, map[string]float64{"go": 0.2}, nil); err != nil {
+		t.Fatalf("RecordLike failed: %v", err)
+	}
+	tags, _ := db.GetAllTagWeights(ctx)
+	if w := tags["go"]; math.Abs(w-1.9) > 1e-9 {
+		t.Errorf("go weight after likes = %f, want 1.9", w)
+	}
+
+	article, err := db.UndoLastLike(ctx, 100, 1.1)
+	if err != nil {
+		t.Fatalf("UndoLastLike failed: %v", err)
+	}
+	if article.ID != 2 || article.Title != "Article 2" {
+		t.Errorf("undid like of article %d %q, want article 2", article.ID, article.Title)
+	}
+
+	// go: 1.9 - 0.2; rust: 1.2 - 0.2 floored at 1.1; example.com: 1.2 - 0.2
+	tags, _ = db.GetAllTagWeights(ctx)
+	if w := tags["go"]; math.Abs(w-1.7) > 1e-9 {
+		t.Errorf("go weight = %f, want 1.7", w)
+	}
+	if w := tags["rust"]; math.Abs(w-1.1) > 1e-9 {
+		t.Errorf("rust weight = %f, want floor 1.1", w)
+	}
+	if count, _ := db.GetTagCount(ctx, "rust"); count != 0 {
+		t.Errorf("rust count = %d, want 0", count)
+	}
+	domains, _ := db.GetAllDomainWeights(ctx)
+	if w := domains["example.com"]; math.Abs(w-1.1) > 1e-9 {
+		t.Errorf("example.com weight = %f, want floor 1.1", w)
+	}
+	if liked, _ := db.IsArticleLiked(ctx, 2); liked {
+		t.Error("article 2 should no longer be liked")
+	}
+	if liked, _ := db.IsArticleLiked(ctx, 1); !liked {
+		t.Error("article 1 should still be liked")
+	}
+
+	// Only the last like can be undone, so a second undo is a no-op and
+	// leaves the earlier like alone
+	if _, err := db.UndoLastLike(ctx, 100, 0.1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second UndoLastLike error = %v, want ErrNotFound", err)
+	}
+	tags, _ = db.GetAllTagWeights(ctx)
+	if w := tags["go"]; math.Abs(w-1.7) > 1e-9 {
+		t.Errorf("go weight changed by a no-op undo: %f", w)
+	}
+	if liked, _ := db.IsArticleLiked(ctx, 1); !liked {
+		t.Error("article 1 should still be liked after a no-op undo")
+	}
+
+	// A new like can be undone again
+	if err := db.RecordLike(ctx, 2, 100, map[string]float64{"go": 0.2}, nil); err != nil {
+		t.Fatalf("RecordLike failed: %v", err)
+	}
+	if article, err := db.UndoLastLike(ctx, 100, 0.1); err != nil || article.ID != 2 {
+		t.Fatalf("UndoLastLike after a new like = %v, %v, want article 2", article, err)
+	}
+	if _, err := db.UndoLastLike(ctx, 200, 0.1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UndoLastLike for a chat without likes error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClearPreferences(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for id := int64(1); id <= 3; id++ {
+		article := &Article{ID: id, Title: "Test", URL: "https://example.com", Tags: []string{"go"}, FetchedAt: time.Now()}
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatalf("SaveArticle failed: %v", err)
+		}
+	}
+	db.MarkArticleSent(ctx, 1, 100, 10, "top")
+	db.LikeArticle(ctx, 1, 100)
+	db.LikeArticle(ctx, 2, 100)
+	db.DislikeArticle(ctx, 3)
+	db.BoostTagWeight(ctx, "go", 0.2)
+	db.BoostTagWeight(ctx, "rust", 0.2)
+	db.BoostTagWeight(ctx, "python", 0.2)
+	db.BoostDomainWeight(ctx, "example.com", 0.2)
+	db.SetSetting(ctx, "digest_time", "18:00")
+
+	cleared, err := db.ClearPreferences(ctx)
+	if err != nil {
+		t.Fatalf("ClearPreferences failed: %v", err)
+	}
+	want := ClearedPreferences{Tags: 3, Domains: 1, Likes: 2, Dislikes: 1}
+	if *cleared != want {
+		t.Errorf("cleared = %+v, want %+v", *cleared, want)
+	}
+
+	// Preferences are gone
+	if weights, _ := db.GetAllTagWeights(ctx); len(weights) != 0 {
+		t.Errorf("tag weights = %v, want none", weights)
+	}
+	if weights, _ := db.GetAllDomainWeights(ctx); len(weights) != 0 {
+		t.Errorf("domain weights = %v, want none", weights)
+	}
+	if count, _ := db.GetLikeCount(ctx); count != 0 {
+		t.Errorf("like count = %d, want 0", count)
+	}
+	if disliked, _ := db.IsArticleDisliked(ctx, 3); disliked {
+		t.Error("article 3 should no longer be disliked")
+	}
+
+	// Articles, sent history and settings remain
+	for id := int64(1); id <= 3; id++ {
+		if _, err := db.GetArticle(ctx, id); err != nil {
+			t.Errorf("GetArticle(%d) after reset: %v", id, err)
+		}
+	}
+	if _, err := db.GetArticleByMessageID(ctx, 100, 10); err != nil {
+		t.Errorf("sent history lost after reset: %v", err)
+	}
+	if v, err := db.GetSetting(ctx, "digest_time"); err != nil || v != "18:00" {
+		t.Errorf("digest_time = %q (err %v), want '18:00'", v, err)
+	}
+}
+
+func TestSeedTagWeights(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	n, err := db.SeedTagWeights(ctx, []string{"go", "rust", "ai", "go"}, 2.0)
+	if err != nil {
+		t.Fatalf("SeedTagWeights failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("seeded %d tags, want 3", n)
+	}
+
+	weights, _ := db.GetAllTagWeights(ctx)
+	for _, tag := range []string{"go", "rust", "ai"} {
+		if weights[tag] != 2.0 {
+			t.Errorf("%s weight = %f, want 2.0", tag, weights[tag])
+		}
+	}
+
+	// Seeding again, e.g. on the next start, is a no-op
+	db.BoostTagWeight(ctx, "go", 0.5)
+	n, err = db.SeedTagWeights(ctx, []string{"go", "python"}, 2.0)
+	if err != nil {
+		t.Fatalf("second SeedTagWeights failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("second seed added %d tags, want 0", n)
+	}
+
+	weights, _ = db.GetAllTagWeights(ctx)
+	if weights["go"] != 2.5 {
+		t.Errorf("go weight = %f, want 2.5 (learned state kept)", weights["go"])
+	}
+	if _, ok := weights["python"]; ok {
+		t.Error("python should not be seeded once weights exist")
+	}
+}
+
+func TestTagWeightOperations(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Get non-existent tag (should return default)
+	weight, err := db.GetTagWeight(ctx, "unknown")
+	if err != nil {
+		t.Fatalf("GetTagWeight failed: %v", err)
+	}
+	if weight != 1.0 {
+		t.Errorf("weight = %f, want 1.0 (default)", weight)
+	}
+
+	// Boost a tag
+	if err := db.BoostTagWeight(ctx, "go", 0.2); err != nil {
+		t.Fatalf("BoostTagWeight failed: %v", err)
+	}
+
+	weight, err = db.GetTagWeight(ctx, "go")
+	if err != nil {
+		t.Fatalf("GetTagWeight failed: %v", err)
+	}
+	if weight != 1.2 {
+		t.Errorf("weight = %f, want 1.2", weight)
+	}
+
+	// Boost again
+	if err := db.BoostTagWeight(ctx, "go", 0.3); err != nil {
+		t.Fatalf("BoostTagWeight failed: %v", err)
+	}
+
+	weight, _ = db.GetTagWeight(ctx, "go")
+	if weight != 1.5 {
+		t.Errorf("weight = %f, want 1.5", weight)
+	}
+
+	// Get all weights
+	weights, err := db.GetAllTagWeights(ctx)
+	if err != nil {
+		t.Fatalf("GetAllTagWeights failed: %v", err)
+	}
+	if w, ok := weights["go"]; !ok || w != 1.5 {
+		t.Errorf("weights[go] = %f, want 1.5", w)
+	}
+}
+
+func TestGetTagCount(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	count, err := db.GetTagCount(ctx, "go")
+	if err != nil {
+		t.Fatalf("GetTagCount failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("count for unknown tag = %d, want 0", count)
+	}
+
+	for range 3 {
+		if err := db.BoostTagWeight(ctx, "go", 0.2); err != nil {
+			t.Fatalf("BoostTagWeight failed: %v", err)
+		}
+	}
+	count, err = db.GetTagCount(ctx, "Go")
+	if err != nil {
+		t.Fatalf("GetTagCount failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+}
+
+func TestGetAllTagCounts(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	db.BoostTagWeight(ctx, "go", 0.2)
+	db.BoostTagWeight(ctx, "go", 0.2)
+	db.BoostTagWeight(ctx, "rust", 0.2)
+
+	counts, err := db.GetAllTagCounts(ctx)
+	if err != nil {
+		t.Fatalf("GetAllTagCounts failed: %v", err)
+	}
+	if len(counts) != 2 || counts["go"] != 2 || counts["rust"] != 1 {
+		t.Errorf("counts = %v, want go=2 rust=1", counts)
+	}
+}
+
+func TestTagWeightsCaseInsensitive(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for _, tag := range []string{"Go", "go", " GO "} {
+		if err := db.BoostTagWeight(ctx, tag, 0.2); err != nil {
+			t.Fatalf("BoostTagWeight(%q) failed: %v", tag, err)
+		}
+	}
+
+	tags, err := db.GetTopTags(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetTopTags failed: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Tag != "go" || tags[0].Count != 3 {
+		t.Fatalf("tags = %+v, want a single 'go' row boosted 3 times", tags)
+	}
+
+	weight, err := db.GetTagWeight(ctx, "GO")
+	if err != nil {
+		t.Fatalf("GetTagWeight failed: %v", err)
+	}
+	if math.Abs(weight-1.6) > 1e-9 {
+		t.Errorf("weight for 'GO' = %f, want 1.6", weight)
+	}
+}
+
+func TestMergeTagCaseMigration(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Rows written by a version that stored tags as given
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	db.conn.Exec(`INSERT INTO tag_weights (tag, weight, count) VALUES ('Go', 1.5, 2), ('go', 1.2, 1), ('GO', 0.5, 4), ('rust', 1.1, 1)`)
+	db.conn.Exec(`INSERT INTO tag_weight_history (tag, weight, recorded_at) VALUES ('Go', 1.5, ?)`, time.Now())
+	db.Close()
+
+	db, err = NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB on mixed-case tags failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	tags, err := db.GetTopTags(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetTopTags failed: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("tags = %+v, want go and rust", tags)
+	}
+	if tags[0].Tag != "go" || math.Abs(tags[0].Weight-3.2) > 1e-9 || tags[0].Count != 7 {
+		t.Errorf("merged tag = %+v, want go with weight 3.2 and count 7", tags[0])
+	}
+	if tags[1].Tag != "rust" || tags[1].Weight != 1.1 || tags[1].Count != 1 {
+		t.Errorf("untouched tag = %+v, want rust unchanged", tags[1])
+	}
+
+	history, err := db.GetTagWeightHistory(ctx, "go", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetTagWeightHistory failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("history for go = %+v, want the renamed 'Go' point", history)
+	}
+}
+
+func TestTagAliasesOnBoost(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.SetTagAliases(ctx, map[string]string{"machine-learning": "ml", "Golang": "go"}); err != nil {
+		t.Fatalf("SetTagAliases failed: %v", err)
+	}
+
+	db.BoostTagWeight(ctx, "Machine-Learning", 0.5)
+	// An article tagged with a tag and its alias boosts the tag once
+	if err := db.BoostTagWeights(ctx, map[string]float64{"ml": 0.2, "machine-learning": 0.2, "golang": 0.1}); err != nil {
+		t.Fatalf("BoostTagWeights failed: %v", err)
+	}
+
+	tags, err := db.GetTopTags(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetTopTags failed: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("tags = %+v, want ml and go", tags)
+	}
+	if tags[0].Tag != "ml" || math.Abs(tags[0].Weight-1.9) > 1e-9 || tags[0].Count != 2 {
+		t.Errorf("tag = %+v, want ml with weight 1.9 and count 2", tags[0])
+	}
+	if tags[1].Tag != "go" || math.Abs(tags[1].Weight-1.1) > 1e-9 {
+		t.Errorf("tag = %+v, want go with weight 1.1", tags[1])
+	}
+
+	weight, err := db.GetTagWeight(ctx, "golang")
+	if err != nil || math.Abs(weight-1.1) > 1e-9 {
+		t.Errorf("GetTagWeight(golang) = %f, %v, want the weight of go", weight, err)
+	}
+}
+
+func TestTagAliasesMergeStoredRows(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Rows learned before the aliases were configured
+	db.BoostTagWeight(ctx, "ml", 0.5)
+	db.BoostTagWeight(ctx, "machine-learning", 0.3)
+	db.BoostTagWeight(ctx, "machine learning", 0.2)
+	db.BoostTagWeight(ctx, "rust", 0.1)
+	if _, err := db.conn.Exec(`INSERT INTO like_boosts (article_id, kind, name, boost) VALUES (1, 'tag', 'ml', 0.1), (1, 'tag', 'machine-learning', 0.1)`); err != nil {
+		t.Fatalf("seed like boosts: %v", err)
+	}
+
+	aliases := map[string]string{"machine-learning": "ml", "machine learning": "ml"}
+	if err := db.SetTagAliases(ctx, aliases); err != nil {
+		t.Fatalf("SetTagAliases failed: %v", err)
+	}
+
+	tags, err := db.GetTopTags(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetTopTags failed: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("tags = %+v, want ml and rust", tags)
+	}
+	if tags[0].Tag != "ml" || math.Abs(tags[0].Weight-4.0) > 1e-9 || tags[0].Count != 3 {
+		t.Errorf("merged tag = %+v, want ml with weight 4.0 and count 3", tags[0])
+	}
+	if tags[1].Tag != "rust" || math.Abs(tags[1].Weight-1.1) > 1e-9 {
+		t.Errorf("untouched tag = %+v, want rust unchanged", tags[1])
+	}
+
+	var boost float64
+	if err := db.conn.QueryRow(`SELECT boost FROM like_boosts WHERE article_id = 1 AND name = 'ml'`).Scan(&boost); err != nil {
+		t.Fatalf("query merged like boost: %v", err)
+	}
+	if math.Abs(boost-0.2) > 1e-9 {
+		t.Errorf("merged like boost = %f, want 0.2", boost)
+	}
 
-	if len(ids) != 1 || ids[0] != 1 {
-		t.Errorf("got IDs %v, want [1]", ids)
+	// Merging again finds nothing left to merge
+	if err := db.SetTagAliases(ctx, aliases); err != nil {
+		t.Fatalf("second SetTagAliases failed: %v", err)
+	}
+	if again, _ := db.GetTopTags(ctx, 10); len(again) != 2 || again[0].Count != 3 {
+		t.Errorf("tags after second merge = %+v, want them unchanged", again)
 	}
 }
 
-func TestMarkArticleSent(t *testing.T) {
+func TestTagWeightsOrdered(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 	ctx := context.Background()
 
-	article := &Article{
-		ID:        12345,
-		Title:     "Test",
-		URL:       "https://example.com",
-		Tags:      []string{},
-		FetchedAt: time.Now(),
+	for _, tag := range []string{"zig", "go", "rust", "ai"} {
+		db.BoostTagWeight(ctx, tag, 0.5) // 1.5
 	}
-	if err := db.SaveArticle(ctx, article); err != nil {
-		t.Fatalf("SaveArticle failed: %v", err)
+	db.BoostTagWeight(ctx, "python", 2.0) // 3.0
+
+	want := []string{"python", "ai", "go", "rust", "zig"}
+	for range 5 {
+		weights, err := db.TagWeightsOrdered(ctx)
+		if err != nil {
+			t.Fatalf("TagWeightsOrdered failed: %v", err)
+		}
+		if len(weights) != len(want) {
+			t.Fatalf("got %d tags, want %d", len(weights), len(want))
+		}
+		for i, tw := range weights {
+			if tw.Tag != want[i] {
+				t.Fatalf("tag %d = %q, want %q (weight desc, then tag asc)", i, tw.Tag, want[i])
+			}
+		}
 	}
+}
 
-	msgID := int64(456)
-	if err := db.MarkArticleSent(ctx, 12345, msgID); err != nil {
-		t.Fatalf("MarkArticleSent failed: %v", err)
+func TestApplyTagDecay(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	// Set up initial weights
+	db.BoostTagWeight(ctx, "go", 0.5)    // 1.5
+	db.BoostTagWeight(ctx, "rust", 0.8)  // 1.8
+
+	// Apply 10% decay with 0.1 minimum
+	if err := db.ApplyTagDecay(ctx, 0.1, 0.1); err != nil {
+		t.Fatalf("ApplyTagDecay failed: %v", err)
 	}
 
-	retrieved, _ := db.GetArticle(ctx, 12345)
-	if retrieved.SentAt == nil {
-		t.Error("SentAt should be set")
+	weights, _ := db.GetAllTagWeights(ctx)
+	// 1.5 * 0.9 = 1.35
+	if w := weights["go"]; w < 1.34 || w > 1.36 {
+		t.Errorf("go weight = %f, want ~1.35", w)
 	}
-	if retrieved.TelegramMsgID == nil || *retrieved.TelegramMsgID != msgID {
-		t.Errorf("TelegramMsgID = %v, want %d", retrieved.TelegramMsgID, msgID)
+	// 1.8 * 0.9 = 1.62
+	if w := weights["rust"]; w < 1.61 || w > 1.63 {
+		t.Errorf("rust weight = %f, want ~1.62", w)
 	}
 }
 
-func TestLikeOperations(t *testing.T) {
+func TestTagWeightHistory(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 	ctx := context.Background()
 
-	// Save article first
-	article := &Article{
-		ID:        12345,
-		Title:     "Test",
-		URL:       "https://example.com",
-		Tags:      []string{"go"},
-		FetchedAt: time.Now(),
-	}
-	if err := db.SaveArticle(ctx, article); err != nil {
-		t.Fatalf("SaveArticle failed: %v", err)
-	}
+	db.BoostTagWeight(ctx, "go", 1.0)   // 2.0
+	db.BoostTagWeight(ctx, "rust", 0.0) // 1.0
+	start := time.Now().Add(-time.Second)
 
-	// Check not liked
-	liked, err := db.IsArticleLiked(ctx, 12345)
-	if err != nil {
-		t.Fatalf("IsArticleLiked failed: %v", err)
-	}
-	if liked {
-		t.Error("article should not be liked initially")
+	// Two digests, each decaying 50% then snapshotting
+	for i := 0; i < 2; i++ {
+		if err := db.ApplyTagDecay(ctx, 0.5, 0.1); err != nil {
+			t.Fatalf("ApplyTagDecay failed: %v", err)
+		}
+		if err := db.SnapshotTagWeights(ctx); err != nil {
+			t.Fatalf("SnapshotTagWeights failed: %v", err)
+		}
 	}
 
-	// Like article
-	if err := db.LikeArticle(ctx, 12345); err != nil {
-		t.Fatalf("LikeArticle failed: %v", err)
+	tests := map[string][]float64{
+		"go":   {1.0, 0.5},
+		"rust": {0.5, 0.25},
+	}
+	for tag, want := range tests {
+		points, err := db.GetTagWeightHistory(ctx, tag, start)
+		if err != nil {
+			t.Fatalf("GetTagWeightHistory(%q) failed: %v", tag, err)
+		}
+		if len(points) != len(want) {
+			t.Fatalf("%s history has %d rows, want %d", tag, len(points), len(want))
+		}
+		for i, p := range points {
+			if p.Weight != want[i] {
+				t.Errorf("%s history[%d] = %f, want %f", tag, i, p.Weight, want[i])
+			}
+		}
+		if points[1].RecordedAt.Before(points[0].RecordedAt) {
+			t.Errorf("%s history not in chronological order", tag)
+		}
 	}
 
-	// Check liked
-	liked, err = db.IsArticleLiked(ctx, 12345)
+	// Snapshots before since are excluded
+	points, err := db.GetTagWeightHistory(ctx, "go", time.Now().Add(time.Hour))
 	if err != nil {
-		t.Fatalf("IsArticleLiked failed: %v", err)
+		t.Fatalf("GetTagWeightHistory failed: %v", err)
 	}
-	if !liked {
-		t.Error("article should be liked")
+	if len(points) != 0 {
+		t.Errorf("history after future since = %v, want empty", points)
 	}
+}
 
-	// Like again (idempotent)
-	if err := db.LikeArticle(ctx, 12345); err != nil {
-		t.Fatalf("LikeArticle (duplicate) failed: %v", err)
+func TestApplyDecay(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	db.BoostTagWeight(ctx, "go", 1.0)             // 2.0
+	db.BoostDomainWeight(ctx, "example.com", 1.0) // 2.0
+	start := time.Now().Add(-time.Second)
+
+	if err := db.ApplyDecay(ctx, 0.5, 0.1); err != nil {
+		t.Fatalf("ApplyDecay failed: %v", err)
 	}
 
-	// Count likes
-	count, err := db.GetLikeCount(ctx)
-	if err != nil {
-		t.Fatalf("GetLikeCount failed: %v", err)
+	tags, _ := db.GetAllTagWeights(ctx)
+	if tags["go"] != 1.0 {
+		t.Errorf("go weight = %f, want 1.0", tags["go"])
 	}
-	if count != 1 {
-		t.Errorf("like count = %d, want 1", count)
+	domains, _ := db.GetAllDomainWeights(ctx)
+	if domains["example.com"] != 1.0 {
+		t.Errorf("example.com weight = %f, want 1.0", domains["example.com"])
+	}
+	points, _ := db.GetTagWeightHistory(ctx, "go", start)
+	if len(points) != 1 || points[0].Weight != 1.0 {
+		t.Errorf("go history = %+v, want one decayed snapshot", points)
 	}
 }
 
-func TestTagWeightOperations(t *testing.T) {
+func TestApplyDecayRollsBackOnFailure(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 	ctx := context.Background()
 
-	// Get non-existent tag (should return default)
-	weight, err := db.GetTagWeight(ctx, "unknown")
-	if err != nil {
-		t.Fatalf("GetTagWeight failed: %v", err)
-	}
-	if weight != 1.0 {
-		t.Errorf("weight = %f, want 1.0 (default)", weight)
-	}
-
-	// Boost a tag
-	if err := db.BoostTagWeight(ctx, "go", 0.2); err != nil {
-		t.Fatalf("BoostTagWeight failed: %v", err)
+	for i := range 100 {
+		db.BoostTagWeight(ctx, fmt.Sprintf("tag-%03d", i), 1.0) // 2.0
 	}
+	db.BoostDomainWeight(ctx, "example.com", 1.0) // 2.0
 
-	weight, err = db.GetTagWeight(ctx, "go")
+	// Fail partway through the snapshot, after every tag and domain has
+	// been decayed
+	_, err := db.conn.ExecContext(ctx, `
+	CREATE TRIGGER fail_snapshot BEFORE INSERT ON tag_weight_history
+	WHEN NEW.tag = 'tag-050'
+	BEGIN SELECT RAISE(ABORT, 'injected failure'); END
+	`)
 	if err != nil {
-		t.Fatalf("GetTagWeight failed: %v", err)
-	}
-	if weight != 1.2 {
-		t.Errorf("weight = %f, want 1.2", weight)
+		t.Fatalf("create trigger: %v", err)
 	}
 
-	// Boost again
-	if err := db.BoostTagWeight(ctx, "go", 0.3); err != nil {
-		t.Fatalf("BoostTagWeight failed: %v", err)
+	if err := db.ApplyDecay(ctx, 0.5, 0.1); err == nil {
+		t.Fatal("expected ApplyDecay to fail")
 	}
 
-	weight, _ = db.GetTagWeight(ctx, "go")
-	if weight != 1.5 {
-		t.Errorf("weight = %f, want 1.5", weight)
+	tags, _ := db.GetAllTagWeights(ctx)
+	if len(tags) != 100 {
+		t.Fatalf("got %d tags, want 100", len(tags))
 	}
-
-	// Get all weights
-	weights, err := db.GetAllTagWeights(ctx)
-	if err != nil {
-		t.Fatalf("GetAllTagWeights failed: %v", err)
+	for tag, w := range tags {
+		if w != 2.0 {
+			t.Errorf("%s weight = %f, want unchanged 2.0", tag, w)
+		}
 	}
-	if w, ok := weights["go"]; !ok || w != 1.5 {
-		t.Errorf("weights[go] = %f, want 1.5", w)
+	domains, _ := db.GetAllDomainWeights(ctx)
+	if domains["example.com"] != 2.0 {
+		t.Errorf("example.com weight = %f, want unchanged 2.0", domains["example.com"])
+	}
+	var snapshots int
+	db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM tag_weight_history`).Scan(&snapshots)
+	if snapshots != 0 {
+		t.Errorf("got %d history rows, want none", snapshots)
 	}
 }
 
-func TestApplyTagDecay(t *testing.T) {
+func TestBoostTagWeightsRollsBackOnFailure(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
 	ctx := context.Background()
 
-	// Set up initial weights
-	db.BoostTagWeight(ctx, "go", 0.5)    // 1.5
-	db.BoostTagWeight(ctx, "rust", 0.8)  // 1.8
+	boosts := make(map[string]float64)
+	for i := range 20 {
+		boosts[fmt.Sprintf("tag-%02d", i)] = 0.5
+	}
+	if err := db.BoostTagWeights(ctx, boosts); err != nil {
+		t.Fatalf("BoostTagWeights failed: %v", err)
+	}
+	if w, _ := db.GetTagWeight(ctx, "tag-07"); w != 1.5 {
+		t.Errorf("tag-07 weight = %f, want 1.5", w)
+	}
 
-	// Apply 10% decay with 0.1 minimum
-	if err := db.ApplyTagDecay(ctx, 0.1, 0.1); err != nil {
-		t.Fatalf("ApplyTagDecay failed: %v", err)
+	_, err := db.conn.ExecContext(ctx, `
+	CREATE TRIGGER fail_boost BEFORE UPDATE ON tag_weights
+	WHEN NEW.tag = 'tag-10'
+	BEGIN SELECT RAISE(ABORT, 'injected failure'); END
+	`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
 	}
 
-	weights, _ := db.GetAllTagWeights(ctx)
-	// 1.5 * 0.9 = 1.35
-	if w := weights["go"]; w < 1.34 || w > 1.36 {
-		t.Errorf("go weight = %f, want ~1.35", w)
+	if err := db.BoostTagWeights(ctx, boosts); err == nil {
+		t.Fatal("expected BoostTagWeights to fail")
 	}
-	// 1.8 * 0.9 = 1.62
-	if w := weights["rust"]; w < 1.61 || w > 1.63 {
-		t.Errorf("rust weight = %f, want ~1.62", w)
+	tags, _ := db.GetAllTagWeights(ctx)
+	for tag, w := range tags {
+		if w != 1.5 {
+			t.Errorf("%s weight = %f, want unchanged 1.5", tag, w)
+		}
 	}
 }
 
@@ -351,6 +1557,40 @@ func TestApplyTagDecayWithFloor(t *testing.T) {
 	}
 }
 
+func TestGetTagWeightsPaged(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	for i, tag := range []string{"a", "b", "c", "d", "e"} {
+		db.BoostTagWeight(ctx, tag, float64(5-i))
+	}
+
+	page, err := db.GetTagWeightsPaged(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("GetTagWeightsPaged failed: %v", err)
+	}
+	if len(page) != 2 || page[0].Tag != "c" || page[1].Tag != "d" {
+		t.Errorf("page = %+v, want c and d", page)
+	}
+
+	last, err := db.GetTagWeightsPaged(ctx, 2, 4)
+	if err != nil {
+		t.Fatalf("GetTagWeightsPaged failed: %v", err)
+	}
+	if len(last) != 1 || last[0].Tag != "e" {
+		t.Errorf("last page = %+v, want e", last)
+	}
+
+	past, err := db.GetTagWeightsPaged(ctx, 2, 6)
+	if err != nil {
+		t.Fatalf("GetTagWeightsPaged failed: %v", err)
+	}
+	if len(past) != 0 {
+		t.Errorf("page past the end = %+v, want none", past)
+	}
+}
+
 func TestGetTopTags(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
@@ -391,6 +1631,125 @@ func TestGetTopTags(t *testing.T) {
 	}
 }
 
+func TestDomainWeightOperations(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	db.BoostDomainWeight(ctx, "example.com", 0.2)
+	db.BoostDomainWeight(ctx, "example.com", 0.3)
+	db.BoostDomainWeight(ctx, "blog.rust-lang.org", 0.2)
+
+	weights, err := db.GetAllDomainWeights(ctx)
+	if err != nil {
+		t.Fatalf("GetAllDomainWeights failed: %v", err)
+	}
+	if w := weights["example.com"]; w != 1.5 {
+		t.Errorf("example.com weight = %f, want 1.5", w)
+	}
+
+	top, err := db.GetTopDomains(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetTopDomains failed: %v", err)
+	}
+	if len(top) != 1 || top[0].Domain != "example.com" || top[0].Count != 2 {
+		t.Errorf("top domains = %+v, want example.com with count 2", top)
+	}
+
+	// Domains are independent of tags
+	tagWeights, _ := db.GetAllTagWeights(ctx)
+	if len(tagWeights) != 0 {
+		t.Errorf("tag weights = %v, want none", tagWeights)
+	}
+}
+
+func TestApplyDomainDecay(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	db.BoostDomainWeight(ctx, "example.com", 0.5) // 1.5
+	db.BoostDomainWeight(ctx, "other.com", -0.85) // 0.15
+
+	if err := db.ApplyDomainDecay(ctx, 0.1, 0.1); err != nil {
+		t.Fatalf("ApplyDomainDecay failed: %v", err)
+	}
+
+	weights, _ := db.GetAllDomainWeights(ctx)
+	// 1.5 * 0.9 = 1.35
+	if w := weights["example.com"]; w < 1.34 || w > 1.36 {
+		t.Errorf("example.com weight = %f, want ~1.35", w)
+	}
+	// 0.15 * 0.9 = 0.135, above the floor
+	if w := weights["other.com"]; w < 0.13 || w > 0.14 {
+		t.Errorf("other.com weight = %f, want ~0.135", w)
+	}
+}
+
+func TestChatSubscription(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	unsubscribed, err := db.IsChatUnsubscribed(ctx, 12345)
+	if err != nil {
+		t.Fatalf("IsChatUnsubscribed failed: %v", err)
+	}
+	if unsubscribed {
+		t.Error("chat should be subscribed by default")
+	}
+
+	if err := db.UnsubscribeChat(ctx, 12345, "Forbidden: bot was blocked by the user"); err != nil {
+		t.Fatalf("UnsubscribeChat failed: %v", err)
+	}
+	// Unsubscribing twice is fine
+	if err := db.UnsubscribeChat(ctx, 12345, "Bad Request: chat not found"); err != nil {
+		t.Fatalf("second UnsubscribeChat failed: %v", err)
+	}
+	if unsubscribed, _ := db.IsChatUnsubscribed(ctx, 12345); !unsubscribed {
+		t.Error("chat should be unsubscribed")
+	}
+	if unsubscribed, _ := db.IsChatUnsubscribed(ctx, 67890); unsubscribed {
+		t.Error("other chats should not be affected")
+	}
+
+	if err := db.ResubscribeChat(ctx, 12345); err != nil {
+		t.Fatalf("ResubscribeChat failed: %v", err)
+	}
+	if unsubscribed, _ := db.IsChatUnsubscribed(ctx, 12345); unsubscribed {
+		t.Error("chat should be subscribed again")
+	}
+}
+
+func TestGetSubscribedChatIDs(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	ids, err := db.GetSubscribedChatIDs(ctx)
+	if err != nil {
+		t.Fatalf("GetSubscribedChatIDs failed: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("expected no subscribers, got %v", ids)
+	}
+
+	db.SaveArticle(ctx, &Article{ID: 1, Title: "Article", URL: "https://example.com/1", FetchedAt: time.Now()})
+	db.MarkArticleSent(ctx, 1, 100, 10, "top")
+	db.MarkArticleSent(ctx, 1, 200, 11, "top")
+	db.MarkArticleSent(ctx, 1, 300, 12, "top")
+	db.SetSetting(ctx, "chat_id", "100")
+	db.UnsubscribeChat(ctx, 300, "Forbidden: bot was blocked by the user")
+
+	ids, err = db.GetSubscribedChatIDs(ctx)
+	if err != nil {
+		t.Fatalf("GetSubscribedChatIDs failed: %v", err)
+	}
+	if !slices.Equal(ids, []int64{100, 200}) {
+		t.Errorf("subscribers = %v, want [100 200]", ids)
+	}
+}
+
 func TestSettingsOperations(t *testing.T) {
 	db := newTestDB(t)
 	defer db.Close()
@@ -483,6 +1842,48 @@ func TestEmptyTags(t *testing.T) {
 	}
 }
 
+func TestPendingBatch(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.GetPendingBatch(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetPendingBatch with none error = %v, want ErrNotFound", err)
+	}
+
+	created := time.Now().Truncate(time.Second)
+	first := &PendingBatch{ID: "a", ChatID: 1, Articles: []byte(`[{"ID":1}]`), CreatedAt: created}
+	if err := db.SavePendingBatch(ctx, first); err != nil {
+		t.Fatalf("SavePendingBatch failed: %v", err)
+	}
+	second := &PendingBatch{ID: "b", ChatID: 1, Articles: []byte(`[{"ID":2}]`), CreatedAt: created}
+	if err := db.SavePendingBatch(ctx, second); err != nil {
+		t.Fatalf("SavePendingBatch failed: %v", err)
+	}
+
+	got, err := db.GetPendingBatch(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetPendingBatch failed: %v", err)
+	}
+	if got.ID != "b" || string(got.Articles) != `[{"ID":2}]` || !got.CreatedAt.Equal(created) {
+		t.Errorf("batch = %+v, want the replacing batch b", got)
+	}
+
+	// Deleting a replaced batch leaves the current one
+	if err := db.DeletePendingBatch(ctx, 1, "a"); err != nil {
+		t.Fatalf("DeletePendingBatch failed: %v", err)
+	}
+	if _, err := db.GetPendingBatch(ctx, 1); err != nil {
+		t.Errorf("batch b gone after deleting a: %v", err)
+	}
+	if err := db.DeletePendingBatch(ctx, 1, "b"); err != nil {
+		t.Fatalf("DeletePendingBatch failed: %v", err)
+	}
+	if _, err := db.GetPendingBatch(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPendingBatch after delete error = %v, want ErrNotFound", err)
+	}
+}
+
 // Helper functions
 
 func newTestDB(t *testing.T) *DB {
@@ -496,14 +1897,6 @@ func newTestDB(t *testing.T) *DB {
 	return db
 }
 
-func ptrTime(t time.Time) *time.Time {
-	return &t
-}
-
-func ptrInt64(i int64) *int64 {
-	return &i
-}
-
 // Ensure tags serialize correctly
 func TestTagsJSONRoundTrip(t *testing.T) {
 	original := []string{"go", "testing", "web-dev"}
@@ -521,3 +1914,45 @@ func TestTagsJSONRoundTrip(t *testing.T) {
 		t.Fatalf("length mismatch: %d vs %d", len(restored), len(original))
 	}
 }
+
+func TestFailedArticles(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	if err := db.RecordFailedArticle(ctx, 1, "summarize", "quota exceeded"); err != nil {
+		t.Fatalf("RecordFailedArticle failed: %v", err)
+	}
+	db.RecordFailedArticle(ctx, 2, "fetch", "timeout")
+	db.RecordFailedArticle(ctx, 2, "fetch", "timeout")
+	db.RecordFailedArticle(ctx, 3, "summarize", "bad summary")
+
+	ids, err := db.GetFailedArticleIDs(ctx, 2, time.Hour)
+	if err != nil {
+		t.Fatalf("GetFailedArticleIDs failed: %v", err)
+	}
+	// Article 2 has used up its two attempts
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("failed IDs = %v, want [1 3]", ids)
+	}
+
+	var stage, msg string
+	var attempts int
+	db.conn.QueryRowContext(ctx, `SELECT stage, error, attempts FROM failed_articles WHERE article_id = 2`).Scan(&stage, &msg, &attempts)
+	if stage != "fetch" || msg != "timeout" || attempts != 2 {
+		t.Errorf("article 2 = %s/%s/%d, want fetch/timeout/2", stage, msg, attempts)
+	}
+
+	if err := db.ClearFailedArticle(ctx, 1); err != nil {
+		t.Fatalf("ClearFailedArticle failed: %v", err)
+	}
+	if ids, _ := db.GetFailedArticleIDs(ctx, 2, time.Hour); len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("failed IDs after clearing = %v, want [3]", ids)
+	}
+
+	// Failures older than the window aren't retried
+	db.conn.ExecContext(ctx, `UPDATE failed_articles SET failed_at = ? WHERE article_id = 3`, time.Now().Add(-2*time.Hour))
+	if ids, _ := db.GetFailedArticleIDs(ctx, 2, time.Hour); len(ids) != 0 {
+		t.Errorf("failed IDs outside window = %v, want none", ids)
+	}
+}
@@ -0,0 +1,73 @@
+package summarizer
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultKeyCooldown is how long a key that hit its quota is skipped when
+// the API doesn't say how long to wait.
+const defaultKeyCooldown = time.Minute
+
+// keyPool hands out API keys in turn, skipping keys that are cooling down
+// after hitting their quota.
+type keyPool struct {
+	keys []string
+
+	mu        sync.Mutex
+	next      int         // Index of the key to try first on the next pick
+	coolUntil []time.Time // When each key may be used again
+
+	now func() time.Time
+}
+
+// newKeyPool returns a pool of the non-empty keys, without duplicates, in
+// the order given. Without any keys, the pool holds a single empty key, so
+// that requests still go out and fail with the API's own error.
+func newKeyPool(keys ...string) *keyPool {
+	p := &keyPool{now: time.Now}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if k != "" && !seen[k] {
+			seen[k] = true
+			p.keys = append(p.keys, k)
+		}
+	}
+	if len(p.keys) == 0 {
+		p.keys = []string{""}
+	}
+	p.coolUntil = make([]time.Time, len(p.keys))
+	return p
+}
+
+// pick returns the next key in turn that isn't cooling down. If every key
+// is cooling down, it returns the one that recovers first and reports
+// false.
+func (p *keyPool) pick() (index int, key string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	soonest := -1
+	for n := range len(p.keys) {
+		i := (p.next + n) % len(p.keys)
+		if !now.Before(p.coolUntil[i]) {
+			p.next = (i + 1) % len(p.keys)
+			return i, p.keys[i], true
+		}
+		if soonest < 0 || p.coolUntil[i].Before(p.coolUntil[soonest]) {
+			soonest = i
+		}
+	}
+	return soonest, p.keys[soonest], false
+}
+
+// coolDown skips the key at index until d has passed.
+func (p *keyPool) coolDown(index int, d time.Duration) {
+	if d <= 0 {
+		d = defaultKeyCooldown
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.coolUntil[index] = p.now().Add(d)
+}
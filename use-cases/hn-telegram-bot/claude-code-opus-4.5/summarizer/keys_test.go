@@ -0,0 +1,134 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	p := newKeyPool("a", "", "b", "a", "c")
+	if !slices.Equal(p.keys, []string{"a", "b", "c"}) {
+		t.Fatalf("keys = %q, want [a b c]", p.keys)
+	}
+
+	var got []string
+	for range 4 {
+		_, key, ok := p.pick()
+		if !ok {
+			t.Fatalf("pick of %q reported every key cooling down", key)
+		}
+		got = append(got, key)
+	}
+	if want := []string{"a", "b", "c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("picked %q, want %q", got, want)
+	}
+}
+
+func TestKeyPoolSkipsCoolingKeys(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	p := newKeyPool("a", "b", "c")
+	p.now = func() time.Time { return now }
+
+	p.coolDown(0, 30*time.Second)
+	p.coolDown(1, 0) // Default cooldown, longer than 30s
+	for range 2 {
+		if _, key, ok := p.pick(); key != "c" || !ok {
+			t.Errorf("pick = %q (ok %v), want c while a and b cool down", key, ok)
+		}
+	}
+
+	p.coolDown(2, time.Hour)
+	if _, key, ok := p.pick(); key != "a" || ok {
+		t.Errorf("pick = %q (ok %v), want a, the first to recover, and not ok", key, ok)
+	}
+
+	now = now.Add(31 * time.Second)
+	if _, key, ok := p.pick(); key != "a" || !ok {
+		t.Errorf("pick = %q (ok %v), want a once its cooldown is over", key, ok)
+	}
+}
+
+func TestKeyPoolWithoutKeys(t *testing.T) {
+	p := newKeyPool("")
+	if _, key, ok := p.pick(); key != "" || !ok {
+		t.Errorf("pick = %q (ok %v), want the empty key", key, ok)
+	}
+}
+
+// keyedServer answers with a summary for keys not in limited, and with 429
+// for those in it, counting requests per key.
+func keyedServer(t *testing.T, limited ...string) (*httptest.Server, func() map[string]int) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		mu.Lock()
+		requests[key]++
+		mu.Unlock()
+		if slices.Contains(limited, key) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(geminiTextResponse(`{"summary": "A summary from ` + key + `", "tags": ["go"]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(requests)
+	}
+}
+
+func TestSummarizeFailsOverToNextKey(t *testing.T) {
+	server, requests := keyedServer(t, "key-a")
+	s := NewSummarizer("key-a", WithAPIKeys("key-b"), WithBaseURL(server.URL))
+
+	for i := range 2 {
+		result, err := s.Summarize(context.Background(), "Title", "Content")
+		if err != nil {
+			t.Fatalf("Summarize %d failed: %v", i+1, err)
+		}
+		if result.Summary != "A summary from key-b" {
+			t.Errorf("summary %d = %q, want one made with key-b", i+1, result.Summary)
+		}
+	}
+
+	// key-a is cooling down after its 429, so the second request goes
+	// straight to key-b
+	if got := requests(); got["key-a"] != 1 || got["key-b"] != 2 {
+		t.Errorf("requests per key = %v, want key-a once and key-b twice", got)
+	}
+}
+
+func TestSummarizeRoundRobinsKeys(t *testing.T) {
+	server, requests := keyedServer(t)
+	s := NewSummarizer("key-a", WithAPIKeys("key-b"), WithBaseURL(server.URL))
+
+	for range 4 {
+		if _, err := s.Summarize(context.Background(), "Title", "Content"); err != nil {
+			t.Fatalf("Summarize failed: %v", err)
+		}
+	}
+	if got := requests(); got["key-a"] != 2 || got["key-b"] != 2 {
+		t.Errorf("requests per key = %v, want two each", got)
+	}
+}
+
+func TestSummarizeAllKeysRateLimited(t *testing.T) {
+	server, requests := keyedServer(t, "key-a", "key-b")
+	s := NewSummarizer("key-a", WithAPIKeys("key-b"), WithBaseURL(server.URL))
+
+	if _, err := s.Summarize(context.Background(), "Title", "Content"); err == nil {
+		t.Fatal("expected error when every key is rate limited")
+	}
+	if got := requests(); got["key-a"] != 1 || got["key-b"] != 1 {
+		t.Errorf("requests per key = %v, want each key tried once", got)
+	}
+}
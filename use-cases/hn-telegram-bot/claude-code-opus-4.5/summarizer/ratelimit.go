@@ -0,0 +1,46 @@
+package summarizer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"hn-telegram-bot/util"
+)
+
+// rateLimiter spaces requests evenly to stay within a number of requests
+// per minute. It is shared by all callers of a Summarizer, so concurrent
+// workers queue for slots instead of bursting.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // Earliest time the next request may start
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRateLimiter(rpm int) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Minute / time.Duration(rpm),
+		now:      time.Now,
+		sleep:    util.Sleep,
+	}
+}
+
+// Wait blocks until the caller may make a request, or until ctx is done.
+// A caller that gives up keeps its slot, so later callers aren't moved
+// ahead of the pace.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := l.now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return l.sleep(ctx, slot.Sub(now))
+}
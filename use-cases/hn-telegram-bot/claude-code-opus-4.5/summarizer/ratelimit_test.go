@@ -0,0 +1,118 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLimiter returns a limiter at rpm whose clock stands still, recording
+// how long each caller was told to wait.
+func fakeLimiter(rpm int, now time.Time) (*rateLimiter, func() []time.Duration) {
+	l := newRateLimiter(rpm)
+	var mu sync.Mutex
+	var waits []time.Duration
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		waits = append(waits, d)
+		mu.Unlock()
+		return nil
+	}
+	return l, func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(waits)
+	}
+}
+
+func TestRateLimiterPacesConcurrentCallers(t *testing.T) {
+	l, waits := fakeLimiter(60, time.Now())
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Wait(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	got := waits()
+	slices.Sort(got)
+	want := []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}
+	if !slices.Equal(got, want) {
+		t.Errorf("waits = %v, want one second apart %v", got, want)
+	}
+}
+
+func TestRateLimiterDoesNotWaitAfterIdle(t *testing.T) {
+	now := time.Now()
+	l, waits := fakeLimiter(60, now)
+	l.Wait(context.Background())
+
+	l.now = func() time.Time { return now.Add(5 * time.Second) }
+	l.Wait(context.Background())
+
+	if got := waits(); got[1] != 0 {
+		t.Errorf("wait after idling = %v, want none", got[1])
+	}
+}
+
+func TestRateLimiterRespectsContext(t *testing.T) {
+	l := newRateLimiter(1)
+	l.Wait(context.Background()) // Takes the only slot this minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Wait = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait returned after %v, want as soon as the context ended", elapsed)
+	}
+}
+
+func TestSummarizeRateLimited(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+		json.NewEncoder(w).Encode(geminiTextResponse(`{"summary": "A summary of the article", "tags": ["go"]}`))
+	}))
+	defer server.Close()
+
+	// 1200 requests per minute is one every 50ms
+	s := NewSummarizer("test-api-key", WithBaseURL(server.URL), WithRateLimit(1200))
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Summarize(context.Background(), "Title", "Content"); err != nil {
+				t.Errorf("Summarize failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(arrivals, func(a, b time.Time) int { return a.Compare(b) })
+	if len(arrivals) != 4 {
+		t.Fatalf("got %d requests, want 4", len(arrivals))
+	}
+	for i := 1; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d came %v after the previous one, want about 50ms", i+1, gap)
+		}
+	}
+}
@@ -4,30 +4,108 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const (
 	defaultModel   = "gemini-2.0-flash-lite"
 	defaultBaseURL = "https://generativelanguage.googleapis.com"
+
+	// maxBatchContentLen caps each article's content in a batch prompt so
+	// that several articles fit in one request.
+	maxBatchContentLen = 4000
+	// maxCommentLen caps each comment in a discussion prompt.
+	maxCommentLen = 1000
+
+	defaultMinSummaryLen = 10
+	defaultMaxSummaryLen = 1000
+
+	// summarySentences and expandedSentences are how long summaries and
+	// expanded summaries are asked to be.
+	summarySentences  = "1-2"
+	expandedSentences = "5-8"
+	// expandedLengthFactor scales the maximum summary length for expanded
+	// summaries.
+	expandedLengthFactor = 4
+	// maxSplitTags caps the merged tags of a TagWeightingSplit summary.
+	maxSplitTags = 5
+)
+
+// TagWeighting sets how much the title counts, against the content, when
+// the model chooses an article's tags.
+type TagWeighting string
+
+const (
+	// TagWeightingEven chooses tags from the title and content alike.
+	TagWeightingEven TagWeighting = "even"
+	// TagWeightingTitle asks for tags based mainly on the title, which
+	// names the topic more precisely than a long body.
+	TagWeightingTitle TagWeighting = "title"
+	// TagWeightingSplit asks for the title's tags and the content's tags
+	// separately, and puts the title's first.
+	TagWeightingSplit TagWeighting = "split"
 )
 
+// ErrBadResponse is returned when the model responds with a summary that
+// fails the quality checks: too short or too long, a single word, a
+// refusal, or the title repeated back.
+var ErrBadResponse = errors.New("bad summary")
+
+// defaultRefusalPatterns are phrases, matched case-insensitively, that
+// show the model declined or failed to read the article.
+var defaultRefusalPatterns = []string{
+	"i can't access",
+	"i cannot access",
+	"i'm unable to",
+	"i am unable to",
+	"unable to access",
+	"i'm sorry",
+	"as an ai",
+	"no content provided",
+}
+
 // Result contains the summarization output.
 type Result struct {
 	Summary string   `json:"summary"`
 	Tags    []string `json:"tags"`
+	Model   string   `json:"-"` // Model that produced the summary
+
+	// Err is set on a batch result that couldn't be parsed or failed the
+	// quality checks. Its other fields are then empty, and the article
+	// needs summarizing on its own.
+	Err error `json:"-"`
+}
+
+// Input is a single article to summarize as part of a batch.
+type Input struct {
+	Title   string
+	Content string
 }
 
 // Summarizer generates article summaries using the Gemini API.
 type Summarizer struct {
-	apiKey     string
-	model      string
-	baseURL    string
-	httpClient *http.Client
+	apiKeys       []string
+	keys          *keyPool
+	model         string
+	fallbackModel string
+	baseURL       string
+	httpClient    *http.Client
+	timeout       time.Duration
+	minLen        int
+	maxLen        int
+	refusals      []string
+	tagWeighting  TagWeighting
+	limiter       *rateLimiter // Nil when requests aren't rate limited
 }
 
 // Option configures a Summarizer.
@@ -40,6 +118,48 @@ func WithModel(model string) Option {
 	}
 }
 
+// WithFallbackModel sets a model to try once when the primary model fails
+// with a retriable error, such as being overloaded. An empty model
+// disables the fallback.
+func WithFallbackModel(model string) Option {
+	return func(s *Summarizer) {
+		s.fallbackModel = model
+	}
+}
+
+// WithSummaryLength sets the bounds, in characters, outside which a summary
+// is rejected with ErrBadResponse.
+func WithSummaryLength(min, max int) Option {
+	return func(s *Summarizer) {
+		s.minLen = min
+		s.maxLen = max
+	}
+}
+
+// WithRefusalPatterns replaces the phrases that mark a summary as a refusal.
+// Matching is case-insensitive. An empty list keeps the defaults.
+func WithRefusalPatterns(patterns []string) Option {
+	return func(s *Summarizer) {
+		if len(patterns) == 0 {
+			return
+		}
+		s.refusals = make([]string, len(patterns))
+		for i, p := range patterns {
+			s.refusals[i] = strings.ToLower(p)
+		}
+	}
+}
+
+// WithTagWeighting sets how the title and content are weighed when
+// choosing tags. An empty weighting keeps TagWeightingEven.
+func WithTagWeighting(w TagWeighting) Option {
+	return func(s *Summarizer) {
+		if w != "" {
+			s.tagWeighting = w
+		}
+	}
+}
+
 // WithBaseURL sets a custom base URL (for testing).
 func WithBaseURL(url string) Option {
 	return func(s *Summarizer) {
@@ -47,23 +167,272 @@ func WithBaseURL(url string) Option {
 	}
 }
 
+// WithTimeout sets the timeout of each request to the Gemini API. It takes
+// precedence over the timeout of a client set with WithHTTPClient, since
+// LLM calls need a longer budget than the page fetches that client may be
+// shared with.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Summarizer) {
+		s.timeout = d
+	}
+}
+
+// WithRateLimit paces requests to the Gemini API to at most rpm per
+// minute, spread evenly, across all concurrent callers. Callers wait for
+// their turn until their context is done. Zero or less disables the limit.
+func WithRateLimit(rpm int) Option {
+	return func(s *Summarizer) {
+		s.limiter = nil
+		if rpm > 0 {
+			s.limiter = newRateLimiter(rpm)
+		}
+	}
+}
+
+// WithAPIKeys adds API keys to the one the summarizer was created with.
+// Requests use the keys in turn, and a key that hits its quota is skipped
+// for a while in favor of the others.
+func WithAPIKeys(keys ...string) Option {
+	return func(s *Summarizer) {
+		s.apiKeys = append(s.apiKeys, keys...)
+	}
+}
+
+// WithHTTPClient sends Gemini API requests through a copy of client, for
+// instance one routed through a proxy. The copy's timeout is the one set
+// with WithTimeout if there is one, then the client's own, and otherwise
+// the summarizer's 60-second default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Summarizer) {
+		hc := *client
+		if hc.Timeout == 0 {
+			hc.Timeout = s.httpClient.Timeout
+		}
+		s.httpClient = &hc
+	}
+}
+
 // NewSummarizer creates a new Gemini-based summarizer.
 func NewSummarizer(apiKey string, opts ...Option) *Summarizer {
 	s := &Summarizer{
-		apiKey:     apiKey,
-		model:      defaultModel,
-		baseURL:    defaultBaseURL,
-		httpClient: &http.Client{Timeout: 60 * time.Second},
+		apiKeys:      []string{apiKey},
+		model:        defaultModel,
+		baseURL:      defaultBaseURL,
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		minLen:       defaultMinSummaryLen,
+		maxLen:       defaultMaxSummaryLen,
+		refusals:     defaultRefusalPatterns,
+		tagWeighting: TagWeightingEven,
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	if s.timeout > 0 {
+		s.httpClient.Timeout = s.timeout
+	}
+	s.keys = newKeyPool(s.apiKeys...)
 	return s
 }
 
-// Summarize generates a summary and tags for the given content.
+// Summarize generates a summary and tags for the given content. If the
+// model returns no tags, tags are derived from the title instead.
 func (s *Summarizer) Summarize(ctx context.Context, title, content string) (*Result, error) {
-	prompt := buildPrompt(title, content)
+	return s.summarize(ctx, title, content, summarySentences, s.maxLen)
+}
+
+// SummarizeExpanded generates a longer summary than Summarize, for readers
+// who want more detail on an article. The maximum summary length is scaled
+// up to match.
+func (s *Summarizer) SummarizeExpanded(ctx context.Context, title, content string) (*Result, error) {
+	return s.summarize(ctx, title, content, expandedSentences, s.maxLen*expandedLengthFactor)
+}
+
+// summarize asks for a summary of the given number of sentences, rejecting
+// summaries longer than maxLen characters.
+func (s *Summarizer) summarize(ctx context.Context, title, content, sentences string, maxLen int) (*Result, error) {
+	resp, model, err := s.generate(ctx, buildPrompt(title, content, sentences, s.tagWeighting))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseGeminiResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.check(title, result.Summary, maxLen); err != nil {
+		return nil, err
+	}
+	result.Tags = withTitleTags(result.Tags, title)
+	result.Model = model
+	slog.Debug("summarized article", "title", title, "sentences", sentences, "model", model)
+	return result, nil
+}
+
+// SummarizeBatch summarizes several articles with a single request. The
+// results are in the same order as inputs. Each result is checked on its
+// own, and one that fails has Err set rather than failing the batch. If
+// the model returns a different number of results than inputs, none can be
+// matched to its article, so ErrBadResponse is returned. Falling back to
+// summarizing articles individually is left to the caller.
+func (s *Summarizer) SummarizeBatch(ctx context.Context, inputs []Input) ([]Result, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	resp, model, err := s.generate(ctx, buildBatchPrompt(inputs, s.tagWeighting))
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := parseBatchResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != len(inputs) {
+		return nil, fmt.Errorf("%w: %d results for %d articles", ErrBadResponse, len(results), len(inputs))
+	}
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		if err := s.check(inputs[i].Title, results[i].Summary, s.maxLen); err != nil {
+			results[i] = Result{Err: fmt.Errorf("summary %d: %w", i+1, err)}
+			continue
+		}
+		results[i].Tags = withTitleTags(results[i].Tags, inputs[i].Title)
+		results[i].Model = model
+	}
+	slog.Debug("summarized batch", "count", len(results), "model", model)
+	return results, nil
+}
+
+// SummarizeDiscussion sums up the gist and sentiment of an HN discussion,
+// given its top comments, in one short sentence.
+func (s *Summarizer) SummarizeDiscussion(ctx context.Context, comments []string) (string, error) {
+	if len(comments) == 0 {
+		return "", errors.New("no comments to summarize")
+	}
+
+	resp, model, err := s.generate(ctx, buildDiscussionPrompt(comments))
+	if err != nil {
+		return "", err
+	}
+	text, err := responseText(resp)
+	if err != nil {
+		return "", err
+	}
+
+	take := strings.TrimSpace(text)
+	if len(strings.Fields(take)) < 2 {
+		return "", fmt.Errorf("%w: discussion take %q", ErrBadResponse, take)
+	}
+	if s.isRefusal(take) {
+		return "", fmt.Errorf("%w: refusal %q", ErrBadResponse, take)
+	}
+	slog.Debug("summarized discussion", "comments", len(comments), "model", model)
+	return take, nil
+}
+
+// check rejects summaries that are empty or too short, longer than maxLen,
+// a single word, a refusal, or only the title repeated back.
+func (s *Summarizer) check(title, summary string, maxLen int) error {
+	summary = strings.TrimSpace(summary)
+	n := len([]rune(summary))
+	if n < s.minLen {
+		return fmt.Errorf("%w: %d characters, want at least %d", ErrBadResponse, n, s.minLen)
+	}
+	if maxLen > 0 && n > maxLen {
+		return fmt.Errorf("%w: %d characters, want at most %d", ErrBadResponse, n, maxLen)
+	}
+	if len(strings.Fields(summary)) < 2 {
+		return fmt.Errorf("%w: single word %q", ErrBadResponse, summary)
+	}
+
+	if s.isRefusal(summary) {
+		return fmt.Errorf("%w: refusal %q", ErrBadResponse, summary)
+	}
+
+	if normalizeText(summary) == normalizeText(title) {
+		return fmt.Errorf("%w: summary repeats the title", ErrBadResponse)
+	}
+	return nil
+}
+
+// isRefusal reports whether text contains one of the refusal patterns.
+func (s *Summarizer) isRefusal(text string) bool {
+	lower := strings.ToLower(text)
+	for _, p := range s.refusals {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeText lowercases s and drops everything but letters and digits,
+// so that punctuation and spacing differences don't hide a repeated title.
+func normalizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// generate sends prompt to the primary model, falling back to the fallback
+// model on a retriable failure. It returns the model that responded.
+func (s *Summarizer) generate(ctx context.Context, prompt string) (*geminiResponse, string, error) {
+	resp, err := s.generateWith(ctx, s.model, prompt)
+	if err == nil {
+		return resp, s.model, nil
+	}
+	if s.fallbackModel == "" || !isRetriable(ctx, err) {
+		return nil, "", err
+	}
+
+	slog.Warn("primary model failed, trying fallback model",
+		"model", s.model, "fallback_model", s.fallbackModel, "error", err)
+	resp, fallbackErr := s.generateWith(ctx, s.fallbackModel, prompt)
+	if fallbackErr != nil {
+		return nil, "", fmt.Errorf("%w (fallback model %s: %v)", err, s.fallbackModel, fallbackErr)
+	}
+	slog.Info("fallback model produced summary", "model", s.fallbackModel)
+	return resp, s.fallbackModel, nil
+}
+
+// generateWith sends prompt to model. A key that hits its quota is cooled
+// down and the request is retried with the next key, until every key has
+// been tried or is cooling down.
+func (s *Summarizer) generateWith(ctx context.Context, model, prompt string) (*geminiResponse, error) {
+	var err error
+	for attempt := range len(s.keys.keys) {
+		index, key, ok := s.keys.pick()
+		if !ok && attempt > 0 {
+			break
+		}
+
+		var resp *geminiResponse
+		resp, err = s.request(ctx, model, key, prompt)
+		var se *statusError
+		if !errors.As(err, &se) || se.code != http.StatusTooManyRequests {
+			return resp, err
+		}
+		s.keys.coolDown(index, se.retryAfter)
+		// Keys are numbered from 1 in logs, which never show the key itself
+		slog.Warn("API key rate limited", "key", index+1, "keys", len(s.keys.keys))
+	}
+	return nil, err
+}
+
+// request sends one generateContent request with the given key.
+func (s *Summarizer) request(ctx context.Context, model, key, prompt string) (*geminiResponse, error) {
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("wait for rate limit: %w", err)
+		}
+	}
 
 	reqBody := geminiRequest{
 		Contents: []geminiContent{
@@ -78,9 +447,9 @@ func (s *Summarizer) Summarize(ctx context.Context, title, content string) (*Res
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", s.baseURL, s.model, s.apiKey)
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", s.baseURL, model, key)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -93,19 +462,54 @@ func (s *Summarizer) Summarize(ctx context.Context, title, content string) (*Res
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		return nil, &statusError{code: resp.StatusCode, retryAfter: retryAfter(resp.Header)}
 	}
 
 	var geminiResp geminiResponse
 	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
+	return &geminiResp, nil
+}
 
-	return parseGeminiResponse(&geminiResp)
+// statusError is a non-OK HTTP response from the Gemini API.
+type statusError struct {
+	code       int
+	retryAfter time.Duration // From the Retry-After header, zero if absent
 }
 
-func buildPrompt(title, content string) string {
-	return fmt.Sprintf(`Summarize the following article in 1-2 sentences and provide 3-5 lowercase tags categorizing the topic.
+// retryAfter returns the wait given in seconds by a Retry-After header, or
+// zero if there is none.
+func retryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.code)
+}
+
+// isRetriable reports whether err is a transient failure worth retrying on
+// another model: rate limiting, a server error, or a failed request that
+// wasn't caused by ctx ending.
+func isRetriable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func buildPrompt(title, content, sentences string, weighting TagWeighting) string {
+	tags, format := tagPrompt(weighting)
+	return fmt.Sprintf(`Summarize the following article in %s sentences and provide %s.
 
 Title: %s
 
@@ -113,30 +517,153 @@ Content:
 %s
 
 Respond with JSON only, in this exact format:
-{"summary": "Your 1-2 sentence summary here", "tags": ["tag1", "tag2", "tag3"]}`, title, content)
+{"summary": "Your %s sentence summary here", %s}`, sentences, tags, title, content, sentences, format)
 }
 
-func parseGeminiResponse(resp *geminiResponse) (*Result, error) {
-	if len(resp.Candidates) == 0 {
-		return nil, fmt.Errorf("no candidates in response")
+func buildBatchPrompt(inputs []Input, weighting TagWeighting) string {
+	tags, format := tagPrompt(weighting)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Summarize each of the following %d articles in 1-2 sentences and provide %s.\n", len(inputs), tags)
+
+	for i, in := range inputs {
+		fmt.Fprintf(&sb, "\nArticle %d\nTitle: %s\n\nContent:\n%s\n", i+1, in.Title, truncate(in.Content, maxBatchContentLen))
 	}
 
-	candidate := resp.Candidates[0]
-	if len(candidate.Content.Parts) == 0 {
-		return nil, fmt.Errorf("no parts in candidate")
+	fmt.Fprintf(&sb, `
+Respond with JSON only: an array of exactly %d objects in the same order as the articles, each in this exact format:
+{"summary": "Your 1-2 sentence summary here", %s}`, len(inputs), format)
+	return sb.String()
+}
+
+// tagPrompt returns how to ask for tags with the given weighting: the
+// request, and the tags fields of the JSON to respond with.
+func tagPrompt(weighting TagWeighting) (tags, format string) {
+	switch weighting {
+	case TagWeightingTitle:
+		return "3-5 lowercase tags categorizing the topic. Base the tags mainly on the title, which names the topic more precisely than the content, and use the content only for what the title leaves out",
+			`"tags": ["tag1", "tag2", "tag3"]`
+	case TagWeightingSplit:
+		return "lowercase tags categorizing the topic in two sets: 1-3 tags for what the title is about, and 2-4 tags for what the content is about",
+			`"title_tags": ["tag1"], "content_tags": ["tag2", "tag3"]`
+	default:
+		return "3-5 lowercase tags categorizing the topic", `"tags": ["tag1", "tag2", "tag3"]`
+	}
+}
+
+// response is a summary as the model returns it, including the separate
+// title and content tags a TagWeightingSplit prompt asks for.
+type response struct {
+	Result
+	TitleTags   []string `json:"title_tags"`
+	ContentTags []string `json:"content_tags"`
+}
+
+// result returns the summary with its tags lowercased and trimmed, the
+// form tag weights are stored under, whatever case the model used. Title
+// and content tags the model returned separately are merged into its tags,
+// title tags first.
+func (r response) result() Result {
+	result := r.Result
+	limit := len(result.Tags)
+	if len(r.TitleTags) > 0 || len(r.ContentTags) > 0 {
+		limit = maxSplitTags
+	}
+
+	tags := make([]string, 0, limit)
+	seen := make(map[string]bool)
+	for _, tag := range slices.Concat(r.TitleTags, r.ContentTags, result.Tags) {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] && len(tags) < limit {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	result.Tags = tags
+	return result
+}
+
+func buildDiscussionPrompt(comments []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Here are the top %d comments from a Hacker News discussion.\n", len(comments))
+
+	for i, c := range comments {
+		fmt.Fprintf(&sb, "\nComment %d:\n%s\n", i+1, truncate(c, maxCommentLen))
 	}
 
-	text := candidate.Content.Parts[0].Text
-	text = stripMarkdownCodeBlock(text)
+	sb.WriteString(`
+In one short sentence, describe the gist of the discussion and its overall sentiment, such as whether commenters are enthusiastic, skeptical or divided.
+Respond with the sentence only, without quotes or formatting.`)
+	return sb.String()
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
 
-	var result Result
-	if err := json.Unmarshal([]byte(text), &result); err != nil {
+func parseGeminiResponse(resp *geminiResponse) (*Result, error) {
+	text, err := responseText(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed response
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
 		return nil, fmt.Errorf("parse summary JSON: %w", err)
 	}
 
+	result := parsed.result()
 	return &result, nil
 }
 
+// parseBatchResponse extracts the JSON array of results, tolerating
+// surrounding prose that models sometimes add. An element that isn't a
+// valid result has Err set.
+func parseBatchResponse(resp *geminiResponse) ([]Result, error) {
+	text, err := responseText(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in batch response")
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(text[start:end+1]), &elements); err != nil {
+		return nil, fmt.Errorf("parse batch JSON: %w", err)
+	}
+	results := make([]Result, len(elements))
+	for i, element := range elements {
+		var parsed response
+		if err := json.Unmarshal(element, &parsed); err != nil {
+			results[i] = Result{Err: fmt.Errorf("parse summary %d JSON: %w", i+1, err)}
+			continue
+		}
+		results[i] = parsed.result()
+	}
+	return results, nil
+}
+
+func responseText(resp *geminiResponse) (string, error) {
+	if len(resp.Candidates) == 0 {
+		return "", fmt.Errorf("no candidates in response")
+	}
+
+	candidate := resp.Candidates[0]
+	if len(candidate.Content.Parts) == 0 {
+		return "", fmt.Errorf("no parts in candidate")
+	}
+
+	return stripMarkdownCodeBlock(candidate.Content.Parts[0].Text), nil
+}
+
 var codeBlockRegex = regexp.MustCompile("(?s)^\\s*```(?:json)?\\s*(.+?)\\s*```\\s*$")
 
 func stripMarkdownCodeBlock(s string) string {
@@ -3,8 +3,11 @@ package summarizer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -47,6 +50,9 @@ func TestSummarize(t *testing.T) {
 	if result.Summary != "Test article about Go programming" {
 		t.Errorf("Summary = %q, want 'Test article about Go programming'", result.Summary)
 	}
+	if result.Model != "gemini-pro" {
+		t.Errorf("Model = %q, want 'gemini-pro'", result.Model)
+	}
 
 	if len(result.Tags) != 3 {
 		t.Errorf("got %d tags, want 3", len(result.Tags))
@@ -109,6 +115,66 @@ func TestSummarizeServerError(t *testing.T) {
 	}
 }
 
+func TestSummarizeFallbackModel(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if strings.Contains(r.URL.Path, "primary-model") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(geminiTextResponse(`{"summary": "Fallback summary", "tags": ["go"]}`))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key",
+		WithModel("primary-model"),
+		WithFallbackModel("fallback-model"),
+		WithBaseURL(server.URL),
+	)
+
+	result, err := s.Summarize(context.Background(), "Title", "Content")
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if result.Summary != "Fallback summary" {
+		t.Errorf("Summary = %q, want 'Fallback summary'", result.Summary)
+	}
+	if result.Model != "fallback-model" {
+		t.Errorf("Model = %q, want 'fallback-model'", result.Model)
+	}
+
+	want := []string{
+		"/v1beta/models/primary-model:generateContent",
+		"/v1beta/models/fallback-model:generateContent",
+	}
+	if len(requested) != len(want) || requested[0] != want[0] || requested[1] != want[1] {
+		t.Errorf("requested = %v, want %v", requested, want)
+	}
+}
+
+func TestSummarizeFallbackSkippedForNonRetriableError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key",
+		WithModel("primary-model"),
+		WithFallbackModel("fallback-model"),
+		WithBaseURL(server.URL),
+	)
+
+	if _, err := s.Summarize(context.Background(), "Title", "Content"); err == nil {
+		t.Fatal("expected error for bad request")
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1 (no fallback on 400)", requests)
+	}
+}
+
 func TestSummarizeEmptyCandidates(t *testing.T) {
 	geminiResp := map[string]interface{}{
 		"candidates": []map[string]interface{}{},
@@ -174,6 +240,379 @@ func TestSummarizeContextCancellation(t *testing.T) {
 	}
 }
 
+func TestSummarizeUsesOwnTimeout(t *testing.T) {
+	var delay time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		json.NewEncoder(w).Encode(geminiTextResponse(`{"summary": "A slow but fine summary", "tags": ["go"]}`))
+	}))
+	defer server.Close()
+
+	// A shared client configured with the short fetch timeout
+	fetchClient := &http.Client{Timeout: 50 * time.Millisecond}
+	s := NewSummarizer("test-key",
+		WithBaseURL(server.URL),
+		WithTimeout(500*time.Millisecond),
+		WithHTTPClient(fetchClient),
+	)
+
+	// Slower than the fetch timeout, but within the summarizer's
+	delay = 150 * time.Millisecond
+	if _, err := s.Summarize(context.Background(), "Title", "Content"); err != nil {
+		t.Fatalf("Summarize failed within the summarizer timeout: %v", err)
+	}
+
+	// Slower than the summarizer timeout
+	delay = 5 * time.Second
+	start := time.Now()
+	if _, err := s.Summarize(context.Background(), "Title", "Content"); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Summarize gave up after %v, want about 500ms", elapsed)
+	}
+}
+
+// geminiTextResponse builds a Gemini response whose single part is text.
+func geminiTextResponse(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"candidates": []map[string]interface{}{
+			{
+				"content": map[string]interface{}{
+					"parts": []map[string]interface{}{{"text": text}},
+				},
+			},
+		},
+	}
+}
+
+func TestSummarizeRejectsBadSummaries(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+		opts    []Option
+	}{
+		{name: "empty", summary: ""},
+		{name: "single word", summary: "Kubernetes."},
+		{name: "refusal", summary: "I'm sorry, but I can't access that URL to summarize it."},
+		{name: "title echo", summary: "Why we moved off Kubernetes!"},
+		{name: "too long", summary: strings.Repeat("Long summary. ", 100)},
+		{
+			name:    "custom refusal pattern",
+			summary: "Content unavailable: the page requires a login.",
+			opts:    []Option{WithRefusalPatterns([]string{"Content Unavailable"})},
+		},
+		{
+			name:    "below custom minimum",
+			summary: "A short summary.",
+			opts:    []Option{WithSummaryLength(40, 500)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				text, _ := json.Marshal(Result{Summary: tt.summary, Tags: []string{"go"}})
+				json.NewEncoder(w).Encode(geminiTextResponse(string(text)))
+			}))
+			defer server.Close()
+
+			s := NewSummarizer("test-key", append(tt.opts, WithBaseURL(server.URL))...)
+			_, err := s.Summarize(context.Background(), "Why We Moved Off Kubernetes", "Content")
+			if !errors.Is(err, ErrBadResponse) {
+				t.Errorf("Summarize(%q) error = %v, want ErrBadResponse", tt.summary, err)
+			}
+		})
+	}
+}
+
+func TestSummarizeExpanded(t *testing.T) {
+	summary := strings.Repeat("A detailed sentence about the article. ", 8)
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Contents[0].Parts[0].Text
+		text, _ := json.Marshal(Result{Summary: summary, Tags: []string{"go"}})
+		json.NewEncoder(w).Encode(geminiTextResponse(string(text)))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL), WithSummaryLength(10, 200))
+
+	// Too long for a regular summary, but within the expanded bound
+	if _, err := s.Summarize(context.Background(), "Go Generics", "Content"); !errors.Is(err, ErrBadResponse) {
+		t.Errorf("Summarize error = %v, want ErrBadResponse", err)
+	}
+	if !strings.Contains(prompt, "in 1-2 sentences") {
+		t.Errorf("regular prompt should ask for 1-2 sentences:\n%s", prompt)
+	}
+
+	result, err := s.SummarizeExpanded(context.Background(), "Go Generics", "Content")
+	if err != nil {
+		t.Fatalf("SummarizeExpanded failed: %v", err)
+	}
+	if result.Summary != summary {
+		t.Errorf("summary = %q, want %q", result.Summary, summary)
+	}
+	if !strings.Contains(prompt, "in 5-8 sentences") {
+		t.Errorf("expanded prompt should ask for 5-8 sentences:\n%s", prompt)
+	}
+}
+
+func TestBuildPromptTagWeighting(t *testing.T) {
+	tests := []struct {
+		weighting TagWeighting
+		want      []string
+		notWant   []string
+	}{
+		{TagWeightingEven, []string{"3-5 lowercase tags", `"tags": [`}, []string{"mainly on the title", "title_tags"}},
+		{TagWeightingTitle, []string{"3-5 lowercase tags", "Base the tags mainly on the title", `"tags": [`}, []string{"title_tags"}},
+		{TagWeightingSplit, []string{"1-3 tags for what the title is about", `"title_tags": [`, `"content_tags": [`}, []string{`"tags": [`}},
+	}
+	for _, tt := range tests {
+		prompts := map[string]string{
+			"single": buildPrompt("Go Generics", "Content", summarySentences, tt.weighting),
+			"batch":  buildBatchPrompt([]Input{{Title: "Go Generics", Content: "Content"}}, tt.weighting),
+		}
+		for kind, prompt := range prompts {
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("%s %s prompt missing %q:\n%s", tt.weighting, kind, want, prompt)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(prompt, notWant) {
+					t.Errorf("%s %s prompt should not contain %q:\n%s", tt.weighting, kind, notWant, prompt)
+				}
+			}
+		}
+	}
+}
+
+func TestSummarizeSplitTags(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Contents[0].Parts[0].Text
+		text := `{"summary": "A look at generics in Go.", "title_tags": ["go", "Generics"], "content_tags": ["generics", "compilers", "performance", "type systems", "ai"]}`
+		json.NewEncoder(w).Encode(geminiTextResponse(text))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL), WithTagWeighting(TagWeightingSplit))
+	result, err := s.Summarize(context.Background(), "Go Generics", "Content")
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	if !strings.Contains(prompt, "title_tags") {
+		t.Errorf("prompt should ask for title tags:\n%s", prompt)
+	}
+	// Title tags come first, duplicates are dropped and the merge is capped
+	want := []string{"go", "generics", "compilers", "performance", "type systems"}
+	if !slices.Equal(result.Tags, want) {
+		t.Errorf("tags = %v, want %v", result.Tags, want)
+	}
+}
+
+func TestSummarizeNormalizesTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := `{"summary": "A look at generics in Go.", "tags": ["Go", " Generics ", "go", ""]}`
+		json.NewEncoder(w).Encode(geminiTextResponse(text))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	result, err := s.Summarize(context.Background(), "Go Generics", "Content")
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if want := []string{"go", "generics"}; !slices.Equal(result.Tags, want) {
+		t.Errorf("tags = %v, want %v", result.Tags, want)
+	}
+}
+
+func TestBuildDiscussionPrompt(t *testing.T) {
+	long := strings.Repeat("x", maxCommentLen+100)
+	prompt := buildDiscussionPrompt([]string{"First comment", "Second comment", long})
+
+	for _, want := range []string{"top 3 comments", "Comment 1:\nFirst comment\n", "Comment 2:\nSecond comment\n", "one short sentence", "sentiment"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, long) {
+		t.Error("long comment should be truncated")
+	}
+	if !strings.Contains(prompt, "Comment 3:\n"+strings.Repeat("x", maxCommentLen)+"\n") {
+		t.Errorf("long comment should be cut to %d characters", maxCommentLen)
+	}
+}
+
+func TestSummarizeDiscussion(t *testing.T) {
+	var prompt string
+	reply := "Commenters are enthusiastic but worried about licensing."
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Contents[0].Parts[0].Text
+		json.NewEncoder(w).Encode(geminiTextResponse(reply))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-api-key", WithBaseURL(server.URL))
+	ctx := context.Background()
+
+	take, err := s.SummarizeDiscussion(ctx, []string{"Great project", "The license worries me"})
+	if err != nil {
+		t.Fatalf("SummarizeDiscussion failed: %v", err)
+	}
+	if take != reply {
+		t.Errorf("take = %q, want %q", take, reply)
+	}
+	if !strings.Contains(prompt, "The license worries me") {
+		t.Errorf("prompt should include the comments, got:\n%s", prompt)
+	}
+
+	for _, bad := range []string{"", "Skeptical", "I'm sorry, I can't help with that."} {
+		reply = bad
+		if _, err := s.SummarizeDiscussion(ctx, []string{"A comment"}); !errors.Is(err, ErrBadResponse) {
+			t.Errorf("reply %q: err = %v, want ErrBadResponse", bad, err)
+		}
+	}
+
+	if _, err := s.SummarizeDiscussion(ctx, nil); err == nil {
+		t.Error("expected error without comments")
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req geminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Contents[0].Parts[0].Text
+		if !strings.Contains(prompt, "Title: First") || !strings.Contains(prompt, "Title: Second") {
+			t.Errorf("prompt should include both articles, got: %s", prompt)
+		}
+		if strings.Contains(prompt, strings.Repeat("x", maxBatchContentLen+1)) {
+			t.Error("batch prompt content should be truncated")
+		}
+
+		text := "Here you go:\n```json\n[" +
+			`{"summary": "First summary", "tags": ["go"]},` +
+			`{"summary": "Second summary", "tags": ["rust", "wasm"]}` +
+			"]\n```"
+		json.NewEncoder(w).Encode(geminiTextResponse(text))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	results, err := s.SummarizeBatch(context.Background(), []Input{
+		{Title: "First", Content: "About Go"},
+		{Title: "Second", Content: strings.Repeat("x", maxBatchContentLen*2)},
+	})
+	if err != nil {
+		t.Fatalf("SummarizeBatch failed: %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Summary != "First summary" || results[1].Summary != "Second summary" {
+		t.Errorf("results out of order: %+v", results)
+	}
+	if len(results[1].Tags) != 2 {
+		t.Errorf("second result tags = %v, want 2 tags", results[1].Tags)
+	}
+}
+
+func TestSummarizeBatchLengthMismatch(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Batch response with one result missing
+		json.NewEncoder(w).Encode(geminiTextResponse(`[{"summary": "Only one", "tags": ["go"]}]`))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	_, err := s.SummarizeBatch(context.Background(), []Input{
+		{Title: "First", Content: "a"},
+		{Title: "Second", Content: "b"},
+	})
+	if !errors.Is(err, ErrBadResponse) {
+		t.Errorf("SummarizeBatch error = %v, want ErrBadResponse", err)
+	}
+	// Falling back to one request per article is the caller's job
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestSummarizeBatchItemFailures(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		text := `[` +
+			`{"summary": "First summary", "tags": ["go"]},` +
+			`{"summary": "I'm sorry, I can't read this", "tags": []},` +
+			`"not a result"` +
+			`]`
+		json.NewEncoder(w).Encode(geminiTextResponse(text))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	results, err := s.SummarizeBatch(context.Background(), []Input{
+		{Title: "First", Content: "a"},
+		{Title: "Second", Content: "b"},
+		{Title: "Third", Content: "c"},
+	})
+	if err != nil {
+		t.Fatalf("SummarizeBatch failed: %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0].Err != nil || results[0].Summary != "First summary" {
+		t.Errorf("first result = %+v, want its summary", results[0])
+	}
+	if !errors.Is(results[1].Err, ErrBadResponse) || results[1].Summary != "" {
+		t.Errorf("refused result = %+v, want ErrBadResponse", results[1])
+	}
+	if results[2].Err == nil {
+		t.Errorf("malformed result = %+v, want an error", results[2])
+	}
+}
+
+func TestSummarizeBatchMalformed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(geminiTextResponse(`[{"summary": "truncated", "tags": [`))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	_, err := s.SummarizeBatch(context.Background(), []Input{{Title: "First", Content: "a"}})
+	if err == nil {
+		t.Fatal("expected error for malformed batch response")
+	}
+}
+
 func TestStripMarkdownCodeBlock(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -0,0 +1,164 @@
+package summarizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTitleTags caps how many tags are derived from a title.
+const maxTitleTags = 4
+
+// techPhrases are multi-word tech terms and the tag each stands for.
+var techPhrases = []struct{ phrase, tag string }{
+	{"large language models", "llm"},
+	{"large language model", "llm"},
+	{"machine learning", "machine learning"},
+	{"deep learning", "deep learning"},
+	{"open source", "open source"},
+}
+
+// techTerms maps words commonly found in HN titles to the tag they stand
+// for. Tech terms are kept even when short, and come before other
+// keywords.
+var techTerms = map[string]string{
+	"ai":           "ai",
+	"llm":          "llm",
+	"llms":         "llm",
+	"ml":           "machine learning",
+	"golang":       "go",
+	"rust":         "rust",
+	"python":       "python",
+	"javascript":   "javascript",
+	"js":           "javascript",
+	"typescript":   "typescript",
+	"node.js":      "node.js",
+	"nodejs":       "node.js",
+	"java":         "java",
+	"kotlin":       "kotlin",
+	"swift":        "swift",
+	"c++":          "c++",
+	"cpp":          "c++",
+	"c#":           "c#",
+	"zig":          "zig",
+	"haskell":      "haskell",
+	"ocaml":        "ocaml",
+	"elixir":       "elixir",
+	"erlang":       "erlang",
+	"ruby":         "ruby",
+	"wasm":         "webassembly",
+	"webassembly":  "webassembly",
+	"linux":        "linux",
+	"kubernetes":   "kubernetes",
+	"k8s":          "kubernetes",
+	"docker":       "docker",
+	"postgres":     "postgresql",
+	"postgresql":   "postgresql",
+	"sqlite":       "sqlite",
+	"mysql":        "mysql",
+	"redis":        "redis",
+	"git":          "git",
+	"gpu":          "gpu",
+	"gpus":         "gpu",
+	"cuda":         "cuda",
+	"risc-v":       "risc-v",
+	"aws":          "aws",
+	"security":     "security",
+	"privacy":      "privacy",
+	"cryptography": "cryptography",
+	"database":     "databases",
+	"databases":    "databases",
+	"compiler":     "compilers",
+	"compilers":    "compilers",
+}
+
+// stopwords are words too common to say what a title is about, including
+// HN's own title prefixes.
+var stopwords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "an": true, "and": true,
+	"any": true, "are": true, "as": true, "at": true, "be": true, "been": true,
+	"before": true, "being": true, "but": true, "by": true, "can": true, "could": true,
+	"do": true, "does": true, "don't": true, "for": true, "from": true, "get": true,
+	"had": true, "has": true, "have": true, "how": true, "i": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "its": true, "just": true,
+	"like": true, "make": true, "more": true, "most": true, "my": true, "new": true,
+	"no": true, "not": true, "now": true, "of": true, "on": true, "one": true,
+	"or": true, "our": true, "out": true, "over": true, "should": true, "so": true,
+	"some": true, "than": true, "that": true, "the": true, "their": true, "them": true,
+	"there": true, "these": true, "they": true, "this": true, "to": true, "too": true,
+	"up": true, "use": true, "using": true, "via": true, "vs": true, "was": true,
+	"we": true, "what": true, "when": true, "where": true, "which": true, "who": true,
+	"why": true, "will": true, "with": true, "without": true, "would": true, "you": true,
+	"your": true, "yet": true, "ask": true, "show": true, "tell": true, "hn": true,
+	"launch": true, "introducing": true, "announcing": true, "released": true, "release": true,
+}
+
+// titleTags derives tags from a title, for articles the model returned no
+// tags for. Known tech terms come first, then the remaining keywords in
+// title order.
+func titleTags(title string) []string {
+	words := titleWords(title)
+	var terms, keywords []string
+
+	// Phrases are matched on the whole title, so that their words aren't
+	// also picked up as keywords
+	joined := " " + strings.Join(words, " ") + " "
+	for _, p := range techPhrases {
+		if strings.Contains(joined, " "+p.phrase+" ") {
+			terms = append(terms, p.tag)
+			joined = strings.ReplaceAll(joined, " "+p.phrase+" ", " ")
+		}
+	}
+
+	for _, w := range strings.Fields(joined) {
+		if tag, ok := techTerms[w]; ok {
+			terms = append(terms, tag)
+		} else if isKeyword(w) {
+			keywords = append(keywords, w)
+		}
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range append(terms, keywords...) {
+		if !seen[tag] && len(tags) < maxTitleTags {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// titleWords splits a lowercased title into words, keeping characters
+// used in names such as C++, C#, Node.js and RISC-V.
+func titleWords(title string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("+#.-'", r)
+	})
+
+	var words []string
+	for _, f := range fields {
+		f = strings.TrimSuffix(strings.Trim(f, ".-'"), "'s")
+		if f != "" {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// isKeyword reports whether a word says something about a title's topic:
+// not a stopword, not a number and not too short.
+func isKeyword(w string) bool {
+	if stopwords[w] || len([]rune(w)) < 3 {
+		return false
+	}
+	return strings.IndexFunc(w, unicode.IsLetter) >= 0
+}
+
+// withTitleTags returns tags, or tags derived from the title if there are
+// none, so that every article can be ranked and learned from.
+func withTitleTags(tags []string, title string) []string {
+	if len(tags) > 0 {
+		return tags
+	}
+	return titleTags(title)
+}
@@ -0,0 +1,85 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestTitleTags(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Show HN: A tiny SQLite clone written in Rust", []string{"sqlite", "rust", "tiny", "clone"}},
+		{"Why Machine Learning is hard", []string{"machine learning", "hard"}},
+		{"Running large language models on a Raspberry Pi", []string{"llm", "running", "raspberry"}},
+		{"The C++ and C# compilers' story", []string{"c++", "c#", "compilers", "story"}},
+		{"Node.js 22 released", []string{"node.js"}},
+		{"Rust's new borrow checker", []string{"rust", "borrow", "checker"}},
+		{"Ask HN: What are you working on?", []string{"working"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := titleTags(tt.title); !slices.Equal(got, tt.want) {
+			t.Errorf("titleTags(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeTitleTagsFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{"no tags", `{"summary": "A database written in a systems language.", "tags": []}`, []string{"sqlite", "rust", "clone"}},
+		{"missing tags", `{"summary": "A database written in a systems language."}`, []string{"sqlite", "rust", "clone"}},
+		{"model tags", `{"summary": "A database written in a systems language.", "tags": ["databases", "rust"]}`, []string{"databases", "rust"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(geminiTextResponse(tt.response))
+			}))
+			defer server.Close()
+
+			s := NewSummarizer("test-key", WithBaseURL(server.URL))
+			result, err := s.Summarize(context.Background(), "An SQLite clone in Rust", "content")
+			if err != nil {
+				t.Fatalf("Summarize failed: %v", err)
+			}
+			if !slices.Equal(result.Tags, tt.want) {
+				t.Errorf("tags = %q, want %q", result.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeBatchTitleTagsFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := `[{"summary": "First summary here", "tags": ["go"]}, {"summary": "Second summary here", "tags": []}]`
+		json.NewEncoder(w).Encode(geminiTextResponse(text))
+	}))
+	defer server.Close()
+
+	s := NewSummarizer("test-key", WithBaseURL(server.URL))
+	results, err := s.SummarizeBatch(context.Background(), []Input{
+		{Title: "Go generics", Content: "About Go"},
+		{Title: "Kubernetes networking explained", Content: "About k8s"},
+	})
+	if err != nil {
+		t.Fatalf("SummarizeBatch failed: %v", err)
+	}
+	if !slices.Equal(results[0].Tags, []string{"go"}) {
+		t.Errorf("first tags = %q, want the model's", results[0].Tags)
+	}
+	if want := []string{"kubernetes", "networking", "explained"}; !slices.Equal(results[1].Tags, want) {
+		t.Errorf("second tags = %q, want %q", results[1].Tags, want)
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d, returning early with the context's error if it is
+// done first. A d that isn't positive only reports the context's error.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,151 @@
+// Package util holds small helpers shared across packages.
+package util
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ellipsis marks truncated text.
+const Ellipsis = "…"
+
+// voidElements are HTML tags that have no closing tag.
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true, "input": true, "meta": true, "link": true, "wbr": true}
+
+// Truncate shortens plain text to at most maxBytes bytes, cutting at a
+// rune and, where there is one, a word boundary, and appending Ellipsis.
+// Text that already fits is returned as is.
+func Truncate(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	budget := maxBytes - len(Ellipsis)
+	if budget <= 0 {
+		return ""
+	}
+	return trimEnd(s[:cutAt(s, budget, false)]) + Ellipsis
+}
+
+// TruncateHTMLSafe shortens HTML to at most maxBytes bytes like Truncate,
+// but never cuts inside a tag or an entity, and closes the tags left open
+// after the ellipsis, so that the result is still valid markup.
+func TruncateHTMLSafe(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	// Closing the open tags may not fit, in which case cut earlier
+	for budget := maxBytes - len(Ellipsis); budget > 0; {
+		prefix := trimEnd(s[:cutAt(s, budget, true)])
+		closers := closingTags(prefix)
+		if len(prefix)+len(Ellipsis)+len(closers) <= maxBytes {
+			return prefix + Ellipsis + closers
+		}
+		budget = min(budget-1, maxBytes-len(Ellipsis)-len(closers))
+	}
+	return ""
+}
+
+// cutAt returns where to cut s to keep at most budget bytes, which must be
+// less than len(s): at a rune boundary, moved back to the last word
+// boundary if there is one and, for HTML, out of any tag or entity.
+func cutAt(s string, budget int, isHTML bool) int {
+	cut := budget
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	next, _ := utf8.DecodeRuneInString(s[cut:])
+	prev, _ := utf8.DecodeLastRuneInString(s[:cut])
+	if !unicode.IsSpace(next) && !unicode.IsSpace(prev) {
+		// Drop the partial word, unless it is the only one
+		if space := strings.LastIndexFunc(s[:cut], unicode.IsSpace); space > 0 {
+			cut = space
+		}
+	}
+	if !isHTML {
+		return cut
+	}
+
+	if lt := strings.LastIndexByte(s[:cut], '<'); lt > strings.LastIndexByte(s[:cut], '>') {
+		cut = lt
+	}
+	if amp := strings.LastIndexByte(s[:cut], '&'); amp >= 0 && isEntityName(s[amp+1:cut]) {
+		cut = amp
+	}
+	return cut
+}
+
+// isEntityName reports whether s could be the start of an entity's name,
+// such as "amp" or "#39", so that cutting after it would split the entity.
+func isEntityName(s string) bool {
+	for _, r := range s {
+		if r != '#' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// trimEnd drops the spaces and punctuation left at the end of a cut,
+// including entities such as "&amp;" that stand for them.
+func trimEnd(s string) string {
+	for s != "" {
+		r, size := utf8.DecodeLastRuneInString(s)
+		if r == ';' {
+			if amp := strings.LastIndexByte(s, '&'); amp >= 0 && amp < len(s)-2 && isEntityName(s[amp+1:len(s)-1]) {
+				r, _ = utf8.DecodeRuneInString(html.UnescapeString(s[amp:]))
+				size = len(s) - amp
+			}
+		}
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) {
+			return s
+		}
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+// closingTags returns the closing tags of the elements left open in s,
+// innermost first.
+func closingTags(s string) string {
+	var open []string
+	for {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			break
+		}
+		gt := strings.IndexByte(s[lt:], '>')
+		if gt < 0 {
+			break
+		}
+		tag := s[lt+1 : lt+gt]
+		s = s[lt+gt+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.ToLower(strings.TrimPrefix(tag, "/"))
+		if i := strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == '/' }); i >= 0 {
+			name = name[:i]
+		}
+		switch {
+		case name == "" || strings.HasPrefix(name, "!") || voidElements[name] || strings.HasSuffix(tag, "/"):
+			// Comments, void and self-closing elements close nothing
+		case closing:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			open = append(open, name)
+		}
+	}
+
+	var sb strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + open[i] + ">")
+	}
+	return sb.String()
+}
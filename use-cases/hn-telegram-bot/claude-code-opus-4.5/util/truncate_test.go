@@ -0,0 +1,61 @@
+package util
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateHTMLSafe(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxBytes int
+		want     string
+	}{
+		{"fits", "short", 10, "short"},
+		{"word boundary", "hello brave new world", 15, "hello brave…"},
+		{"trailing punctuation", "first, second", 10, "first…"},
+		{"multibyte at boundary", "héllo wörld", 12, "héllo…"},
+		{"multibyte single word", "ééééé", 8, "éé…"},
+		{"complete entity kept", "caf&eacute; au lait", 15, "caf&eacute;…"},
+		{"punctuation entity trimmed", "Tom &amp; Jerry", 12, "Tom…"},
+		{"inside entity", "AT&amp;T rocks", 9, "AT…"},
+		{"inside numeric entity", "it&#39;s fine", 9, "it…"},
+		{"inside tag", `see <a href="https://example.com/x">the link</a>`, 20, "see…"},
+		{"open tag closed", "<b>very important</b> news", 15, "<b>very…</b>"},
+		{"closing tag needs room", "<b>very important</b> news", 13, "<b>ver…</b>"},
+		{"nested tags closed", "<b><i>deeply nested text</i></b>", 24, "<b><i>deeply…</i></b>"},
+		{"too small", "hello world", 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateHTMLSafe(tt.s, tt.maxBytes)
+			if got != tt.want {
+				t.Errorf("TruncateHTMLSafe(%q, %d) = %q, want %q", tt.s, tt.maxBytes, got, tt.want)
+			}
+			if len(got) > tt.maxBytes || !utf8.ValidString(got) {
+				t.Errorf("TruncateHTMLSafe(%q, %d) = %q: %d bytes, valid UTF-8 %v", tt.s, tt.maxBytes, got, len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxBytes int
+		want     string
+	}{
+		{"fits", "short", 5, "short"},
+		{"angle brackets are text", "1 < 2 and more", 9, "1 < 2…"},
+		{"multibyte at boundary", "日本語のテキスト", 10, "日本…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Truncate(tt.s, tt.maxBytes); got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.maxBytes, got, tt.want)
+			}
+		})
+	}
+}